@@ -18,6 +18,8 @@ package controller
 
 import (
 	"context"
+	"fmt"
+	"strings"
 	"sync"
 	"time"
 
@@ -48,6 +50,15 @@ var (
 			Help:      "Number of Source errors.",
 		},
 	)
+	sourceFailuresTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "external_dns",
+			Subsystem: "source",
+			Name:      "failures_total",
+			Help:      "Number of failed queries per source, including those covered by the last known endpoints of the source.",
+		},
+		[]string{"source"},
+	)
 	sourceEndpointsTotal = prometheus.NewGauge(
 		prometheus.GaugeOpts{
 			Namespace: "external_dns",
@@ -72,6 +83,23 @@ var (
 			Help:      "Timestamp of last successful sync with the DNS provider",
 		},
 	)
+	registryOrphanedRecords = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: "external_dns",
+			Subsystem: "registry",
+			Name:      "orphaned_records",
+			Help:      "Number of ownership records without a matching managed record found by the last garbage collection, per zone",
+		},
+		[]string{"zone"},
+	)
+	controllerDriftedRecords = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: "external_dns",
+			Subsystem: "controller",
+			Name:      "drifted_records",
+			Help:      "Number of owned records changed outside of ExternalDNS found by the last synchronization",
+		},
+	)
 	deprecatedRegistryErrors = prometheus.NewCounter(
 		prometheus.CounterOpts{
 			Subsystem: "registry",
@@ -91,13 +119,25 @@ var (
 func init() {
 	prometheus.MustRegister(registryErrorsTotal)
 	prometheus.MustRegister(sourceErrorsTotal)
+	prometheus.MustRegister(sourceFailuresTotal)
 	prometheus.MustRegister(sourceEndpointsTotal)
 	prometheus.MustRegister(registryEndpointsTotal)
 	prometheus.MustRegister(lastSyncTimestamp)
+	prometheus.MustRegister(registryOrphanedRecords)
+	prometheus.MustRegister(controllerDriftedRecords)
 	prometheus.MustRegister(deprecatedRegistryErrors)
 	prometheus.MustRegister(deprecatedSourceErrors)
 }
 
+const (
+	// DriftPolicyRevert restores records changed outside of ExternalDNS to their desired state
+	DriftPolicyRevert = "revert"
+	// DriftPolicyAdopt keeps the changes made outside of ExternalDNS until the source changes
+	DriftPolicyAdopt = "adopt"
+	// DriftPolicyAlert only reports records changed outside of ExternalDNS and leaves them as they are
+	DriftPolicyAlert = "alert"
+)
+
 // Controller is responsible for orchestrating the different components.
 // It works in the following way:
 // * Ask the DNS provider for current list of endpoints.
@@ -117,6 +157,20 @@ type Controller struct {
 	nextRunAt time.Time
 	// The nextRunAtMux is for atomic updating of nextRunAt
 	nextRunAtMux sync.Mutex
+	// The interval between garbage collections of orphaned ownership records, disabled when zero
+	GCInterval time.Duration
+	// The nextGCAt is the time of the next garbage collection
+	nextGCAt time.Time
+	// The DriftPolicy defines how records changed outside of ExternalDNS are handled, drift detection is disabled when empty
+	DriftPolicy string
+	// The DriftEvents are emitted on the Kubernetes objects of drifted records, no events are emitted when nil
+	DriftEvents *DriftEventRecorder
+	// The reportedDrift are the drifted records events were emitted for, so that they are emitted only once
+	reportedDrift map[string]bool
+	// The Capabilities of the provider the plan is adapted to, the plan is not restricted when nil
+	Capabilities *provider.Capabilities
+	// The Zones of the provider used to attribute records to zones in metrics, the domain filter is used when nil
+	Zones provider.ZoneLister
 }
 
 // RunOnce runs a single iteration of a reconciliation loop.
@@ -132,6 +186,7 @@ func (c *Controller) RunOnce(ctx context.Context) error {
 	ctx = context.WithValue(ctx, provider.RecordsContextKey, records)
 
 	endpoints, err := c.Source.Endpoints(ctx)
+	failed := c.failedSources()
 	if err != nil {
 		sourceErrorsTotal.Inc()
 		deprecatedSourceErrors.Inc()
@@ -139,27 +194,387 @@ func (c *Controller) RunOnce(ctx context.Context) error {
 	}
 	sourceEndpointsTotal.Set(float64(len(endpoints)))
 
+	var recordTypes []string
+	if c.Capabilities != nil {
+		endpoints = c.adaptEndpoints(endpoints)
+		recordTypes = c.Capabilities.RecordTypes
+	}
+
+	policies := []plan.Policy{c.Policy}
+	if len(failed) > 0 {
+		// the endpoints of a failed source are missing, which must not be mistaken for deletions
+		policies = append(policies, newFailedSourcesPolicy(failed))
+	}
+
 	plan := &plan.Plan{
-		Policies:           []plan.Policy{c.Policy},
+		Policies:           policies,
 		Current:            records,
 		Desired:            endpoints,
 		DomainFilter:       c.DomainFilter,
 		PropertyComparator: c.Registry.PropertyValuesEqual,
+		RecordTypes:        recordTypes,
 	}
 
 	plan = plan.Calculate()
 
-	err = c.Registry.ApplyChanges(ctx, plan.Changes)
+	if c.DriftPolicy != "" {
+		c.applyDriftPolicy(plan.Changes, c.detectDrift(ctx, records))
+	}
+
+	// the records changing their type are deleted first, so that they and their ownership records
+	// can be created again with the new type
+	deletions := c.splitTypeTransitions(plan.Changes)
+
+	for _, batch := range c.batches(deletions, plan.Changes) {
+		err = c.Registry.ApplyChanges(ctx, batch)
+		if err != nil {
+			registryErrorsTotal.Inc()
+			deprecatedRegistryErrors.Inc()
+			return err
+		}
+	}
+
+	lastSyncTimestamp.SetToCurrentTime()
+	return nil
+}
+
+// adaptEndpoints drops the desired endpoints the provider doesn't support and adjusts their TTLs
+// to the ones the provider supports, so that they don't appear to differ from the current records
+func (c *Controller) adaptEndpoints(endpoints []*endpoint.Endpoint) []*endpoint.Endpoint {
+	adapted := make([]*endpoint.Endpoint, 0, len(endpoints))
+	for _, ep := range endpoints {
+		if !c.Capabilities.SupportsRecordType(ep.RecordType) {
+			log.Warnf("Dropping endpoint %s of type %s which is not supported by the provider", ep.DNSName, ep.RecordType)
+			continue
+		}
+		if ep.SetIdentifier != "" && !c.Capabilities.SetIdentifier {
+			log.Warnf("Dropping endpoint %s %s with set identifier %s, set identifiers are not supported by the provider", ep.DNSName, ep.RecordType, ep.SetIdentifier)
+			continue
+		}
+		if ttl := c.adaptTTL(ep.RecordTTL); ttl != ep.RecordTTL {
+			log.Debugf("Adjusting TTL of endpoint %s %s from %d to %d supported by the provider", ep.DNSName, ep.RecordType, ep.RecordTTL, ttl)
+			ep.RecordTTL = ttl
+		}
+		adapted = append(adapted, ep)
+	}
+	return adapted
+}
+
+// adaptTTL rounds a configured TTL up to the granularity of the provider and limits it to its bounds
+func (c *Controller) adaptTTL(ttl endpoint.TTL) endpoint.TTL {
+	if !ttl.IsConfigured() {
+		return ttl
+	}
+	if granularity := c.Capabilities.TTLGranularity; granularity > 0 && ttl%granularity != 0 {
+		ttl += granularity - ttl%granularity
+	}
+	if c.Capabilities.MinTTL > 0 && ttl < c.Capabilities.MinTTL {
+		ttl = c.Capabilities.MinTTL
+	}
+	if c.Capabilities.MaxTTL > 0 && ttl > c.Capabilities.MaxTTL {
+		ttl = c.Capabilities.MaxTTL
+	}
+	return ttl
+}
+
+// splitTypeTransitions returns the deletions of the records changing their type, which have to be applied
+// before the changes, unless the provider can change the type of a record in place. Records shared with
+// other owners keep their type.
+func (c *Controller) splitTypeTransitions(changes *plan.Changes) *plan.Changes {
+	c.skipSharedTypeTransitions(changes)
+	if c.Capabilities != nil && c.Capabilities.AtomicTypeChange {
+		return nil
+	}
+	deletions := changes.SplitTypeTransitions()
+	if deletions != nil {
+		for _, ep := range deletions.Delete {
+			log.Infof("Recreating record %s to change its type from %s", ep.DNSName, ep.RecordType)
+		}
+	}
+	return deletions
+}
+
+// skipSharedTypeTransitions drops the updates changing the type of a record which other owners still
+// contribute targets to, as their targets can't be kept next to a record of another type
+func (c *Controller) skipSharedTypeTransitions(changes *plan.Changes) {
+	lister, ok := c.Registry.(registry.SharedOwnerLister)
+	if !ok {
+		return
+	}
+	updateOld := make([]*endpoint.Endpoint, 0, len(changes.UpdateOld))
+	updateNew := make([]*endpoint.Endpoint, 0, len(changes.UpdateNew))
+	for i, current := range changes.UpdateOld {
+		if i >= len(changes.UpdateNew) {
+			break
+		}
+		desired := changes.UpdateNew[i]
+		if desired.RecordType != current.RecordType {
+			if owners := lister.OtherOwners(current); len(owners) > 0 {
+				log.Warnf("Not changing the type of record %s from %s to %s, it is shared with %s", current.DNSName, current.RecordType, desired.RecordType, strings.Join(owners, ", "))
+				continue
+			}
+		}
+		updateOld = append(updateOld, current)
+		updateNew = append(updateNew, desired)
+	}
+	changes.UpdateOld = updateOld
+	changes.UpdateNew = updateNew
+}
+
+// batches splits each of the non-nil changes into batches of at most the maximum batch size of the
+// provider, which are applied in order. Within the changes, deletions are applied first, followed by
+// updates and creations.
+func (c *Controller) batches(changes ...*plan.Changes) []*plan.Changes {
+	size := 0
+	if c.Capabilities != nil {
+		size = c.Capabilities.MaxBatchSize
+	}
+	// the batch size of the provider includes the records the registry adds to each change
+	if expander, ok := c.Registry.(registry.ChangeExpander); ok && size > 0 && expander.RecordsPerChange() > 1 {
+		size /= expander.RecordsPerChange()
+		if size == 0 {
+			size = 1
+		}
+	}
+
+	var batches []*plan.Changes
+	for _, ch := range changes {
+		if ch == nil {
+			continue
+		}
+		total := len(ch.Delete) + len(ch.UpdateNew) + len(ch.Create)
+		if size <= 0 || total <= size {
+			batches = append(batches, ch)
+			continue
+		}
+
+		batch := &plan.Changes{}
+		count := 0
+		next := func() {
+			count++
+			if count == size {
+				batches = append(batches, batch)
+				batch = &plan.Changes{}
+				count = 0
+			}
+		}
+		for _, ep := range ch.Delete {
+			batch.Delete = append(batch.Delete, ep)
+			next()
+		}
+		for i := range ch.UpdateNew {
+			batch.UpdateOld = append(batch.UpdateOld, ch.UpdateOld[i])
+			batch.UpdateNew = append(batch.UpdateNew, ch.UpdateNew[i])
+			next()
+		}
+		for _, ep := range ch.Create {
+			batch.Create = append(batch.Create, ep)
+			next()
+		}
+		if count > 0 {
+			batches = append(batches, batch)
+		}
+		log.Debugf("Applying %d changes in batches of at most %d changes", total, size)
+	}
+	return batches
+}
+
+// failedSources counts the failures of the nested sources of the last query and returns
+// the names of the sources whose endpoints are missing
+func (c *Controller) failedSources() []string {
+	reporter, ok := c.Source.(source.FailureReporter)
+	if !ok {
+		return nil
+	}
+
+	var failed []string
+	for _, failure := range reporter.Failures() {
+		sourceFailuresTotal.WithLabelValues(failure.Source).Inc()
+		if !failure.Stale {
+			failed = append(failed, failure.Source)
+		}
+	}
+	return failed
+}
+
+// failedSourcesPolicy strips the deletions of the records which may belong to failed sources.
+// Records are attributed to sources by the kind of their resource label, records without one
+// and all records of sources which don't label their endpoints are kept.
+type failedSourcesPolicy struct {
+	kinds map[string]bool
+	all   bool
+}
+
+func newFailedSourcesPolicy(failed []string) *failedSourcesPolicy {
+	p := &failedSourcesPolicy{kinds: map[string]bool{}}
+	for _, name := range failed {
+		kind, ok := source.ResourceLabelKind(name)
+		if !ok {
+			p.all = true
+			continue
+		}
+		p.kinds[kind] = true
+	}
+	if p.all {
+		log.Warnf("Skipping deletions because of failed sources %s", strings.Join(failed, ", "))
+	} else {
+		log.Warnf("Skipping deletions of the records of failed sources %s", strings.Join(failed, ", "))
+	}
+	return p
+}
+
+// Apply strips the deletions of the records which may belong to the failed sources.
+func (p *failedSourcesPolicy) Apply(changes *plan.Changes) *plan.Changes {
+	if p.all {
+		return (&plan.UpsertOnlyPolicy{}).Apply(changes)
+	}
+
+	deletions := make([]*endpoint.Endpoint, 0, len(changes.Delete))
+	for _, ep := range changes.Delete {
+		resource, ok := ep.Labels[endpoint.ResourceLabelKey]
+		if !ok || p.kinds[strings.SplitN(resource, "/", 2)[0]] {
+			log.Debugf("Skipping deletion of %s %s which may belong to a failed source", ep.DNSName, ep.RecordType)
+			continue
+		}
+		deletions = append(deletions, ep)
+	}
+	return &plan.Changes{
+		Create:    changes.Create,
+		UpdateOld: changes.UpdateOld,
+		UpdateNew: changes.UpdateNew,
+		Delete:    deletions,
+	}
+}
+
+// detectDrift reports the owned records which were changed outside of ExternalDNS
+func (c *Controller) detectDrift(ctx context.Context, records []*endpoint.Endpoint) map[*endpoint.Endpoint]bool {
+	detector, ok := c.Registry.(registry.DriftDetector)
+	if !ok {
+		log.Debugf("Registry %T does not support drift detection", c.Registry)
+		return nil
+	}
+
+	drifted := map[*endpoint.Endpoint]bool{}
+	records = detector.Drifted(records)
+	for _, ep := range records {
+		log.Warnf("Record %s %s was changed outside of ExternalDNS to %s, drift policy: %s", ep.DNSName, ep.RecordType, ep.Targets, c.DriftPolicy)
+		drifted[ep] = true
+	}
+	controllerDriftedRecords.Set(float64(len(drifted)))
+	c.emitDriftEvents(ctx, records)
+	return drifted
+}
+
+// applyDriftPolicy rewrites the planned updates of drifted records according to the drift policy.
+// Updates caused by a change of the source are always applied, as are updates of records which did not drift.
+func (c *Controller) applyDriftPolicy(changes *plan.Changes, drifted map[*endpoint.Endpoint]bool) {
+	if c.DriftPolicy == DriftPolicyRevert {
+		return
+	}
+
+	updateOld := make([]*endpoint.Endpoint, 0, len(changes.UpdateOld))
+	updateNew := make([]*endpoint.Endpoint, 0, len(changes.UpdateNew))
+	for i, current := range changes.UpdateOld {
+		if i >= len(changes.UpdateNew) {
+			break
+		}
+		desired := changes.UpdateNew[i]
+		adopted := current.Labels[endpoint.AdoptedHashLabelKey] != "" && current.Labels[endpoint.AdoptedHashLabelKey] == current.StateHash()
+		if (!drifted[current] && !adopted) || desired.StateHash() != current.Labels[endpoint.HashLabelKey] {
+			updateOld = append(updateOld, current)
+			updateNew = append(updateNew, desired)
+			continue
+		}
+
+		// keep the state set outside of ExternalDNS but remember what the source asked for,
+		// so that a later change of the source is still detected
+		desired.RecordType = current.RecordType
+		desired.Targets = current.Targets
+		if desired.Labels == nil {
+			desired.Labels = endpoint.NewLabels()
+		}
+		desired.Labels[endpoint.HashLabelKey] = current.Labels[endpoint.HashLabelKey]
+		if adoptedHash, ok := current.Labels[endpoint.AdoptedHashLabelKey]; ok {
+			desired.Labels[endpoint.AdoptedHashLabelKey] = adoptedHash
+		}
+		adopt := c.DriftPolicy == DriftPolicyAdopt && !adopted
+		if adopt {
+			desired.Labels[endpoint.AdoptedHashLabelKey] = current.StateHash()
+		}
+		if adopt || (desired.RecordTTL.IsConfigured() && desired.RecordTTL != current.RecordTTL) {
+			updateOld = append(updateOld, current)
+			updateNew = append(updateNew, desired)
+		}
+	}
+	changes.UpdateOld = updateOld
+	changes.UpdateNew = updateNew
+}
+
+// RunGarbageCollection removes the ownership records which no longer have a matching managed record
+// and reports the number of such records found per zone.
+func (c *Controller) RunGarbageCollection(ctx context.Context) error {
+	gc, ok := c.Registry.(registry.GarbageCollector)
+	if !ok {
+		return fmt.Errorf("registry %T does not support garbage collection", c.Registry)
+	}
+
+	orphans, err := gc.GarbageCollect(ctx)
 	if err != nil {
 		registryErrorsTotal.Inc()
 		deprecatedRegistryErrors.Inc()
 		return err
 	}
 
-	lastSyncTimestamp.SetToCurrentTime()
+	var zones provider.ZoneIDName
+	if c.Zones != nil {
+		if zones, err = c.Zones.ZoneIDNames(ctx); err != nil {
+			log.Warnf("Failed to list the zones of the provider, attributing orphaned records to zones by domain filter: %v", err)
+		}
+	}
+
+	registryOrphanedRecords.Reset()
+	for _, ep := range orphans {
+		registryOrphanedRecords.WithLabelValues(c.zoneOf(ep.DNSName, zones)).Inc()
+	}
+	log.Infof("Garbage collection removed %d orphaned ownership records", len(orphans))
 	return nil
 }
 
+// zoneOf returns the zone of the provider the given name belongs to, or the longest domain filter
+// matching it if the zone isn't known, falling back to the parent domain of the name if none matches.
+func (c *Controller) zoneOf(name string, zones provider.ZoneIDName) string {
+	name = strings.TrimSuffix(name, ".")
+	if _, zone := zones.FindZone(name); zone != "" {
+		return zone
+	}
+	zone := ""
+	for _, filter := range c.DomainFilter.Filters {
+		filter = strings.TrimPrefix(filter, ".")
+		if filter == "" || len(filter) <= len(zone) {
+			continue
+		}
+		if name == filter || strings.HasSuffix(name, "."+filter) {
+			zone = filter
+		}
+	}
+	if zone != "" {
+		return zone
+	}
+	if parts := strings.SplitN(name, ".", 2); len(parts) == 2 {
+		return parts[1]
+	}
+	return name
+}
+
+// ShouldRunGarbageCollection makes sure garbage collection happens at most once per GCInterval.
+func (c *Controller) ShouldRunGarbageCollection(now time.Time) bool {
+	if c.GCInterval <= 0 || now.Before(c.nextGCAt) {
+		return false
+	}
+	c.nextGCAt = now.Add(c.GCInterval)
+	return true
+}
+
 // MinInterval is used as window for batching events
 const MinInterval = 5 * time.Second
 
@@ -190,6 +605,11 @@ func (c *Controller) Run(ctx context.Context) {
 				log.Error(err)
 			}
 		}
+		if c.ShouldRunGarbageCollection(time.Now()) {
+			if err := c.RunGarbageCollection(ctx); err != nil {
+				log.Error(err)
+			}
+		}
 		select {
 		case <-ticker.C:
 		case <-ctx.Done():
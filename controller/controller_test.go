@@ -20,15 +20,27 @@ import (
 	"context"
 	"errors"
 	"reflect"
+	"sync"
 	"testing"
 	"time"
 
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	fakeDynamic "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+
 	"sigs.k8s.io/external-dns/endpoint"
 	"sigs.k8s.io/external-dns/internal/testutils"
 	"sigs.k8s.io/external-dns/plan"
 	"sigs.k8s.io/external-dns/provider"
+	"sigs.k8s.io/external-dns/provider/inmemory"
 	"sigs.k8s.io/external-dns/registry"
+	"sigs.k8s.io/external-dns/source"
 
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -69,6 +81,10 @@ func (p *mockProvider) ApplyChanges(ctx context.Context, changes *plan.Changes)
 		}
 	}
 
+	if len(changes.Delete) != len(p.ExpectChanges.Delete) {
+		return errors.New("number of deleted records is wrong")
+	}
+
 	for i := range changes.Delete {
 		if changes.Delete[i].DNSName != p.ExpectChanges.Delete[i].DNSName || !changes.Delete[i].Targets.Same(p.ExpectChanges.Delete[i].Targets) {
 			return errors.New("delete record is wrong")
@@ -154,6 +170,88 @@ func TestRunOnce(t *testing.T) {
 	source.AssertExpectations(t)
 }
 
+// TestRunOnceWithFailedSource tests that records are not deleted while the endpoints of a source are missing.
+func TestRunOnceWithFailedSource(t *testing.T) {
+	healthy := new(testutils.MockSource)
+	healthy.On("Endpoints").Return([]*endpoint.Endpoint{
+		{DNSName: "create-record", RecordType: endpoint.RecordTypeA, Targets: endpoint.Targets{"1.2.3.4"}},
+	}, nil)
+	failing := new(testutils.MockSource)
+	failing.On("Endpoints").Return(nil, errors.New("no such resource"))
+
+	provider := newMockProvider(
+		[]*endpoint.Endpoint{
+			{DNSName: "failed-source-record", RecordType: endpoint.RecordTypeA, Targets: endpoint.Targets{"4.3.2.1"}},
+		},
+		&plan.Changes{
+			Create: []*endpoint.Endpoint{
+				{DNSName: "create-record", RecordType: endpoint.RecordTypeA, Targets: endpoint.Targets{"1.2.3.4"}},
+			},
+		},
+	)
+	r, err := registry.NewNoopRegistry(provider)
+	require.NoError(t, err)
+
+	ctrl := &Controller{
+		Source:   source.NewDedupSource(source.NewTolerantMultiSource([]source.Source{healthy, failing}, []string{"service", "istio-gateway"}, 0)),
+		Registry: r,
+		Policy:   &plan.SyncPolicy{},
+	}
+
+	assert.NoError(t, ctrl.RunOnce(context.Background()))
+	healthy.AssertExpectations(t)
+	failing.AssertExpectations(t)
+}
+
+// TestRunOnceWithFailedSourceDeletions tests that only the records of failed sources are kept.
+func TestRunOnceWithFailedSourceDeletions(t *testing.T) {
+	healthy := new(testutils.MockSource)
+	healthy.On("Endpoints").Return([]*endpoint.Endpoint{}, nil)
+	failing := new(testutils.MockSource)
+	failing.On("Endpoints").Return(nil, errors.New("no such resource"))
+
+	provider := newMockProvider(
+		[]*endpoint.Endpoint{
+			{DNSName: "gateway-record", RecordType: endpoint.RecordTypeA, Targets: endpoint.Targets{"4.3.2.1"}, Labels: endpoint.Labels{endpoint.ResourceLabelKey: "gateway/default/gw"}},
+			{DNSName: "service-record", RecordType: endpoint.RecordTypeA, Targets: endpoint.Targets{"1.2.3.4"}, Labels: endpoint.Labels{endpoint.ResourceLabelKey: "service/default/svc"}},
+			{DNSName: "unlabelled-record", RecordType: endpoint.RecordTypeA, Targets: endpoint.Targets{"1.1.1.1"}},
+		},
+		&plan.Changes{
+			Delete: []*endpoint.Endpoint{
+				{DNSName: "service-record", RecordType: endpoint.RecordTypeA, Targets: endpoint.Targets{"1.2.3.4"}},
+			},
+		},
+	)
+	r, err := registry.NewNoopRegistry(provider)
+	require.NoError(t, err)
+
+	ctrl := &Controller{
+		Source:   source.NewDedupSource(source.NewTolerantMultiSource([]source.Source{healthy, failing}, []string{"service", "istio-gateway"}, 0)),
+		Registry: r,
+		Policy:   &plan.SyncPolicy{},
+	}
+	assert.NoError(t, ctrl.RunOnce(context.Background()))
+
+	// a failed source without a resource label of its own keeps all records
+	failing = new(testutils.MockSource)
+	failing.On("Endpoints").Return(nil, errors.New("no such resource"))
+	provider.(*mockProvider).ExpectChanges = &plan.Changes{}
+	ctrl.Source = source.NewDedupSource(source.NewTolerantMultiSource([]source.Source{healthy, failing}, []string{"service", "node"}, 0))
+	assert.NoError(t, ctrl.RunOnce(context.Background()))
+}
+
+// TestFailedSourcesPolicyIngressRoutes tests that the records of Contour and Traefik ingress routes are told apart.
+func TestFailedSourcesPolicyIngressRoutes(t *testing.T) {
+	contour := &endpoint.Endpoint{DNSName: "contour-record", RecordType: endpoint.RecordTypeA, Targets: endpoint.Targets{"1.2.3.4"}, Labels: endpoint.Labels{endpoint.ResourceLabelKey: "ingressroute/default/route"}}
+	traefik := &endpoint.Endpoint{DNSName: "traefik-record", RecordType: endpoint.RecordTypeA, Targets: endpoint.Targets{"4.3.2.1"}, Labels: endpoint.Labels{endpoint.ResourceLabelKey: "traefik-ingressroute/default/route"}}
+
+	changes := newFailedSourcesPolicy([]string{"traefik-ingressroute"}).Apply(&plan.Changes{Delete: []*endpoint.Endpoint{contour, traefik}})
+	assert.Equal(t, []*endpoint.Endpoint{contour}, changes.Delete)
+
+	changes = newFailedSourcesPolicy([]string{"contour-ingressroute"}).Apply(&plan.Changes{Delete: []*endpoint.Endpoint{contour, traefik}})
+	assert.Equal(t, []*endpoint.Endpoint{traefik}, changes.Delete)
+}
+
 func TestShouldRunOnce(t *testing.T) {
 	ctrl := &Controller{Interval: 10 * time.Minute}
 
@@ -192,3 +290,403 @@ func TestShouldRunOnce(t *testing.T) {
 	// But not two times
 	assert.False(t, ctrl.ShouldRunOnce(now))
 }
+
+// gcRegistry is a registry reporting a fixed set of orphaned ownership records.
+type gcRegistry struct {
+	registry.Registry
+	orphans []*endpoint.Endpoint
+	err     error
+}
+
+func (r *gcRegistry) GarbageCollect(ctx context.Context) ([]*endpoint.Endpoint, error) {
+	return r.orphans, r.err
+}
+
+func TestRunGarbageCollection(t *testing.T) {
+	noop, err := registry.NewNoopRegistry(newMockProvider(nil, &plan.Changes{}))
+	require.NoError(t, err)
+
+	ctrl := &Controller{Registry: noop}
+	assert.Error(t, ctrl.RunGarbageCollection(context.Background()))
+
+	ctrl = &Controller{
+		Registry: &gcRegistry{
+			orphans: []*endpoint.Endpoint{
+				endpoint.NewEndpoint("a.example.org", endpoint.RecordTypeTXT, "\"heritage=external-dns,external-dns/owner=default\""),
+				endpoint.NewEndpoint("b.sub.example.org", endpoint.RecordTypeTXT, "\"heritage=external-dns,external-dns/owner=default\""),
+				endpoint.NewEndpoint("c.other.com", endpoint.RecordTypeTXT, "\"heritage=external-dns,external-dns/owner=default\""),
+			},
+		},
+		DomainFilter: endpoint.NewDomainFilter([]string{"example.org", "sub.example.org"}),
+	}
+	require.NoError(t, ctrl.RunGarbageCollection(context.Background()))
+	assert.Equal(t, "example.org", ctrl.zoneOf("a.example.org", nil))
+	assert.Equal(t, "sub.example.org", ctrl.zoneOf("b.sub.example.org", nil))
+	assert.Equal(t, "other.com", ctrl.zoneOf("c.other.com", nil))
+
+	// the zones of the provider take precedence, also for names several levels below them
+	zones := provider.ZoneIDName{"Z1": "example.org", "Z2": "other.com"}
+	assert.Equal(t, "example.org", ctrl.zoneOf("b.sub.example.org", zones))
+	assert.Equal(t, "other.com", ctrl.zoneOf("d.c.other.com", zones))
+	assert.Equal(t, "sub.example.org", ctrl.zoneOf("b.sub.example.org", provider.ZoneIDName{"Z3": "example.com"}))
+
+	ctrl.Zones = inmemory.NewInMemoryProvider(inmemory.InMemoryInitZones([]string{"example.org"}))
+	require.NoError(t, ctrl.RunGarbageCollection(context.Background()))
+	assert.Equal(t, 2.0, testutil.ToFloat64(registryOrphanedRecords.WithLabelValues("example.org")))
+
+	ctrl.Registry = &gcRegistry{err: errors.New("provider unavailable")}
+	assert.Error(t, ctrl.RunGarbageCollection(context.Background()))
+}
+
+func TestShouldRunGarbageCollection(t *testing.T) {
+	ctrl := &Controller{}
+	now := time.Now()
+
+	// Disabled without an interval
+	assert.False(t, ctrl.ShouldRunGarbageCollection(now))
+
+	ctrl.GCInterval = time.Hour
+	assert.True(t, ctrl.ShouldRunGarbageCollection(now))
+	assert.False(t, ctrl.ShouldRunGarbageCollection(now.Add(time.Minute)))
+	assert.True(t, ctrl.ShouldRunGarbageCollection(now.Add(time.Hour)))
+}
+
+func TestApplyDriftPolicy(t *testing.T) {
+	written := endpoint.NewEndpoint("foo.example.org", endpoint.RecordTypeA, "1.2.3.4")
+	hash := written.StateHash()
+
+	newCurrent := func(labels map[string]string, targets ...string) *endpoint.Endpoint {
+		ep := endpoint.NewEndpoint("foo.example.org", endpoint.RecordTypeA, targets...)
+		ep.Labels = endpoint.NewLabels()
+		ep.Labels[endpoint.OwnerLabelKey] = "owner"
+		for k, v := range labels {
+			ep.Labels[k] = v
+		}
+		return ep
+	}
+	drifted := newCurrent(map[string]string{endpoint.HashLabelKey: hash}, "5.6.7.8")
+	adopted := newCurrent(map[string]string{
+		endpoint.HashLabelKey:        hash,
+		endpoint.AdoptedHashLabelKey: endpoint.NewEndpoint("", endpoint.RecordTypeA, "5.6.7.8").StateHash(),
+	}, "5.6.7.8")
+
+	for _, tc := range []struct {
+		title           string
+		policy          string
+		current         *endpoint.Endpoint
+		isDrifted       bool
+		desiredTargets  []string
+		expectedTargets []string
+		expectedAdopted bool
+	}{
+		{title: "revert restores the desired state", policy: DriftPolicyRevert, current: drifted, isDrifted: true, desiredTargets: []string{"1.2.3.4"}, expectedTargets: []string{"1.2.3.4"}},
+		{title: "alert keeps the drifted record", policy: DriftPolicyAlert, current: drifted, isDrifted: true, desiredTargets: []string{"1.2.3.4"}},
+		{title: "adopt records the drifted state", policy: DriftPolicyAdopt, current: drifted, isDrifted: true, desiredTargets: []string{"1.2.3.4"}, expectedTargets: []string{"5.6.7.8"}, expectedAdopted: true},
+		{title: "adopted record is left alone", policy: DriftPolicyAdopt, current: adopted, desiredTargets: []string{"1.2.3.4"}},
+		{title: "change of the source wins over adoption", policy: DriftPolicyAdopt, current: adopted, desiredTargets: []string{"9.9.9.9"}, expectedTargets: []string{"9.9.9.9"}},
+		{title: "change of the source wins over alerting", policy: DriftPolicyAlert, current: drifted, isDrifted: true, desiredTargets: []string{"9.9.9.9"}, expectedTargets: []string{"9.9.9.9"}},
+	} {
+		t.Run(tc.title, func(t *testing.T) {
+			current := tc.current.DeepCopy()
+			desired := endpoint.NewEndpoint("foo.example.org", endpoint.RecordTypeA, tc.desiredTargets...)
+			desired.Labels = endpoint.NewLabels()
+			changes := &plan.Changes{
+				UpdateOld: []*endpoint.Endpoint{current},
+				UpdateNew: []*endpoint.Endpoint{desired},
+			}
+
+			ctrl := &Controller{DriftPolicy: tc.policy}
+			ctrl.applyDriftPolicy(changes, map[*endpoint.Endpoint]bool{current: tc.isDrifted})
+
+			if tc.expectedTargets == nil {
+				assert.Empty(t, changes.UpdateOld)
+				assert.Empty(t, changes.UpdateNew)
+				return
+			}
+			require.Len(t, changes.UpdateNew, 1)
+			assert.Equal(t, endpoint.Targets(tc.expectedTargets), changes.UpdateNew[0].Targets)
+			_, isAdopted := changes.UpdateNew[0].Labels[endpoint.AdoptedHashLabelKey]
+			assert.Equal(t, tc.expectedAdopted, isAdopted)
+			if tc.expectedAdopted {
+				assert.Equal(t, hash, changes.UpdateNew[0].Labels[endpoint.HashLabelKey])
+			}
+		})
+	}
+}
+
+func TestEmitDriftEvents(t *testing.T) {
+	kubeClient := fake.NewSimpleClientset()
+	kubeClient.Resources = []*metav1.APIResourceList{
+		{GroupVersion: "v1", APIResources: []metav1.APIResource{{Name: "services", Namespaced: true, Kind: "Service"}}},
+		{GroupVersion: "example.com/v1", APIResources: []metav1.APIResource{{Name: "foos", Namespaced: true, Kind: "Foo"}}},
+	}
+	newObject := func(apiVersion, kind, name, uid string) *unstructured.Unstructured {
+		return &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": apiVersion,
+			"kind":       kind,
+			"metadata":   map[string]interface{}{"namespace": "default", "name": name, "uid": uid},
+		}}
+	}
+	dynamicClient := fakeDynamic.NewSimpleDynamicClient(runtime.NewScheme(),
+		newObject("v1", "Service", "foo", "1234"),
+		newObject("example.com/v1", "Foo", "qux", "5678"),
+	)
+	ctrl := &Controller{DriftPolicy: DriftPolicyAlert, DriftEvents: NewDriftEventRecorder(kubeClient, dynamicClient)}
+
+	newDrifted := func(name, resource string) *endpoint.Endpoint {
+		ep := endpoint.NewEndpoint(name, endpoint.RecordTypeA, "5.6.7.8")
+		ep.Labels = endpoint.NewLabels()
+		if resource != "" {
+			ep.Labels[endpoint.ResourceLabelKey] = resource
+		}
+		return ep
+	}
+	drifted := []*endpoint.Endpoint{
+		newDrifted("foo.example.org", "service/default/foo"),
+		newDrifted("qux.example.org", "foos.example.com/default/qux"),
+		newDrifted("gone.example.org", "service/default/gone"),
+		newDrifted("bar.example.org", "file//etc/external-dns/bar.yaml"),
+		newDrifted("baz.example.org", ""),
+	}
+	// the fake client can't create events in their own namespace, as the event sink does
+	var mu sync.Mutex
+	var created []*corev1.Event
+	emitted := 0
+	kubeClient.PrependReactor("*", "events", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		emitted++
+		if create, ok := action.(k8stesting.CreateAction); ok {
+			event := create.GetObject().(*corev1.Event)
+			created = append(created, event)
+			return true, event, nil
+		}
+		// repeated events are patched
+		return true, &corev1.Event{}, nil
+	})
+	expectEvents := func(count int) []*corev1.Event {
+		assert.Eventually(t, func() bool {
+			mu.Lock()
+			defer mu.Unlock()
+			return emitted == count
+		}, time.Second, 10*time.Millisecond)
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]*corev1.Event{}, created...)
+	}
+
+	ctrl.emitDriftEvents(context.Background(), drifted)
+	got := expectEvents(2)
+	require.Len(t, got, 2, "should only emit events on existing Kubernetes objects")
+	involved := map[string]corev1.ObjectReference{}
+	for _, event := range got {
+		involved[event.InvolvedObject.Name] = event.InvolvedObject
+		assert.Equal(t, driftEventReason, event.Reason)
+		assert.Equal(t, corev1.EventTypeWarning, event.Type)
+		assert.Equal(t, eventComponent, event.Source.Component)
+		assert.Contains(t, event.Message, event.InvolvedObject.Name+".example.org")
+	}
+	assert.Equal(t, corev1.ObjectReference{APIVersion: "v1", Kind: "Service", Namespace: "default", Name: "foo", UID: "1234"}, involved["foo"])
+	assert.Equal(t, corev1.ObjectReference{APIVersion: "example.com/v1", Kind: "Foo", Namespace: "default", Name: "qux", UID: "5678"}, involved["qux"])
+
+	ctrl.emitDriftEvents(context.Background(), drifted)
+	time.Sleep(50 * time.Millisecond)
+	assert.Len(t, expectEvents(2), 2, "should not emit events again for records which are still drifted")
+
+	// records which drifted again are reported again, the repeated events are counted by a patch
+	ctrl.emitDriftEvents(context.Background(), nil)
+	ctrl.emitDriftEvents(context.Background(), drifted)
+	expectEvents(4)
+}
+
+// capableProvider declares its capabilities and records the changes applied to it.
+type capableProvider struct {
+	provider.BaseProvider
+	records      []*endpoint.Endpoint
+	capabilities provider.Capabilities
+	applied      []*plan.Changes
+}
+
+func (p *capableProvider) Records(ctx context.Context) ([]*endpoint.Endpoint, error) {
+	return p.records, nil
+}
+
+func (p *capableProvider) ApplyChanges(ctx context.Context, changes *plan.Changes) error {
+	p.applied = append(p.applied, changes)
+	return nil
+}
+
+func (p *capableProvider) Capabilities() provider.Capabilities {
+	return p.capabilities
+}
+
+// TestRunOnceWithCapabilities tests that the plan is adapted to the capabilities of the provider.
+func TestRunOnceWithCapabilities(t *testing.T) {
+	source := new(testutils.MockSource)
+	source.On("Endpoints").Return([]*endpoint.Endpoint{
+		endpoint.NewEndpoint("type.example.org", endpoint.RecordTypeCNAME, "lb.example.org"),
+		endpoint.NewEndpoint("mx.example.org", endpoint.RecordTypeMX, "10 mail.example.org"),
+		endpoint.NewEndpoint("set.example.org", endpoint.RecordTypeA, "1.2.3.4").WithSetIdentifier("eu"),
+		endpoint.NewEndpointWithTTL("ttl.example.org", endpoint.RecordTypeA, 45, "1.2.3.4"),
+		endpoint.NewEndpointWithTTL("max.example.org", endpoint.RecordTypeA, 90000, "1.2.3.4"),
+		endpoint.NewEndpoint("create.example.org", endpoint.RecordTypeA, "1.2.3.4"),
+	}, nil)
+
+	p := &capableProvider{
+		records: []*endpoint.Endpoint{
+			endpoint.NewEndpoint("type.example.org", endpoint.RecordTypeA, "1.2.3.4"),
+		},
+		capabilities: provider.Capabilities{
+			RecordTypes:    []string{endpoint.RecordTypeA, endpoint.RecordTypeCNAME, endpoint.RecordTypeTXT},
+			TTLGranularity: 60,
+			MinTTL:         120,
+			MaxTTL:         86400,
+			MaxBatchSize:   2,
+		},
+	}
+	r, err := registry.NewNoopRegistry(p)
+	require.NoError(t, err)
+
+	capabilities := p.Capabilities()
+	ctrl := &Controller{
+		Source:       source,
+		Registry:     r,
+		Policy:       &plan.SyncPolicy{},
+		Capabilities: &capabilities,
+	}
+	require.NoError(t, ctrl.RunOnce(context.Background()))
+
+	// the record changing its type is deleted before the other changes are applied in batches
+	require.Len(t, p.applied, 3)
+	validateChanges := func(changes *plan.Changes) {
+		assert.Empty(t, changes.UpdateOld)
+		assert.Empty(t, changes.UpdateNew)
+		assert.LessOrEqual(t, len(changes.Create)+len(changes.Delete), 2)
+	}
+	validateChanges(p.applied[0])
+	require.Len(t, p.applied[0].Delete, 1)
+	assert.Equal(t, endpoint.RecordTypeA, p.applied[0].Delete[0].RecordType)
+	assert.Empty(t, p.applied[0].Create)
+
+	created := map[string]*endpoint.Endpoint{}
+	for _, changes := range p.applied[1:] {
+		validateChanges(changes)
+		assert.Empty(t, changes.Delete)
+		for _, ep := range changes.Create {
+			created[ep.DNSName] = ep
+		}
+	}
+	require.Len(t, created, 4)
+	assert.Equal(t, endpoint.RecordTypeCNAME, created["type.example.org"].RecordType)
+	assert.Equal(t, endpoint.TTL(120), created["ttl.example.org"].RecordTTL)
+	assert.Equal(t, endpoint.TTL(86400), created["max.example.org"].RecordTTL)
+	assert.False(t, created["create.example.org"].RecordTTL.IsConfigured())
+}
+
+// TestRunOnceWithBatchesOfOwnedRecords tests that the batch size includes the ownership records of the registry.
+func TestRunOnceWithBatchesOfOwnedRecords(t *testing.T) {
+	source := new(testutils.MockSource)
+	source.On("Endpoints").Return([]*endpoint.Endpoint{
+		endpoint.NewEndpoint("a.example.org", endpoint.RecordTypeA, "1.2.3.4"),
+		endpoint.NewEndpoint("b.example.org", endpoint.RecordTypeA, "1.2.3.4"),
+		endpoint.NewEndpoint("c.example.org", endpoint.RecordTypeA, "1.2.3.4"),
+	}, nil)
+
+	p := &capableProvider{capabilities: provider.Capabilities{MaxBatchSize: 4}}
+	r, err := registry.NewTXTRegistry(p, "", "", "owner", 0)
+	require.NoError(t, err)
+
+	capabilities := p.Capabilities()
+	ctrl := &Controller{
+		Source:       source,
+		Registry:     r,
+		Policy:       &plan.SyncPolicy{},
+		Capabilities: &capabilities,
+	}
+	require.NoError(t, ctrl.RunOnce(context.Background()))
+
+	require.Len(t, p.applied, 2)
+	created := 0
+	for _, changes := range p.applied {
+		assert.LessOrEqual(t, len(changes.Create), 4)
+		created += len(changes.Create)
+	}
+	assert.Equal(t, 6, created)
+}
+
+// TestRunOnceWithTypeTransition tests that a record changing its type is recreated together with its ownership record.
+func TestRunOnceWithTypeTransition(t *testing.T) {
+	p := inmemory.NewInMemoryProvider(inmemory.InMemoryInitZones([]string{"example.org"}))
+	r, err := registry.NewTXTRegistry(p, "", "", "owner", 0)
+	require.NoError(t, err)
+
+	source := new(testutils.MockSource)
+	source.On("Endpoints").Return([]*endpoint.Endpoint{
+		endpoint.NewEndpoint("foo.example.org", endpoint.RecordTypeCNAME, "lb.example.org"),
+	}, nil).Once()
+	source.On("Endpoints").Return([]*endpoint.Endpoint{
+		endpoint.NewEndpoint("foo.example.org", endpoint.RecordTypeA, "1.2.3.4"),
+	}, nil).Once()
+
+	capabilities := p.Capabilities()
+	ctrl := &Controller{
+		Source:       source,
+		Registry:     r,
+		Policy:       &plan.SyncPolicy{},
+		Capabilities: &capabilities,
+	}
+	require.NoError(t, ctrl.RunOnce(context.Background()))
+	// the inmemory provider can't update the type of a record in place
+	require.NoError(t, ctrl.RunOnce(context.Background()))
+
+	records, err := r.Records(context.Background())
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+	assert.Equal(t, endpoint.RecordTypeA, records[0].RecordType)
+	assert.Equal(t, endpoint.Targets{"1.2.3.4"}, records[0].Targets)
+	assert.Equal(t, "owner", records[0].Labels[endpoint.OwnerLabelKey])
+
+	// the ownership record was created again
+	records, err = p.Records(context.Background())
+	require.NoError(t, err)
+	var recordTypes []string
+	for _, ep := range records {
+		recordTypes = append(recordTypes, ep.RecordType)
+	}
+	assert.ElementsMatch(t, []string{endpoint.RecordTypeA, endpoint.RecordTypeTXT}, recordTypes)
+	source.AssertExpectations(t)
+}
+
+// TestRunOnceWithSharedTypeTransition tests that a record shared with other owners keeps its type.
+func TestRunOnceWithSharedTypeTransition(t *testing.T) {
+	p := inmemory.NewInMemoryProvider(inmemory.InMemoryInitZones([]string{"example.org"}))
+	capabilities := p.Capabilities()
+
+	newController := func(ownerID string, endpoints ...*endpoint.Endpoint) *Controller {
+		r, err := registry.NewTXTRegistry(p, "", "", ownerID, 0, registry.TXTWithSharedOwnership())
+		require.NoError(t, err)
+		source := new(testutils.MockSource)
+		source.On("Endpoints").Return(endpoints, nil)
+		return &Controller{
+			Source:       source,
+			Registry:     r,
+			Policy:       &plan.SyncPolicy{},
+			Capabilities: &capabilities,
+		}
+	}
+
+	require.NoError(t, newController("cluster-a", endpoint.NewEndpoint("foo.example.org", endpoint.RecordTypeA, "1.2.3.4")).RunOnce(context.Background()))
+	require.NoError(t, newController("cluster-b", endpoint.NewEndpoint("foo.example.org", endpoint.RecordTypeA, "5.6.7.8")).RunOnce(context.Background()))
+	require.NoError(t, newController("cluster-a", endpoint.NewEndpoint("foo.example.org", endpoint.RecordTypeCNAME, "lb.example.org")).RunOnce(context.Background()))
+
+	records, err := p.Records(context.Background())
+	require.NoError(t, err)
+	var recordTypes []string
+	for _, ep := range records {
+		recordTypes = append(recordTypes, ep.RecordType)
+		if ep.RecordType == endpoint.RecordTypeA {
+			assert.True(t, ep.Targets.Same(endpoint.Targets{"1.2.3.4", "5.6.7.8"}))
+		}
+	}
+	assert.ElementsMatch(t, []string{endpoint.RecordTypeA, endpoint.RecordTypeTXT}, recordTypes)
+}
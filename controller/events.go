@@ -0,0 +1,126 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery/cached/memory"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/restmapper"
+	"k8s.io/client-go/tools/record"
+
+	"sigs.k8s.io/external-dns/endpoint"
+)
+
+const (
+	// driftEventReason is the reason of the events emitted for drifted records
+	driftEventReason = "RecordDrifted"
+	// eventComponent is the source of the events emitted by the controller
+	eventComponent = "external-dns"
+)
+
+// resourceKinds maps the kinds used in resource labels to the resources of their Kubernetes objects,
+// other kinds are the <resource>.<group> of a resource, as used by the generic CRD source
+var resourceKinds = map[string]schema.GroupResource{
+	"service":              {Resource: "services"},
+	"ingress":              {Group: "networking.k8s.io", Resource: "ingresses"},
+	"crd":                  {Group: "externaldns.k8s.io", Resource: "dnsendpoints"},
+	"gateway":              {Group: "networking.istio.io", Resource: "gateways"},
+	"virtualservice":       {Group: "networking.istio.io", Resource: "virtualservices"},
+	"httproute":            {Group: "gateway.networking.k8s.io", Resource: "httproutes"},
+	"tlsroute":             {Group: "gateway.networking.k8s.io", Resource: "tlsroutes"},
+	"ingressroute":         {Group: "contour.heptio.com", Resource: "ingressroutes"},
+	"traefik-ingressroute": {Group: "traefik.containo.us", Resource: "ingressroutes"},
+	"HTTPProxy":            {Group: "projectcontour.io", Resource: "httpproxies"},
+	"route":                {Group: "route.openshift.io", Resource: "routes"},
+	"routegroup":           {Group: "zalando.org", Resource: "routegroups"},
+}
+
+// DriftEventRecorder emits events on the Kubernetes objects records were created for
+type DriftEventRecorder struct {
+	recorder record.EventRecorder
+	mapper   meta.RESTMapper
+	client   dynamic.Interface
+}
+
+// NewDriftEventRecorder creates a DriftEventRecorder which looks up the objects of the records with the
+// dynamic client, using the resources served by the API server
+func NewDriftEventRecorder(kubeClient kubernetes.Interface, dynamicClient dynamic.Interface) *DriftEventRecorder {
+	broadcaster := record.NewBroadcaster()
+	broadcaster.StartRecordingToSink(&typedcorev1.EventSinkImpl{Interface: kubeClient.CoreV1().Events("")})
+	return &DriftEventRecorder{
+		recorder: broadcaster.NewRecorder(scheme.Scheme, corev1.EventSource{Component: eventComponent}),
+		mapper:   restmapper.NewDeferredDiscoveryRESTMapper(memory.NewMemCacheClient(kubeClient.Discovery())),
+		client:   dynamicClient,
+	}
+}
+
+// involvedObject looks up the Kubernetes object given by the resource label of an endpoint, which has
+// the form <kind>/<namespace>/<name>. It returns nil if the endpoint doesn't belong to a Kubernetes
+// object, e.g. if it was read from a file, and an error if the object can't be looked up.
+func (r *DriftEventRecorder) involvedObject(ctx context.Context, ep *endpoint.Endpoint) (*unstructured.Unstructured, error) {
+	parts := strings.SplitN(ep.Labels[endpoint.ResourceLabelKey], "/", 3)
+	if len(parts) != 3 || parts[0] == "file" || parts[2] == "" {
+		return nil, nil
+	}
+	resource, ok := resourceKinds[parts[0]]
+	if !ok {
+		resource = schema.ParseGroupResource(parts[0])
+	}
+	gvr, err := r.mapper.ResourceFor(resource.WithVersion(""))
+	if err != nil {
+		return nil, err
+	}
+	if parts[1] == "" {
+		return r.client.Resource(gvr).Get(ctx, parts[2], metav1.GetOptions{})
+	}
+	return r.client.Resource(gvr).Namespace(parts[1]).Get(ctx, parts[2], metav1.GetOptions{})
+}
+
+// emitDriftEvents emits a warning event on the object of each record which drifted since the last
+// synchronization, so that the owners of the objects notice changes made outside of ExternalDNS.
+func (c *Controller) emitDriftEvents(ctx context.Context, drifted []*endpoint.Endpoint) {
+	reported := make(map[string]bool, len(drifted))
+	for _, ep := range drifted {
+		key := ep.DNSName + " " + ep.RecordType + " " + ep.SetIdentifier + " " + ep.Targets.String()
+		reported[key] = true
+		if c.DriftEvents == nil || c.reportedDrift[key] {
+			continue
+		}
+		obj, err := c.DriftEvents.involvedObject(ctx, ep)
+		if err != nil {
+			log.Warnf("Failed to emit event for drifted record %s %s on %s: %v", ep.DNSName, ep.RecordType, ep.Labels[endpoint.ResourceLabelKey], err)
+			continue
+		}
+		if obj == nil {
+			continue
+		}
+		c.DriftEvents.recorder.Eventf(obj, corev1.EventTypeWarning, driftEventReason, "Record %s %s was changed outside of ExternalDNS to %s, drift policy: %s", ep.DNSName, ep.RecordType, ep.Targets, c.DriftPolicy)
+	}
+	c.reportedDrift = reported
+}
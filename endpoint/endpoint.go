@@ -18,6 +18,7 @@ package endpoint
 
 import (
 	"fmt"
+	"hash/fnv"
 	"sort"
 	"strings"
 
@@ -27,6 +28,8 @@ import (
 const (
 	// RecordTypeA is a RecordType enum value
 	RecordTypeA = "A"
+	// RecordTypeAAAA is a RecordType enum value
+	RecordTypeAAAA = "AAAA"
 	// RecordTypeCNAME is a RecordType enum value
 	RecordTypeCNAME = "CNAME"
 	// RecordTypeTXT is a RecordType enum value
@@ -35,6 +38,12 @@ const (
 	RecordTypeSRV = "SRV"
 	// RecordTypeNS is a RecordType enum value
 	RecordTypeNS = "NS"
+	// RecordTypeMX is a RecordType enum value
+	RecordTypeMX = "MX"
+	// RecordTypePTR is a RecordType enum value
+	RecordTypePTR = "PTR"
+	// RecordTypeCAA is a RecordType enum value
+	RecordTypeCAA = "CAA"
 )
 
 // TTL is a structure defining the TTL of a DNS record
@@ -191,6 +200,20 @@ func (e *Endpoint) GetProviderSpecificProperty(key string) (ProviderSpecificProp
 	return ProviderSpecificProperty{}, false
 }
 
+// StateHash returns a hash of the record type and the targets of the endpoint. It is stored by the
+// registry when a record is written so that changes made to the record by others can be detected.
+func (e *Endpoint) StateHash() string {
+	targets := make([]string, len(e.Targets))
+	for i, target := range e.Targets {
+		targets[i] = strings.ToLower(strings.TrimSuffix(target, "."))
+	}
+	sort.Strings(targets)
+
+	h := fnv.New64a()
+	h.Write([]byte(e.RecordType + " " + strings.Join(targets, " ")))
+	return fmt.Sprintf("%x", h.Sum64())
+}
+
 func (e *Endpoint) String() string {
 	return fmt.Sprintf("%s %d IN %s %s %s %s", e.DNSName, e.RecordTTL, e.RecordType, e.SetIdentifier, e.Targets, e.ProviderSpecific)
 }
@@ -75,3 +75,26 @@ func TestSameFailures(t *testing.T) {
 		}
 	}
 }
+
+func TestStateHash(t *testing.T) {
+	a := NewEndpoint("example.org", RecordTypeA, "1.2.3.4", "4.3.2.1")
+	b := NewEndpointWithTTL("example.org", RecordTypeA, TTL(300), "4.3.2.1", "1.2.3.4")
+	if a.StateHash() != b.StateHash() {
+		t.Error("hash should not depend on the order of the targets or the TTL")
+	}
+
+	c := NewEndpoint("example.org", RecordTypeCNAME, "Foo.com.")
+	d := NewEndpoint("example.org", RecordTypeCNAME, "foo.com")
+	if c.StateHash() != d.StateHash() {
+		t.Error("hash should not depend on the case or the trailing dot of the targets")
+	}
+
+	for _, other := range []*Endpoint{
+		NewEndpoint("example.org", RecordTypeA, "1.2.3.4"),
+		NewEndpoint("example.org", RecordTypeTXT, "1.2.3.4", "4.3.2.1"),
+	} {
+		if a.StateHash() == other.StateHash() {
+			t.Errorf("%v and %v should have different hashes", a, other)
+		}
+	}
+}
@@ -41,6 +41,27 @@ const (
 
 	// DualstackLabelKey is the name of the label that identifies dualstack endpoints
 	DualstackLabelKey = "dualstack"
+
+	// OwnersLabelKey is the name of the label that lists the owners of an Endpoint shared by several instances
+	OwnersLabelKey = "owners"
+	// OwnerTargetsLabelPrefix is prepended to an owner id to name the label listing the targets contributed by that owner
+	OwnerTargetsLabelPrefix = "targets/"
+	// LabelValueSeparator separates the items of label values holding a list
+	LabelValueSeparator = "|"
+
+	// MergeLabelKey is the name of the label that marks endpoints whose targets are merged with the ones of other resources
+	MergeLabelKey = "merge"
+	// ResourceTargetsLabelPrefix is prepended to a resource to name the label listing the targets contributed by that resource
+	ResourceTargetsLabelPrefix = "resource-targets/"
+
+	// HashLabelKey is the name of the label that stores the state hash of an Endpoint when it was last written
+	HashLabelKey = "hash"
+	// AdoptedHashLabelKey is the name of the label that stores the state hash of a change made outside
+	// of ExternalDNS which was adopted instead of reverted
+	AdoptedHashLabelKey = "adopted-hash"
+
+	// txtStringMaxLength is the maximum length of a character string of a TXT record
+	txtStringMaxLength = 255
 )
 
 // Labels store metadata related to the endpoint
@@ -58,6 +79,8 @@ func NewLabels() Labels {
 func NewLabelsFromString(labelText string) (Labels, error) {
 	endpointLabels := map[string]string{}
 	labelText = strings.Trim(labelText, "\"") // drop quotes
+	// join the character strings of labels which were split to fit into TXT records
+	labelText = strings.Replace(labelText, "\" \"", "", -1)
 	tokens := strings.Split(labelText, ",")
 	foundExternalDNSHeritage := false
 	for _, token := range tokens {
@@ -100,7 +123,19 @@ func (l Labels) Serialize(withQuotes bool) string {
 		tokens = append(tokens, fmt.Sprintf("%s/%s=%s", heritage, key, l[key]))
 	}
 	if withQuotes {
-		return fmt.Sprintf("\"%s\"", strings.Join(tokens, ","))
+		return quoteTXT(strings.Join(tokens, ","))
 	}
 	return strings.Join(tokens, ",")
 }
+
+// quoteTXT quotes the value of a TXT record. Values exceeding the maximum length of a character string,
+// e.g. because of the targets recorded per owner or resource, are split into several character strings.
+func quoteTXT(value string) string {
+	var quoted []string
+	for len(value) > txtStringMaxLength {
+		quoted = append(quoted, fmt.Sprintf("\"%s\"", value[:txtStringMaxLength]))
+		value = value[txtStringMaxLength:]
+	}
+	quoted = append(quoted, fmt.Sprintf("\"%s\"", value))
+	return strings.Join(quoted, " ")
+}
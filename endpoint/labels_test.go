@@ -18,6 +18,7 @@ package endpoint
 
 import (
 	"fmt"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/suite"
@@ -86,6 +87,29 @@ func (suite *LabelsSuite) TestDeserialize() {
 	suite.Nil(multipleHeritage, "if error should return nil")
 }
 
+func (suite *LabelsSuite) TestSerializeLong() {
+	long := Labels{
+		"owner":             "foo-owner",
+		"resource":          "service/default/foo",
+		"targets/foo-owner": strings.Repeat("10.0.0.1|", 40),
+	}
+
+	text := long.Serialize(true)
+	strs := strings.Split(strings.Trim(text, `"`), `" "`)
+	suite.Len(strs, 2, "should split into character strings")
+	for _, s := range strs {
+		suite.LessOrEqual(len(s), txtStringMaxLength, "should fit into a character string")
+	}
+
+	labels, err := NewLabelsFromString(text)
+	suite.NoError(err, "should succeed for split label text")
+	suite.Equal(long, labels, "should reconstruct original label map")
+
+	labels, err = NewLabelsFromString(strings.Join(strs, ""))
+	suite.NoError(err, "should succeed for joined label text")
+	suite.Equal(long, labels, "should reconstruct original label map")
+}
+
 func TestLabels(t *testing.T) {
 	suite.Run(t, new(LabelsSuite))
 }
@@ -64,6 +64,7 @@ require (
 	k8s.io/api v0.18.8
 	k8s.io/apimachinery v0.18.8
 	k8s.io/client-go v0.18.8
+	sigs.k8s.io/yaml v1.2.0
 )
 
 replace (
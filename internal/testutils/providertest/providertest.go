@@ -0,0 +1,266 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package providertest provides a conformance test suite for providers. It lives in its own
+// package because the tests of the plan package use testutils, which therefore can't import plan.
+package providertest
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"sigs.k8s.io/external-dns/endpoint"
+	"sigs.k8s.io/external-dns/plan"
+	"sigs.k8s.io/external-dns/provider"
+	"sigs.k8s.io/external-dns/registry"
+)
+
+// Zone is the zone the providers under test have to manage
+const Zone = "example.org"
+
+// Capabilities declare the optional behaviour a provider supports, the tests of the
+// unsupported behaviour are skipped.
+type Capabilities struct {
+	// RecordTypes are the record types besides A, CNAME and TXT the provider supports
+	RecordTypes []string
+	// MultipleTargets is set if a record may have more than one target
+	MultipleTargets bool
+	// TTL is set if the TTL of a record is kept
+	TTL bool
+	// SetIdentifier is set if records of the same name and type are told apart by their set identifier
+	SetIdentifier bool
+}
+
+// recordTypeTargets are the targets used for records of the other types
+var recordTypeTargets = map[string][]string{
+	endpoint.RecordTypeAAAA: {"fd00::1"},
+	endpoint.RecordTypeSRV:  {"10 50 443 target." + Zone},
+	endpoint.RecordTypeNS:   {"ns1." + Zone},
+	endpoint.RecordTypeMX:   {"10 mail." + Zone},
+	endpoint.RecordTypePTR:  {"target." + Zone},
+	endpoint.RecordTypeCAA:  {`0 issue "letsencrypt.org"`},
+}
+
+// Run runs the conformance tests against the providers created by newProvider. Each test
+// gets a new provider, which has to manage the zone Zone without any records of the test.
+func Run(t *testing.T, newProvider func(t *testing.T) provider.Provider, capabilities Capabilities) {
+	s := &suite{newProvider: newProvider, capabilities: capabilities}
+
+	t.Run("Create", s.testCreate)
+	t.Run("Update", s.testUpdate)
+	t.Run("Delete", s.testDelete)
+	t.Run("MultipleTargets", s.testMultipleTargets)
+	t.Run("TTL", s.testTTL)
+	t.Run("SetIdentifier", s.testSetIdentifier)
+	t.Run("RecordTypes", s.testRecordTypes)
+	t.Run("TXTRegistry", s.testTXTRegistry)
+}
+
+type suite struct {
+	newProvider  func(t *testing.T) provider.Provider
+	capabilities Capabilities
+}
+
+// name returns a name in the zone
+func name(label string) string {
+	return label + "." + Zone
+}
+
+func (s *suite) apply(t *testing.T, p provider.Provider, changes *plan.Changes) {
+	require.NoError(t, p.ApplyChanges(context.Background(), changes))
+}
+
+// expectRecords checks that the records of the provider contain the expected ones and none of the absent ones
+func (s *suite) expectRecords(t *testing.T, p provider.Provider, expected []*endpoint.Endpoint, absent ...*endpoint.Endpoint) {
+	records, err := p.Records(context.Background())
+	require.NoError(t, err)
+
+	for _, ep := range expected {
+		record := findRecord(records, ep)
+		if !assert.NotNil(t, record, "record %s not found in %s", ep, records) {
+			continue
+		}
+		assert.ElementsMatch(t, normalizeTargets(ep.RecordType, ep.Targets), normalizeTargets(record.RecordType, record.Targets), "targets of record %s", ep)
+		if s.capabilities.TTL && ep.RecordTTL.IsConfigured() {
+			assert.Equal(t, ep.RecordTTL, record.RecordTTL, "TTL of record %s", ep)
+		}
+	}
+	for _, ep := range absent {
+		assert.Nil(t, findRecord(records, ep), "record %s should have been deleted", ep)
+	}
+}
+
+// findRecord returns the record with the name, type and set identifier of the endpoint
+func findRecord(records []*endpoint.Endpoint, ep *endpoint.Endpoint) *endpoint.Endpoint {
+	for _, record := range records {
+		if strings.EqualFold(strings.TrimSuffix(record.DNSName, "."), ep.DNSName) && record.RecordType == ep.RecordType && record.SetIdentifier == ep.SetIdentifier {
+			return record
+		}
+	}
+	return nil
+}
+
+// normalizeTargets removes the differences of targets which are insignificant for DNS,
+// i.e. the case and trailing dot of names and the quotes of TXT records
+func normalizeTargets(recordType string, targets endpoint.Targets) []string {
+	normalized := make([]string, 0, len(targets))
+	for _, target := range targets {
+		if recordType == endpoint.RecordTypeTXT {
+			normalized = append(normalized, strings.Trim(target, `"`))
+			continue
+		}
+		normalized = append(normalized, strings.ToLower(strings.TrimSuffix(target, ".")))
+	}
+	return normalized
+}
+
+func (s *suite) testCreate(t *testing.T) {
+	p := s.newProvider(t)
+	records := []*endpoint.Endpoint{
+		endpoint.NewEndpoint(name("create-a"), endpoint.RecordTypeA, "10.0.0.1"),
+		endpoint.NewEndpoint(name("create-cname"), endpoint.RecordTypeCNAME, name("create-a")),
+		endpoint.NewEndpoint(name("create-txt"), endpoint.RecordTypeTXT, `"heritage=external-dns,external-dns/owner=default"`),
+	}
+
+	s.apply(t, p, &plan.Changes{Create: records})
+	s.expectRecords(t, p, records)
+}
+
+func (s *suite) testUpdate(t *testing.T) {
+	p := s.newProvider(t)
+	old := []*endpoint.Endpoint{
+		endpoint.NewEndpoint(name("update-a"), endpoint.RecordTypeA, "10.0.0.1"),
+		endpoint.NewEndpoint(name("update-cname"), endpoint.RecordTypeCNAME, name("update-a")),
+	}
+	updated := []*endpoint.Endpoint{
+		endpoint.NewEndpoint(name("update-a"), endpoint.RecordTypeA, "10.0.0.2"),
+		endpoint.NewEndpoint(name("update-cname"), endpoint.RecordTypeCNAME, name("other")),
+	}
+
+	s.apply(t, p, &plan.Changes{Create: old})
+	s.apply(t, p, &plan.Changes{UpdateOld: old, UpdateNew: updated})
+	s.expectRecords(t, p, updated)
+}
+
+func (s *suite) testDelete(t *testing.T) {
+	p := s.newProvider(t)
+	kept := endpoint.NewEndpoint(name("delete-kept"), endpoint.RecordTypeA, "10.0.0.1")
+	deleted := []*endpoint.Endpoint{
+		endpoint.NewEndpoint(name("delete-a"), endpoint.RecordTypeA, "10.0.0.2"),
+		endpoint.NewEndpoint(name("delete-txt"), endpoint.RecordTypeTXT, `"heritage=external-dns,external-dns/owner=default"`),
+	}
+
+	s.apply(t, p, &plan.Changes{Create: append([]*endpoint.Endpoint{kept}, deleted...)})
+	s.apply(t, p, &plan.Changes{Delete: deleted})
+	s.expectRecords(t, p, []*endpoint.Endpoint{kept}, deleted...)
+}
+
+func (s *suite) testMultipleTargets(t *testing.T) {
+	if !s.capabilities.MultipleTargets {
+		t.Skip("records with multiple targets are not supported")
+	}
+	p := s.newProvider(t)
+	old := endpoint.NewEndpoint(name("multiple"), endpoint.RecordTypeA, "10.0.0.1", "10.0.0.2")
+	updated := endpoint.NewEndpoint(name("multiple"), endpoint.RecordTypeA, "10.0.0.2", "10.0.0.3", "10.0.0.4")
+
+	s.apply(t, p, &plan.Changes{Create: []*endpoint.Endpoint{old}})
+	s.expectRecords(t, p, []*endpoint.Endpoint{old})
+
+	s.apply(t, p, &plan.Changes{UpdateOld: []*endpoint.Endpoint{old}, UpdateNew: []*endpoint.Endpoint{updated}})
+	s.expectRecords(t, p, []*endpoint.Endpoint{updated})
+}
+
+func (s *suite) testTTL(t *testing.T) {
+	if !s.capabilities.TTL {
+		t.Skip("TTLs are not supported")
+	}
+	p := s.newProvider(t)
+	old := endpoint.NewEndpointWithTTL(name("ttl"), endpoint.RecordTypeA, 600, "10.0.0.1")
+	updated := endpoint.NewEndpointWithTTL(name("ttl"), endpoint.RecordTypeA, 900, "10.0.0.1")
+
+	s.apply(t, p, &plan.Changes{Create: []*endpoint.Endpoint{old}})
+	s.expectRecords(t, p, []*endpoint.Endpoint{old})
+
+	s.apply(t, p, &plan.Changes{UpdateOld: []*endpoint.Endpoint{old}, UpdateNew: []*endpoint.Endpoint{updated}})
+	s.expectRecords(t, p, []*endpoint.Endpoint{updated})
+}
+
+func (s *suite) testSetIdentifier(t *testing.T) {
+	if !s.capabilities.SetIdentifier {
+		t.Skip("set identifiers are not supported")
+	}
+	p := s.newProvider(t)
+	eu := endpoint.NewEndpoint(name("set"), endpoint.RecordTypeA, "10.0.0.1").WithSetIdentifier("eu")
+	us := endpoint.NewEndpoint(name("set"), endpoint.RecordTypeA, "10.0.0.2").WithSetIdentifier("us")
+
+	s.apply(t, p, &plan.Changes{Create: []*endpoint.Endpoint{eu, us}})
+	s.expectRecords(t, p, []*endpoint.Endpoint{eu, us})
+
+	s.apply(t, p, &plan.Changes{Delete: []*endpoint.Endpoint{eu}})
+	s.expectRecords(t, p, []*endpoint.Endpoint{us}, eu)
+}
+
+func (s *suite) testRecordTypes(t *testing.T) {
+	if len(s.capabilities.RecordTypes) == 0 {
+		t.Skip("no other record types are supported")
+	}
+	for _, recordType := range s.capabilities.RecordTypes {
+		t.Run(recordType, func(t *testing.T) {
+			targets, ok := recordTypeTargets[recordType]
+			require.True(t, ok, "no targets known for record type %s", recordType)
+
+			p := s.newProvider(t)
+			ep := endpoint.NewEndpoint(name("type-"+strings.ToLower(recordType)), recordType, targets...)
+			s.apply(t, p, &plan.Changes{Create: []*endpoint.Endpoint{ep}})
+			s.expectRecords(t, p, []*endpoint.Endpoint{ep})
+
+			s.apply(t, p, &plan.Changes{Delete: []*endpoint.Endpoint{ep}})
+			s.expectRecords(t, p, nil, ep)
+		})
+	}
+}
+
+// testTXTRegistry checks that the TXT registry finds the ownership of the records it created
+func (s *suite) testTXTRegistry(t *testing.T) {
+	p := s.newProvider(t)
+	r, err := registry.NewTXTRegistry(p, "", "", "conformance", time.Hour)
+	require.NoError(t, err)
+
+	desired := []*endpoint.Endpoint{
+		endpoint.NewEndpoint(name("owned-a"), endpoint.RecordTypeA, "10.0.0.1"),
+		endpoint.NewEndpoint(name("owned-cname"), endpoint.RecordTypeCNAME, name("owned-a")),
+	}
+	for _, ep := range desired {
+		ep.Labels[endpoint.ResourceLabelKey] = fmt.Sprintf("service/default/%s", ep.DNSName)
+	}
+	require.NoError(t, r.ApplyChanges(context.Background(), &plan.Changes{Create: desired}))
+
+	records, err := r.Records(context.Background())
+	require.NoError(t, err)
+	for _, ep := range desired {
+		record := findRecord(records, ep)
+		if assert.NotNil(t, record, "record %s not found in %s", ep, records) {
+			assert.Equal(t, "conformance", record.Labels[endpoint.OwnerLabelKey], "owner of record %s", ep)
+			assert.Equal(t, ep.Labels[endpoint.ResourceLabelKey], record.Labels[endpoint.ResourceLabelKey], "resource of record %s", ep)
+		}
+	}
+}
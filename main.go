@@ -18,6 +18,7 @@ package main
 
 import (
 	"context"
+	"fmt"
 	"net/http"
 	"os"
 	"os/signal"
@@ -27,6 +28,7 @@ import (
 
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	log "github.com/sirupsen/logrus"
+	"k8s.io/client-go/kubernetes"
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
 
 	"sigs.k8s.io/external-dns/controller"
@@ -64,6 +66,7 @@ import (
 	"sigs.k8s.io/external-dns/provider/ultradns"
 	"sigs.k8s.io/external-dns/provider/vinyldns"
 	"sigs.k8s.io/external-dns/provider/vultr"
+	"sigs.k8s.io/external-dns/provider/zonefile"
 	"sigs.k8s.io/external-dns/registry"
 	"sigs.k8s.io/external-dns/source"
 )
@@ -106,13 +109,20 @@ func main() {
 		CombineFQDNAndAnnotation:       cfg.CombineFQDNAndAnnotation,
 		IgnoreHostnameAnnotation:       cfg.IgnoreHostnameAnnotation,
 		IgnoreIngressTLSSpec:           cfg.IgnoreIngressTLSSpec,
+		IngressClassNames:              cfg.IngressClassNames,
+		NodeAddressTypes:               cfg.NodeAddressTypes,
+		NodeLabelFilter:                cfg.NodeLabelFilter,
+		ExcludeUnavailableNodes:        cfg.ExcludeUnavailableNodes,
 		Compatibility:                  cfg.Compatibility,
 		PublishInternal:                cfg.PublishInternal,
 		PublishHostIP:                  cfg.PublishHostIP,
 		AlwaysPublishNotReadyAddresses: cfg.AlwaysPublishNotReadyAddresses,
+		UseEndpointSlices:              cfg.UseEndpointSlices,
 		ConnectorServer:                cfg.ConnectorSourceServer,
+		FileSourcePaths:                cfg.FileSourcePaths,
 		CRDSourceAPIVersion:            cfg.CRDSourceAPIVersion,
 		CRDSourceKind:                  cfg.CRDSourceKind,
+		GenericCRDConfig:               cfg.GenericCRDConfig,
 		KubeConfig:                     cfg.KubeConfig,
 		APIServerURL:                   cfg.APIServerURL,
 		ServiceTypeFilter:              cfg.ServiceTypeFilter,
@@ -120,12 +130,12 @@ func main() {
 		CFUsername:                     cfg.CFUsername,
 		CFPassword:                     cfg.CFPassword,
 		ContourLoadBalancerService:     cfg.ContourLoadBalancerService,
+		TraefikLoadBalancerService:     cfg.TraefikLoadBalancerService,
 		SkipperRouteGroupVersion:       cfg.SkipperRouteGroupVersion,
 		RequestTimeout:                 cfg.RequestTimeout,
 	}
 
-	// Lookup all the selected sources by names and pass them the desired configuration.
-	sources, err := source.ByNames(&source.SingletonClientGenerator{
+	clientGenerator := &source.SingletonClientGenerator{
 		KubeConfig:   cfg.KubeConfig,
 		APIServerURL: cfg.APIServerURL,
 		// If update events are enabled, disable timeout.
@@ -135,13 +145,35 @@ func main() {
 			}
 			return cfg.RequestTimeout
 		}(),
-	}, cfg.Sources, sourceCfg)
+	}
+
+	// Lookup all the selected sources by names and pass them the desired configuration.
+	sources, err := source.ByNames(clientGenerator, cfg.Sources, sourceCfg)
 	if err != nil {
 		log.Fatal(err)
 	}
 
 	// Combine multiple sources into a single, deduplicated source.
-	endpointsSource := source.NewDedupSource(source.NewMultiSource(sources))
+	multiSource := source.NewMultiSource(sources)
+	if cfg.TolerateSourceErrors {
+		multiSource = source.NewTolerantMultiSource(sources, cfg.Sources, cfg.SourceMaxStaleness)
+	}
+	endpointsSource := source.NewDedupSource(multiSource)
+
+	// Apply the TTL policies of the domains on top of the global one.
+	ttlPolicies := []source.TTLPolicy{{
+		Default: endpoint.TTL(cfg.DefaultTTL.Seconds()),
+		Min:     endpoint.TTL(cfg.MinTTL.Seconds()),
+		Max:     endpoint.TTL(cfg.MaxTTL.Seconds()),
+	}}
+	for _, s := range cfg.DomainTTLPolicies {
+		policy, err := source.ParseTTLPolicy(s)
+		if err != nil {
+			log.Fatal(err)
+		}
+		ttlPolicies = append(ttlPolicies, policy)
+	}
+	endpointsSource = source.NewTTLPolicySource(endpointsSource, ttlPolicies)
 
 	domainFilter := endpoint.NewDomainFilterWithExclusions(cfg.DomainFilter, cfg.ExcludeDomains)
 	zoneNameFilter := endpoint.NewDomainFilter(cfg.ZoneNameFilter)
@@ -242,12 +274,18 @@ func main() {
 				CustomerName:  cfg.DynCustomerName,
 				Username:      cfg.DynUsername,
 				Password:      cfg.DynPassword,
-				MinTTLSeconds: cfg.DynMinTTLSeconds,
+				MinTTLSeconds: int(cfg.MinTTL.Seconds()),
 				AppVersion:    externaldns.Version,
 			},
 		)
 	case "coredns", "skydns":
 		p, err = coredns.NewCoreDNSProvider(domainFilter, cfg.CoreDNSPrefix, cfg.DryRun)
+	case "coredns-configmap":
+		var kubeClient kubernetes.Interface
+		kubeClient, err = clientGenerator.KubeClient()
+		if err == nil {
+			p, err = coredns.NewCoreDNSConfigMapProvider(kubeClient, cfg.CoreDNSConfigMap, cfg.CoreDNSConfigMapKey, cfg.CoreDNSConfigMapFormat, domainFilter, cfg.DryRun)
+		}
 	case "rdns":
 		p, err = rdns.NewRDNSProvider(
 			rdns.RDNSConfig{
@@ -258,7 +296,15 @@ func main() {
 	case "exoscale":
 		p, err = exoscale.NewExoscaleProvider(cfg.ExoscaleEndpoint, cfg.ExoscaleAPIKey, cfg.ExoscaleAPISecret, cfg.DryRun, exoscale.ExoscaleWithDomain(domainFilter), exoscale.ExoscaleWithLogging()), nil
 	case "inmemory":
-		p, err = inmemory.NewInMemoryProvider(inmemory.InMemoryInitZones(cfg.InMemoryZones), inmemory.InMemoryWithDomain(domainFilter), inmemory.InMemoryWithLogging()), nil
+		im := inmemory.NewInMemoryProvider(inmemory.InMemoryInitZones(cfg.InMemoryZones), inmemory.InMemoryWithDomain(domainFilter), inmemory.InMemoryWithLogging(), inmemory.InMemoryWithPersistence(cfg.InMemoryPersistenceFile))
+		if cfg.InMemoryDNSPort != 0 {
+			go func() {
+				if err := im.ListenAndServeDNS(ctx, fmt.Sprintf(":%d", cfg.InMemoryDNSPort)); err != nil {
+					log.Fatal(err)
+				}
+			}()
+		}
+		p, err = im, nil
 	case "designate":
 		p, err = designate.NewDesignateProvider(domainFilter, cfg.DryRun)
 	case "pdns":
@@ -284,7 +330,9 @@ func main() {
 			p, err = oci.NewOCIProvider(*config, domainFilter, zoneIDFilter, cfg.DryRun)
 		}
 	case "rfc2136":
-		p, err = rfc2136.NewRfc2136Provider(cfg.RFC2136Host, cfg.RFC2136Port, cfg.RFC2136Zone, cfg.RFC2136Insecure, cfg.RFC2136TSIGKeyName, cfg.RFC2136TSIGSecret, cfg.RFC2136TSIGSecretAlg, cfg.RFC2136TAXFR, domainFilter, cfg.DryRun, cfg.RFC2136MinTTL, nil)
+		p, err = rfc2136.NewRfc2136Provider(cfg.RFC2136Hosts, cfg.RFC2136Port, cfg.RFC2136Zones, cfg.RFC2136ZoneTSIGKeys, cfg.RFC2136Insecure, cfg.RFC2136TSIGKeyName, cfg.RFC2136TSIGSecret, cfg.RFC2136TSIGSecretAlg, cfg.RFC2136TAXFR, domainFilter, cfg.DryRun, cfg.MinTTL, nil)
+	case "zonefile":
+		p, err = zonefile.NewZonefileProvider(cfg.ZonefileDirectory, cfg.ZonefileZones, domainFilter, cfg.ZonefileReloadCommand, cfg.DryRun)
 	case "ns1":
 		p, err = ns1.NewNS1Provider(
 			ns1.NS1Config{
@@ -293,7 +341,7 @@ func main() {
 				NS1Endpoint:   cfg.NS1Endpoint,
 				NS1IgnoreSSL:  cfg.NS1IgnoreSSL,
 				DryRun:        cfg.DryRun,
-				MinTTLSeconds: cfg.NS1MinTTLSeconds,
+				MinTTLSeconds: int(cfg.MinTTL.Seconds()),
 			},
 		)
 	case "transip":
@@ -312,7 +360,19 @@ func main() {
 	case "noop":
 		r, err = registry.NewNoopRegistry(p)
 	case "txt":
-		r, err = registry.NewTXTRegistry(p, cfg.TXTPrefix, cfg.TXTSuffix, cfg.TXTOwnerID, cfg.TXTCacheInterval)
+		var txtOpts []registry.TXTRegistryOption
+		if cfg.TXTSharedOwnership {
+			txtOpts = append(txtOpts, registry.TXTWithSharedOwnership())
+		}
+		if cfg.DriftPolicy != "disabled" {
+			txtOpts = append(txtOpts, registry.TXTWithDriftDetection())
+		}
+		var txtRegistry *registry.TXTRegistry
+		txtRegistry, err = registry.NewTXTRegistry(p, cfg.TXTPrefix, cfg.TXTSuffix, cfg.TXTOwnerID, cfg.TXTCacheInterval, txtOpts...)
+		if err == nil {
+			go txtRegistry.RunCacheRefresh(ctx)
+			r = txtRegistry
+		}
 	case "aws-sd":
 		r, err = registry.NewAWSSDRegistry(p.(*awssd.AWSSDProvider), cfg.TXTOwnerID)
 	default:
@@ -334,6 +394,30 @@ func main() {
 		Policy:       policy,
 		Interval:     cfg.Interval,
 		DomainFilter: domainFilter,
+		GCInterval:   cfg.TXTGCInterval,
+	}
+	if cfg.DriftPolicy != "disabled" {
+		ctrl.DriftPolicy = cfg.DriftPolicy
+		if events, err := newDriftEventRecorder(clientGenerator); err == nil {
+			ctrl.DriftEvents = events
+		} else {
+			log.Warnf("No events are emitted for drifted records: %v", err)
+		}
+	}
+	if lister, ok := p.(provider.ZoneLister); ok {
+		ctrl.Zones = lister
+	}
+	if reporter, ok := p.(provider.CapabilitiesReporter); ok {
+		capabilities := reporter.Capabilities()
+		ctrl.Capabilities = &capabilities
+	}
+
+	if cfg.Command == "gc" {
+		if err := ctrl.RunGarbageCollection(ctx); err != nil {
+			log.Fatal(err)
+		}
+
+		os.Exit(0)
 	}
 
 	if cfg.Once {
@@ -356,6 +440,19 @@ func main() {
 	ctrl.Run(ctx)
 }
 
+// newDriftEventRecorder creates the recorder of the events emitted on the Kubernetes objects of drifted records
+func newDriftEventRecorder(p source.ClientGenerator) (*controller.DriftEventRecorder, error) {
+	kubeClient, err := p.KubeClient()
+	if err != nil {
+		return nil, err
+	}
+	dynamicClient, err := p.DynamicKubernetesClient()
+	if err != nil {
+		return nil, err
+	}
+	return controller.NewDriftEventRecorder(kubeClient, dynamicClient), nil
+}
+
 func handleSigterm(cancel func()) {
 	signals := make(chan os.Signal, 1)
 	signal.Notify(signals, syscall.SIGTERM)
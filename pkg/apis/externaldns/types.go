@@ -43,8 +43,11 @@ type Config struct {
 	KubeConfig                        string
 	RequestTimeout                    time.Duration
 	ContourLoadBalancerService        string
+	TraefikLoadBalancerService        string
 	SkipperRouteGroupVersion          string
 	Sources                           []string
+	TolerateSourceErrors              bool
+	SourceMaxStaleness                time.Duration
 	Namespace                         string
 	AnnotationFilter                  string
 	LabelFilter                       string
@@ -52,11 +55,17 @@ type Config struct {
 	CombineFQDNAndAnnotation          bool
 	IgnoreHostnameAnnotation          bool
 	IgnoreIngressTLSSpec              bool
+	IngressClassNames                 []string
+	NodeAddressTypes                  []string
+	NodeLabelFilter                   string
+	ExcludeUnavailableNodes           bool
 	Compatibility                     string
 	PublishInternal                   bool
 	PublishHostIP                     bool
 	AlwaysPublishNotReadyAddresses    bool
+	UseEndpointSlices                 bool
 	ConnectorSourceServer             string
+	FileSourcePaths                   []string
 	Provider                          string
 	GoogleProject                     string
 	GoogleBatchChangeSize             int
@@ -83,6 +92,9 @@ type Config struct {
 	CloudflareProxied                 bool
 	CloudflareZonesPerPage            int
 	CoreDNSPrefix                     string
+	CoreDNSConfigMap                  string
+	CoreDNSConfigMapKey               string
+	CoreDNSConfigMapFormat            string
 	RcodezeroTXTEncrypt               bool
 	AkamaiServiceConsumerDomain       string
 	AkamaiClientToken                 string
@@ -102,6 +114,8 @@ type Config struct {
 	DynMinTTLSeconds                  int
 	OCIConfigFile                     string
 	InMemoryZones                     []string
+	InMemoryPersistenceFile           string
+	InMemoryDNSPort                   int
 	OVHEndpoint                       string
 	OVHApiRateLimit                   int
 	PDNSServer                        string
@@ -111,6 +125,10 @@ type Config struct {
 	TLSClientCert                     string
 	TLSClientCertKey                  string
 	Policy                            string
+	DefaultTTL                        time.Duration
+	MinTTL                            time.Duration
+	MaxTTL                            time.Duration
+	DomainTTLPolicies                 []string
 	Registry                          string
 	TXTOwnerID                        string
 	TXTPrefix                         string
@@ -123,24 +141,33 @@ type Config struct {
 	MetricsAddress                    string
 	LogLevel                          string
 	TXTCacheInterval                  time.Duration
+	TXTGCInterval                     time.Duration
+	TXTSharedOwnership                bool
+	DriftPolicy                       string
+	Command                           string
 	ExoscaleEndpoint                  string
 	ExoscaleAPIKey                    string `secure:"yes"`
 	ExoscaleAPISecret                 string `secure:"yes"`
 	CRDSourceAPIVersion               string
 	CRDSourceKind                     string
+	GenericCRDConfig                  string
 	ServiceTypeFilter                 []string
 	CFAPIEndpoint                     string
 	CFUsername                        string
 	CFPassword                        string
-	RFC2136Host                       string
+	RFC2136Hosts                      []string
 	RFC2136Port                       int
-	RFC2136Zone                       string
+	RFC2136Zones                      []string
+	RFC2136ZoneTSIGKeys               []string `secure:"yes"`
 	RFC2136Insecure                   bool
 	RFC2136TSIGKeyName                string
 	RFC2136TSIGSecret                 string `secure:"yes"`
 	RFC2136TSIGSecretAlg              string
 	RFC2136TAXFR                      bool
 	RFC2136MinTTL                     time.Duration
+	ZonefileDirectory                 string
+	ZonefileZones                     []string
+	ZonefileReloadCommand             string
 	NS1Endpoint                       string
 	NS1IgnoreSSL                      bool
 	NS1MinTTLSeconds                  int
@@ -158,8 +185,11 @@ var defaultConfig = &Config{
 	KubeConfig:                  "",
 	RequestTimeout:              time.Second * 30,
 	ContourLoadBalancerService:  "heptio-contour/contour",
+	TraefikLoadBalancerService:  "traefik/traefik",
 	SkipperRouteGroupVersion:    "zalando.org/v1",
 	Sources:                     nil,
+	TolerateSourceErrors:        false,
+	SourceMaxStaleness:          time.Hour,
 	Namespace:                   "",
 	AnnotationFilter:            "",
 	LabelFilter:                 "",
@@ -167,10 +197,15 @@ var defaultConfig = &Config{
 	CombineFQDNAndAnnotation:    false,
 	IgnoreHostnameAnnotation:    false,
 	IgnoreIngressTLSSpec:        false,
+	IngressClassNames:           []string{},
+	NodeAddressTypes:            []string{"ExternalIP", "InternalIP"},
+	NodeLabelFilter:             "",
+	ExcludeUnavailableNodes:     false,
 	Compatibility:               "",
 	PublishInternal:             false,
 	PublishHostIP:               false,
 	ConnectorSourceServer:       "localhost:8080",
+	FileSourcePaths:             []string{},
 	Provider:                    "",
 	GoogleProject:               "",
 	GoogleBatchChangeSize:       1000,
@@ -193,6 +228,9 @@ var defaultConfig = &Config{
 	CloudflareProxied:           false,
 	CloudflareZonesPerPage:      50,
 	CoreDNSPrefix:               "/skydns/",
+	CoreDNSConfigMap:            "kube-system/coredns",
+	CoreDNSConfigMapKey:         "Corefile",
+	CoreDNSConfigMapFormat:      "hosts",
 	RcodezeroTXTEncrypt:         false,
 	AkamaiServiceConsumerDomain: "",
 	AkamaiClientToken:           "",
@@ -208,6 +246,8 @@ var defaultConfig = &Config{
 	InfobloxMaxResults:          0,
 	OCIConfigFile:               "/etc/kubernetes/oci.yaml",
 	InMemoryZones:               []string{},
+	InMemoryPersistenceFile:     "",
+	InMemoryDNSPort:             0,
 	OVHEndpoint:                 "ovh-eu",
 	OVHApiRateLimit:             20,
 	PDNSServer:                  "http://localhost:8081",
@@ -217,11 +257,19 @@ var defaultConfig = &Config{
 	TLSClientCert:               "",
 	TLSClientCertKey:            "",
 	Policy:                      "sync",
+	DefaultTTL:                  0,
+	MinTTL:                      0,
+	MaxTTL:                      0,
+	DomainTTLPolicies:           []string{},
 	Registry:                    "txt",
 	TXTOwnerID:                  "default",
 	TXTPrefix:                   "",
 	TXTSuffix:                   "",
 	TXTCacheInterval:            0,
+	TXTGCInterval:               0,
+	TXTSharedOwnership:          false,
+	DriftPolicy:                 "disabled",
+	Command:                     "run",
 	Interval:                    time.Minute,
 	Once:                        false,
 	DryRun:                      false,
@@ -234,19 +282,24 @@ var defaultConfig = &Config{
 	ExoscaleAPISecret:           "",
 	CRDSourceAPIVersion:         "externaldns.k8s.io/v1alpha1",
 	CRDSourceKind:               "DNSEndpoint",
+	GenericCRDConfig:            "",
 	ServiceTypeFilter:           []string{},
 	CFAPIEndpoint:               "",
 	CFUsername:                  "",
 	CFPassword:                  "",
-	RFC2136Host:                 "",
+	RFC2136Hosts:                []string{},
 	RFC2136Port:                 0,
-	RFC2136Zone:                 "",
+	RFC2136Zones:                []string{},
+	RFC2136ZoneTSIGKeys:         []string{},
 	RFC2136Insecure:             false,
 	RFC2136TSIGKeyName:          "",
 	RFC2136TSIGSecret:           "",
 	RFC2136TSIGSecretAlg:        "",
 	RFC2136TAXFR:                true,
 	RFC2136MinTTL:               0,
+	ZonefileDirectory:           "",
+	ZonefileZones:               []string{},
+	ZonefileReloadCommand:       "",
 	NS1Endpoint:                 "",
 	NS1IgnoreSSL:                false,
 	TransIPAccountName:          "",
@@ -271,12 +324,19 @@ func (cfg *Config) String() string {
 	for i := 0; i < t.NumField(); i++ {
 		f := t.Field(i)
 		if val, ok := f.Tag.Lookup("secure"); ok && val == "yes" {
-			if f.Type.Kind() != reflect.String {
-				continue
-			}
 			v := reflect.ValueOf(&temp).Elem().Field(i)
-			if v.String() != "" {
-				v.SetString(passwordMask)
+			switch {
+			case f.Type.Kind() == reflect.String:
+				if v.String() != "" {
+					v.SetString(passwordMask)
+				}
+			case f.Type.Kind() == reflect.Slice && f.Type.Elem().Kind() == reflect.String:
+				// replace the slice instead of its elements, which are shared with cfg
+				masked := make([]string, v.Len())
+				for j := range masked {
+					masked[j] = passwordMask
+				}
+				v.Set(reflect.ValueOf(masked))
 			}
 		}
 	}
@@ -299,6 +359,10 @@ func (cfg *Config) ParseFlags(args []string) error {
 	app.Version(Version)
 	app.DefaultEnvars()
 
+	// Commands
+	app.Command("run", "Continuously synchronize DNS records with the configured sources (default)").Default()
+	app.Command("gc", "Delete the ownership records of this owner id which have no matching DNS record and exit")
+
 	// Flags related to Kubernetes
 	app.Flag("server", "The Kubernetes API server to connect to (default: auto-detect)").Default(defaultConfig.APIServerURL).StringVar(&cfg.APIServerURL)
 	app.Flag("kubeconfig", "Retrieve target cluster configuration from a Kubernetes configuration file (default: auto-detect)").Default(defaultConfig.KubeConfig).StringVar(&cfg.KubeConfig)
@@ -311,12 +375,15 @@ func (cfg *Config) ParseFlags(args []string) error {
 
 	// Flags related to Contour
 	app.Flag("contour-load-balancer", "The fully-qualified name of the Contour load balancer service. (default: heptio-contour/contour)").Default("heptio-contour/contour").StringVar(&cfg.ContourLoadBalancerService)
+	app.Flag("traefik-load-balancer", "The fully-qualified name of the Traefik load balancer service, valid only when using traefik-ingressroute source (default: traefik/traefik)").Default(defaultConfig.TraefikLoadBalancerService).StringVar(&cfg.TraefikLoadBalancerService)
 
 	// Flags related to Skipper RouteGroup
 	app.Flag("skipper-routegroup-groupversion", "The resource version for skipper routegroup").Default(source.DefaultRoutegroupVersion).StringVar(&cfg.SkipperRouteGroupVersion)
 
 	// Flags related to processing sources
-	app.Flag("source", "The resource types that are queried for endpoints; specify multiple times for multiple sources (required, options: service, ingress, node, fake, connector, istio-gateway, istio-virtualservice, cloudfoundry, contour-ingressroute, contour-httpproxy, crd, empty, skipper-routegroup,openshift-route)").Required().PlaceHolder("source").EnumsVar(&cfg.Sources, "service", "ingress", "node", "istio-gateway", "istio-virtualservice", "cloudfoundry", "contour-ingressroute", "contour-httpproxy", "fake", "connector", "crd", "empty", "skipper-routegroup", "openshift-route")
+	app.Flag("source", "The resource types that are queried for endpoints; specify multiple times for multiple sources (required, options: service, ingress, node, pod, fake, connector, file, istio-gateway, istio-virtualservice, cloudfoundry, contour-ingressroute, contour-httpproxy, gateway-httproute, gateway-tlsroute, traefik-ingressroute, crd, generic-crd, empty, skipper-routegroup,openshift-route)").Required().PlaceHolder("source").EnumsVar(&cfg.Sources, "service", "ingress", "node", "pod", "istio-gateway", "istio-virtualservice", "cloudfoundry", "contour-ingressroute", "contour-httpproxy", "gateway-httproute", "gateway-tlsroute", "traefik-ingressroute", "fake", "connector", "file", "crd", "generic-crd", "empty", "skipper-routegroup", "openshift-route")
+	app.Flag("tolerate-source-errors", "Keep synchronizing the endpoints of the other sources when a source fails, using the last known endpoints of the failed source and skipping deletions while there are none (default: disabled)").BoolVar(&cfg.TolerateSourceErrors)
+	app.Flag("source-max-staleness", "The maximum age of the last known endpoints used for a failed source when tolerating source errors, 0 for no limit").Default(defaultConfig.SourceMaxStaleness.String()).DurationVar(&cfg.SourceMaxStaleness)
 
 	app.Flag("namespace", "Limit sources of endpoints to a specific namespace (default: all namespaces)").Default(defaultConfig.Namespace).StringVar(&cfg.Namespace)
 	app.Flag("annotation-filter", "Filter sources managed by external-dns via annotation using label selector semantics (default: all sources)").Default(defaultConfig.AnnotationFilter).StringVar(&cfg.AnnotationFilter)
@@ -325,17 +392,24 @@ func (cfg *Config) ParseFlags(args []string) error {
 	app.Flag("combine-fqdn-annotation", "Combine FQDN template and Annotations instead of overwriting").BoolVar(&cfg.CombineFQDNAndAnnotation)
 	app.Flag("ignore-hostname-annotation", "Ignore hostname annotation when generating DNS names, valid only when using fqdn-template is set (optional, default: false)").BoolVar(&cfg.IgnoreHostnameAnnotation)
 	app.Flag("ignore-ingress-tls-spec", "Ignore tls spec section in ingresses resources, applicable only for ingress sources (optional, default: false)").BoolVar(&cfg.IgnoreIngressTLSSpec)
+	app.Flag("ingress-class", "Only publish ingresses of this ingress class, taken from spec.ingressClassName or the kubernetes.io/ingress.class annotation; specify multiple times for multiple classes (optional, default: all ingress classes)").StringsVar(&cfg.IngressClassNames)
+	app.Flag("node-address-type", "The node address types to publish in order of preference, the addresses of the first type a node has are used; specify multiple times for multiple types (default: ExternalIP, InternalIP, options: ExternalIP, InternalIP, ExternalIPv6, InternalIPv6)").Default(defaultConfig.NodeAddressTypes...).EnumsVar(&cfg.NodeAddressTypes, "ExternalIP", "InternalIP", "ExternalIPv6", "InternalIPv6")
+	app.Flag("node-label-filter", "Only publish the nodes matching this label selector, applicable only for the node source (optional, default: all nodes)").Default(defaultConfig.NodeLabelFilter).StringVar(&cfg.NodeLabelFilter)
+	app.Flag("exclude-unavailable-nodes", "Skip nodes which are cordoned or not Ready, applicable only for the node source (optional, default: false)").BoolVar(&cfg.ExcludeUnavailableNodes)
 	app.Flag("compatibility", "Process annotation semantics from legacy implementations (optional, options: mate, molecule)").Default(defaultConfig.Compatibility).EnumVar(&cfg.Compatibility, "", "mate", "molecule")
 	app.Flag("publish-internal-services", "Allow external-dns to publish DNS records for ClusterIP services (optional)").BoolVar(&cfg.PublishInternal)
 	app.Flag("publish-host-ip", "Allow external-dns to publish host-ip for headless services (optional)").BoolVar(&cfg.PublishHostIP)
 	app.Flag("always-publish-not-ready-addresses", "Always publish also not ready addresses for headless services (optional)").BoolVar(&cfg.AlwaysPublishNotReadyAddresses)
+	app.Flag("use-endpoint-slices", "Publish headless services from their discovery.k8s.io EndpointSlices instead of Endpoints and pods (optional)").BoolVar(&cfg.UseEndpointSlices)
 	app.Flag("connector-source-server", "The server to connect for connector source, valid only when using connector source").Default(defaultConfig.ConnectorSourceServer).StringVar(&cfg.ConnectorSourceServer)
+	app.Flag("file-source-path", "A YAML or JSON file with endpoints in the format of a DNSEndpoint spec, or a directory containing such files; specify multiple times for multiple paths, valid only when using file source").StringsVar(&cfg.FileSourcePaths)
 	app.Flag("crd-source-apiversion", "API version of the CRD for crd source, e.g. `externaldns.k8s.io/v1alpha1`, valid only when using crd source").Default(defaultConfig.CRDSourceAPIVersion).StringVar(&cfg.CRDSourceAPIVersion)
 	app.Flag("crd-source-kind", "Kind of the CRD for the crd source in API group and version specified by crd-source-apiversion").Default(defaultConfig.CRDSourceKind).StringVar(&cfg.CRDSourceKind)
+	app.Flag("generic-crd-config", "Path to a YAML file with the rules of the generic-crd source, each naming a resource and the JSONPath expressions of its hostnames and targets, valid only when using generic-crd source").Default(defaultConfig.GenericCRDConfig).StringVar(&cfg.GenericCRDConfig)
 	app.Flag("service-type-filter", "The service types to take care about (default: all, expected: ClusterIP, NodePort, LoadBalancer or ExternalName)").StringsVar(&cfg.ServiceTypeFilter)
 
 	// Flags related to providers
-	app.Flag("provider", "The DNS provider where the DNS records will be created (required, options: wunderdns, aws, aws-sd, google, azure, azure-dns, azure-private-dns, cloudflare, rcodezero, digitalocean, hetzner, dnsimple, akamai, infoblox, dyn, designate, coredns, skydns, inmemory, ovh, pdns, oci, exoscale, linode, rfc2136, ns1, transip, vinyldns, rdns, scaleway, vultr, ultradns)").Required().PlaceHolder("provider").EnumVar(&cfg.Provider, "wunderdns", "aws", "aws-sd", "google", "azure", "azure-dns", "hetzner", "azure-private-dns", "alibabacloud", "cloudflare", "rcodezero", "digitalocean", "dnsimple", "akamai", "infoblox", "dyn", "designate", "coredns", "skydns", "inmemory", "ovh", "pdns", "oci", "exoscale", "linode", "rfc2136", "ns1", "transip", "vinyldns", "rdns", "scaleway", "vultr", "ultradns")
+	app.Flag("provider", "The DNS provider where the DNS records will be created (required, options: wunderdns, aws, aws-sd, google, azure, azure-dns, azure-private-dns, cloudflare, rcodezero, digitalocean, hetzner, dnsimple, akamai, infoblox, dyn, designate, coredns, coredns-configmap, skydns, inmemory, ovh, pdns, oci, exoscale, linode, rfc2136, zonefile, ns1, transip, vinyldns, rdns, scaleway, vultr, ultradns)").Required().PlaceHolder("provider").EnumVar(&cfg.Provider, "wunderdns", "aws", "aws-sd", "google", "azure", "azure-dns", "hetzner", "azure-private-dns", "alibabacloud", "cloudflare", "rcodezero", "digitalocean", "dnsimple", "akamai", "infoblox", "dyn", "designate", "coredns", "coredns-configmap", "skydns", "inmemory", "ovh", "pdns", "oci", "exoscale", "linode", "rfc2136", "zonefile", "ns1", "transip", "vinyldns", "rdns", "scaleway", "vultr", "ultradns")
 	app.Flag("domain-filter", "Limit possible target zones by a domain suffix; specify multiple times for multiple domains (optional)").Default("").StringsVar(&cfg.DomainFilter)
 	app.Flag("exclude-domains", "Exclude subdomains (optional)").Default("").StringsVar(&cfg.ExcludeDomains)
 	app.Flag("zone-name-filter", "Filter target zones by zone domain (For now, only AzureDNS provider is using this flag); specify multiple times for multiple zones (optional)").Default("").StringsVar(&cfg.ZoneNameFilter)
@@ -361,6 +435,9 @@ func (cfg *Config) ParseFlags(args []string) error {
 	app.Flag("cloudflare-proxied", "When using the Cloudflare provider, specify if the proxy mode must be enabled (default: disabled)").BoolVar(&cfg.CloudflareProxied)
 	app.Flag("cloudflare-zones-per-page", "When using the Cloudflare provider, specify how many zones per page listed, max. possible 50 (default: 50)").Default(strconv.Itoa(defaultConfig.CloudflareZonesPerPage)).IntVar(&cfg.CloudflareZonesPerPage)
 	app.Flag("coredns-prefix", "When using the CoreDNS provider, specify the prefix name").Default(defaultConfig.CoreDNSPrefix).StringVar(&cfg.CoreDNSPrefix)
+	app.Flag("coredns-configmap", "When using the CoreDNS ConfigMap provider, specify the ConfigMap as <namespace>/<name>").Default(defaultConfig.CoreDNSConfigMap).StringVar(&cfg.CoreDNSConfigMap)
+	app.Flag("coredns-configmap-key", "When using the CoreDNS ConfigMap provider, specify the key of the ConfigMap containing the hosts or zone file block").Default(defaultConfig.CoreDNSConfigMapKey).StringVar(&cfg.CoreDNSConfigMapKey)
+	app.Flag("coredns-configmap-format", "When using the CoreDNS ConfigMap provider, specify the format of the block, either a hosts plugin block supporting A and AAAA records or a zone file (default: hosts, options: hosts, zone)").Default(defaultConfig.CoreDNSConfigMapFormat).EnumVar(&cfg.CoreDNSConfigMapFormat, "hosts", "zone")
 	app.Flag("akamai-serviceconsumerdomain", "When using the Akamai provider, specify the base URL (required when --provider=akamai)").Default(defaultConfig.AkamaiServiceConsumerDomain).StringVar(&cfg.AkamaiServiceConsumerDomain)
 	app.Flag("akamai-client-token", "When using the Akamai provider, specify the client token (required when --provider=akamai)").Default(defaultConfig.AkamaiClientToken).StringVar(&cfg.AkamaiClientToken)
 	app.Flag("akamai-client-secret", "When using the Akamai provider, specify the client secret (required when --provider=akamai)").Default(defaultConfig.AkamaiClientSecret).StringVar(&cfg.AkamaiClientSecret)
@@ -376,10 +453,12 @@ func (cfg *Config) ParseFlags(args []string) error {
 	app.Flag("dyn-customer-name", "When using the Dyn provider, specify the Customer Name").Default("").StringVar(&cfg.DynCustomerName)
 	app.Flag("dyn-username", "When using the Dyn provider, specify the Username").Default("").StringVar(&cfg.DynUsername)
 	app.Flag("dyn-password", "When using the Dyn provider, specify the pasword").Default("").StringVar(&cfg.DynPassword)
-	app.Flag("dyn-min-ttl", "Minimal TTL (in seconds) for records. This value will be used if the provided TTL for a service/ingress is lower than this.").IntVar(&cfg.DynMinTTLSeconds)
+	app.Flag("dyn-min-ttl", "Minimal TTL (in seconds) for records. This value will be used if the provided TTL for a service/ingress is lower than this. Alias of --min-ttl when using the Dyn provider.").IntVar(&cfg.DynMinTTLSeconds)
 	app.Flag("oci-config-file", "When using the OCI provider, specify the OCI configuration file (required when --provider=oci").Default(defaultConfig.OCIConfigFile).StringVar(&cfg.OCIConfigFile)
 	app.Flag("rcodezero-txt-encrypt", "When using the Rcodezero provider with txt registry option, set if TXT rrs are encrypted (default: false)").Default(strconv.FormatBool(defaultConfig.RcodezeroTXTEncrypt)).BoolVar(&cfg.RcodezeroTXTEncrypt)
 	app.Flag("inmemory-zone", "Provide a list of pre-configured zones for the inmemory provider; specify multiple times for multiple zones (optional)").Default("").StringsVar(&cfg.InMemoryZones)
+	app.Flag("inmemory-persistence-file", "When using the inmemory provider, keep the zones in this JSON file, which is loaded on start and saved after every change (optional)").Default(defaultConfig.InMemoryPersistenceFile).StringVar(&cfg.InMemoryPersistenceFile)
+	app.Flag("inmemory-dns-port", "When using the inmemory provider, serve the zones as authoritative DNS server via UDP and TCP on this port (default: disabled)").Default(strconv.Itoa(defaultConfig.InMemoryDNSPort)).IntVar(&cfg.InMemoryDNSPort)
 	app.Flag("ovh-endpoint", "When using the OVH provider, specify the endpoint (default: ovh-eu)").Default(defaultConfig.OVHEndpoint).StringVar(&cfg.OVHEndpoint)
 	app.Flag("ovh-api-rate-limit", "When using the OVH provider, specify the API request rate limit, X operations by seconds (default: 20)").Default(strconv.Itoa(defaultConfig.OVHApiRateLimit)).IntVar(&cfg.OVHApiRateLimit)
 	app.Flag("pdns-server", "When using the PowerDNS/PDNS provider, specify the URL to the pdns server (required when --provider=pdns)").Default(defaultConfig.PDNSServer).StringVar(&cfg.PDNSServer)
@@ -387,7 +466,7 @@ func (cfg *Config) ParseFlags(args []string) error {
 	app.Flag("pdns-tls-enabled", "When using the PowerDNS/PDNS provider, specify whether to use TLS (default: false, requires --tls-ca, optionally specify --tls-client-cert and --tls-client-cert-key)").Default(strconv.FormatBool(defaultConfig.PDNSTLSEnabled)).BoolVar(&cfg.PDNSTLSEnabled)
 	app.Flag("ns1-endpoint", "When using the NS1 provider, specify the URL of the API endpoint to target (default: https://api.nsone.net/v1/)").Default(defaultConfig.NS1Endpoint).StringVar(&cfg.NS1Endpoint)
 	app.Flag("ns1-ignoressl", "When using the NS1 provider, specify whether to verify the SSL certificate (default: false)").Default(strconv.FormatBool(defaultConfig.NS1IgnoreSSL)).BoolVar(&cfg.NS1IgnoreSSL)
-	app.Flag("ns1-min-ttl", "Minimal TTL (in seconds) for records. This value will be used if the provided TTL for a service/ingress is lower than this.").IntVar(&cfg.NS1MinTTLSeconds)
+	app.Flag("ns1-min-ttl", "Minimal TTL (in seconds) for records. This value will be used if the provided TTL for a service/ingress is lower than this. Alias of --min-ttl when using the NS1 provider.").IntVar(&cfg.NS1MinTTLSeconds)
 	app.Flag("digitalocean-api-page-size", "Configure the page size used when querying the DigitalOcean API.").Default(strconv.Itoa(defaultConfig.DigitalOceanAPIPageSize)).IntVar(&cfg.DigitalOceanAPIPageSize)
 
 	// Flags related to TLS communication
@@ -400,15 +479,22 @@ func (cfg *Config) ParseFlags(args []string) error {
 	app.Flag("exoscale-apisecret", "Provide your API Secret for the Exoscale provider").Default(defaultConfig.ExoscaleAPISecret).StringVar(&cfg.ExoscaleAPISecret)
 
 	// Flags related to RFC2136 provider
-	app.Flag("rfc2136-host", "When using the RFC2136 provider, specify the host of the DNS server").Default(defaultConfig.RFC2136Host).StringVar(&cfg.RFC2136Host)
+	app.Flag("rfc2136-host", "When using the RFC2136 provider, specify the host of the DNS server, optionally with its port; specify multiple times for multiple servers which are tried in the given order").StringsVar(&cfg.RFC2136Hosts)
 	app.Flag("rfc2136-port", "When using the RFC2136 provider, specify the port of the DNS server").Default(strconv.Itoa(defaultConfig.RFC2136Port)).IntVar(&cfg.RFC2136Port)
-	app.Flag("rfc2136-zone", "When using the RFC2136 provider, specify the zone entry of the DNS server to use").Default(defaultConfig.RFC2136Zone).StringVar(&cfg.RFC2136Zone)
+	app.Flag("rfc2136-zone", "When using the RFC2136 provider, specify the zone entry of the DNS server to use; specify multiple times for multiple zones").StringsVar(&cfg.RFC2136Zones)
+	app.Flag("rfc2136-zone-tsig", "When using the RFC2136 provider, specify the TSIG key of a zone as <zone>:<keyname>:<alg>:<secret>, overriding the TSIG key given by --rfc2136-tsig-keyname for that zone; specify multiple times for multiple zones").StringsVar(&cfg.RFC2136ZoneTSIGKeys)
 	app.Flag("rfc2136-insecure", "When using the RFC2136 provider, specify whether to attach TSIG or not (default: false, requires --rfc2136-tsig-keyname and rfc2136-tsig-secret)").Default(strconv.FormatBool(defaultConfig.RFC2136Insecure)).BoolVar(&cfg.RFC2136Insecure)
 	app.Flag("rfc2136-tsig-keyname", "When using the RFC2136 provider, specify the TSIG key to attached to DNS messages (required when --rfc2136-insecure=false)").Default(defaultConfig.RFC2136TSIGKeyName).StringVar(&cfg.RFC2136TSIGKeyName)
 	app.Flag("rfc2136-tsig-secret", "When using the RFC2136 provider, specify the TSIG (base64) value to attached to DNS messages (required when --rfc2136-insecure=false)").Default(defaultConfig.RFC2136TSIGSecret).StringVar(&cfg.RFC2136TSIGSecret)
 	app.Flag("rfc2136-tsig-secret-alg", "When using the RFC2136 provider, specify the TSIG (base64) value to attached to DNS messages (required when --rfc2136-insecure=false)").Default(defaultConfig.RFC2136TSIGSecretAlg).StringVar(&cfg.RFC2136TSIGSecretAlg)
 	app.Flag("rfc2136-tsig-axfr", "When using the RFC2136 provider, specify the TSIG (base64) value to attached to DNS messages (required when --rfc2136-insecure=false)").BoolVar(&cfg.RFC2136TAXFR)
-	app.Flag("rfc2136-min-ttl", "When using the RFC2136 provider, specify minimal TTL (in duration format) for records. This value will be used if the provided TTL for a service/ingress is lower than this").Default(defaultConfig.RFC2136MinTTL.String()).DurationVar(&cfg.RFC2136MinTTL)
+	app.Flag("rfc2136-min-ttl", "When using the RFC2136 provider, specify minimal TTL (in duration format) for records. This value will be used if the provided TTL for a service/ingress is lower than this. Alias of --min-ttl when using the RFC2136 provider.").Default(defaultConfig.RFC2136MinTTL.String()).DurationVar(&cfg.RFC2136MinTTL)
+
+	// Flags related to zonefile provider
+	app.Flag("zonefile-directory", "When using the zonefile provider, specify the directory containing the zone files named <zone>.zone (required when --provider=zonefile)").Default(defaultConfig.ZonefileDirectory).StringVar(&cfg.ZonefileDirectory)
+	app.Flag("zonefile-zone", "When using the zonefile provider, specify a zone whose zone file is maintained; specify multiple times for multiple zones (required when --provider=zonefile)").StringsVar(&cfg.ZonefileZones)
+	app.Flag("zonefile-reload-command", "When using the zonefile provider, specify a shell command which is run after the zone files were changed, e.g. 'rndc reload' (optional)").Default(defaultConfig.ZonefileReloadCommand).StringVar(&cfg.ZonefileReloadCommand)
+
 	// Flags related to WunderDNS provider
 	app.Flag("wunderdns-url", "WunderDNS HTTP API  URL").Default(defaultConfig.WunderDNSUrl).StringVar(&cfg.WunderDNSUrl)
 	app.Flag("wunderdns-token", "WunderDNS HTTP API  Token").Default(defaultConfig.WunderDNSToken).StringVar(&cfg.WunderDNSToken)
@@ -421,15 +507,22 @@ func (cfg *Config) ParseFlags(args []string) error {
 
 	// Flags related to policies
 	app.Flag("policy", "Modify how DNS records are synchronized between sources and providers (default: sync, options: sync, upsert-only, create-only)").Default(defaultConfig.Policy).EnumVar(&cfg.Policy, "sync", "upsert-only", "create-only")
+	app.Flag("default-ttl", "The TTL of records without a TTL annotation in duration format, the default of the provider is used if 0s (default: 0s)").Default(defaultConfig.DefaultTTL.String()).DurationVar(&cfg.DefaultTTL)
+	app.Flag("min-ttl", "The minimum TTL of records in duration format, higher TTLs are used instead of lower ones (default: 0s, no minimum)").Default(defaultConfig.MinTTL.String()).DurationVar(&cfg.MinTTL)
+	app.Flag("max-ttl", "The maximum TTL of records in duration format, lower TTLs are used instead of higher ones (default: 0s, no maximum)").Default(defaultConfig.MaxTTL.String()).DurationVar(&cfg.MaxTTL)
+	app.Flag("domain-ttl-policy", "Override --default-ttl, --min-ttl and --max-ttl for the records of a domain and its subdomains, e.g. `example.org:default=5m,min=1m,max=1h`; specify multiple times for multiple domains, the policy of the longest matching domain wins (optional)").StringsVar(&cfg.DomainTTLPolicies)
 
 	// Flags related to the registry
 	app.Flag("registry", "The registry implementation to use to keep track of DNS record ownership (default: txt, options: txt, noop, aws-sd)").Default(defaultConfig.Registry).EnumVar(&cfg.Registry, "txt", "noop", "aws-sd")
 	app.Flag("txt-owner-id", "When using the TXT registry, a name that identifies this instance of ExternalDNS (default: default)").Default(defaultConfig.TXTOwnerID).StringVar(&cfg.TXTOwnerID)
 	app.Flag("txt-prefix", "When using the TXT registry, a custom string that's prefixed to each ownership DNS record (optional). Mutual exclusive with txt-suffix!").Default(defaultConfig.TXTPrefix).StringVar(&cfg.TXTPrefix)
 	app.Flag("txt-suffix", "When using the TXT registry, a custom string that's suffixed to the host portion of each ownership DNS record (optional). Mutual exclusive with txt-prefix!").Default(defaultConfig.TXTSuffix).StringVar(&cfg.TXTSuffix)
+	app.Flag("txt-shared-ownership", "When using the TXT registry, allow several instances to publish their targets for the same DNS name; each instance only adds and removes its own targets (default: disabled)").BoolVar(&cfg.TXTSharedOwnership)
+	app.Flag("drift-policy", "When using the TXT registry, detect owned records changed outside of ExternalDNS and either revert the change, adopt it until the source changes or only alert about it (default: disabled, options: disabled, revert, adopt, alert)").Default(defaultConfig.DriftPolicy).EnumVar(&cfg.DriftPolicy, "disabled", "revert", "adopt", "alert")
 
 	// Flags related to the main control loop
-	app.Flag("txt-cache-interval", "The interval between cache synchronizations in duration format (default: disabled)").Default(defaultConfig.TXTCacheInterval.String()).DurationVar(&cfg.TXTCacheInterval)
+	app.Flag("txt-cache-interval", "The interval between cache synchronizations in duration format, the cache is refreshed in the background and dropped when applying changes fails (default: disabled)").Default(defaultConfig.TXTCacheInterval.String()).DurationVar(&cfg.TXTCacheInterval)
+	app.Flag("txt-gc-interval", "When using the TXT registry, the interval between deletions of ownership records without a matching DNS record in duration format (default: disabled)").Default(defaultConfig.TXTGCInterval.String()).DurationVar(&cfg.TXTGCInterval)
 	app.Flag("interval", "The interval between two consecutive synchronizations in duration format (default: 1m)").Default(defaultConfig.Interval.String()).DurationVar(&cfg.Interval)
 	app.Flag("once", "When enabled, exits the synchronization loop after the first iteration (default: disabled)").BoolVar(&cfg.Once)
 	app.Flag("dry-run", "When enabled, prints DNS record changes rather than actually performing them (default: disabled)").BoolVar(&cfg.DryRun)
@@ -440,10 +533,23 @@ func (cfg *Config) ParseFlags(args []string) error {
 	app.Flag("metrics-address", "Specify where to serve the metrics and health check endpoint (default: :7979)").Default(defaultConfig.MetricsAddress).StringVar(&cfg.MetricsAddress)
 	app.Flag("log-level", "Set the level of logging. (default: info, options: panic, debug, info, warning, error, fatal").Default(defaultConfig.LogLevel).EnumVar(&cfg.LogLevel, allLogLevelsAsStrings()...)
 
-	_, err := app.Parse(args)
+	command, err := app.Parse(args)
 	if err != nil {
 		return err
 	}
+	cfg.Command = command
+
+	// the minimum TTLs of the providers are aliases of --min-ttl
+	if cfg.MinTTL == 0 {
+		switch cfg.Provider {
+		case "dyn":
+			cfg.MinTTL = time.Duration(cfg.DynMinTTLSeconds) * time.Second
+		case "ns1":
+			cfg.MinTTL = time.Duration(cfg.NS1MinTTLSeconds) * time.Second
+		case "rfc2136":
+			cfg.MinTTL = cfg.RFC2136MinTTL
+		}
+	}
 
 	return nil
 }
@@ -33,8 +33,10 @@ var (
 		KubeConfig:                  "",
 		RequestTimeout:              time.Second * 30,
 		ContourLoadBalancerService:  "heptio-contour/contour",
+		TraefikLoadBalancerService:  "traefik/traefik",
 		SkipperRouteGroupVersion:    "zalando.org/v1",
 		Sources:                     []string{"service"},
+		SourceMaxStaleness:          time.Hour,
 		Namespace:                   "",
 		FQDNTemplate:                "",
 		Compatibility:               "",
@@ -53,6 +55,8 @@ var (
 		AWSBatchChangeSize:          1000,
 		AWSBatchChangeInterval:      time.Second,
 		AWSEvaluateTargetHealth:     true,
+		NodeAddressTypes:            []string{"ExternalIP", "InternalIP"},
+		ExcludeUnavailableNodes:     false,
 		AWSAPIRetries:               3,
 		AWSPreferCNAME:              false,
 		AWSZoneCacheDuration:        0 * time.Second,
@@ -62,6 +66,9 @@ var (
 		CloudflareProxied:           false,
 		CloudflareZonesPerPage:      50,
 		CoreDNSPrefix:               "/skydns/",
+		CoreDNSConfigMap:            "kube-system/coredns",
+		CoreDNSConfigMapKey:         "Corefile",
+		CoreDNSConfigMapFormat:      "hosts",
 		AkamaiServiceConsumerDomain: "",
 		AkamaiClientToken:           "",
 		AkamaiClientSecret:          "",
@@ -85,6 +92,8 @@ var (
 		TXTOwnerID:                  "default",
 		TXTPrefix:                   "",
 		TXTCacheInterval:            0,
+		DriftPolicy:                 "disabled",
+		Command:                     "run",
 		Interval:                    time.Minute,
 		Once:                        false,
 		DryRun:                      false,
@@ -98,6 +107,7 @@ var (
 		ExoscaleAPISecret:           "",
 		CRDSourceAPIVersion:         "externaldns.k8s.io/v1alpha1",
 		CRDSourceKind:               "DNSEndpoint",
+		GenericCRDConfig:            "",
 		RcodezeroTXTEncrypt:         false,
 		TransIPAccountName:          "",
 		TransIPPrivateKeyFile:       "",
@@ -112,11 +122,18 @@ var (
 		KubeConfig:                  "/some/path",
 		RequestTimeout:              time.Second * 77,
 		ContourLoadBalancerService:  "heptio-contour-other/contour-other",
+		TraefikLoadBalancerService:  "traefik-other/traefik-other",
 		SkipperRouteGroupVersion:    "zalando.org/v2",
 		Sources:                     []string{"service", "ingress", "connector"},
+		TolerateSourceErrors:        true,
+		SourceMaxStaleness:          10 * time.Minute,
 		Namespace:                   "namespace",
 		IgnoreHostnameAnnotation:    true,
 		IgnoreIngressTLSSpec:        true,
+		IngressClassNames:           []string{"internal", "external"},
+		NodeAddressTypes:            []string{"InternalIPv6", "InternalIP"},
+		NodeLabelFilter:             "node-role.kubernetes.io/edge=",
+		ExcludeUnavailableNodes:     true,
 		FQDNTemplate:                "{{.Name}}.service.example.com",
 		Compatibility:               "mate",
 		Provider:                    "google",
@@ -143,6 +160,9 @@ var (
 		CloudflareProxied:           true,
 		CloudflareZonesPerPage:      20,
 		CoreDNSPrefix:               "/coredns/",
+		CoreDNSConfigMap:            "dns/coredns-hosts",
+		CoreDNSConfigMapKey:         "example.org.zone",
+		CoreDNSConfigMapFormat:      "zone",
 		AkamaiServiceConsumerDomain: "oooo-xxxxxxxxxxxxxxxx-xxxxxxxxxxxxxxxx.luna.akamaiapis.net",
 		AkamaiClientToken:           "o184671d5307a388180fbf7f11dbdf46",
 		AkamaiClientSecret:          "o184671d5307a388180fbf7f11dbdf46",
@@ -157,6 +177,8 @@ var (
 		InfobloxMaxResults:          2000,
 		OCIConfigFile:               "oci.yaml",
 		InMemoryZones:               []string{"example.org", "company.com"},
+		InMemoryPersistenceFile:     "/var/lib/external-dns/zones.json",
+		InMemoryDNSPort:             5353,
 		OVHEndpoint:                 "ovh-ca",
 		OVHApiRateLimit:             42,
 		PDNSServer:                  "http://ns.example.com:8081",
@@ -166,10 +188,18 @@ var (
 		TLSClientCert:               "/path/to/cert.pem",
 		TLSClientCertKey:            "/path/to/key.pem",
 		Policy:                      "upsert-only",
+		DefaultTTL:                  5 * time.Minute,
+		MinTTL:                      time.Minute,
+		MaxTTL:                      time.Hour,
+		DomainTTLPolicies:           []string{"example.org:min=30s", "dev.example.org:default=1m,max=5m"},
 		Registry:                    "noop",
 		TXTOwnerID:                  "owner-1",
 		TXTPrefix:                   "associated-txt-record",
 		TXTCacheInterval:            12 * time.Hour,
+		TXTGCInterval:               6 * time.Hour,
+		TXTSharedOwnership:          true,
+		DriftPolicy:                 "alert",
+		Command:                     "run",
 		Interval:                    10 * time.Minute,
 		Once:                        true,
 		DryRun:                      true,
@@ -183,6 +213,7 @@ var (
 		ExoscaleAPISecret:           "2",
 		CRDSourceAPIVersion:         "test.k8s.io/v1alpha1",
 		CRDSourceKind:               "Endpoint",
+		GenericCRDConfig:            "/etc/external-dns/generic-crd.yaml",
 		RcodezeroTXTEncrypt:         true,
 		NS1Endpoint:                 "https://api.example.com/v1",
 		NS1IgnoreSSL:                true,
@@ -218,14 +249,23 @@ func TestParseFlags(t *testing.T) {
 				"--kubeconfig=/some/path",
 				"--request-timeout=77s",
 				"--contour-load-balancer=heptio-contour-other/contour-other",
+				"--traefik-load-balancer=traefik-other/traefik-other",
 				"--skipper-routegroup-groupversion=zalando.org/v2",
 				"--source=service",
 				"--source=ingress",
 				"--source=connector",
+				"--tolerate-source-errors",
+				"--source-max-staleness=10m",
 				"--namespace=namespace",
 				"--fqdn-template={{.Name}}.service.example.com",
 				"--ignore-hostname-annotation",
 				"--ignore-ingress-tls-spec",
+				"--ingress-class=internal",
+				"--ingress-class=external",
+				"--node-address-type=InternalIPv6",
+				"--node-address-type=InternalIP",
+				"--node-label-filter=node-role.kubernetes.io/edge=",
+				"--exclude-unavailable-nodes",
 				"--compatibility=mate",
 				"--provider=google",
 				"--google-project=project",
@@ -237,6 +277,9 @@ func TestParseFlags(t *testing.T) {
 				"--cloudflare-proxied",
 				"--cloudflare-zones-per-page=20",
 				"--coredns-prefix=/coredns/",
+				"--coredns-configmap=dns/coredns-hosts",
+				"--coredns-configmap-key=example.org.zone",
+				"--coredns-configmap-format=zone",
 				"--akamai-serviceconsumerdomain=oooo-xxxxxxxxxxxxxxxx-xxxxxxxxxxxxxxxx.luna.akamaiapis.net",
 				"--akamai-client-token=o184671d5307a388180fbf7f11dbdf46",
 				"--akamai-client-secret=o184671d5307a388180fbf7f11dbdf46",
@@ -250,6 +293,8 @@ func TestParseFlags(t *testing.T) {
 				"--infoblox-max-results=2000",
 				"--inmemory-zone=example.org",
 				"--inmemory-zone=company.com",
+				"--inmemory-persistence-file=/var/lib/external-dns/zones.json",
+				"--inmemory-dns-port=5353",
 				"--ovh-endpoint=ovh-ca",
 				"--ovh-api-rate-limit=42",
 				"--pdns-server=http://ns.example.com:8081",
@@ -278,10 +323,18 @@ func TestParseFlags(t *testing.T) {
 				"--aws-zones-cache-duration=10s",
 				"--no-aws-evaluate-target-health",
 				"--policy=upsert-only",
+				"--default-ttl=5m",
+				"--min-ttl=1m",
+				"--max-ttl=1h",
+				"--domain-ttl-policy=example.org:min=30s",
+				"--domain-ttl-policy=dev.example.org:default=1m,max=5m",
 				"--registry=noop",
 				"--txt-owner-id=owner-1",
 				"--txt-prefix=associated-txt-record",
 				"--txt-cache-interval=12h",
+				"--txt-gc-interval=6h",
+				"--txt-shared-ownership",
+				"--drift-policy=alert",
 				"--interval=10m",
 				"--once",
 				"--dry-run",
@@ -295,6 +348,7 @@ func TestParseFlags(t *testing.T) {
 				"--exoscale-apisecret=2",
 				"--crd-source-apiversion=test.k8s.io/v1alpha1",
 				"--crd-source-kind=Endpoint",
+				"--generic-crd-config=/etc/external-dns/generic-crd.yaml",
 				"--rcodezero-txt-encrypt",
 				"--ns1-endpoint=https://api.example.com/v1",
 				"--ns1-ignoressl",
@@ -316,12 +370,19 @@ func TestParseFlags(t *testing.T) {
 				"EXTERNAL_DNS_KUBECONFIG":                      "/some/path",
 				"EXTERNAL_DNS_REQUEST_TIMEOUT":                 "77s",
 				"EXTERNAL_DNS_CONTOUR_LOAD_BALANCER":           "heptio-contour-other/contour-other",
+				"EXTERNAL_DNS_TRAEFIK_LOAD_BALANCER":           "traefik-other/traefik-other",
 				"EXTERNAL_DNS_SKIPPER_ROUTEGROUP_GROUPVERSION": "zalando.org/v2",
 				"EXTERNAL_DNS_SOURCE":                          "service\ningress\nconnector",
+				"EXTERNAL_DNS_TOLERATE_SOURCE_ERRORS":          "1",
+				"EXTERNAL_DNS_SOURCE_MAX_STALENESS":            "10m",
 				"EXTERNAL_DNS_NAMESPACE":                       "namespace",
 				"EXTERNAL_DNS_FQDN_TEMPLATE":                   "{{.Name}}.service.example.com",
 				"EXTERNAL_DNS_IGNORE_HOSTNAME_ANNOTATION":      "1",
 				"EXTERNAL_DNS_IGNORE_INGRESS_TLS_SPEC":         "1",
+				"EXTERNAL_DNS_INGRESS_CLASS":                   "internal\nexternal",
+				"EXTERNAL_DNS_NODE_ADDRESS_TYPE":               "InternalIPv6\nInternalIP",
+				"EXTERNAL_DNS_NODE_LABEL_FILTER":               "node-role.kubernetes.io/edge=",
+				"EXTERNAL_DNS_EXCLUDE_UNAVAILABLE_NODES":       "1",
 				"EXTERNAL_DNS_COMPATIBILITY":                   "mate",
 				"EXTERNAL_DNS_PROVIDER":                        "google",
 				"EXTERNAL_DNS_GOOGLE_PROJECT":                  "project",
@@ -333,6 +394,9 @@ func TestParseFlags(t *testing.T) {
 				"EXTERNAL_DNS_CLOUDFLARE_PROXIED":              "1",
 				"EXTERNAL_DNS_CLOUDFLARE_ZONES_PER_PAGE":       "20",
 				"EXTERNAL_DNS_COREDNS_PREFIX":                  "/coredns/",
+				"EXTERNAL_DNS_COREDNS_CONFIGMAP":               "dns/coredns-hosts",
+				"EXTERNAL_DNS_COREDNS_CONFIGMAP_KEY":           "example.org.zone",
+				"EXTERNAL_DNS_COREDNS_CONFIGMAP_FORMAT":        "zone",
 				"EXTERNAL_DNS_AKAMAI_SERVICECONSUMERDOMAIN":    "oooo-xxxxxxxxxxxxxxxx-xxxxxxxxxxxxxxxx.luna.akamaiapis.net",
 				"EXTERNAL_DNS_AKAMAI_CLIENT_TOKEN":             "o184671d5307a388180fbf7f11dbdf46",
 				"EXTERNAL_DNS_AKAMAI_CLIENT_SECRET":            "o184671d5307a388180fbf7f11dbdf46",
@@ -347,6 +411,8 @@ func TestParseFlags(t *testing.T) {
 				"EXTERNAL_DNS_INFOBLOX_MAX_RESULTS":            "2000",
 				"EXTERNAL_DNS_OCI_CONFIG_FILE":                 "oci.yaml",
 				"EXTERNAL_DNS_INMEMORY_ZONE":                   "example.org\ncompany.com",
+				"EXTERNAL_DNS_INMEMORY_PERSISTENCE_FILE":       "/var/lib/external-dns/zones.json",
+				"EXTERNAL_DNS_INMEMORY_DNS_PORT":               "5353",
 				"EXTERNAL_DNS_OVH_ENDPOINT":                    "ovh-ca",
 				"EXTERNAL_DNS_OVH_API_RATE_LIMIT":              "42",
 				"EXTERNAL_DNS_DOMAIN_FILTER":                   "example.org\ncompany.com",
@@ -370,10 +436,17 @@ func TestParseFlags(t *testing.T) {
 				"EXTERNAL_DNS_AWS_PREFER_CNAME":                "true",
 				"EXTERNAL_DNS_AWS_ZONES_CACHE_DURATION":        "10s",
 				"EXTERNAL_DNS_POLICY":                          "upsert-only",
+				"EXTERNAL_DNS_DEFAULT_TTL":                     "5m",
+				"EXTERNAL_DNS_MIN_TTL":                         "1m",
+				"EXTERNAL_DNS_MAX_TTL":                         "1h",
+				"EXTERNAL_DNS_DOMAIN_TTL_POLICY":               "example.org:min=30s\ndev.example.org:default=1m,max=5m",
 				"EXTERNAL_DNS_REGISTRY":                        "noop",
 				"EXTERNAL_DNS_TXT_OWNER_ID":                    "owner-1",
 				"EXTERNAL_DNS_TXT_PREFIX":                      "associated-txt-record",
 				"EXTERNAL_DNS_TXT_CACHE_INTERVAL":              "12h",
+				"EXTERNAL_DNS_TXT_GC_INTERVAL":                 "6h",
+				"EXTERNAL_DNS_TXT_SHARED_OWNERSHIP":            "1",
+				"EXTERNAL_DNS_DRIFT_POLICY":                    "alert",
 				"EXTERNAL_DNS_INTERVAL":                        "10m",
 				"EXTERNAL_DNS_ONCE":                            "1",
 				"EXTERNAL_DNS_DRY_RUN":                         "1",
@@ -387,6 +460,7 @@ func TestParseFlags(t *testing.T) {
 				"EXTERNAL_DNS_EXOSCALE_APISECRET":              "2",
 				"EXTERNAL_DNS_CRD_SOURCE_APIVERSION":           "test.k8s.io/v1alpha1",
 				"EXTERNAL_DNS_CRD_SOURCE_KIND":                 "Endpoint",
+				"EXTERNAL_DNS_GENERIC_CRD_CONFIG":              "/etc/external-dns/generic-crd.yaml",
 				"EXTERNAL_DNS_RCODEZERO_TXT_ENCRYPT":           "1",
 				"EXTERNAL_DNS_NS1_ENDPOINT":                    "https://api.example.com/v1",
 				"EXTERNAL_DNS_NS1_IGNORESSL":                   "1",
@@ -411,6 +485,19 @@ func TestParseFlags(t *testing.T) {
 	}
 }
 
+func TestParseCommand(t *testing.T) {
+	cfg := NewConfig()
+	require.NoError(t, cfg.ParseFlags([]string{"gc", "--source=service", "--provider=google"}))
+	assert.Equal(t, "gc", cfg.Command)
+
+	cfg = NewConfig()
+	require.NoError(t, cfg.ParseFlags([]string{"run", "--source=service", "--provider=google"}))
+	assert.Equal(t, "run", cfg.Command)
+
+	cfg = NewConfig()
+	require.Error(t, cfg.ParseFlags([]string{"unknown", "--source=service", "--provider=google"}))
+}
+
 // helper functions
 
 func setEnv(t *testing.T, env map[string]string) map[string]string {
@@ -436,6 +523,7 @@ func TestPasswordsNotLogged(t *testing.T) {
 		InfobloxWapiPassword: "infoblox-pass",
 		PDNSAPIKey:           "pdns-api-key",
 		RFC2136TSIGSecret:    "tsig-secret",
+		RFC2136ZoneTSIGKeys:  []string{"example.org:key:hmac-sha256:zone-tsig-secret"},
 	}
 
 	s := cfg.String()
@@ -444,4 +532,25 @@ func TestPasswordsNotLogged(t *testing.T) {
 	assert.False(t, strings.Contains(s, "infoblox-pass"))
 	assert.False(t, strings.Contains(s, "pdns-api-key"))
 	assert.False(t, strings.Contains(s, "tsig-secret"))
+	assert.False(t, strings.Contains(s, "zone-tsig-secret"))
+	assert.Equal(t, []string{"example.org:key:hmac-sha256:zone-tsig-secret"}, cfg.RFC2136ZoneTSIGKeys)
+}
+
+func TestMinTTLAliases(t *testing.T) {
+	for _, tc := range []struct {
+		args     []string
+		expected time.Duration
+	}{
+		{args: []string{"--provider=dyn", "--dyn-min-ttl=60"}, expected: time.Minute},
+		{args: []string{"--provider=ns1", "--ns1-min-ttl=120"}, expected: 2 * time.Minute},
+		{args: []string{"--provider=rfc2136", "--rfc2136-min-ttl=5m"}, expected: 5 * time.Minute},
+		{args: []string{"--provider=rfc2136", "--rfc2136-min-ttl=5m", "--min-ttl=10m"}, expected: 10 * time.Minute},
+		{args: []string{"--provider=google", "--rfc2136-min-ttl=5m"}, expected: 0},
+	} {
+		t.Run(strings.Join(tc.args, " "), func(t *testing.T) {
+			cfg := NewConfig()
+			require.NoError(t, cfg.ParseFlags(append([]string{"--source=service"}, tc.args...)))
+			assert.Equal(t, tc.expected, cfg.MinTTL)
+		})
+	}
 }
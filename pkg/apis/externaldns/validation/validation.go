@@ -88,6 +88,19 @@ func ValidateConfig(cfg *externaldns.Config) error {
 		}
 	}
 
+	if cfg.Provider == "coredns-configmap" {
+		if cfg.CoreDNSConfigMapFormat == "hosts" && cfg.Registry == "txt" {
+			return errors.New("the hosts format of the CoreDNS ConfigMap provider can't store the TXT records of --registry=txt, use --registry=noop or --coredns-configmap-format=zone")
+		}
+	}
+
+	if cfg.DefaultTTL < 0 || cfg.MinTTL < 0 || cfg.MaxTTL < 0 {
+		return errors.New("TTL specified by --default-ttl, --min-ttl or --max-ttl is negative")
+	}
+	if cfg.MinTTL > 0 && cfg.MaxTTL > 0 && cfg.MinTTL > cfg.MaxTTL {
+		return errors.New("min-ttl is higher than max-ttl")
+	}
+
 	if cfg.IgnoreHostnameAnnotation && cfg.FQDNTemplate == "" {
 		return errors.New("FQDN Template must be set if ignoring annotations")
 	}
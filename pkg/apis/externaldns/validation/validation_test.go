@@ -18,6 +18,7 @@ package validation
 
 import (
 	"testing"
+	"time"
 
 	"sigs.k8s.io/external-dns/pkg/apis/externaldns"
 
@@ -150,3 +151,55 @@ func TestValidateGoodRfc2136Config(t *testing.T) {
 
 	assert.Nil(t, err)
 }
+
+func TestValidateCoreDNSConfigMapConfig(t *testing.T) {
+	for _, tc := range []struct {
+		format, registry string
+		valid            bool
+	}{
+		{format: "hosts", registry: "txt"},
+		{format: "hosts", registry: "noop", valid: true},
+		{format: "zone", registry: "txt", valid: true},
+	} {
+		cfg := externaldns.NewConfig()
+		cfg.LogFormat = "json"
+		cfg.Sources = []string{"test-source"}
+		cfg.Provider = "coredns-configmap"
+		cfg.CoreDNSConfigMapFormat = tc.format
+		cfg.Registry = tc.registry
+
+		if tc.valid {
+			assert.NoError(t, ValidateConfig(cfg))
+		} else {
+			assert.Error(t, ValidateConfig(cfg))
+		}
+	}
+}
+
+func TestValidateTTLConfig(t *testing.T) {
+	for _, tc := range []struct {
+		defaultTTL, minTTL, maxTTL time.Duration
+		valid                      bool
+	}{
+		{valid: true},
+		{defaultTTL: 5 * time.Minute, minTTL: time.Minute, maxTTL: time.Hour, valid: true},
+		{minTTL: time.Hour, maxTTL: time.Minute},
+		{defaultTTL: -time.Minute},
+		{minTTL: -time.Minute},
+		{maxTTL: -time.Minute},
+	} {
+		cfg := externaldns.NewConfig()
+		cfg.LogFormat = "json"
+		cfg.Sources = []string{"test-source"}
+		cfg.Provider = "test-provider"
+		cfg.DefaultTTL = tc.defaultTTL
+		cfg.MinTTL = tc.minTTL
+		cfg.MaxTTL = tc.maxTTL
+
+		if tc.valid {
+			assert.NoError(t, ValidateConfig(cfg))
+		} else {
+			assert.Error(t, ValidateConfig(cfg))
+		}
+	}
+}
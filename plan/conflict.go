@@ -18,6 +18,7 @@ package plan
 
 import (
 	"sort"
+	"strings"
 
 	"sigs.k8s.io/external-dns/endpoint"
 )
@@ -67,4 +68,67 @@ func (s PerResource) less(x, y *endpoint.Endpoint) bool {
 	return x.Targets.IsLess(y.Targets)
 }
 
+// Merge merges the targets of all resources which want to acquire a DNS name, if they opted in to it
+// by the merge label. The other DNS names are resolved by PerResource.
+type Merge struct {
+	PerResource
+}
+
+// ResolveCreate merges the targets of the candidates which opted in to merging,
+// or takes the endpoint chosen by PerResource
+func (s Merge) ResolveCreate(candidates []*endpoint.Endpoint) *endpoint.Endpoint {
+	return s.merge(s.PerResource.ResolveCreate(candidates), candidates)
+}
+
+// ResolveUpdate merges the targets of the candidates which opted in to merging, so that the targets
+// of a resource which no longer wants the DNS name are removed, or takes the endpoint chosen by PerResource
+func (s Merge) ResolveUpdate(current *endpoint.Endpoint, candidates []*endpoint.Endpoint) *endpoint.Endpoint {
+	return s.merge(s.PerResource.ResolveUpdate(current, candidates), candidates)
+}
+
+// merge returns a copy of the chosen endpoint with the targets of all candidates of its type which opted
+// in to merging, recording the targets contributed by each resource in its labels
+func (s Merge) merge(chosen *endpoint.Endpoint, candidates []*endpoint.Endpoint) *endpoint.Endpoint {
+	if chosen == nil || chosen.Labels[endpoint.MergeLabelKey] != "true" {
+		return chosen
+	}
+
+	contributions := map[string]endpoint.Targets{}
+	for _, ep := range candidates {
+		if ep.RecordType != chosen.RecordType || ep.Labels[endpoint.MergeLabelKey] != "true" {
+			continue
+		}
+		resource := ep.Labels[endpoint.ResourceLabelKey]
+		contributions[resource] = append(contributions[resource], ep.Targets...)
+	}
+
+	merged := chosen.DeepCopy()
+	for key := range merged.Labels {
+		if strings.HasPrefix(key, endpoint.ResourceTargetsLabelPrefix) {
+			delete(merged.Labels, key)
+		}
+	}
+	merged.Targets = uniqueTargets(nil)
+	for resource, targets := range contributions {
+		targets = uniqueTargets(targets)
+		merged.Targets = uniqueTargets(append(merged.Targets, targets...))
+		merged.Labels[endpoint.ResourceTargetsLabelPrefix+resource] = strings.Join(targets, endpoint.LabelValueSeparator)
+	}
+	return merged
+}
+
+// uniqueTargets returns the sorted targets without duplicates
+func uniqueTargets(targets endpoint.Targets) endpoint.Targets {
+	seen := map[string]bool{}
+	unique := endpoint.Targets{}
+	for _, target := range targets {
+		if !seen[target] {
+			seen[target] = true
+			unique = append(unique, target)
+		}
+	}
+	sort.Sort(unique)
+	return unique
+}
+
 // TODO: with cross-resource/cross-cluster setup alternative variations of ConflictResolver can be used
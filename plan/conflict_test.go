@@ -25,6 +25,7 @@ import (
 )
 
 var _ ConflictResolver = PerResource{}
+var _ ConflictResolver = Merge{}
 
 type ResolverSuite struct {
 	// resolvers
@@ -133,6 +134,44 @@ func (suite *ResolverSuite) TestStrictResolver() {
 	suite.Equal(suite.bar127A, suite.perResource.ResolveUpdate(suite.legacyBar192A, []*endpoint.Endpoint{suite.bar127A, suite.bar192A}), " legacy record's resource value will not match, should pick minimum")
 }
 
+func (suite *ResolverSuite) TestMergeResolver() {
+	merge := Merge{}
+	mergeable := func(ep *endpoint.Endpoint) *endpoint.Endpoint {
+		ep = ep.DeepCopy()
+		ep.Labels[endpoint.MergeLabelKey] = "true"
+		return ep
+	}
+	bar127A, bar127AAnother, bar192A := mergeable(suite.bar127A), mergeable(suite.bar127AAnother), mergeable(suite.bar192A)
+	fooV1Cname, fooA5 := mergeable(suite.fooV1Cname), mergeable(suite.fooA5)
+
+	// test that merge resolver unions the targets of all resources for create list
+	merged := merge.ResolveCreate([]*endpoint.Endpoint{bar192A, bar127A, bar127AAnother})
+	suite.Equal(endpoint.Targets{"127.0.0.1", "192.168.0.1", "8.8.8.8"}, merged.Targets, "should merge the targets of all resources")
+	suite.Equal("127.0.0.1|8.8.8.8", merged.Labels[endpoint.ResourceTargetsLabelPrefix+"ingress/default/bar-127"], "should record the targets of each resource")
+	suite.Equal("192.168.0.1", merged.Labels[endpoint.ResourceTargetsLabelPrefix+"ingress/default/bar-192"], "should record the targets of each resource")
+	suite.Equal(endpoint.Targets{"127.0.0.1"}, bar127A.Targets, "should not modify the candidates")
+
+	// test that merge resolver only merges candidates of the same type which opted in to merging
+	suite.Equal(fooA5.Targets, merge.ResolveCreate([]*endpoint.Endpoint{fooA5, fooV1Cname}).Targets, "should not merge other record types")
+	suite.Equal(bar127A.Targets, merge.ResolveCreate([]*endpoint.Endpoint{bar127A, suite.bar192A}).Targets, "should not merge resources without opt-in")
+	suite.Equal(suite.bar127A, merge.ResolveCreate([]*endpoint.Endpoint{suite.bar127A, bar192A}), "should pick min one without opt-in")
+
+	// test that merge resolver only removes the targets of a resource which is gone
+	current := merge.ResolveCreate([]*endpoint.Endpoint{bar127A, bar192A})
+	updated := merge.ResolveUpdate(current, []*endpoint.Endpoint{bar192A})
+	suite.Equal(endpoint.Targets{"192.168.0.1"}, updated.Targets, "should remove the targets of the departed resource")
+	suite.NotContains(updated.Labels, endpoint.ResourceTargetsLabelPrefix+"ingress/default/bar-127", "should forget the departed resource")
+	suite.Equal("192.168.0.1", updated.Labels[endpoint.ResourceTargetsLabelPrefix+"ingress/default/bar-192"])
+
+	// test that merge resolver keeps a target shared with a departed resource
+	shared := mergeable(suite.bar127A)
+	shared.Labels[endpoint.ResourceLabelKey] = "ingress/default/bar-shared"
+	current = merge.ResolveCreate([]*endpoint.Endpoint{bar127A, shared, bar192A})
+	updated = merge.ResolveUpdate(current, []*endpoint.Endpoint{shared, bar192A})
+	suite.Equal(endpoint.Targets{"127.0.0.1", "192.168.0.1"}, updated.Targets, "should keep the targets of the remaining resources")
+	suite.Equal("127.0.0.1", updated.Labels[endpoint.ResourceTargetsLabelPrefix+"ingress/default/bar-shared"])
+}
+
 func TestConflictResolver(t *testing.T) {
 	suite.Run(t, new(ResolverSuite))
 }
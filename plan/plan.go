@@ -43,6 +43,8 @@ type Plan struct {
 	DomainFilter endpoint.DomainFilter
 	// Property comparator compares custom properties of providers
 	PropertyComparator PropertyComparator
+	// RecordTypes are the types of the records to plan, the default types are planned if empty
+	RecordTypes []string
 }
 
 // Changes holds lists of actions to be executed by dns providers
@@ -76,7 +78,7 @@ type planTable struct {
 }
 
 func newPlanTable() planTable { //TODO: make resolver configurable
-	return planTable{map[string]map[string]*planTableRow{}, PerResource{}}
+	return planTable{map[string]map[string]*planTableRow{}, Merge{}}
 }
 
 // planTableRow
@@ -119,10 +121,10 @@ func (t planTable) addCandidate(e *endpoint.Endpoint) {
 func (p *Plan) Calculate() *Plan {
 	t := newPlanTable()
 
-	for _, current := range filterRecordsForPlan(p.Current, p.DomainFilter) {
+	for _, current := range filterRecordsForPlan(p.Current, p.DomainFilter, p.RecordTypes) {
 		t.addCurrent(current)
 	}
-	for _, desired := range filterRecordsForPlan(p.Desired, p.DomainFilter) {
+	for _, desired := range filterRecordsForPlan(p.Desired, p.DomainFilter, p.RecordTypes) {
 		t.addCandidate(desired)
 	}
 
@@ -137,11 +139,11 @@ func (p *Plan) Calculate() *Plan {
 				changes.Delete = append(changes.Delete, row.current)
 			}
 
-			// TODO: allows record type change, which might not be supported by all dns providers
+			// a change of the record type is planned as an update, see SplitTypeTransitions
 			if row.current != nil && len(row.candidates) > 0 { //dns name is taken
 				update := t.resolver.ResolveUpdate(row.current, row.candidates)
 				// compare "update" to "current" to figure out if actual update is required
-				if shouldUpdateTTL(update, row.current) || targetChanged(update, row.current) || p.shouldUpdateProviderSpecific(update, row.current) {
+				if shouldUpdateTTL(update, row.current) || targetChanged(update, row.current) || contributionsChanged(update, row.current) || p.shouldUpdateProviderSpecific(update, row.current) {
 					inheritOwner(row.current, update)
 					changes.UpdateNew = append(changes.UpdateNew, update)
 					changes.UpdateOld = append(changes.UpdateOld, row.current)
@@ -163,6 +165,36 @@ func (p *Plan) Calculate() *Plan {
 	return plan
 }
 
+// SplitTypeTransitions moves the updates changing the type of a record out of the changes. The current
+// records are returned as deletions, which have to be applied before the changes, and the desired records,
+// which keep the owner of the current ones, are created by the changes instead. This way providers never
+// see records of both types at once, e.g. a CNAME next to an A record, which most of them reject.
+// It returns nil if no record changes its type.
+func (c *Changes) SplitTypeTransitions() *Changes {
+	var deletions *Changes
+	updateOld := make([]*endpoint.Endpoint, 0, len(c.UpdateOld))
+	updateNew := make([]*endpoint.Endpoint, 0, len(c.UpdateNew))
+	for i, current := range c.UpdateOld {
+		if i >= len(c.UpdateNew) {
+			break
+		}
+		desired := c.UpdateNew[i]
+		if desired.RecordType == current.RecordType {
+			updateOld = append(updateOld, current)
+			updateNew = append(updateNew, desired)
+			continue
+		}
+		if deletions == nil {
+			deletions = &Changes{}
+		}
+		deletions.Delete = append(deletions.Delete, current)
+		c.Create = append(c.Create, desired)
+	}
+	c.UpdateOld = updateOld
+	c.UpdateNew = updateNew
+	return deletions
+}
+
 func inheritOwner(from, to *endpoint.Endpoint) {
 	if to.Labels == nil {
 		to.Labels = map[string]string{}
@@ -177,6 +209,26 @@ func targetChanged(desired, current *endpoint.Endpoint) bool {
 	return !desired.Targets.Same(current.Targets)
 }
 
+// contributionsChanged tells whether the targets contributed by the resources of a merged record changed
+// without changing its targets, e.g. when one of two resources sharing a target is gone. Records without
+// an owner aren't compared, as their labels aren't stored by the registry.
+func contributionsChanged(desired, current *endpoint.Endpoint) bool {
+	if current.Labels[endpoint.OwnerLabelKey] == "" || desired.Labels[endpoint.MergeLabelKey] != "true" {
+		return false
+	}
+	for key, value := range desired.Labels {
+		if strings.HasPrefix(key, endpoint.ResourceTargetsLabelPrefix) && current.Labels[key] != value {
+			return true
+		}
+	}
+	for key := range current.Labels {
+		if _, ok := desired.Labels[key]; strings.HasPrefix(key, endpoint.ResourceTargetsLabelPrefix) && !ok {
+			return true
+		}
+	}
+	return false
+}
+
 func shouldUpdateTTL(desired, current *endpoint.Endpoint) bool {
 	if !desired.RecordTTL.IsConfigured() {
 		return false
@@ -223,16 +275,29 @@ func (p *Plan) shouldUpdateProviderSpecific(desired, current *endpoint.Endpoint)
 	return false
 }
 
+// defaultRecordTypes are the types of the records planned unless other types are given
+var defaultRecordTypes = []string{endpoint.RecordTypeA, endpoint.RecordTypeAAAA, endpoint.RecordTypeCNAME, endpoint.RecordTypeNS, endpoint.RecordTypeSRV}
+
 // filterRecordsForPlan removes records that are not relevant to the planner.
 // Currently this just removes TXT records to prevent them from being
 // deleted erroneously by the planner (only the TXT registry should do this.)
+// Records of other types than the given ones, or the default ones if none
+// are given, are removed as well.
 //
 // Per RFC 1034, CNAME records conflict with all other records - it is the
 // only record with this property. The behavior of the planner may need to be
 // made more sophisticated to codify this.
-func filterRecordsForPlan(records []*endpoint.Endpoint, domainFilter endpoint.DomainFilter) []*endpoint.Endpoint {
+func filterRecordsForPlan(records []*endpoint.Endpoint, domainFilter endpoint.DomainFilter, recordTypes []string) []*endpoint.Endpoint {
 	filtered := []*endpoint.Endpoint{}
 
+	if len(recordTypes) == 0 {
+		recordTypes = defaultRecordTypes
+	}
+	planned := make(map[string]bool, len(recordTypes))
+	for _, recordType := range recordTypes {
+		planned[recordType] = recordType != endpoint.RecordTypeTXT
+	}
+
 	for _, record := range records {
 		// Ignore records that do not match the domain filter provided
 		if !domainFilter.Match(record.DNSName) {
@@ -240,12 +305,8 @@ func filterRecordsForPlan(records []*endpoint.Endpoint, domainFilter endpoint.Do
 		}
 
 		// Explicitly specify which records we want to use for planning.
-		// TODO: Add AAAA records as well when they are supported.
-		switch record.RecordType {
-		case endpoint.RecordTypeA, endpoint.RecordTypeCNAME, endpoint.RecordTypeNS:
+		if planned[record.RecordType] {
 			filtered = append(filtered, record)
-		default:
-			continue
 		}
 	}
 
@@ -443,6 +443,32 @@ func (suite *PlanTestSuite) TestIgnoreTXT() {
 	validateEntries(suite.T(), changes.Delete, expectedDelete)
 }
 
+func (suite *PlanTestSuite) TestSRVRecords() {
+	srv := &endpoint.Endpoint{
+		DNSName:    "_sip._udp.foo",
+		Targets:    endpoint.Targets{"0 50 5060 foo"},
+		RecordType: endpoint.RecordTypeSRV,
+	}
+	current := []*endpoint.Endpoint{}
+	desired := []*endpoint.Endpoint{srv}
+	expectedCreate := []*endpoint.Endpoint{srv}
+	expectedUpdateOld := []*endpoint.Endpoint{}
+	expectedUpdateNew := []*endpoint.Endpoint{}
+	expectedDelete := []*endpoint.Endpoint{}
+
+	p := &Plan{
+		Policies: []Policy{&SyncPolicy{}},
+		Current:  current,
+		Desired:  desired,
+	}
+
+	changes := p.Calculate().Changes
+	validateEntries(suite.T(), changes.Create, expectedCreate)
+	validateEntries(suite.T(), changes.UpdateNew, expectedUpdateNew)
+	validateEntries(suite.T(), changes.UpdateOld, expectedUpdateOld)
+	validateEntries(suite.T(), changes.Delete, expectedDelete)
+}
+
 func (suite *PlanTestSuite) TestRemoveEndpoint() {
 	current := []*endpoint.Endpoint{suite.fooV1Cname, suite.bar192A}
 	desired := []*endpoint.Endpoint{suite.fooV1Cname}
@@ -620,6 +646,70 @@ func (suite *PlanTestSuite) TestDomainFiltersUpdate() {
 	validateEntries(suite.T(), changes.Delete, expectedDelete)
 }
 
+func (suite *PlanTestSuite) TestRecordTypes() {
+	mx := endpoint.NewEndpoint("mail.domain.tld", endpoint.RecordTypeMX, "10 mx.domain.tld")
+	caa := endpoint.NewEndpoint("domain.tld", endpoint.RecordTypeCAA, `0 issue "letsencrypt.org"`)
+	txt := endpoint.NewEndpoint("txt.domain.tld", endpoint.RecordTypeTXT, "text")
+
+	p := &Plan{
+		Policies: []Policy{&SyncPolicy{}},
+		Desired:  []*endpoint.Endpoint{mx, caa, txt, suite.bar127A},
+	}
+	validateEntries(suite.T(), p.Calculate().Changes.Create, []*endpoint.Endpoint{suite.bar127A})
+
+	// the TXT records are left to the registry even if they are given
+	p.RecordTypes = []string{endpoint.RecordTypeMX, endpoint.RecordTypeCAA, endpoint.RecordTypeTXT}
+	validateEntries(suite.T(), p.Calculate().Changes.Create, []*endpoint.Endpoint{mx, caa})
+}
+
+func (suite *PlanTestSuite) TestMergedTargets() {
+	desired := func(resource string, target string) *endpoint.Endpoint {
+		return &endpoint.Endpoint{
+			DNSName:    "bar",
+			Targets:    endpoint.Targets{target},
+			RecordType: endpoint.RecordTypeA,
+			Labels: map[string]string{
+				endpoint.ResourceLabelKey: resource,
+				endpoint.MergeLabelKey:    "true",
+			},
+		}
+	}
+	current := &endpoint.Endpoint{
+		DNSName:    "bar",
+		Targets:    endpoint.Targets{"127.0.0.1", "192.168.0.1"},
+		RecordType: endpoint.RecordTypeA,
+		Labels: map[string]string{
+			endpoint.OwnerLabelKey:    "pwner",
+			endpoint.ResourceLabelKey: "service/default/bar-127",
+			endpoint.MergeLabelKey:    "true",
+			endpoint.ResourceTargetsLabelPrefix + "service/default/bar-127": "127.0.0.1",
+			endpoint.ResourceTargetsLabelPrefix + "service/default/bar-192": "192.168.0.1",
+		},
+	}
+
+	// the targets of the remaining resource are kept
+	p := &Plan{
+		Policies: []Policy{&SyncPolicy{}},
+		Current:  []*endpoint.Endpoint{current},
+		Desired:  []*endpoint.Endpoint{desired("service/default/bar-192", "192.168.0.1")},
+	}
+	changes := p.Calculate().Changes
+	suite.Require().Len(changes.UpdateNew, 1)
+	suite.Equal(endpoint.Targets{"192.168.0.1"}, changes.UpdateNew[0].Targets)
+	suite.Equal("pwner", changes.UpdateNew[0].Labels[endpoint.OwnerLabelKey])
+
+	// the record isn't changed as long as all resources are there
+	p.Desired = []*endpoint.Endpoint{desired("service/default/bar-127", "127.0.0.1"), desired("service/default/bar-192", "192.168.0.1")}
+	suite.Empty(p.Calculate().Changes.UpdateNew)
+
+	// the labels are updated even if the targets are the same
+	p.Desired = []*endpoint.Endpoint{desired("service/default/bar-127", "127.0.0.1"), desired("service/default/bar-192", "192.168.0.1"), desired("service/default/bar-shared", "127.0.0.1")}
+	changes = p.Calculate().Changes
+	suite.Require().Len(changes.UpdateNew, 1)
+	suite.Equal(current.Targets, changes.UpdateNew[0].Targets)
+	suite.Equal("127.0.0.1", changes.UpdateNew[0].Labels[endpoint.ResourceTargetsLabelPrefix+"service/default/bar-shared"])
+}
+
 func TestPlan(t *testing.T) {
 	suite.Run(t, new(PlanTestSuite))
 }
@@ -686,3 +776,34 @@ func TestNormalizeDNSName(t *testing.T) {
 		assert.Equal(t, r.expect, gotName)
 	}
 }
+
+func TestSplitTypeTransitions(t *testing.T) {
+	currentCNAME := endpoint.NewEndpoint("foo.domain.tld", endpoint.RecordTypeCNAME, "lb.domain.tld")
+	currentCNAME.Labels = map[string]string{endpoint.OwnerLabelKey: "pwner"}
+	desiredA := endpoint.NewEndpoint("foo.domain.tld", endpoint.RecordTypeA, "1.2.3.4")
+	currentA := endpoint.NewEndpoint("bar.domain.tld", endpoint.RecordTypeA, "1.2.3.4")
+	desiredA2 := endpoint.NewEndpoint("bar.domain.tld", endpoint.RecordTypeA, "5.6.7.8")
+	created := endpoint.NewEndpoint("baz.domain.tld", endpoint.RecordTypeA, "1.2.3.4")
+
+	p := &Plan{
+		Policies: []Policy{&SyncPolicy{}},
+		Current:  []*endpoint.Endpoint{currentCNAME, currentA},
+		Desired:  []*endpoint.Endpoint{desiredA, desiredA2, created},
+	}
+	changes := p.Calculate().Changes
+	assert.Len(t, changes.UpdateOld, 2)
+
+	deletions := changes.SplitTypeTransitions()
+	if assert.NotNil(t, deletions) {
+		validateEntries(t, deletions.Delete, []*endpoint.Endpoint{currentCNAME})
+		assert.Empty(t, deletions.Create)
+		assert.Empty(t, deletions.UpdateNew)
+	}
+	validateEntries(t, changes.Create, []*endpoint.Endpoint{created, desiredA})
+	validateEntries(t, changes.UpdateOld, []*endpoint.Endpoint{currentA})
+	validateEntries(t, changes.UpdateNew, []*endpoint.Endpoint{desiredA2})
+	assert.Empty(t, changes.Delete)
+	assert.Equal(t, "pwner", desiredA.Labels[endpoint.OwnerLabelKey])
+
+	assert.Nil(t, changes.SplitTypeTransitions())
+}
@@ -277,6 +277,19 @@ func wildcardUnescape(s string) string {
 	return s
 }
 
+// ZoneIDNames returns the names of the hosted zones by their IDs.
+func (p *AWSProvider) ZoneIDNames(ctx context.Context) (provider.ZoneIDName, error) {
+	zones, err := p.Zones(ctx)
+	if err != nil {
+		return nil, err
+	}
+	zoneNames := provider.ZoneIDName{}
+	for id, zone := range zones {
+		zoneNames.Add(id, strings.TrimSuffix(aws.StringValue(zone.Name), "."))
+	}
+	return zoneNames, nil
+}
+
 // Records returns the list of records in a given hosted zone.
 func (p *AWSProvider) Records(ctx context.Context) (endpoints []*endpoint.Endpoint, _ error) {
 	zones, err := p.Zones(ctx)
@@ -0,0 +1,464 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package coredns
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/miekg/dns"
+	log "github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/util/retry"
+
+	"sigs.k8s.io/external-dns/endpoint"
+	"sigs.k8s.io/external-dns/plan"
+	"sigs.k8s.io/external-dns/provider"
+)
+
+const (
+	// ConfigMapFormatHosts is the format of the hosts plugin, supporting A and AAAA records
+	ConfigMapFormatHosts = "hosts"
+	// ConfigMapFormatZone is the format of zone files of the file plugin, supporting all record types
+	ConfigMapFormatZone = "zone"
+
+	sectionBegin = "BEGIN external-dns"
+	sectionEnd   = "END external-dns"
+
+	// defaultZoneTTL is the TTL of records without a configured TTL in the zone format
+	defaultZoneTTL = 300
+)
+
+// coreDNSConfigMapProvider maintains the records of a CoreDNS hosts or zone file block in a ConfigMap.
+// Only the lines between the "BEGIN external-dns" and "END external-dns" comments of the block are
+// read and written, the rest of the ConfigMap is left untouched. If the comments are missing, the
+// section is appended to the end of the block.
+type coreDNSConfigMapProvider struct {
+	provider.BaseProvider
+	client       kubernetes.Interface
+	namespace    string
+	name         string
+	key          string
+	format       string
+	domainFilter endpoint.DomainFilter
+	dryRun       bool
+}
+
+// configMapSection is the managed section of a ConfigMap block split from the unmanaged lines around it
+type configMapSection struct {
+	before []string
+	lines  []string
+	after  []string
+	// indent is the indentation of the section, e.g. within a hosts block of a Corefile
+	indent string
+}
+
+// NewCoreDNSConfigMapProvider creates a provider managing the block with the given key in the ConfigMap
+// "namespace/name" in the hosts or zone format.
+func NewCoreDNSConfigMapProvider(client kubernetes.Interface, configMap, key, format string, domainFilter endpoint.DomainFilter, dryRun bool) (provider.Provider, error) {
+	parts := strings.Split(configMap, "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return nil, fmt.Errorf("invalid ConfigMap %q, expected <namespace>/<name>", configMap)
+	}
+	if key == "" {
+		return nil, fmt.Errorf("no ConfigMap key given")
+	}
+	if format != ConfigMapFormatHosts && format != ConfigMapFormatZone {
+		return nil, fmt.Errorf("unknown ConfigMap format %q", format)
+	}
+
+	return &coreDNSConfigMapProvider{
+		client:       client,
+		namespace:    parts[0],
+		name:         parts[1],
+		key:          key,
+		format:       format,
+		domainFilter: domainFilter,
+		dryRun:       dryRun,
+	}, nil
+}
+
+// Records returns the records of the managed section.
+func (p *coreDNSConfigMapProvider) Records(ctx context.Context) ([]*endpoint.Endpoint, error) {
+	configMap, err := p.client.CoreV1().ConfigMaps(p.namespace).Get(ctx, p.name, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	return p.parseSection(splitSection(configMap.Data[p.key]).lines)
+}
+
+// Capabilities returns the record types supported by the format of the managed section. The type of a
+// record is changed atomically, since the section is rewritten as a whole.
+func (p *coreDNSConfigMapProvider) Capabilities() provider.Capabilities {
+	if p.format == ConfigMapFormatHosts {
+		return provider.Capabilities{
+			RecordTypes:      []string{endpoint.RecordTypeA, endpoint.RecordTypeAAAA},
+			AtomicTypeChange: true,
+		}
+	}
+	return provider.Capabilities{
+		RecordTypes:      []string{endpoint.RecordTypeA, endpoint.RecordTypeAAAA, endpoint.RecordTypeCNAME, endpoint.RecordTypeTXT, endpoint.RecordTypeSRV, endpoint.RecordTypeNS, endpoint.RecordTypeMX, endpoint.RecordTypePTR, endpoint.RecordTypeCAA},
+		AtomicTypeChange: true,
+	}
+}
+
+// ApplyChanges rewrites the managed section with the changes applied. The ConfigMap is updated with the
+// resourceVersion it was read with and the changes are applied again to its current content on conflicts.
+func (p *coreDNSConfigMapProvider) ApplyChanges(ctx context.Context, changes *plan.Changes) error {
+	changes = p.filterChanges(changes)
+	if len(changes.Create) == 0 && len(changes.UpdateNew) == 0 && len(changes.Delete) == 0 {
+		return nil
+	}
+
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		configMap, err := p.client.CoreV1().ConfigMaps(p.namespace).Get(ctx, p.name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+
+		data := configMap.Data[p.key]
+		section := splitSection(data)
+		endpoints, err := p.parseSection(section.lines)
+		if err != nil {
+			return err
+		}
+
+		endpoints = applyChanges(endpoints, changes)
+		if section.lines, err = p.renderSection(endpoints); err != nil {
+			return err
+		}
+		if p.format == ConfigMapFormatZone {
+			// the file plugin only reloads a zone whose serial changed
+			section.before = increaseSerial(section.before)
+		}
+
+		updated := section.String(p.commentPrefix())
+		if updated == data {
+			return nil
+		}
+		if p.dryRun {
+			log.Infof("Would update key %s of ConfigMap %s/%s to:\n%s", p.key, p.namespace, p.name, updated)
+			return nil
+		}
+
+		if configMap.Data == nil {
+			configMap.Data = map[string]string{}
+		}
+		configMap.Data[p.key] = updated
+		log.Infof("Updating key %s of ConfigMap %s/%s at resourceVersion %s", p.key, p.namespace, p.name, configMap.ResourceVersion)
+		_, err = p.client.CoreV1().ConfigMaps(p.namespace).Update(ctx, configMap, metav1.UpdateOptions{})
+		return err
+	})
+}
+
+// filterChanges drops the changes of records which don't match the domain filter
+// or whose type isn't supported by the format
+func (p *coreDNSConfigMapProvider) filterChanges(changes *plan.Changes) *plan.Changes {
+	filtered := &plan.Changes{}
+	supported := func(ep *endpoint.Endpoint) bool {
+		if !p.domainFilter.Match(ep.DNSName) {
+			log.Debugf("Skipping record %s because it was filtered out by the specified --domain-filter", ep.DNSName)
+			return false
+		}
+		if p.format == ConfigMapFormatHosts && ep.RecordType != endpoint.RecordTypeA && ep.RecordType != endpoint.RecordTypeAAAA {
+			log.Warnf("Skipping record %s of type %s which is not supported by the hosts format", ep.DNSName, ep.RecordType)
+			return false
+		}
+		return true
+	}
+
+	for _, ep := range changes.Create {
+		if supported(ep) {
+			filtered.Create = append(filtered.Create, ep)
+		}
+	}
+	for i, ep := range changes.UpdateNew {
+		if supported(ep) {
+			filtered.UpdateOld = append(filtered.UpdateOld, changes.UpdateOld[i])
+			filtered.UpdateNew = append(filtered.UpdateNew, ep)
+		}
+	}
+	for _, ep := range changes.Delete {
+		if supported(ep) {
+			filtered.Delete = append(filtered.Delete, ep)
+		}
+	}
+	return filtered
+}
+
+// applyChanges returns the endpoints with the changes applied
+func applyChanges(endpoints []*endpoint.Endpoint, changes *plan.Changes) []*endpoint.Endpoint {
+	key := func(ep *endpoint.Endpoint) string {
+		return strings.ToLower(strings.TrimSuffix(ep.DNSName, ".")) + " " + ep.RecordType
+	}
+	records := map[string]*endpoint.Endpoint{}
+	for _, ep := range endpoints {
+		records[key(ep)] = ep
+	}
+
+	for _, ep := range append(append([]*endpoint.Endpoint{}, changes.UpdateOld...), changes.Delete...) {
+		current, ok := records[key(ep)]
+		if !ok {
+			continue
+		}
+		var targets endpoint.Targets
+		for _, target := range current.Targets {
+			if !containsTarget(ep.Targets, target) {
+				targets = append(targets, target)
+			}
+		}
+		current.Targets = targets
+	}
+	for _, ep := range append(append([]*endpoint.Endpoint{}, changes.Create...), changes.UpdateNew...) {
+		current, ok := records[key(ep)]
+		if !ok {
+			current = endpoint.NewEndpoint(strings.TrimSuffix(ep.DNSName, "."), ep.RecordType)
+			records[key(ep)] = current
+		}
+		current.RecordTTL = ep.RecordTTL
+		for _, target := range ep.Targets {
+			if !containsTarget(current.Targets, target) {
+				current.Targets = append(current.Targets, target)
+			}
+		}
+	}
+
+	result := make([]*endpoint.Endpoint, 0, len(records))
+	for _, ep := range records {
+		if len(ep.Targets) > 0 {
+			result = append(result, ep)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return key(result[i]) < key(result[j])
+	})
+	return result
+}
+
+// containsTarget returns whether the target is one of the targets
+func containsTarget(targets endpoint.Targets, target string) bool {
+	for _, t := range targets {
+		if strings.EqualFold(strings.TrimSuffix(t, "."), strings.TrimSuffix(target, ".")) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseSection returns the records of the lines of the managed section
+func (p *coreDNSConfigMapProvider) parseSection(lines []string) ([]*endpoint.Endpoint, error) {
+	var endpoints []*endpoint.Endpoint
+	records := map[string]*endpoint.Endpoint{}
+	add := func(name, recordType string, ttl endpoint.TTL, target string) {
+		key := strings.ToLower(strings.TrimSuffix(name, ".")) + " " + recordType
+		if ep, ok := records[key]; ok {
+			ep.Targets = append(ep.Targets, strings.TrimSuffix(target, "."))
+			return
+		}
+		ep := endpoint.NewEndpointWithTTL(name, recordType, ttl, target)
+		records[key] = ep
+		endpoints = append(endpoints, ep)
+	}
+
+	if p.format == ConfigMapFormatHosts {
+		for _, line := range lines {
+			fields := strings.Fields(strings.SplitN(line, "#", 2)[0])
+			if len(fields) < 2 {
+				continue
+			}
+			ip := net.ParseIP(fields[0])
+			if ip == nil {
+				return nil, fmt.Errorf("invalid hosts entry %q", strings.TrimSpace(line))
+			}
+			recordType := endpoint.RecordTypeAAAA
+			if ip.To4() != nil {
+				recordType = endpoint.RecordTypeA
+			}
+			for _, name := range fields[1:] {
+				add(name, recordType, 0, fields[0])
+			}
+		}
+		return endpoints, nil
+	}
+
+	zp := dns.NewZoneParser(strings.NewReader(strings.Join(lines, "\n")), ".", p.key)
+	for rr, ok := zp.Next(); ok; rr, ok = zp.Next() {
+		var target string
+		switch rr := rr.(type) {
+		case *dns.A:
+			target = rr.A.String()
+		case *dns.AAAA:
+			target = rr.AAAA.String()
+		case *dns.CNAME:
+			target = rr.Target
+		case *dns.TXT:
+			target = strings.Join(rr.Txt, "")
+		default:
+			target = strings.TrimPrefix(rr.String(), rr.Header().String())
+		}
+		add(rr.Header().Name, dns.TypeToString[rr.Header().Rrtype], endpoint.TTL(rr.Header().Ttl), target)
+	}
+	if err := zp.Err(); err != nil {
+		return nil, fmt.Errorf("failed to parse records of ConfigMap %s/%s: %v", p.namespace, p.name, err)
+	}
+	return endpoints, nil
+}
+
+// renderSection returns the lines of the managed section for the records
+func (p *coreDNSConfigMapProvider) renderSection(endpoints []*endpoint.Endpoint) ([]string, error) {
+	var lines []string
+	for _, ep := range endpoints {
+		for _, target := range ep.Targets {
+			if p.format == ConfigMapFormatHosts {
+				lines = append(lines, fmt.Sprintf("%s %s", target, ep.DNSName))
+				continue
+			}
+
+			ttl := int64(defaultZoneTTL)
+			if ep.RecordTTL.IsConfigured() {
+				ttl = int64(ep.RecordTTL)
+			}
+			rr, err := dns.NewRR(fmt.Sprintf("%s %d IN %s %s", dns.Fqdn(ep.DNSName), ttl, ep.RecordType, target))
+			if err != nil {
+				return nil, fmt.Errorf("failed to build record %s %s %s: %v", ep.DNSName, ep.RecordType, target, err)
+			}
+			if rr == nil {
+				return nil, fmt.Errorf("failed to build record %s %s %s", ep.DNSName, ep.RecordType, target)
+			}
+			lines = append(lines, rr.String())
+		}
+	}
+	return lines, nil
+}
+
+// commentPrefix returns the comment prefix of the format
+func (p *coreDNSConfigMapProvider) commentPrefix() string {
+	if p.format == ConfigMapFormatZone {
+		return ";"
+	}
+	return "#"
+}
+
+// splitSection splits the managed section from the lines around it
+func splitSection(data string) configMapSection {
+	section := configMapSection{}
+	lines := strings.Split(strings.TrimSuffix(data, "\n"), "\n")
+	if data == "" {
+		lines = nil
+	}
+
+	begin, end := -1, -1
+	for i, line := range lines {
+		marker := strings.TrimSpace(strings.TrimLeft(strings.TrimSpace(line), "#;"))
+		if marker == sectionBegin && begin < 0 {
+			begin = i
+		} else if marker == sectionEnd && begin >= 0 {
+			end = i
+			break
+		}
+	}
+	if begin < 0 || end < 0 {
+		section.before = lines
+		return section
+	}
+
+	section.before = lines[:begin]
+	section.lines = lines[begin+1 : end]
+	section.after = lines[end+1:]
+	section.indent = lines[begin][:len(lines[begin])-len(strings.TrimLeft(lines[begin], " \t"))]
+	return section
+}
+
+// String joins the section and the lines around it, keeping the indentation of the section
+func (s configMapSection) String(commentPrefix string) string {
+	lines := append([]string{}, s.before...)
+	lines = append(lines, s.indent+commentPrefix+" "+sectionBegin)
+	for _, line := range s.lines {
+		lines = append(lines, s.indent+strings.TrimSpace(line))
+	}
+	lines = append(lines, s.indent+commentPrefix+" "+sectionEnd)
+	lines = append(lines, s.after...)
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// increaseSerial increases the serial of the first SOA record in the lines, which follows its two names
+func increaseSerial(lines []string) []string {
+	text := strings.Join(lines, "\n")
+	zp := dns.NewZoneParser(strings.NewReader(text), ".", "")
+	for rr, ok := zp.Next(); ok; rr, ok = zp.Next() {
+		soa, ok := rr.(*dns.SOA)
+		if !ok {
+			continue
+		}
+
+		start, end, ok := soaSerialIndex(text)
+		if !ok || text[start:end] != strconv.FormatUint(uint64(soa.Serial), 10) {
+			log.Warnf("Failed to increase serial %d of the zone", soa.Serial)
+			return lines
+		}
+		text = text[:start] + strconv.FormatUint(uint64(soa.Serial+1), 10) + text[end:]
+		return strings.Split(text, "\n")
+	}
+	return lines
+}
+
+// soaSerialIndex returns the position of the serial of the first SOA record in a zone file, which is
+// the third field following the SOA type, skipping comments, quoted strings and parentheses
+func soaSerialIndex(text string) (int, int, bool) {
+	soa := false
+	fields := 0
+	for i := 0; i < len(text); {
+		switch text[i] {
+		case ';':
+			for i < len(text) && text[i] != '\n' {
+				i++
+			}
+		case '"':
+			i++
+			for i < len(text) && text[i] != '"' {
+				if text[i] == '\\' {
+					i++
+				}
+				i++
+			}
+			i++
+		case ' ', '\t', '\r', '\n', '(', ')':
+			i++
+		default:
+			j := i
+			for j < len(text) && !strings.ContainsRune(" \t\r\n();\"", rune(text[j])) {
+				j++
+			}
+			if soa {
+				fields++
+				if fields == 3 {
+					return i, j, true
+				}
+			} else if strings.EqualFold(text[i:j], "SOA") {
+				soa = true
+			}
+			i = j
+		}
+	}
+	return 0, 0, false
+}
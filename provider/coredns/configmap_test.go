@@ -0,0 +1,246 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package coredns
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+
+	"sigs.k8s.io/external-dns/endpoint"
+	"sigs.k8s.io/external-dns/internal/testutils"
+	"sigs.k8s.io/external-dns/plan"
+	"sigs.k8s.io/external-dns/provider"
+)
+
+var _ provider.Provider = &coreDNSConfigMapProvider{}
+
+const testCorefile = `.:53 {
+    errors
+    hosts {
+        10.0.0.53 dns.example.org
+        # BEGIN external-dns
+        10.0.0.1 foo.example.org
+        10.0.0.2 foo.example.org
+        fd00::1 foo.example.org
+        # END external-dns
+        fallthrough
+    }
+    forward . /etc/resolv.conf
+}
+`
+
+const testZone = `$ORIGIN example.org.
+@ 3600 IN SOA ns.example.org. hostmaster.example.org. (
+        2020010101 ; serial
+        7200 3600 1209600 3600 )
+ns 3600 IN A 10.0.0.53
+; BEGIN external-dns
+foo.example.org. 600 IN A 10.0.0.1
+foo.example.org. 300 IN TXT "heritage=external-dns,external-dns/owner=default"
+; END external-dns
+`
+
+func newTestConfigMapProvider(t *testing.T, format, key, data string) (*coreDNSConfigMapProvider, *fake.Clientset) {
+	client := fake.NewSimpleClientset(&v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "kube-system", Name: "coredns"},
+		Data:       map[string]string{key: data},
+	})
+
+	p, err := NewCoreDNSConfigMapProvider(client, "kube-system/coredns", key, format, endpoint.NewDomainFilter([]string{"example.org"}), false)
+	require.NoError(t, err)
+	return p.(*coreDNSConfigMapProvider), client
+}
+
+func testConfigMapData(t *testing.T, client *fake.Clientset, key string) string {
+	configMap, err := client.CoreV1().ConfigMaps("kube-system").Get(context.Background(), "coredns", metav1.GetOptions{})
+	require.NoError(t, err)
+	return configMap.Data[key]
+}
+
+func TestNewCoreDNSConfigMapProvider(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	for _, ti := range []struct {
+		configMap string
+		key       string
+		format    string
+	}{
+		{"coredns", "Corefile", ConfigMapFormatHosts},
+		{"kube-system/", "Corefile", ConfigMapFormatHosts},
+		{"kube-system/coredns", "", ConfigMapFormatHosts},
+		{"kube-system/coredns", "Corefile", "etcd"},
+	} {
+		_, err := NewCoreDNSConfigMapProvider(client, ti.configMap, ti.key, ti.format, endpoint.NewDomainFilter(nil), false)
+		assert.Error(t, err, "%+v", ti)
+	}
+}
+
+func TestCoreDNSConfigMapHosts(t *testing.T) {
+	p, client := newTestConfigMapProvider(t, ConfigMapFormatHosts, "Corefile", testCorefile)
+
+	records, err := p.Records(context.Background())
+	require.NoError(t, err)
+	assert.True(t, testutils.SameEndpoints(records, []*endpoint.Endpoint{
+		endpoint.NewEndpoint("foo.example.org", endpoint.RecordTypeA, "10.0.0.1", "10.0.0.2"),
+		endpoint.NewEndpoint("foo.example.org", endpoint.RecordTypeAAAA, "fd00::1"),
+	}), "unexpected records %s", records)
+
+	require.NoError(t, p.ApplyChanges(context.Background(), &plan.Changes{
+		Create: []*endpoint.Endpoint{
+			endpoint.NewEndpoint("bar.example.org", endpoint.RecordTypeA, "10.0.0.3"),
+			endpoint.NewEndpoint("bar.example.org", endpoint.RecordTypeCNAME, "foo.example.org"),
+			endpoint.NewEndpoint("bar.example.com", endpoint.RecordTypeA, "10.0.0.4"),
+		},
+		UpdateOld: []*endpoint.Endpoint{endpoint.NewEndpoint("foo.example.org", endpoint.RecordTypeA, "10.0.0.1", "10.0.0.2")},
+		UpdateNew: []*endpoint.Endpoint{endpoint.NewEndpoint("foo.example.org", endpoint.RecordTypeA, "10.0.0.2", "10.0.0.5")},
+		Delete:    []*endpoint.Endpoint{endpoint.NewEndpoint("foo.example.org", endpoint.RecordTypeAAAA, "fd00::1")},
+	}))
+
+	assert.Equal(t, `.:53 {
+    errors
+    hosts {
+        10.0.0.53 dns.example.org
+        # BEGIN external-dns
+        10.0.0.3 bar.example.org
+        10.0.0.2 foo.example.org
+        10.0.0.5 foo.example.org
+        # END external-dns
+        fallthrough
+    }
+    forward . /etc/resolv.conf
+}
+`, testConfigMapData(t, client, "Corefile"))
+}
+
+func TestCoreDNSConfigMapHostsWithoutSection(t *testing.T) {
+	p, client := newTestConfigMapProvider(t, ConfigMapFormatHosts, "external-dns.hosts", "10.0.0.53 dns.example.org")
+
+	records, err := p.Records(context.Background())
+	require.NoError(t, err)
+	assert.Empty(t, records)
+
+	require.NoError(t, p.ApplyChanges(context.Background(), &plan.Changes{
+		Create: []*endpoint.Endpoint{endpoint.NewEndpoint("foo.example.org", endpoint.RecordTypeA, "10.0.0.1")},
+	}))
+	assert.Equal(t, "10.0.0.53 dns.example.org\n# BEGIN external-dns\n10.0.0.1 foo.example.org\n# END external-dns\n", testConfigMapData(t, client, "external-dns.hosts"))
+}
+
+func TestCoreDNSConfigMapZone(t *testing.T) {
+	p, client := newTestConfigMapProvider(t, ConfigMapFormatZone, "example.org.zone", testZone)
+
+	records, err := p.Records(context.Background())
+	require.NoError(t, err)
+	assert.True(t, testutils.SameEndpoints(records, []*endpoint.Endpoint{
+		endpoint.NewEndpointWithTTL("foo.example.org", endpoint.RecordTypeA, 600, "10.0.0.1"),
+		endpoint.NewEndpointWithTTL("foo.example.org", endpoint.RecordTypeTXT, 300, "heritage=external-dns,external-dns/owner=default"),
+	}), "unexpected records %s", records)
+
+	require.NoError(t, p.ApplyChanges(context.Background(), &plan.Changes{
+		Create: []*endpoint.Endpoint{
+			endpoint.NewEndpoint("www.example.org", endpoint.RecordTypeCNAME, "foo.example.org"),
+			endpoint.NewEndpointWithTTL("example.org", endpoint.RecordTypeMX, 3600, "10 mail.example.org"),
+		},
+		Delete: []*endpoint.Endpoint{
+			endpoint.NewEndpointWithTTL("foo.example.org", endpoint.RecordTypeTXT, 300, "heritage=external-dns,external-dns/owner=default"),
+		},
+	}))
+
+	assert.Equal(t, `$ORIGIN example.org.
+@ 3600 IN SOA ns.example.org. hostmaster.example.org. (
+        2020010102 ; serial
+        7200 3600 1209600 3600 )
+ns 3600 IN A 10.0.0.53
+; BEGIN external-dns
+example.org.	3600	IN	MX	10 mail.example.org.
+foo.example.org.	600	IN	A	10.0.0.1
+www.example.org.	300	IN	CNAME	foo.example.org.
+; END external-dns
+`, testConfigMapData(t, client, "example.org.zone"))
+}
+
+func TestIncreaseSerial(t *testing.T) {
+	for _, tc := range []struct {
+		title    string
+		zone     string
+		expected string
+	}{
+		{
+			title:    "small serial also part of the names",
+			zone:     "@ 3600 IN SOA ns1.example.org. hostmaster.example.org. 1 7200 3600 1209600 3600",
+			expected: "@ 3600 IN SOA ns1.example.org. hostmaster.example.org. 2 7200 3600 1209600 3600",
+		},
+		{
+			title:    "serial in parentheses with comments",
+			zone:     "; SOA 1 of the zone\n@ 3600 IN SOA ns.example.org. hostmaster.example.org. ( ; names 2 3\n        3 ; serial\n        3 3600 1209600 3600 )",
+			expected: "; SOA 1 of the zone\n@ 3600 IN SOA ns.example.org. hostmaster.example.org. ( ; names 2 3\n        4 ; serial\n        3 3600 1209600 3600 )",
+		},
+		{
+			title:    "record before the SOA record",
+			zone:     "$ORIGIN example.org.\ntxt 300 IN TXT \"SOA; 1 2 3\"\n@ 3600 IN SOA ns.example.org. hostmaster.example.org. 10 7200 3600 1209600 3600",
+			expected: "$ORIGIN example.org.\ntxt 300 IN TXT \"SOA; 1 2 3\"\n@ 3600 IN SOA ns.example.org. hostmaster.example.org. 11 7200 3600 1209600 3600",
+		},
+		{
+			title:    "no SOA record",
+			zone:     "foo 300 IN A 10.0.0.1",
+			expected: "foo 300 IN A 10.0.0.1",
+		},
+	} {
+		t.Run(tc.title, func(t *testing.T) {
+			assert.Equal(t, tc.expected, strings.Join(increaseSerial(strings.Split(tc.zone, "\n")), "\n"))
+		})
+	}
+}
+
+func TestCoreDNSConfigMapConflict(t *testing.T) {
+	p, client := newTestConfigMapProvider(t, ConfigMapFormatHosts, "Corefile", testCorefile)
+
+	// another writer updates the ConfigMap between the read and the update of the first attempt
+	conflicts := 0
+	client.PrependReactor("update", "configmaps", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if conflicts > 0 {
+			return false, nil, nil
+		}
+		conflicts++
+		return true, nil, errors.NewConflict(schema.GroupResource{Resource: "configmaps"}, "coredns", nil)
+	})
+
+	require.NoError(t, p.ApplyChanges(context.Background(), &plan.Changes{
+		Create: []*endpoint.Endpoint{endpoint.NewEndpoint("bar.example.org", endpoint.RecordTypeA, "10.0.0.3")},
+	}))
+	assert.Equal(t, 1, conflicts)
+	assert.Contains(t, testConfigMapData(t, client, "Corefile"), "10.0.0.3 bar.example.org")
+}
+
+func TestCoreDNSConfigMapDryRun(t *testing.T) {
+	p, client := newTestConfigMapProvider(t, ConfigMapFormatHosts, "Corefile", testCorefile)
+	p.dryRun = true
+
+	require.NoError(t, p.ApplyChanges(context.Background(), &plan.Changes{
+		Create: []*endpoint.Endpoint{endpoint.NewEndpoint("bar.example.org", endpoint.RecordTypeA, "10.0.0.3")},
+	}))
+	assert.Equal(t, testCorefile, testConfigMapData(t, client, "Corefile"))
+}
@@ -0,0 +1,36 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package inmemory
+
+import (
+	"testing"
+
+	"sigs.k8s.io/external-dns/endpoint"
+	"sigs.k8s.io/external-dns/internal/testutils/providertest"
+	"sigs.k8s.io/external-dns/provider"
+)
+
+func TestInMemoryConformance(t *testing.T) {
+	providertest.Run(t, func(t *testing.T) provider.Provider {
+		return NewInMemoryProvider(InMemoryInitZones([]string{providertest.Zone}))
+	}, providertest.Capabilities{
+		RecordTypes:     []string{endpoint.RecordTypeAAAA, endpoint.RecordTypeSRV, endpoint.RecordTypeNS, endpoint.RecordTypeMX, endpoint.RecordTypePTR, endpoint.RecordTypeCAA},
+		MultipleTargets: true,
+		TTL:             true,
+		SetIdentifier:   true,
+	})
+}
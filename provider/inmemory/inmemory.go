@@ -18,8 +18,13 @@ package inmemory
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"strings"
+	"sync"
 
 	log "github.com/sirupsen/logrus"
 
@@ -50,6 +55,8 @@ type InMemoryProvider struct {
 	filter         *filter
 	OnApplyChanges func(ctx context.Context, changes *plan.Changes)
 	OnRecords      func()
+	// persistenceFile is the JSON file the zones are saved to after every change, if set
+	persistenceFile string
 }
 
 // InMemoryOption allows to extend in-memory provider
@@ -86,13 +93,21 @@ func InMemoryWithDomain(domainFilter endpoint.DomainFilter) InMemoryOption {
 func InMemoryInitZones(zones []string) InMemoryOption {
 	return func(p *InMemoryProvider) {
 		for _, z := range zones {
-			if err := p.CreateZone(z); err != nil {
+			if err := p.client.CreateZone(z); err != nil {
 				log.Warnf("Unable to initialize zones for inmemory provider")
 			}
 		}
 	}
 }
 
+// InMemoryWithPersistence keeps the zones in a JSON file, which is loaded on start and saved after every change.
+// The zones of the file take precedence over the zones given by InMemoryInitZones.
+func InMemoryWithPersistence(file string) InMemoryOption {
+	return func(p *InMemoryProvider) {
+		p.persistenceFile = file
+	}
+}
+
 // NewInMemoryProvider returns InMemoryProvider DNS provider interface implementation
 func NewInMemoryProvider(opts ...InMemoryOption) *InMemoryProvider {
 	im := &InMemoryProvider{
@@ -107,12 +122,34 @@ func NewInMemoryProvider(opts ...InMemoryOption) *InMemoryProvider {
 		opt(im)
 	}
 
+	if im.persistenceFile != "" {
+		if err := im.client.Load(im.persistenceFile); err != nil {
+			// don't overwrite a file which might just be unreadable at the moment
+			log.Errorf("Unable to load zones of inmemory provider from %s, persistence is disabled: %v", im.persistenceFile, err)
+			im.persistenceFile = ""
+		}
+	}
+
 	return im
 }
 
 // CreateZone adds new zone if not present
 func (im *InMemoryProvider) CreateZone(newZone string) error {
-	return im.client.CreateZone(newZone)
+	if err := im.client.CreateZone(newZone); err != nil {
+		return err
+	}
+	im.persist()
+	return nil
+}
+
+// persist saves the zones to the persistence file, if any
+func (im *InMemoryProvider) persist() {
+	if im.persistenceFile == "" {
+		return
+	}
+	if err := im.client.Save(im.persistenceFile); err != nil {
+		log.Errorf("Unable to save zones of inmemory provider to %s: %v", im.persistenceFile, err)
+	}
 }
 
 // Zones returns filtered zones as specified by domain
@@ -120,6 +157,19 @@ func (im *InMemoryProvider) Zones() map[string]string {
 	return im.filter.Zones(im.client.Zones())
 }
 
+// ZoneIDNames returns the filtered zones, which are identified by their names.
+func (im *InMemoryProvider) ZoneIDNames(ctx context.Context) (provider.ZoneIDName, error) {
+	return im.Zones(), nil
+}
+
+// Capabilities returns the record types the provider supports. Records keep their type once created.
+func (im *InMemoryProvider) Capabilities() provider.Capabilities {
+	return provider.Capabilities{
+		RecordTypes:   []string{endpoint.RecordTypeA, endpoint.RecordTypeAAAA, endpoint.RecordTypeCNAME, endpoint.RecordTypeTXT, endpoint.RecordTypeSRV, endpoint.RecordTypeNS, endpoint.RecordTypeMX, endpoint.RecordTypePTR, endpoint.RecordTypeCAA},
+		SetIdentifier: true,
+	}
+}
+
 // Records returns the list of endpoints
 func (im *InMemoryProvider) Records(ctx context.Context) ([]*endpoint.Endpoint, error) {
 	defer im.OnRecords()
@@ -133,8 +183,9 @@ func (im *InMemoryProvider) Records(ctx context.Context) ([]*endpoint.Endpoint,
 		}
 
 		for _, record := range records {
-			ep := endpoint.NewEndpoint(record.Name, record.Type, record.Target).WithSetIdentifier(record.SetIdentifier)
+			ep := endpoint.NewEndpointWithTTL(record.Name, record.Type, record.TTL, record.Targets...).WithSetIdentifier(record.SetIdentifier)
 			ep.Labels = record.Labels
+			ep.ProviderSpecific = record.ProviderSpecific
 			endpoints = append(endpoints, ep)
 		}
 	}
@@ -186,6 +237,9 @@ func (im *InMemoryProvider) ApplyChanges(ctx context.Context, changes *plan.Chan
 		perZoneChanges[zoneID].Delete = append(perZoneChanges[zoneID].Delete, ep)
 	}
 
+	// zones changed before an error are saved as well
+	defer im.persist()
+
 	for zoneID := range perZoneChanges {
 		change := &inMemoryChange{
 			Create:    convertToInMemoryRecord(perZoneChanges[zoneID].Create),
@@ -206,11 +260,13 @@ func convertToInMemoryRecord(endpoints []*endpoint.Endpoint) []*inMemoryRecord {
 	records := []*inMemoryRecord{}
 	for _, ep := range endpoints {
 		records = append(records, &inMemoryRecord{
-			Type:          ep.RecordType,
-			Name:          ep.DNSName,
-			Target:        ep.Targets[0],
-			SetIdentifier: ep.SetIdentifier,
-			Labels:        ep.Labels,
+			Type:             ep.RecordType,
+			Name:             ep.DNSName,
+			Targets:          append(endpoint.Targets{}, ep.Targets...),
+			TTL:              ep.RecordTTL,
+			SetIdentifier:    ep.SetIdentifier,
+			Labels:           ep.Labels,
+			ProviderSpecific: ep.ProviderSpecific,
 		})
 	}
 	return records
@@ -247,13 +303,32 @@ func (f *filter) EndpointZoneID(endpoint *endpoint.Endpoint, zones map[string]st
 // inMemoryRecord - record stored in memory
 // Type - type of record
 // Name - DNS name assigned to the record
-// Target - target of the record
+// Targets - targets of the record
+// TTL - TTL of the record, 0 if not configured
 type inMemoryRecord struct {
-	Type          string
-	SetIdentifier string
-	Name          string
-	Target        string
-	Labels        endpoint.Labels
+	Type             string                    `json:"type"`
+	SetIdentifier    string                    `json:"setIdentifier,omitempty"`
+	Name             string                    `json:"name"`
+	Targets          endpoint.Targets          `json:"targets"`
+	TTL              endpoint.TTL              `json:"ttl,omitempty"`
+	Labels           endpoint.Labels           `json:"labels,omitempty"`
+	ProviderSpecific endpoint.ProviderSpecific `json:"providerSpecific,omitempty"`
+}
+
+// deepCopy returns a copy of the record which shares no data with it
+func (r *inMemoryRecord) deepCopy() *inMemoryRecord {
+	c := *r
+	c.Targets = append(endpoint.Targets{}, r.Targets...)
+	if r.Labels != nil {
+		c.Labels = endpoint.Labels{}
+		for k, v := range r.Labels {
+			c.Labels[k] = v
+		}
+	}
+	if r.ProviderSpecific != nil {
+		c.ProviderSpecific = append(endpoint.ProviderSpecific{}, r.ProviderSpecific...)
+	}
+	return &c
 }
 
 type zone map[string][]*inMemoryRecord
@@ -266,26 +341,37 @@ type inMemoryChange struct {
 }
 
 type inMemoryClient struct {
-	zones map[string]zone
+	// zonesMux guards the zones, which are read by the DNS server concurrently
+	zonesMux sync.RWMutex
+	zones    map[string]zone
 }
 
 func newInMemoryClient() *inMemoryClient {
-	return &inMemoryClient{map[string]zone{}}
+	return &inMemoryClient{zones: map[string]zone{}}
 }
 
 func (c *inMemoryClient) Records(zone string) ([]*inMemoryRecord, error) {
+	c.zonesMux.RLock()
+	defer c.zonesMux.RUnlock()
+
 	if _, ok := c.zones[zone]; !ok {
 		return nil, ErrZoneNotFound
 	}
 
+	// the records are copied, since they are changed by ApplyChanges while the DNS server reads them
 	records := []*inMemoryRecord{}
-	for _, rec := range c.zones[zone] {
-		records = append(records, rec...)
+	for _, recs := range c.zones[zone] {
+		for _, rec := range recs {
+			records = append(records, rec.deepCopy())
+		}
 	}
 	return records, nil
 }
 
 func (c *inMemoryClient) Zones() map[string]string {
+	c.zonesMux.RLock()
+	defer c.zonesMux.RUnlock()
+
 	zones := map[string]string{}
 	for zone := range c.zones {
 		zones[zone] = zone
@@ -294,6 +380,9 @@ func (c *inMemoryClient) Zones() map[string]string {
 }
 
 func (c *inMemoryClient) CreateZone(zone string) error {
+	c.zonesMux.Lock()
+	defer c.zonesMux.Unlock()
+
 	if _, ok := c.zones[zone]; ok {
 		return ErrZoneAlreadyExists
 	}
@@ -303,6 +392,9 @@ func (c *inMemoryClient) CreateZone(zone string) error {
 }
 
 func (c *inMemoryClient) ApplyChanges(ctx context.Context, zoneID string, changes *inMemoryChange) error {
+	c.zonesMux.Lock()
+	defer c.zonesMux.Unlock()
+
 	if err := c.validateChangeBatch(zoneID, changes); err != nil {
 		return err
 	}
@@ -313,17 +405,17 @@ func (c *inMemoryClient) ApplyChanges(ctx context.Context, zoneID string, change
 		c.zones[zoneID][newEndpoint.Name] = append(c.zones[zoneID][newEndpoint.Name], newEndpoint)
 	}
 	for _, updateEndpoint := range changes.UpdateNew {
-		for _, rec := range c.zones[zoneID][updateEndpoint.Name] {
-			if rec.Type == updateEndpoint.Type {
-				rec.Target = updateEndpoint.Target
-				break
-			}
+		if rec := c.findByTypeAndSetIdentifier(updateEndpoint.Type, updateEndpoint.SetIdentifier, c.zones[zoneID][updateEndpoint.Name]); rec != nil {
+			rec.Targets = updateEndpoint.Targets
+			rec.TTL = updateEndpoint.TTL
+			rec.Labels = updateEndpoint.Labels
+			rec.ProviderSpecific = updateEndpoint.ProviderSpecific
 		}
 	}
 	for _, deleteEndpoint := range changes.Delete {
 		newSet := make([]*inMemoryRecord, 0)
 		for _, rec := range c.zones[zoneID][deleteEndpoint.Name] {
-			if rec.Type != deleteEndpoint.Type {
+			if rec.Type != deleteEndpoint.Type || rec.SetIdentifier != deleteEndpoint.SetIdentifier {
 				newSet = append(newSet, rec)
 			}
 		}
@@ -332,6 +424,65 @@ func (c *inMemoryClient) ApplyChanges(ctx context.Context, zoneID string, change
 	return nil
 }
 
+// Load replaces the zones with the zones of a JSON file, a missing file is ignored
+func (c *inMemoryClient) Load(file string) error {
+	data, err := ioutil.ReadFile(file)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	zones := map[string][]*inMemoryRecord{}
+	if err := json.Unmarshal(data, &zones); err != nil {
+		return err
+	}
+
+	c.zonesMux.Lock()
+	defer c.zonesMux.Unlock()
+
+	for zoneID, records := range zones {
+		c.zones[zoneID] = zone{}
+		for _, record := range records {
+			c.zones[zoneID][record.Name] = append(c.zones[zoneID][record.Name], record)
+		}
+	}
+	return nil
+}
+
+// Save writes the zones to a JSON file, replacing it atomically
+func (c *inMemoryClient) Save(file string) error {
+	c.zonesMux.RLock()
+	zones := map[string][]*inMemoryRecord{}
+	for zoneID, z := range c.zones {
+		zones[zoneID] = []*inMemoryRecord{}
+		for _, records := range z {
+			zones[zoneID] = append(zones[zoneID], records...)
+		}
+	}
+	data, err := json.MarshalIndent(zones, "", "  ")
+	c.zonesMux.RUnlock()
+	if err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(file), "."+filepath.Base(file))
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), file)
+}
+
 func (c *inMemoryClient) updateMesh(mesh map[string]map[string]map[string]bool, record *inMemoryRecord) error {
 	if _, exists := mesh[record.Name]; exists {
 		if _, exists := mesh[record.Name][record.Type]; exists {
@@ -372,12 +523,12 @@ func (c *inMemoryClient) validateChangeBatch(zone string, changes *inMemoryChang
 		}
 	}
 	for _, updateOldEndpoint := range changes.UpdateOld {
-		if rec := c.findByTypeAndSetIdentifier(updateOldEndpoint.Type, updateOldEndpoint.SetIdentifier, curZone[updateOldEndpoint.Name]); rec == nil || rec.Target != updateOldEndpoint.Target {
+		if rec := c.findByTypeAndSetIdentifier(updateOldEndpoint.Type, updateOldEndpoint.SetIdentifier, curZone[updateOldEndpoint.Name]); rec == nil || !rec.Targets.Same(updateOldEndpoint.Targets) {
 			return ErrRecordNotFound
 		}
 	}
 	for _, deleteEndpoint := range changes.Delete {
-		if rec := c.findByTypeAndSetIdentifier(deleteEndpoint.Type, deleteEndpoint.SetIdentifier, curZone[deleteEndpoint.Name]); rec == nil || rec.Target != deleteEndpoint.Target {
+		if rec := c.findByTypeAndSetIdentifier(deleteEndpoint.Type, deleteEndpoint.SetIdentifier, curZone[deleteEndpoint.Name]); rec == nil || !rec.Targets.Same(deleteEndpoint.Targets) {
 			return ErrRecordNotFound
 		}
 		if err := c.updateMesh(mesh, deleteEndpoint); err != nil {
@@ -18,6 +18,9 @@ package inmemory
 
 import (
 	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -40,6 +43,8 @@ func TestInMemoryProvider(t *testing.T) {
 	t.Run("ApplyChanges", testInMemoryApplyChanges)
 	t.Run("NewInMemoryProvider", testNewInMemoryProvider)
 	t.Run("CreateZone", testInMemoryCreateZone)
+	t.Run("RecordModel", testInMemoryRecordModel)
+	t.Run("Persistence", testInMemoryPersistence)
 }
 
 func testInMemoryFindByType(t *testing.T) {
@@ -180,29 +185,30 @@ func testInMemoryRecords(t *testing.T) {
 				"org": {
 					"example.org": []*inMemoryRecord{
 						{
-							Name:   "example.org",
-							Target: "8.8.8.8",
-							Type:   endpoint.RecordTypeA,
+							Name:    "example.org",
+							Targets: endpoint.Targets{"8.8.8.8"},
+							Type:    endpoint.RecordTypeA,
 						},
 						{
-							Name: "example.org",
-							Type: endpoint.RecordTypeTXT,
+							Name:    "example.org",
+							Targets: endpoint.Targets{""},
+							Type:    endpoint.RecordTypeTXT,
 						},
 					},
 					"foo.org": []*inMemoryRecord{
 						{
-							Name:   "foo.org",
-							Target: "4.4.4.4",
-							Type:   endpoint.RecordTypeCNAME,
+							Name:    "foo.org",
+							Targets: endpoint.Targets{"4.4.4.4"},
+							Type:    endpoint.RecordTypeCNAME,
 						},
 					},
 				},
 				"com": {
 					"example.com": []*inMemoryRecord{
 						{
-							Name:   "example.com",
-							Target: "4.4.4.4",
-							Type:   endpoint.RecordTypeCNAME,
+							Name:    "example.com",
+							Targets: endpoint.Targets{"4.4.4.4"},
+							Type:    endpoint.RecordTypeCNAME,
 						},
 					},
 				},
@@ -251,9 +257,9 @@ func testInMemoryValidateChangeBatch(t *testing.T) {
 		"org": {
 			"example.org": []*inMemoryRecord{
 				{
-					Name:   "example.org",
-					Target: "8.8.8.8",
-					Type:   endpoint.RecordTypeA,
+					Name:    "example.org",
+					Targets: endpoint.Targets{"8.8.8.8"},
+					Type:    endpoint.RecordTypeA,
 				},
 				{
 					Name: "example.org",
@@ -261,25 +267,25 @@ func testInMemoryValidateChangeBatch(t *testing.T) {
 			},
 			"foo.org": []*inMemoryRecord{
 				{
-					Name:   "foo.org",
-					Target: "bar.org",
-					Type:   endpoint.RecordTypeCNAME,
+					Name:    "foo.org",
+					Targets: endpoint.Targets{"bar.org"},
+					Type:    endpoint.RecordTypeCNAME,
 				},
 			},
 			"foo.bar.org": []*inMemoryRecord{
 				{
-					Name:   "foo.bar.org",
-					Target: "5.5.5.5",
-					Type:   endpoint.RecordTypeA,
+					Name:    "foo.bar.org",
+					Targets: endpoint.Targets{"5.5.5.5"},
+					Type:    endpoint.RecordTypeA,
 				},
 			},
 		},
 		"com": {
 			"example.com": []*inMemoryRecord{
 				{
-					Name:   "example.com",
-					Target: "another-example.com",
-					Type:   endpoint.RecordTypeCNAME,
+					Name:    "example.com",
+					Targets: endpoint.Targets{"another-example.com"},
+					Type:    endpoint.RecordTypeCNAME,
 				},
 			},
 		},
@@ -584,9 +590,9 @@ func getInitData() map[string]zone {
 		"org": {
 			"example.org": []*inMemoryRecord{
 				{
-					Name:   "example.org",
-					Target: "8.8.8.8",
-					Type:   endpoint.RecordTypeA,
+					Name:    "example.org",
+					Targets: endpoint.Targets{"8.8.8.8"},
+					Type:    endpoint.RecordTypeA,
 				},
 				{
 					Name: "example.org",
@@ -595,25 +601,25 @@ func getInitData() map[string]zone {
 			},
 			"foo.org": []*inMemoryRecord{
 				{
-					Name:   "foo.org",
-					Target: "4.4.4.4",
-					Type:   endpoint.RecordTypeCNAME,
+					Name:    "foo.org",
+					Targets: endpoint.Targets{"4.4.4.4"},
+					Type:    endpoint.RecordTypeCNAME,
 				},
 			},
 			"foo.bar.org": []*inMemoryRecord{
 				{
-					Name:   "foo.bar.org",
-					Target: "5.5.5.5",
-					Type:   endpoint.RecordTypeA,
+					Name:    "foo.bar.org",
+					Targets: endpoint.Targets{"5.5.5.5"},
+					Type:    endpoint.RecordTypeA,
 				},
 			},
 		},
 		"com": {
 			"example.com": []*inMemoryRecord{
 				{
-					Name:   "example.com",
-					Target: "4.4.4.4",
-					Type:   endpoint.RecordTypeCNAME,
+					Name:    "example.com",
+					Targets: endpoint.Targets{"4.4.4.4"},
+					Type:    endpoint.RecordTypeCNAME,
 				},
 			},
 		},
@@ -685,9 +691,9 @@ func testInMemoryApplyChanges(t *testing.T) {
 					"example.org": []*inMemoryRecord{
 						{
 
-							Name:   "example.org",
-							Target: "8.8.8.8",
-							Type:   endpoint.RecordTypeA,
+							Name:    "example.org",
+							Targets: endpoint.Targets{"8.8.8.8"},
+							Type:    endpoint.RecordTypeA,
 						},
 						{
 
@@ -698,9 +704,9 @@ func testInMemoryApplyChanges(t *testing.T) {
 					"foo.org": []*inMemoryRecord{
 						{
 
-							Name:   "foo.org",
-							Target: "4.4.4.4",
-							Type:   endpoint.RecordTypeCNAME,
+							Name:    "foo.org",
+							Targets: endpoint.Targets{"4.4.4.4"},
+							Type:    endpoint.RecordTypeCNAME,
 						},
 					},
 					"foo.bar.org": []*inMemoryRecord{},
@@ -708,9 +714,9 @@ func testInMemoryApplyChanges(t *testing.T) {
 				"com": {
 					"example.com": []*inMemoryRecord{
 						{
-							Name:   "example.com",
-							Target: "4.4.4.4",
-							Type:   endpoint.RecordTypeCNAME,
+							Name:    "example.com",
+							Targets: endpoint.Targets{"4.4.4.4"},
+							Type:    endpoint.RecordTypeCNAME,
 						},
 					},
 				},
@@ -759,32 +765,32 @@ func testInMemoryApplyChanges(t *testing.T) {
 					},
 					"foo.org": []*inMemoryRecord{
 						{
-							Name:   "foo.org",
-							Target: "4.4.4.4",
-							Type:   endpoint.RecordTypeCNAME,
+							Name:    "foo.org",
+							Targets: endpoint.Targets{"4.4.4.4"},
+							Type:    endpoint.RecordTypeCNAME,
 						},
 					},
 					"foo.bar.org": []*inMemoryRecord{
 						{
-							Name:   "foo.bar.org",
-							Target: "4.8.8.4",
-							Type:   endpoint.RecordTypeA,
+							Name:    "foo.bar.org",
+							Targets: endpoint.Targets{"4.8.8.4"},
+							Type:    endpoint.RecordTypeA,
 						},
 					},
 					"foo.bar.new.org": []*inMemoryRecord{
 						{
-							Name:   "foo.bar.new.org",
-							Target: "4.8.8.9",
-							Type:   endpoint.RecordTypeA,
+							Name:    "foo.bar.new.org",
+							Targets: endpoint.Targets{"4.8.8.9"},
+							Type:    endpoint.RecordTypeA,
 						},
 					},
 				},
 				"com": {
 					"example.com": []*inMemoryRecord{
 						{
-							Name:   "example.com",
-							Target: "4.4.4.4",
-							Type:   endpoint.RecordTypeCNAME,
+							Name:    "example.com",
+							Targets: endpoint.Targets{"4.4.4.4"},
+							Type:    endpoint.RecordTypeCNAME,
 						},
 					},
 				},
@@ -821,3 +827,58 @@ func testInMemoryCreateZone(t *testing.T) {
 	err = im.CreateZone("zone")
 	assert.EqualError(t, err, ErrZoneAlreadyExists.Error())
 }
+
+func testInMemoryRecordModel(t *testing.T) {
+	im := NewInMemoryProvider(InMemoryInitZones([]string{"example.org"}))
+
+	records := []*endpoint.Endpoint{
+		endpoint.NewEndpointWithTTL("foo.example.org", endpoint.RecordTypeA, 60, "10.0.0.1", "10.0.0.2"),
+		endpoint.NewEndpoint("foo.example.org", endpoint.RecordTypeA, "10.0.0.3").WithSetIdentifier("eu").WithProviderSpecific("weight", "10"),
+	}
+	require.NoError(t, im.ApplyChanges(context.Background(), &plan.Changes{Create: records}))
+
+	current, err := im.Records(context.Background())
+	require.NoError(t, err)
+	assert.True(t, testutils.SameEndpoints(records, current), "Endpoints not the same: Expected: %+v Records: %+v", records, current)
+
+	// updates and deletes only affect the record with the same set identifier
+	require.NoError(t, im.ApplyChanges(context.Background(), &plan.Changes{
+		UpdateOld: []*endpoint.Endpoint{records[0]},
+		UpdateNew: []*endpoint.Endpoint{endpoint.NewEndpointWithTTL("foo.example.org", endpoint.RecordTypeA, 120, "10.0.0.2", "10.0.0.4")},
+		Delete:    []*endpoint.Endpoint{records[1]},
+	}))
+
+	current, err = im.Records(context.Background())
+	require.NoError(t, err)
+	expected := []*endpoint.Endpoint{endpoint.NewEndpointWithTTL("foo.example.org", endpoint.RecordTypeA, 120, "10.0.0.2", "10.0.0.4")}
+	assert.True(t, testutils.SameEndpoints(expected, current), "Endpoints not the same: Expected: %+v Records: %+v", expected, current)
+}
+
+func testInMemoryPersistence(t *testing.T) {
+	dir, err := ioutil.TempDir("", "inmemory")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "zones.json")
+
+	im := NewInMemoryProvider(InMemoryInitZones([]string{"example.org"}), InMemoryWithPersistence(file))
+	records := []*endpoint.Endpoint{
+		endpoint.NewEndpointWithTTL("foo.example.org", endpoint.RecordTypeA, 60, "10.0.0.1", "10.0.0.2"),
+		endpoint.NewEndpoint("foo.example.org", endpoint.RecordTypeTXT, "heritage=external-dns,external-dns/owner=default"),
+	}
+	require.NoError(t, im.ApplyChanges(context.Background(), &plan.Changes{Create: records}))
+
+	// the zones of the file are restored, other zones are kept
+	restored := NewInMemoryProvider(InMemoryInitZones([]string{"example.com"}), InMemoryWithPersistence(file))
+	assert.Equal(t, map[string]string{"example.org": "example.org", "example.com": "example.com"}, restored.Zones())
+	current, err := restored.Records(context.Background())
+	require.NoError(t, err)
+	assert.True(t, testutils.SameEndpoints(records, current), "Endpoints not the same: Expected: %+v Records: %+v", records, current)
+
+	// a broken file is neither loaded nor overwritten
+	require.NoError(t, ioutil.WriteFile(file, []byte("{"), 0600))
+	broken := NewInMemoryProvider(InMemoryInitZones([]string{"example.org"}), InMemoryWithPersistence(file))
+	require.NoError(t, broken.ApplyChanges(context.Background(), &plan.Changes{Create: records}))
+	data, err := ioutil.ReadFile(file)
+	require.NoError(t, err)
+	assert.Equal(t, "{", string(data))
+}
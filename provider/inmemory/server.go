@@ -0,0 +1,191 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package inmemory
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/miekg/dns"
+	log "github.com/sirupsen/logrus"
+
+	"sigs.k8s.io/external-dns/endpoint"
+)
+
+// defaultServerTTL is the TTL of answers for records without a configured TTL
+const defaultServerTTL = 300
+
+// ListenAndServeDNS serves the zones as authoritative DNS server via UDP and TCP on the given address
+// until the context is done.
+func (im *InMemoryProvider) ListenAndServeDNS(ctx context.Context, addr string) error {
+	pc, err := net.ListenPacket("udp", addr)
+	if err != nil {
+		return err
+	}
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		pc.Close()
+		return err
+	}
+
+	return im.serveDNS(ctx, pc, l)
+}
+
+// serveDNS serves the zones on the given connections until the context is done or a server fails
+func (im *InMemoryProvider) serveDNS(ctx context.Context, pc net.PacketConn, l net.Listener) error {
+	handler := dns.HandlerFunc(im.handleDNS)
+	servers := []*dns.Server{
+		{PacketConn: pc, Handler: handler},
+		{Listener: l, Handler: handler},
+	}
+
+	errs := make(chan error, len(servers))
+	for _, server := range servers {
+		go func(server *dns.Server) {
+			errs <- server.ActivateAndServe()
+		}(server)
+	}
+	log.Infof("Serving inmemory zones via DNS on %s", pc.LocalAddr())
+
+	var err error
+	select {
+	case <-ctx.Done():
+	case err = <-errs:
+	}
+	for _, server := range servers {
+		server.Shutdown()
+	}
+	return err
+}
+
+// handleDNS answers a query authoritatively from the records of the zones
+func (im *InMemoryProvider) handleDNS(w dns.ResponseWriter, req *dns.Msg) {
+	m := new(dns.Msg)
+	m.SetReply(req)
+	defer func() {
+		if err := w.WriteMsg(m); err != nil {
+			log.Debugf("Failed to write DNS response: %v", err)
+		}
+	}()
+
+	if len(req.Question) != 1 {
+		m.Rcode = dns.RcodeFormatError
+		return
+	}
+	q := req.Question[0]
+	name := strings.ToLower(strings.TrimSuffix(q.Name, "."))
+
+	zoneID, zoneName := im.findZone(name)
+	if zoneID == "" {
+		m.Rcode = dns.RcodeRefused
+		return
+	}
+	m.Authoritative = true
+
+	records, err := im.client.Records(zoneID)
+	if err != nil {
+		m.Rcode = dns.RcodeServerFailure
+		return
+	}
+
+	var matching []*inMemoryRecord
+	for _, record := range records {
+		if strings.EqualFold(strings.TrimSuffix(record.Name, "."), name) {
+			matching = append(matching, record)
+		}
+	}
+
+	soa := zoneSOA(zoneName)
+	if name == zoneName && (q.Qtype == dns.TypeSOA || q.Qtype == dns.TypeANY) {
+		m.Answer = append(m.Answer, soa)
+	}
+	for _, record := range matching {
+		if q.Qtype == dns.TypeANY || record.Type == dns.TypeToString[q.Qtype] {
+			m.Answer = append(m.Answer, recordRRs(record)...)
+		}
+	}
+	if len(m.Answer) == 0 {
+		// answer with the alias of the name, if any
+		for _, record := range matching {
+			if record.Type == endpoint.RecordTypeCNAME {
+				m.Answer = append(m.Answer, recordRRs(record)...)
+			}
+		}
+	}
+
+	if len(m.Answer) == 0 {
+		if len(matching) == 0 && name != zoneName {
+			m.Rcode = dns.RcodeNameError
+		}
+		m.Ns = append(m.Ns, soa)
+	}
+}
+
+// findZone returns the zone with the longest name the name belongs to
+func (im *InMemoryProvider) findZone(name string) (string, string) {
+	var matchZoneID, matchZoneName string
+	for zoneID, zoneName := range im.Zones() {
+		zoneName = strings.ToLower(strings.TrimSuffix(zoneName, "."))
+		if (name == zoneName || strings.HasSuffix(name, "."+zoneName)) && len(zoneName) >= len(matchZoneName) {
+			matchZoneID, matchZoneName = zoneID, zoneName
+		}
+	}
+	return matchZoneID, matchZoneName
+}
+
+// zoneSOA returns the SOA record of a zone, which is the same for all zones apart from its name
+func zoneSOA(zoneName string) dns.RR {
+	return &dns.SOA{
+		Hdr:     dns.RR_Header{Name: dns.Fqdn(zoneName), Rrtype: dns.TypeSOA, Class: dns.ClassINET, Ttl: defaultServerTTL},
+		Ns:      dns.Fqdn("ns." + zoneName),
+		Mbox:    dns.Fqdn("hostmaster." + zoneName),
+		Serial:  1,
+		Refresh: 3600,
+		Retry:   600,
+		Expire:  86400,
+		Minttl:  defaultServerTTL,
+	}
+}
+
+// recordRRs returns the resource records of the targets of a record
+func recordRRs(record *inMemoryRecord) []dns.RR {
+	ttl := uint32(defaultServerTTL)
+	if record.TTL.IsConfigured() {
+		ttl = uint32(record.TTL)
+	}
+	hdr := dns.RR_Header{Name: dns.Fqdn(record.Name), Class: dns.ClassINET, Ttl: ttl}
+
+	var rrs []dns.RR
+	for _, target := range record.Targets {
+		if record.Type == endpoint.RecordTypeTXT {
+			hdr.Rrtype = dns.TypeTXT
+			// long values consist of several quoted character strings
+			rrs = append(rrs, &dns.TXT{Hdr: hdr, Txt: strings.Split(strings.Trim(target, `"`), `" "`)})
+			continue
+		}
+
+		rr, err := dns.NewRR(fmt.Sprintf("%s %d IN %s %s", hdr.Name, ttl, record.Type, target))
+		if err != nil || rr == nil {
+			log.Debugf("Unable to serve record %s %s %s: %v", record.Name, record.Type, target, err)
+			continue
+		}
+		rrs = append(rrs, rr)
+	}
+	return rrs
+}
@@ -0,0 +1,190 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package inmemory
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"sigs.k8s.io/external-dns/endpoint"
+	"sigs.k8s.io/external-dns/plan"
+)
+
+func TestInMemoryDNSServer(t *testing.T) {
+	im := NewInMemoryProvider(InMemoryInitZones([]string{"example.org", "sub.example.org"}))
+	require.NoError(t, im.ApplyChanges(context.Background(), &plan.Changes{
+		Create: []*endpoint.Endpoint{
+			endpoint.NewEndpointWithTTL("foo.example.org", endpoint.RecordTypeA, 60, "10.0.0.1", "10.0.0.2"),
+			endpoint.NewEndpoint("foo.example.org", endpoint.RecordTypeTXT, `"heritage=external-dns,external-dns/owner=default"`),
+			endpoint.NewEndpoint("www.example.org", endpoint.RecordTypeCNAME, "foo.example.org"),
+			endpoint.NewEndpoint("bar.sub.example.org", endpoint.RecordTypeAAAA, "fd00::1"),
+		},
+	}))
+
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- im.serveDNS(ctx, pc, l) }()
+	defer func() {
+		cancel()
+		assert.NoError(t, <-done)
+	}()
+
+	for _, ti := range []struct {
+		title   string
+		name    string
+		qtype   uint16
+		rcode   int
+		answers []string
+	}{
+		{
+			title:   "multiple targets with TTL",
+			name:    "foo.example.org.",
+			qtype:   dns.TypeA,
+			answers: []string{"foo.example.org.\t60\tIN\tA\t10.0.0.1", "foo.example.org.\t60\tIN\tA\t10.0.0.2"},
+		},
+		{
+			title:   "TXT record with default TTL",
+			name:    "FOO.example.org.",
+			qtype:   dns.TypeTXT,
+			answers: []string{"foo.example.org.\t300\tIN\tTXT\t\"heritage=external-dns,external-dns/owner=default\""},
+		},
+		{
+			title:   "alias",
+			name:    "www.example.org.",
+			qtype:   dns.TypeA,
+			answers: []string{"www.example.org.\t300\tIN\tCNAME\tfoo.example.org."},
+		},
+		{
+			title:   "record of the longest zone",
+			name:    "bar.sub.example.org.",
+			qtype:   dns.TypeAAAA,
+			answers: []string{"bar.sub.example.org.\t300\tIN\tAAAA\tfd00::1"},
+		},
+		{
+			title: "no record of the type",
+			name:  "foo.example.org.",
+			qtype: dns.TypeAAAA,
+		},
+		{
+			title: "unknown name",
+			name:  "baz.example.org.",
+			qtype: dns.TypeA,
+			rcode: dns.RcodeNameError,
+		},
+		{
+			title: "unknown zone",
+			name:  "foo.example.com.",
+			qtype: dns.TypeA,
+			rcode: dns.RcodeRefused,
+		},
+	} {
+		t.Run(ti.title, func(t *testing.T) {
+			for _, network := range []string{"udp", "tcp"} {
+				addr := pc.LocalAddr().String()
+				if network == "tcp" {
+					addr = l.Addr().String()
+				}
+
+				req := new(dns.Msg)
+				req.SetQuestion(ti.name, ti.qtype)
+				c := &dns.Client{Net: network, Timeout: 5 * time.Second}
+				resp, _, err := c.Exchange(req, addr)
+				require.NoError(t, err, network)
+
+				assert.Equal(t, ti.rcode, resp.Rcode, network)
+				var answers []string
+				for _, rr := range resp.Answer {
+					answers = append(answers, rr.String())
+				}
+				assert.ElementsMatch(t, ti.answers, answers, network)
+				if ti.rcode != dns.RcodeRefused {
+					assert.True(t, resp.Authoritative, network)
+				}
+				if len(ti.answers) == 0 && ti.rcode != dns.RcodeRefused {
+					require.Len(t, resp.Ns, 1, network)
+					assert.IsType(t, &dns.SOA{}, resp.Ns[0], network)
+				}
+			}
+		})
+	}
+}
+
+// TestInMemoryDNSServerConcurrentChanges tests that queries are answered while the records change,
+// run with -race to detect concurrent accesses to the records.
+func TestInMemoryDNSServerConcurrentChanges(t *testing.T) {
+	im := NewInMemoryProvider(InMemoryInitZones([]string{"example.org"}))
+	current := endpoint.NewEndpointWithTTL("foo.example.org", endpoint.RecordTypeA, 60, "10.0.0.1")
+	require.NoError(t, im.ApplyChanges(context.Background(), &plan.Changes{Create: []*endpoint.Endpoint{current}}))
+
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- im.serveDNS(ctx, pc, l) }()
+	defer func() {
+		cancel()
+		assert.NoError(t, <-done)
+	}()
+
+	stop := make(chan struct{})
+	changed := make(chan error)
+	go func() {
+		defer close(changed)
+		for i := 2; ; i++ {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			desired := endpoint.NewEndpointWithTTL("foo.example.org", endpoint.RecordTypeA, endpoint.TTL(60+i%100), fmt.Sprintf("10.0.0.%d", i%250))
+			if err := im.ApplyChanges(context.Background(), &plan.Changes{
+				UpdateOld: []*endpoint.Endpoint{current},
+				UpdateNew: []*endpoint.Endpoint{desired},
+			}); err != nil {
+				changed <- err
+				return
+			}
+			current = desired
+		}
+	}()
+
+	c := &dns.Client{Net: "udp", Timeout: 5 * time.Second}
+	for i := 0; i < 200; i++ {
+		req := new(dns.Msg)
+		req.SetQuestion("foo.example.org.", dns.TypeA)
+		resp, _, err := c.Exchange(req, pc.LocalAddr().String())
+		require.NoError(t, err)
+		assert.Len(t, resp.Answer, 1)
+	}
+	close(stop)
+	assert.NoError(t, <-changed)
+}
@@ -32,6 +32,53 @@ type Provider interface {
 	PropertyValuesEqual(name string, previous string, current string) bool
 }
 
+// Capabilities describe what a provider supports, so that no changes are planned which the
+// provider would silently ignore or reject.
+type Capabilities struct {
+	// RecordTypes are the supported record types, including the TXT records of the registry.
+	// The default record types of the planner are planned if it is empty.
+	RecordTypes []string
+	// SetIdentifier is set if records of the same name and type are told apart by their set identifier
+	SetIdentifier bool
+	// TTLGranularity is the step TTLs are rounded up to, TTLs are not rounded if it is zero
+	TTLGranularity endpoint.TTL
+	// MinTTL is the lowest supported TTL, there is no lower bound if it is zero
+	MinTTL endpoint.TTL
+	// MaxTTL is the highest supported TTL, there is no upper bound if it is zero
+	MaxTTL endpoint.TTL
+	// MaxBatchSize is the maximum number of records changed at once, which includes the ownership
+	// records added by the registry. The number of changes is unlimited if it is zero.
+	MaxBatchSize int
+	// AtomicTypeChange is set if the type of a record can be changed by a single update. Otherwise
+	// the record is deleted before it is created again with the new type.
+	AtomicTypeChange bool
+}
+
+// SupportsRecordType tells whether records of the given type are supported
+func (c Capabilities) SupportsRecordType(recordType string) bool {
+	if len(c.RecordTypes) == 0 {
+		return true
+	}
+	for _, t := range c.RecordTypes {
+		if t == recordType {
+			return true
+		}
+	}
+	return false
+}
+
+// CapabilitiesReporter is implemented by providers declaring their capabilities. The changes
+// for providers not implementing it are planned without any restrictions.
+type CapabilitiesReporter interface {
+	Capabilities() Capabilities
+}
+
+// ZoneLister is implemented by providers which can list the names of the zones they manage without
+// trailing dot, keyed by their zone IDs.
+type ZoneLister interface {
+	ZoneIDNames(ctx context.Context) (ZoneIDName, error)
+}
+
 type BaseProvider struct {
 }
 
@@ -0,0 +1,134 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rfc2136
+
+import (
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/require"
+
+	"sigs.k8s.io/external-dns/endpoint"
+	"sigs.k8s.io/external-dns/internal/testutils/providertest"
+	"sigs.k8s.io/external-dns/provider"
+)
+
+const (
+	testServerKey    = "conformance-key."
+	testServerSecret = "c2VjcmV0"
+)
+
+// testServer is a minimal authoritative DNS server accepting dynamic updates and zone transfers
+// signed with its TSIG key
+type testServer struct {
+	zone    string
+	rrs     []dns.RR
+	rrsLock sync.Mutex
+}
+
+func (s *testServer) ServeDNS(w dns.ResponseWriter, req *dns.Msg) {
+	m := new(dns.Msg)
+	m.SetReply(req)
+
+	switch {
+	case req.IsTsig() == nil || w.TsigStatus() != nil:
+		m.Rcode = dns.RcodeNotAuth
+	case req.Opcode == dns.OpcodeUpdate:
+		s.update(req.Ns)
+	case len(req.Question) == 1 && req.Question[0].Qtype == dns.TypeAXFR:
+		s.transfer(w, req)
+		return
+	default:
+		m.Rcode = dns.RcodeNotImplemented
+	}
+
+	if tsig := req.IsTsig(); tsig != nil {
+		m.SetTsig(tsig.Hdr.Name, tsig.Algorithm, tsig.Fudge, time.Now().Unix())
+	}
+	w.WriteMsg(m)
+}
+
+// update applies the update section of an update message as described in RFC 2136 section 3.4.2
+func (s *testServer) update(updates []dns.RR) {
+	s.rrsLock.Lock()
+	defer s.rrsLock.Unlock()
+
+	for _, update := range updates {
+		hdr := update.Header()
+		var kept []dns.RR
+		for _, rr := range s.rrs {
+			var remove bool
+			switch hdr.Class {
+			case dns.ClassANY:
+				remove = rr.Header().Name == hdr.Name && (hdr.Rrtype == dns.TypeANY || rr.Header().Rrtype == hdr.Rrtype)
+			case dns.ClassNONE, dns.ClassINET:
+				// an added record replaces the same record with another TTL
+				rdata := dns.Copy(update)
+				rdata.Header().Class = dns.ClassINET
+				remove = dns.IsDuplicate(rr, rdata)
+			}
+			if !remove {
+				kept = append(kept, rr)
+			}
+		}
+		if hdr.Class == dns.ClassINET {
+			kept = append(kept, update)
+		}
+		s.rrs = kept
+	}
+}
+
+// transfer sends the records of the zone framed by its SOA record
+func (s *testServer) transfer(w dns.ResponseWriter, req *dns.Msg) {
+	s.rrsLock.Lock()
+	soa, _ := dns.NewRR(s.zone + " 3600 IN SOA ns1." + s.zone + " hostmaster." + s.zone + " 1 7200 3600 1209600 3600")
+	rrs := append(append([]dns.RR{soa}, s.rrs...), soa)
+	s.rrsLock.Unlock()
+
+	ch := make(chan *dns.Envelope, 1)
+	ch <- &dns.Envelope{RR: rrs}
+	close(ch)
+	tr := new(dns.Transfer)
+	tr.Out(w, req, ch)
+}
+
+func TestRfc2136Conformance(t *testing.T) {
+	providertest.Run(t, func(t *testing.T) provider.Provider {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+		server := &dns.Server{
+			Listener:   l,
+			Handler:    &testServer{zone: dns.Fqdn(providertest.Zone)},
+			TsigSecret: map[string]string{testServerKey: testServerSecret},
+			// the default rejects updates
+			MsgAcceptFunc: func(dns.Header) dns.MsgAcceptAction { return dns.MsgAccept },
+		}
+		go server.ActivateAndServe()
+		t.Cleanup(func() { server.Shutdown() })
+
+		p, err := NewRfc2136Provider([]string{l.Addr().String()}, 0, []string{providertest.Zone}, nil, false, testServerKey, testServerSecret, "hmac-sha256", true, endpoint.DomainFilter{}, false, 0, nil)
+		require.NoError(t, err)
+		return p
+	}, providertest.Capabilities{
+		RecordTypes:     []string{endpoint.RecordTypeAAAA, endpoint.RecordTypeSRV, endpoint.RecordTypeNS, endpoint.RecordTypeMX, endpoint.RecordTypePTR, endpoint.RecordTypeCAA},
+		MultipleTargets: true,
+		TTL:             true,
+	})
+}
@@ -34,21 +34,25 @@ import (
 )
 
 const (
-	// maximum size of a UDP transport message in DNS protocol
-	udpMaxMsgSize = 512
+	// maximum size of an update message, leaving room for the TSIG record below the 64KB limit of DNS messages over TCP
+	maxUpdateMsgSize = 32 * 1024
 )
 
 // rfc2136 provider type
 type rfc2136Provider struct {
 	provider.BaseProvider
-	nameserver    string
-	zoneName      string
-	tsigKeyName   string
-	tsigSecret    string
-	tsigSecretAlg string
-	insecure      bool
-	axfr          bool
-	minTTL        time.Duration
+	// nameservers are tried in order until one of them succeeds
+	nameservers []string
+	zones       []rfc2136Zone
+	// zoneNames maps the FQDN of each zone to its name for looking up the zone of a record
+	zoneNames provider.ZoneIDName
+	// tsigSecrets holds the secrets of all TSIG keys by their name
+	tsigSecrets map[string]string
+	insecure    bool
+	axfr        bool
+	minTTL      time.Duration
+	// updates are split into messages of at most maxMsgSize bytes
+	maxMsgSize int
 
 	// only consider hosted zones managing domains ending in this suffix
 	domainFilter endpoint.DomainFilter
@@ -56,6 +60,18 @@ type rfc2136Provider struct {
 	actions      rfc2136Actions
 }
 
+// rfc2136Zone is a zone updated by the provider, tsig is nil if its messages are not signed
+type rfc2136Zone struct {
+	name string
+	tsig *rfc2136TSIG
+}
+
+type rfc2136TSIG struct {
+	keyName   string
+	secret    string
+	secretAlg string
+}
+
 var (
 	// Map of supported TSIG algorithms
 	tsigAlgs = map[string]string{
@@ -67,25 +83,53 @@ var (
 )
 
 type rfc2136Actions interface {
-	SendMessage(msg *dns.Msg) error
+	SendMessage(msg *dns.Msg, nameserver string) error
 	IncomeTransfer(m *dns.Msg, a string) (env chan *dns.Envelope, err error)
 }
 
-// NewRfc2136Provider is a factory function for OpenStack rfc2136 providers
-func NewRfc2136Provider(host string, port int, zoneName string, insecure bool, keyName string, secret string, secretAlg string, axfr bool, domainFilter endpoint.DomainFilter, dryRun bool, minTTL time.Duration, actions rfc2136Actions) (provider.Provider, error) {
-	secretAlgChecked, ok := tsigAlgs[secretAlg]
-	if !ok && !insecure {
-		return nil, errors.Errorf("%s is not supported TSIG algorithm", secretAlg)
+// NewRfc2136Provider is a factory function for OpenStack rfc2136 providers.
+// The hosts are the nameservers in order of preference, given with their port or using the given port.
+// The zone TSIG keys have the format <zone>:<keyname>:<alg>:<secret> and override the TSIG key
+// given by keyName, secret and secretAlg for the zone; no zone means the root zone.
+func NewRfc2136Provider(hosts []string, port int, zoneNames []string, zoneTSIGKeys []string, insecure bool, keyName string, secret string, secretAlg string, axfr bool, domainFilter endpoint.DomainFilter, dryRun bool, minTTL time.Duration, actions rfc2136Actions) (provider.Provider, error) {
+	var defaultTSIG *rfc2136TSIG
+	if !insecure {
+		secretAlgChecked, ok := tsigAlgs[secretAlg]
+		if !ok {
+			return nil, errors.Errorf("%s is not supported TSIG algorithm", secretAlg)
+		}
+		defaultTSIG = &rfc2136TSIG{keyName: dns.Fqdn(keyName), secret: secret, secretAlg: secretAlgChecked}
+	}
+
+	zoneTSIG := map[string]*rfc2136TSIG{}
+	for _, zoneTSIGKey := range zoneTSIGKeys {
+		parts := strings.SplitN(zoneTSIGKey, ":", 4)
+		if len(parts) != 4 || parts[0] == "" || parts[1] == "" {
+			return nil, errors.Errorf("invalid zone TSIG key %q, expected <zone>:<keyname>:<alg>:<secret>", strings.SplitN(zoneTSIGKey, ":", 2)[0])
+		}
+		secretAlgChecked, ok := tsigAlgs[parts[2]]
+		if !ok {
+			return nil, errors.Errorf("%s is not supported TSIG algorithm", parts[2])
+		}
+		zoneTSIG[dns.Fqdn(parts[0])] = &rfc2136TSIG{keyName: dns.Fqdn(parts[1]), secret: parts[3], secretAlg: secretAlgChecked}
+	}
+
+	if len(zoneNames) == 0 {
+		zoneNames = []string{""}
+	}
+	if len(hosts) == 0 {
+		hosts = []string{""}
 	}
 
 	r := &rfc2136Provider{
-		nameserver:   net.JoinHostPort(host, strconv.Itoa(port)),
-		zoneName:     dns.Fqdn(zoneName),
+		zoneNames:    provider.ZoneIDName{},
+		tsigSecrets:  map[string]string{},
 		insecure:     insecure,
 		domainFilter: domainFilter,
 		dryRun:       dryRun,
 		axfr:         axfr,
 		minTTL:       minTTL,
+		maxMsgSize:   maxUpdateMsgSize,
 	}
 	if actions != nil {
 		r.actions = actions
@@ -93,13 +137,31 @@ func NewRfc2136Provider(host string, port int, zoneName string, insecure bool, k
 		r.actions = r
 	}
 
-	if !insecure {
-		r.tsigKeyName = dns.Fqdn(keyName)
-		r.tsigSecret = secret
-		r.tsigSecretAlg = secretAlgChecked
+	for _, host := range hosts {
+		if _, _, err := net.SplitHostPort(host); err == nil {
+			r.nameservers = append(r.nameservers, host)
+			continue
+		}
+		r.nameservers = append(r.nameservers, net.JoinHostPort(host, strconv.Itoa(port)))
+	}
+
+	for _, zoneName := range zoneNames {
+		zone := rfc2136Zone{name: dns.Fqdn(zoneName), tsig: defaultTSIG}
+		if tsig, ok := zoneTSIG[zone.name]; ok {
+			zone.tsig = tsig
+			delete(zoneTSIG, zone.name)
+		}
+		if zone.tsig != nil {
+			r.tsigSecrets[zone.tsig.keyName] = zone.tsig.secret
+		}
+		r.zones = append(r.zones, zone)
+		r.zoneNames.Add(zone.name, strings.TrimSuffix(zone.name, "."))
+	}
+	for zoneName := range zoneTSIG {
+		return nil, errors.Errorf("TSIG key given for unknown zone %s", zoneName)
 	}
 
-	log.Infof("Configured RFC2136 with zone '%s' and nameserver '%s'", r.zoneName, r.nameserver)
+	log.Infof("Configured RFC2136 with zones '%s' and nameservers '%s'", zoneNames, r.nameservers)
 	return r, nil
 }
 
@@ -111,8 +173,9 @@ func (r rfc2136Provider) Records(ctx context.Context) ([]*endpoint.Endpoint, err
 	}
 
 	var eps []*endpoint.Endpoint
+	// records are grouped by name and type
+	rrsets := map[string]*endpoint.Endpoint{}
 
-OuterLoop:
 	for _, rr := range rrs {
 		log.Debugf("Record=%s", rr)
 
@@ -127,25 +190,40 @@ OuterLoop:
 		switch rr.Header().Rrtype {
 		case dns.TypeCNAME:
 			rrValues = []string{rr.(*dns.CNAME).Target}
-			rrType = "CNAME"
+			rrType = endpoint.RecordTypeCNAME
 		case dns.TypeA:
 			rrValues = []string{rr.(*dns.A).A.String()}
-			rrType = "A"
+			rrType = endpoint.RecordTypeA
 		case dns.TypeAAAA:
 			rrValues = []string{rr.(*dns.AAAA).AAAA.String()}
-			rrType = "AAAA"
+			rrType = endpoint.RecordTypeAAAA
 		case dns.TypeTXT:
-			rrValues = (rr.(*dns.TXT).Txt)
-			rrType = "TXT"
+			// long values are split into several character strings of one record
+			rrValues = []string{strings.Join(rr.(*dns.TXT).Txt, "")}
+			rrType = endpoint.RecordTypeTXT
+		case dns.TypeNS:
+			if _, ok := r.zoneNames[rrFqdn]; ok {
+				continue // The nameservers of the zone itself
+			}
+			rrValues = []string{rdata(rr)}
+			rrType = endpoint.RecordTypeNS
+		case dns.TypeSRV, dns.TypeMX, dns.TypePTR, dns.TypeCAA:
+			rrValues = []string{rdata(rr)}
+			rrType = dns.TypeToString[rr.Header().Rrtype]
 		default:
 			continue // Unhandled record type
 		}
 
-		for idx, existingEndpoint := range eps {
-			if existingEndpoint.DNSName == strings.TrimSuffix(rrFqdn, ".") && existingEndpoint.RecordType == rrType {
-				eps[idx].Targets = append(eps[idx].Targets, rrValues...)
-				continue OuterLoop
+		key := strings.ToLower(rrFqdn) + " " + rrType
+		if ep, ok := rrsets[key]; ok {
+			for _, value := range rrValues {
+				ep.Targets = append(ep.Targets, strings.TrimSuffix(value, "."))
+			}
+			// the records of a set should share their TTL, otherwise the lowest one applies
+			if rrTTL < ep.RecordTTL {
+				ep.RecordTTL = rrTTL
 			}
+			continue
 		}
 
 		ep := endpoint.NewEndpointWithTTL(
@@ -155,99 +233,207 @@ OuterLoop:
 			rrValues...,
 		)
 
+		rrsets[key] = ep
 		eps = append(eps, ep)
 	}
 
 	return eps, nil
 }
 
+// rdata returns the data of a record in presentation format, e.g. "10 mail.example.org." for MX records
+func rdata(rr dns.RR) string {
+	return strings.TrimPrefix(rr.String(), rr.Header().String())
+}
+
 func (r rfc2136Provider) IncomeTransfer(m *dns.Msg, a string) (env chan *dns.Envelope, err error) {
 	t := new(dns.Transfer)
-	if !r.insecure {
-		t.TsigSecret = map[string]string{r.tsigKeyName: r.tsigSecret}
+	if len(r.tsigSecrets) > 0 {
+		t.TsigSecret = r.tsigSecrets
 	}
 
-	return t.In(m, r.nameserver)
+	return t.In(m, a)
 }
 
+// List returns the records of all zones.
 func (r rfc2136Provider) List() ([]dns.RR, error) {
 	if !r.axfr {
 		log.Debug("axfr is disabled")
 		return make([]dns.RR, 0), nil
 	}
 
-	log.Debugf("Fetching records for '%s'", r.zoneName)
+	records := make([]dns.RR, 0)
+	for _, zone := range r.zones {
+		zoneRecords, err := r.listZone(zone)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, zoneRecords...)
+	}
+
+	return records, nil
+}
+
+// listZone fetches the records of a zone via AXFR from the first nameserver which succeeds
+func (r rfc2136Provider) listZone(zone rfc2136Zone) ([]dns.RR, error) {
+	log.Debugf("Fetching records for '%s'", zone.name)
 
+	var err error
+	for _, nameserver := range r.nameservers {
+		var records []dns.RR
+		records, err = r.transferZone(zone, nameserver)
+		if err == nil {
+			return records, nil
+		}
+		log.Warnf("Failed to fetch records of zone '%s' from nameserver '%s': %v", zone.name, nameserver, err)
+	}
+
+	return nil, fmt.Errorf("failed to fetch records via AXFR: %v", err)
+}
+
+func (r rfc2136Provider) transferZone(zone rfc2136Zone, nameserver string) ([]dns.RR, error) {
 	m := new(dns.Msg)
-	m.SetAxfr(r.zoneName)
-	if !r.insecure {
-		m.SetTsig(r.tsigKeyName, r.tsigSecretAlg, 300, time.Now().Unix())
+	m.SetAxfr(zone.name)
+	if zone.tsig != nil {
+		m.SetTsig(zone.tsig.keyName, zone.tsig.secretAlg, 300, time.Now().Unix())
 	}
 
-	env, err := r.actions.IncomeTransfer(m, r.nameserver)
+	env, err := r.actions.IncomeTransfer(m, nameserver)
 	if err != nil {
-		return nil, fmt.Errorf("failed to fetch records via AXFR: %v", err)
+		return nil, err
 	}
 
 	records := make([]dns.RR, 0)
 	for e := range env {
 		if e.Error != nil {
 			if e.Error == dns.ErrSoa {
-				log.Error("AXFR error: unexpected response received from the server")
+				err = errors.New("unexpected response received from the server")
 			} else {
-				log.Errorf("AXFR error: %v", e.Error)
+				err = e.Error
 			}
 			continue
 		}
 		records = append(records, e.RR...)
 	}
+	if err != nil {
+		return nil, err
+	}
 
 	return records, nil
 }
 
-// ApplyChanges applies a given set of changes in a given zone.
+// ApplyChanges applies a given set of changes, sending the changes of each zone in batches
+// of limited size. A failed batch doesn't prevent the others from being sent.
 func (r rfc2136Provider) ApplyChanges(ctx context.Context, changes *plan.Changes) error {
 	log.Debugf("ApplyChanges (Create: %d, UpdateOld: %d, UpdateNew: %d, Delete: %d)", len(changes.Create), len(changes.UpdateOld), len(changes.UpdateNew), len(changes.Delete))
 
-	m := new(dns.Msg)
-	m.SetUpdate(r.zoneName)
-
-	for _, ep := range changes.Create {
+	// the records of each change by zone, the records of a change are always sent together
+	zoneChanges := map[string][][]dns.RR{}
+	addChange := func(ep *endpoint.Endpoint, build func(m *dns.Msg) error) {
 		if !r.domainFilter.Match(ep.DNSName) {
 			log.Debugf("Skipping record %s because it was filtered out by the specified --domain-filter", ep.DNSName)
-			continue
+			return
 		}
+		zone := r.findZone(ep.DNSName)
+		if zone == nil {
+			log.Debugf("Skipping record %s because no zone was found", ep.DNSName)
+			return
+		}
+		m := new(dns.Msg)
+		m.SetUpdate(zone.name)
+		if err := build(m); err != nil {
+			log.Errorf("Skipping record %s: %v", ep.DNSName, err)
+			return
+		}
+		zoneChanges[zone.name] = append(zoneChanges[zone.name], m.Ns)
+	}
 
-		r.AddRecord(m, ep)
+	for _, ep := range changes.Create {
+		addChange(ep, func(m *dns.Msg) error { return r.AddRecord(m, ep) })
 	}
 	for i, ep := range changes.UpdateNew {
-		if !r.domainFilter.Match(ep.DNSName) {
-			log.Debugf("Skipping record %s because it was filtered out by the specified --domain-filter", ep.DNSName)
+		oldEp := changes.UpdateOld[i]
+		if oldEp.Targets.Same(ep.Targets) && int64(oldEp.RecordTTL) == r.ttl(ep) {
+			log.Debugf("Skipping update of record %s because its TTL is raised to the minimum TTL", ep.DNSName)
 			continue
 		}
-
-		r.UpdateRecord(m, changes.UpdateOld[i], ep)
+		addChange(ep, func(m *dns.Msg) error { return r.UpdateRecord(m, oldEp, ep) })
 	}
 	for _, ep := range changes.Delete {
-		if !r.domainFilter.Match(ep.DNSName) {
-			log.Debugf("Skipping record %s because it was filtered out by the specified --domain-filter", ep.DNSName)
-			continue
-		}
+		addChange(ep, func(m *dns.Msg) error { return r.RemoveRecord(m, ep) })
+	}
 
-		r.RemoveRecord(m, ep)
+	batches, failed := 0, 0
+	for _, zone := range r.zones {
+		for _, m := range r.batches(zone, zoneChanges[zone.name]) {
+			batches++
+			if err := r.sendUpdate(zone, m); err != nil {
+				failed++
+				log.Errorf("RFC2136 update of %d records in zone %s failed: %v", len(m.Ns), zone.name, err)
+			}
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("RFC2136 update failed for %d of %d batches", failed, batches)
 	}
 
-	// only send if there are records available
-	if len(m.Ns) > 0 {
-		err := r.actions.SendMessage(m)
-		if err != nil {
-			return fmt.Errorf("RFC2136 update failed: %v", err)
+	return nil
+}
+
+// batches splits the changes of a zone into update messages of at most maxMsgSize bytes.
+// A single change exceeding the size is sent in a message of its own.
+func (r rfc2136Provider) batches(zone rfc2136Zone, changes [][]dns.RR) []*dns.Msg {
+	var batches []*dns.Msg
+	var m *dns.Msg
+	for _, rrs := range changes {
+		if m != nil {
+			n := len(m.Ns)
+			m.Ns = append(m.Ns, rrs...)
+			if m.Len() <= r.maxMsgSize {
+				continue
+			}
+			m.Ns = m.Ns[:n]
 		}
+		m = new(dns.Msg)
+		m.SetUpdate(zone.name)
+		m.Ns = append(m.Ns, rrs...)
+		batches = append(batches, m)
 	}
+	return batches
+}
 
+// findZone returns the zone of a record, which is the zone with the longest matching name
+func (r rfc2136Provider) findZone(hostname string) *rfc2136Zone {
+	zoneName, _ := r.zoneNames.FindZone(strings.TrimSuffix(hostname, "."))
+	if zoneName == "" {
+		// the root zone contains all records
+		zoneName = "."
+	}
+	for i := range r.zones {
+		if r.zones[i].name == zoneName {
+			return &r.zones[i]
+		}
+	}
 	return nil
 }
 
+// sendUpdate sends an update signed with the key of its zone to the first nameserver which accepts it
+func (r rfc2136Provider) sendUpdate(zone rfc2136Zone, m *dns.Msg) error {
+	var err error
+	for _, nameserver := range r.nameservers {
+		msg := m.Copy()
+		if zone.tsig != nil {
+			msg.SetTsig(zone.tsig.keyName, zone.tsig.secretAlg, 300, time.Now().Unix())
+		}
+
+		err = r.actions.SendMessage(msg, nameserver)
+		if err == nil {
+			return nil
+		}
+		log.Warnf("Failed to update zone '%s' on nameserver '%s': %v", zone.name, nameserver, err)
+	}
+	return err
+}
+
 func (r rfc2136Provider) UpdateRecord(m *dns.Msg, oldEp *endpoint.Endpoint, newEp *endpoint.Endpoint) error {
 	err := r.RemoveRecord(m, oldEp)
 	if err != nil {
@@ -260,11 +446,7 @@ func (r rfc2136Provider) UpdateRecord(m *dns.Msg, oldEp *endpoint.Endpoint, newE
 func (r rfc2136Provider) AddRecord(m *dns.Msg, ep *endpoint.Endpoint) error {
 	log.Debugf("AddRecord.ep=%s", ep)
 
-	var ttl = int64(r.minTTL.Seconds())
-	if ep.RecordTTL.IsConfigured() && int64(ep.RecordTTL) > ttl {
-		ttl = int64(ep.RecordTTL)
-	}
-
+	ttl := r.ttl(ep)
 	for _, target := range ep.Targets {
 		newRR := fmt.Sprintf("%s %d %s %s", ep.DNSName, ttl, ep.RecordType, target)
 		log.Infof("Adding RR: %s", newRR)
@@ -280,6 +462,30 @@ func (r rfc2136Provider) AddRecord(m *dns.Msg, ep *endpoint.Endpoint) error {
 	return nil
 }
 
+// ZoneIDNames returns the configured zones.
+func (r rfc2136Provider) ZoneIDNames(ctx context.Context) (provider.ZoneIDName, error) {
+	return r.zoneNames, nil
+}
+
+// Capabilities returns the record types the provider supports and its minimum TTL. The type of a record
+// is changed atomically, since the old records are removed by the same update message adding the new ones.
+func (r rfc2136Provider) Capabilities() provider.Capabilities {
+	return provider.Capabilities{
+		RecordTypes:      []string{endpoint.RecordTypeA, endpoint.RecordTypeAAAA, endpoint.RecordTypeCNAME, endpoint.RecordTypeTXT, endpoint.RecordTypeSRV, endpoint.RecordTypeNS, endpoint.RecordTypeMX, endpoint.RecordTypePTR, endpoint.RecordTypeCAA},
+		MinTTL:           endpoint.TTL(r.minTTL.Seconds()),
+		AtomicTypeChange: true,
+	}
+}
+
+// ttl returns the TTL of a record, which is at least the minimum TTL
+func (r rfc2136Provider) ttl(ep *endpoint.Endpoint) int64 {
+	var ttl = int64(r.minTTL.Seconds())
+	if ep.RecordTTL.IsConfigured() && int64(ep.RecordTTL) > ttl {
+		ttl = int64(ep.RecordTTL)
+	}
+	return ttl
+}
+
 func (r rfc2136Provider) RemoveRecord(m *dns.Msg, ep *endpoint.Endpoint) error {
 	log.Debugf("RemoveRecord.ep=%s", ep)
 	for _, target := range ep.Targets {
@@ -297,7 +503,7 @@ func (r rfc2136Provider) RemoveRecord(m *dns.Msg, ep *endpoint.Endpoint) error {
 	return nil
 }
 
-func (r rfc2136Provider) SendMessage(msg *dns.Msg) error {
+func (r rfc2136Provider) SendMessage(msg *dns.Msg, nameserver string) error {
 	if r.dryRun {
 		log.Debugf("SendMessage.skipped")
 		return nil
@@ -307,16 +513,14 @@ func (r rfc2136Provider) SendMessage(msg *dns.Msg) error {
 	c := new(dns.Client)
 	c.SingleInflight = true
 
-	if !r.insecure {
-		c.TsigSecret = map[string]string{r.tsigKeyName: r.tsigSecret}
-		msg.SetTsig(r.tsigKeyName, r.tsigSecretAlg, 300, time.Now().Unix())
+	if len(r.tsigSecrets) > 0 {
+		c.TsigSecret = r.tsigSecrets
 	}
 
-	if msg.Len() > udpMaxMsgSize {
-		c.Net = "tcp"
-	}
+	// updates may exceed the size of UDP messages
+	c.Net = "tcp"
 
-	resp, _, err := c.Exchange(msg, r.nameserver)
+	resp, _, err := c.Exchange(msg, nameserver)
 	if err != nil {
 		log.Infof("error in dns.Client.Exchange: %s", err)
 		return err
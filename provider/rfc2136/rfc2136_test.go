@@ -18,6 +18,7 @@ package rfc2136
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"strings"
 	"testing"
@@ -36,6 +37,14 @@ type rfc2136Stub struct {
 	output     []*dns.Envelope
 	updateMsgs []*dns.Msg
 	createMsgs []*dns.Msg
+	// failing nameservers return an error for every request
+	failing map[string]bool
+	// updates containing this text are rejected
+	rejected string
+	// sentMsgs holds every accepted update
+	sentMsgs []*dns.Msg
+	// requests holds the zone and nameserver of every sent update and zone transfer
+	requests []string
 }
 
 func newStub() *rfc2136Stub {
@@ -46,7 +55,15 @@ func newStub() *rfc2136Stub {
 	}
 }
 
-func (r *rfc2136Stub) SendMessage(msg *dns.Msg) error {
+func (r *rfc2136Stub) SendMessage(msg *dns.Msg, nameserver string) error {
+	r.requests = append(r.requests, "UPDATE "+msg.Question[0].Name+" "+nameserver)
+	if r.failing[nameserver] {
+		return errors.New("connection refused")
+	}
+	if r.rejected != "" && strings.Contains(msg.String(), r.rejected) {
+		return errors.New("bad return code: REFUSED")
+	}
+	r.sentMsgs = append(r.sentMsgs, msg)
 	log.Info(msg.String())
 	lines := extractAuthoritySectionFromMessage(msg)
 	for _, line := range lines {
@@ -83,6 +100,10 @@ func (r *rfc2136Stub) setOutput(output []string) error {
 }
 
 func (r *rfc2136Stub) IncomeTransfer(m *dns.Msg, a string) (env chan *dns.Envelope, err error) {
+	r.requests = append(r.requests, "AXFR "+m.Question[0].Name+" "+a)
+	if r.failing[a] {
+		return nil, errors.New("connection refused")
+	}
 	outChan := make(chan *dns.Envelope)
 	go func() {
 		for _, e := range r.output {
@@ -95,13 +116,15 @@ func (r *rfc2136Stub) IncomeTransfer(m *dns.Msg, a string) (env chan *dns.Envelo
 }
 
 func createRfc2136StubProvider(stub *rfc2136Stub) (provider.Provider, error) {
-	return NewRfc2136Provider("", 0, "", false, "key", "secret", "hmac-sha512", true, endpoint.DomainFilter{}, false, 300*time.Second, stub)
+	return NewRfc2136Provider(nil, 0, nil, nil, false, "key", "secret", "hmac-sha512", true, endpoint.DomainFilter{}, false, 300*time.Second, stub)
 }
 
 func extractAuthoritySectionFromMessage(msg fmt.Stringer) []string {
 	const searchPattern = "AUTHORITY SECTION:"
 	authoritySectionOffset := strings.Index(msg.String(), searchPattern)
-	return strings.Split(strings.TrimSpace(msg.String()[authoritySectionOffset+len(searchPattern):]), "\n")
+	authoritySection := strings.TrimSpace(msg.String()[authoritySectionOffset+len(searchPattern):])
+	// the authority section ends at the first empty line, followed by the additional section of signed messages
+	return strings.Split(strings.Split(authoritySection, "\n\n")[0], "\n")
 }
 
 // TestRfc2136GetRecordsMultipleTargets simulates a single record with multiple targets.
@@ -326,6 +349,223 @@ func TestRfc2136ApplyChangesWithUpdate(t *testing.T) {
 
 }
 
+func TestNewRfc2136Provider(t *testing.T) {
+	for _, ti := range []struct {
+		title        string
+		zones        []string
+		zoneTSIGKeys []string
+		insecure     bool
+		secretAlg    string
+	}{
+		{title: "unsupported algorithm", secretAlg: "hmac-foo"},
+		{title: "invalid zone key", zones: []string{"foo.com"}, zoneTSIGKeys: []string{"foo.com:key"}, secretAlg: "hmac-sha256"},
+		{title: "unsupported zone key algorithm", zones: []string{"foo.com"}, zoneTSIGKeys: []string{"foo.com:key:hmac-foo:secret"}, insecure: true},
+		{title: "key of unknown zone", zones: []string{"foo.com"}, zoneTSIGKeys: []string{"bar.com:key:hmac-sha256:secret"}, insecure: true},
+	} {
+		t.Run(ti.title, func(t *testing.T) {
+			_, err := NewRfc2136Provider([]string{"ns1"}, 53, ti.zones, ti.zoneTSIGKeys, ti.insecure, "key", "secret", ti.secretAlg, true, endpoint.DomainFilter{}, false, 0, newStub())
+			assert.Error(t, err)
+		})
+	}
+
+	p, err := NewRfc2136Provider([]string{"ns1", "ns2:5353", "[fd00::1]:53"}, 53, []string{"foo.com", "bar.com."}, []string{"bar.com:bar-key:hmac-sha256:c2VjcmV0"}, false, "key", "secret", "hmac-sha512", true, endpoint.DomainFilter{}, false, 0, nil)
+	assert.NoError(t, err)
+	r := p.(*rfc2136Provider)
+	assert.Equal(t, []string{"ns1:53", "ns2:5353", "[fd00::1]:53"}, r.nameservers)
+	assert.Equal(t, []rfc2136Zone{
+		{name: "foo.com.", tsig: &rfc2136TSIG{keyName: "key.", secret: "secret", secretAlg: dns.HmacSHA512}},
+		{name: "bar.com.", tsig: &rfc2136TSIG{keyName: "bar-key.", secret: "c2VjcmV0", secretAlg: dns.HmacSHA256}},
+	}, r.zones)
+	assert.Equal(t, map[string]string{"key.": "secret", "bar-key.": "c2VjcmV0"}, r.tsigSecrets)
+}
+
+// TestRfc2136ApplyChangesMultipleZones tests that changes are sent to the zone with the longest matching name.
+func TestRfc2136ApplyChangesMultipleZones(t *testing.T) {
+	stub := newStub()
+	provider, err := NewRfc2136Provider([]string{"ns1"}, 53, []string{"foo.com", "sub.foo.com", "bar.com"}, []string{"bar.com:bar-key:hmac-sha256:c2VjcmV0"}, false, "key", "secret", "hmac-sha512", true, endpoint.DomainFilter{}, false, 0, stub)
+	assert.NoError(t, err)
+
+	err = provider.ApplyChanges(context.Background(), &plan.Changes{
+		Create: []*endpoint.Endpoint{
+			{DNSName: "v1.foo.com", RecordType: "A", Targets: []string{"1.2.3.4"}},
+			{DNSName: "v1.sub.foo.com", RecordType: "A", Targets: []string{"1.2.3.5"}},
+			{DNSName: "bar.com", RecordType: "A", Targets: []string{"1.2.3.6"}},
+			{DNSName: "v1.other.com", RecordType: "A", Targets: []string{"1.2.3.7"}},
+		},
+		Delete: []*endpoint.Endpoint{
+			{DNSName: "v2.sub.foo.com", RecordType: "A", Targets: []string{"1.2.3.8"}},
+		},
+	})
+	assert.NoError(t, err)
+
+	assert.Equal(t, []string{"UPDATE foo.com. ns1:53", "UPDATE sub.foo.com. ns1:53", "UPDATE bar.com. ns1:53"}, stub.requests)
+	assert.Len(t, stub.createMsgs, 3)
+	assert.Contains(t, stub.createMsgs[0].String(), "v1.foo.com")
+	assert.Contains(t, stub.createMsgs[1].String(), "v1.sub.foo.com")
+	assert.NotContains(t, stub.createMsgs[1].String(), "1.2.3.7")
+	assert.Len(t, stub.updateMsgs, 1)
+	assert.Contains(t, stub.updateMsgs[0].String(), "v2.sub.foo.com")
+
+	// each zone is signed with its own key
+	assert.Equal(t, "key.", stub.createMsgs[0].IsTsig().Hdr.Name)
+	assert.Equal(t, "bar-key.", stub.createMsgs[2].IsTsig().Hdr.Name)
+}
+
+// TestRfc2136Failover tests that the next nameserver is used if one fails.
+func TestRfc2136Failover(t *testing.T) {
+	stub := newStub()
+	stub.failing = map[string]bool{"ns1:53": true}
+	err := stub.setOutput([]string{"v1.foo.com 3600 IN A 1.2.3.4"})
+	assert.NoError(t, err)
+
+	provider, err := NewRfc2136Provider([]string{"ns1", "ns2"}, 53, []string{"foo.com", "bar.com"}, nil, true, "", "", "", true, endpoint.DomainFilter{}, false, 0, stub)
+	assert.NoError(t, err)
+
+	recs, err := provider.Records(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, recs, 1)
+
+	err = provider.ApplyChanges(context.Background(), &plan.Changes{
+		Create: []*endpoint.Endpoint{{DNSName: "v2.foo.com", RecordType: "A", Targets: []string{"1.2.3.5"}}},
+	})
+	assert.NoError(t, err)
+	assert.Len(t, stub.createMsgs, 1)
+	assert.Nil(t, stub.createMsgs[0].IsTsig())
+
+	assert.Equal(t, []string{
+		"AXFR foo.com. ns1:53", "AXFR foo.com. ns2:53",
+		"AXFR bar.com. ns1:53", "AXFR bar.com. ns2:53",
+		"UPDATE foo.com. ns1:53", "UPDATE foo.com. ns2:53",
+	}, stub.requests)
+
+	// the error of the last nameserver is returned if all fail
+	stub.failing["ns2:53"] = true
+	_, err = provider.Records(context.Background())
+	assert.Error(t, err)
+	err = provider.ApplyChanges(context.Background(), &plan.Changes{
+		Create: []*endpoint.Endpoint{{DNSName: "v2.foo.com", RecordType: "A", Targets: []string{"1.2.3.5"}}},
+	})
+	assert.Error(t, err)
+}
+
+func TestRfc2136GetRecordsOfAllTypes(t *testing.T) {
+	stub := newStub()
+	err := stub.setOutput([]string{
+		"foo.com 3600 IN NS ns1.foo.com.",
+		"sub.foo.com 3600 IN NS ns1.sub.foo.com.",
+		"sub.foo.com 3600 IN NS ns2.sub.foo.com.",
+		"foo.com 3600 IN MX 10 mail1.foo.com.",
+		"foo.com 300 IN MX 20 mail2.foo.com.",
+		"_sip._udp.foo.com 3600 IN SRV 0 50 5060 sip.foo.com.",
+		"4.3.2.1.in-addr.arpa 3600 IN PTR v1.foo.com.",
+		"foo.com 3600 IN CAA 0 issue \"letsencrypt.org\"",
+		"foo.com 3600 IN HINFO \"PC\" \"Linux\"",
+	})
+	assert.NoError(t, err)
+
+	provider, err := NewRfc2136Provider(nil, 0, []string{"foo.com"}, nil, true, "", "", "", true, endpoint.DomainFilter{}, false, 0, stub)
+	assert.NoError(t, err)
+
+	recs, err := provider.Records(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, []*endpoint.Endpoint{
+		endpoint.NewEndpointWithTTL("sub.foo.com", endpoint.RecordTypeNS, 3600, "ns1.sub.foo.com.", "ns2.sub.foo.com."),
+		endpoint.NewEndpointWithTTL("foo.com", endpoint.RecordTypeMX, 300, "10 mail1.foo.com.", "20 mail2.foo.com."),
+		endpoint.NewEndpointWithTTL("_sip._udp.foo.com", endpoint.RecordTypeSRV, 3600, "0 50 5060 sip.foo.com."),
+		endpoint.NewEndpointWithTTL("4.3.2.1.in-addr.arpa", endpoint.RecordTypePTR, 3600, "v1.foo.com."),
+		endpoint.NewEndpointWithTTL("foo.com", endpoint.RecordTypeCAA, 3600, "0 issue \"letsencrypt.org\""),
+	}, recs)
+}
+
+func TestRfc2136ApplyChangesOfAllTypes(t *testing.T) {
+	stub := newStub()
+	provider, err := createRfc2136StubProvider(stub)
+	assert.NoError(t, err)
+
+	err = provider.ApplyChanges(context.Background(), &plan.Changes{
+		Create: []*endpoint.Endpoint{
+			endpoint.NewEndpoint("sub.foo.com", endpoint.RecordTypeNS, "ns1.sub.foo.com", "ns2.sub.foo.com"),
+			endpoint.NewEndpoint("foo.com", endpoint.RecordTypeMX, "10 mail.foo.com"),
+			endpoint.NewEndpoint("_sip._udp.foo.com", endpoint.RecordTypeSRV, "0 50 5060 sip.foo.com"),
+			endpoint.NewEndpoint("4.3.2.1.in-addr.arpa", endpoint.RecordTypePTR, "v1.foo.com"),
+			endpoint.NewEndpoint("foo.com", endpoint.RecordTypeCAA, "0 issue \"letsencrypt.org\""),
+		},
+	})
+	assert.NoError(t, err)
+
+	createRecords := extractAuthoritySectionFromMessage(stub.sentMsgs[0])
+	assert.Equal(t, []string{
+		"sub.foo.com.\t300\tIN\tNS\tns1.sub.foo.com.",
+		"sub.foo.com.\t300\tIN\tNS\tns2.sub.foo.com.",
+		"foo.com.\t300\tIN\tMX\t10 mail.foo.com.",
+		"_sip._udp.foo.com.\t300\tIN\tSRV\t0 50 5060 sip.foo.com.",
+		"4.3.2.1.in-addr.arpa.\t300\tIN\tPTR\tv1.foo.com.",
+		"foo.com.\t300\tIN\tCAA\t0 issue \"letsencrypt.org\"",
+	}, createRecords)
+}
+
+// TestRfc2136ApplyChangesInBatches tests that large updates are split and failed batches don't stop the others.
+func TestRfc2136ApplyChangesInBatches(t *testing.T) {
+	stub := newStub()
+	stub.rejected = "v3.foo.com"
+	provider, err := createRfc2136StubProvider(stub)
+	assert.NoError(t, err)
+	provider.(*rfc2136Provider).maxMsgSize = 80
+
+	err = provider.ApplyChanges(context.Background(), &plan.Changes{
+		Create: []*endpoint.Endpoint{
+			endpoint.NewEndpoint("v1.foo.com", endpoint.RecordTypeA, "1.2.3.4"),
+			endpoint.NewEndpoint("v2.foo.com", endpoint.RecordTypeA, "1.2.3.4"),
+			endpoint.NewEndpoint("v3.foo.com", endpoint.RecordTypeA, "1.2.3.4"),
+		},
+		UpdateOld: []*endpoint.Endpoint{
+			endpoint.NewEndpoint("v4.foo.com", endpoint.RecordTypeA, "1.2.3.4"),
+		},
+		UpdateNew: []*endpoint.Endpoint{
+			endpoint.NewEndpoint("v4.foo.com", endpoint.RecordTypeA, "1.2.3.5"),
+		},
+	})
+	assert.EqualError(t, err, "RFC2136 update failed for 1 of 3 batches")
+
+	assert.Len(t, stub.requests, 3)
+	// the removal and addition of an update are sent together
+	assert.Len(t, stub.sentMsgs, 2)
+	assert.Equal(t, []string{
+		"v1.foo.com.\t300\tIN\tA\t1.2.3.4",
+		"v2.foo.com.\t300\tIN\tA\t1.2.3.4",
+	}, extractAuthoritySectionFromMessage(stub.sentMsgs[0]))
+	assert.Equal(t, []string{
+		"v4.foo.com.\t0\tNONE\tA\t1.2.3.4",
+		"v4.foo.com.\t300\tIN\tA\t1.2.3.5",
+	}, extractAuthoritySectionFromMessage(stub.sentMsgs[1]))
+}
+
+// TestRfc2136ApplyChangesBelowMinTTL tests that TTL updates without effect due to the minimum TTL are skipped.
+func TestRfc2136ApplyChangesBelowMinTTL(t *testing.T) {
+	stub := newStub()
+	provider, err := createRfc2136StubProvider(stub)
+	assert.NoError(t, err)
+
+	err = provider.ApplyChanges(context.Background(), &plan.Changes{
+		UpdateOld: []*endpoint.Endpoint{
+			endpoint.NewEndpointWithTTL("v1.foo.com", endpoint.RecordTypeA, 300, "1.2.3.4"),
+			endpoint.NewEndpointWithTTL("v2.foo.com", endpoint.RecordTypeA, 300, "1.2.3.4"),
+		},
+		UpdateNew: []*endpoint.Endpoint{
+			endpoint.NewEndpointWithTTL("v1.foo.com", endpoint.RecordTypeA, 60, "1.2.3.4"),
+			endpoint.NewEndpointWithTTL("v2.foo.com", endpoint.RecordTypeA, 600, "1.2.3.4"),
+		},
+	})
+	assert.NoError(t, err)
+
+	assert.Len(t, stub.requests, 1)
+	assert.NotContains(t, stub.requests[0], "v1.foo.com")
+	assert.Equal(t, []string{
+		"v2.foo.com.\t0\tNONE\tA\t1.2.3.4",
+		"v2.foo.com.\t600\tIN\tA\t1.2.3.4",
+	}, extractAuthoritySectionFromMessage(stub.sentMsgs[0]))
+}
+
 func contains(arr []*endpoint.Endpoint, name string) bool {
 	for _, a := range arr {
 		if a.DNSName == name {
@@ -0,0 +1,92 @@
+package wunderdns
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"sigs.k8s.io/external-dns/endpoint"
+	"sigs.k8s.io/external-dns/internal/testutils/providertest"
+	"sigs.k8s.io/external-dns/provider"
+)
+
+// testAPI is a minimal WunderDNS API serving the zone in both views
+type testAPI struct {
+	records     map[string]map[string]map[string]interface{} // view - name and type - record
+	recordsLock sync.Mutex
+}
+
+func (a *testAPI) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	a.recordsLock.Lock()
+	defer a.recordsLock.Unlock()
+
+	reply := func(data interface{}) {
+		json.NewEncoder(w).Encode(map[string]interface{}{"status": "SUCCESS", "data": data})
+	}
+
+	switch {
+	case req.URL.Path == "/domain" && req.Method == http.MethodGet:
+		domains := []interface{}{map[string]string{"n": providertest.Zone}}
+		reply(map[string]interface{}{viewPublic: domains, viewPrivate: domains})
+	case req.URL.Path == "/record" && req.Method == http.MethodGet:
+		data := map[string][]interface{}{viewPublic: {}, viewPrivate: {}}
+		for view, records := range a.records {
+			for _, rec := range records {
+				data[view] = append(data[view], rec)
+			}
+		}
+		reply(data)
+	case req.URL.Path == "/record":
+		var body struct {
+			Domain string `json:"domain"`
+			Record []struct {
+				Target string   `json:"target"`
+				Type   string   `json:"type"`
+				View   string   `json:"view"`
+				TTL    int      `json:"ttl"`
+				Data   []string `json:"data"`
+			} `json:"record"`
+		}
+		if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		for _, rec := range body.Record {
+			name := rec.Target + "." + body.Domain
+			if rec.Target == "." {
+				name = body.Domain
+			}
+			if a.records[rec.View] == nil {
+				a.records[rec.View] = map[string]map[string]interface{}{}
+			}
+			key := name + " " + rec.Type
+			if req.Method == http.MethodDelete {
+				delete(a.records[rec.View], key)
+				continue
+			}
+			a.records[rec.View][key] = map[string]interface{}{"n": name, "t": rec.Type, "l": rec.TTL, "d": rec.Data}
+		}
+		reply(map[string]interface{}{})
+	default:
+		http.NotFound(w, req)
+	}
+}
+
+func TestWunderDNSConformance(t *testing.T) {
+	providertest.Run(t, func(t *testing.T) provider.Provider {
+		server := httptest.NewServer(&testAPI{records: map[string]map[string]map[string]interface{}{}})
+		t.Cleanup(server.Close)
+
+		p, err := NewProvider(endpoint.DomainFilter{}, server.URL, "token", "secret", false)
+		require.NoError(t, err)
+		return p
+	}, providertest.Capabilities{
+		RecordTypes:     []string{endpoint.RecordTypeAAAA, endpoint.RecordTypeSRV, endpoint.RecordTypeMX},
+		MultipleTargets: true,
+		TTL:             true,
+	})
+}
@@ -0,0 +1,395 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package zonefile
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+
+	"sigs.k8s.io/external-dns/endpoint"
+	"sigs.k8s.io/external-dns/plan"
+	"sigs.k8s.io/external-dns/provider"
+)
+
+const (
+	// defaultTTL is the TTL of records without a configured TTL
+	defaultTTL = 300
+	// reloadTimeout limits the runtime of the reload command
+	reloadTimeout = time.Minute
+)
+
+// zonefileProvider maintains one RFC 1035 master file per zone in a directory, named <zone>.zone.
+// Records which are not part of a change, including the SOA and NS records of the zone, are kept
+// as they are, but comments and formatting of the files are not preserved.
+type zonefileProvider struct {
+	provider.BaseProvider
+	directory string
+	// zoneNames maps the FQDN of each zone to its name for looking up the zone of a record
+	zoneNames     provider.ZoneIDName
+	domainFilter  endpoint.DomainFilter
+	reloadCommand string
+	// reloadPending is set while zone files were written without a successful reload afterwards
+	reloadPending bool
+	dryRun        bool
+}
+
+// NewZonefileProvider creates a new zonefile provider for the given zones, whose files must exist in the directory.
+// The reload command is run by the shell after the files were changed, if given.
+func NewZonefileProvider(directory string, zones []string, domainFilter endpoint.DomainFilter, reloadCommand string, dryRun bool) (provider.Provider, error) {
+	if len(zones) == 0 {
+		return nil, errors.New("no zone given")
+	}
+
+	p := &zonefileProvider{
+		directory:     directory,
+		zoneNames:     provider.ZoneIDName{},
+		domainFilter:  domainFilter,
+		reloadCommand: reloadCommand,
+		dryRun:        dryRun,
+	}
+	for _, zone := range zones {
+		zone = dns.Fqdn(zone)
+		if _, err := os.Stat(p.zoneFile(zone)); err != nil {
+			return nil, errors.Wrapf(err, "zone file of zone %s not found", zone)
+		}
+		p.zoneNames.Add(zone, strings.TrimSuffix(zone, "."))
+	}
+
+	log.Infof("Configured zonefile provider with zones %s in directory '%s'", zones, directory)
+	return p, nil
+}
+
+// zoneFile returns the path of the file of a zone
+func (p *zonefileProvider) zoneFile(zone string) string {
+	return filepath.Join(p.directory, strings.TrimSuffix(zone, ".")+".zone")
+}
+
+// Records returns the records of all zone files.
+func (p *zonefileProvider) Records(ctx context.Context) ([]*endpoint.Endpoint, error) {
+	var endpoints []*endpoint.Endpoint
+	// records are grouped by name and type
+	rrsets := map[string]*endpoint.Endpoint{}
+
+	for zone := range p.zoneNames {
+		rrs, err := p.readZone(zone)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, rr := range rrs {
+			recordType, target, ok := recordTarget(zone, rr)
+			if !ok {
+				continue
+			}
+
+			key := strings.ToLower(rr.Header().Name) + " " + recordType
+			ttl := endpoint.TTL(rr.Header().Ttl)
+			if ep, ok := rrsets[key]; ok {
+				ep.Targets = append(ep.Targets, strings.TrimSuffix(target, "."))
+				// the records of a set should share their TTL, otherwise the lowest one applies
+				if ttl < ep.RecordTTL {
+					ep.RecordTTL = ttl
+				}
+				continue
+			}
+
+			ep := endpoint.NewEndpointWithTTL(rr.Header().Name, recordType, ttl, target)
+			rrsets[key] = ep
+			endpoints = append(endpoints, ep)
+		}
+	}
+
+	return endpoints, nil
+}
+
+// ZoneIDNames returns the configured zones.
+func (p *zonefileProvider) ZoneIDNames(ctx context.Context) (provider.ZoneIDName, error) {
+	return p.zoneNames, nil
+}
+
+// Capabilities returns the record types the provider supports. The type of a record is changed
+// atomically, since each zone file is replaced as a whole.
+func (p *zonefileProvider) Capabilities() provider.Capabilities {
+	return provider.Capabilities{
+		RecordTypes:      []string{endpoint.RecordTypeA, endpoint.RecordTypeAAAA, endpoint.RecordTypeCNAME, endpoint.RecordTypeTXT, endpoint.RecordTypeSRV, endpoint.RecordTypeNS, endpoint.RecordTypeMX, endpoint.RecordTypePTR, endpoint.RecordTypeCAA},
+		AtomicTypeChange: true,
+	}
+}
+
+// recordTarget returns the type and target of a record managed by the provider
+func recordTarget(zone string, rr dns.RR) (string, string, bool) {
+	if rr.Header().Class != dns.ClassINET {
+		return "", "", false
+	}
+
+	switch rr := rr.(type) {
+	case *dns.A:
+		return endpoint.RecordTypeA, rr.A.String(), true
+	case *dns.AAAA:
+		return endpoint.RecordTypeAAAA, rr.AAAA.String(), true
+	case *dns.CNAME:
+		return endpoint.RecordTypeCNAME, rr.Target, true
+	case *dns.TXT:
+		return endpoint.RecordTypeTXT, strings.Join(rr.Txt, ""), true
+	case *dns.NS:
+		if strings.EqualFold(rr.Hdr.Name, zone) {
+			return "", "", false // The nameservers of the zone itself
+		}
+		return endpoint.RecordTypeNS, rr.Ns, true
+	case *dns.SRV, *dns.MX, *dns.PTR, *dns.CAA:
+		return dns.TypeToString[rr.Header().Rrtype], strings.TrimPrefix(rr.String(), rr.Header().String()), true
+	}
+	return "", "", false
+}
+
+// ApplyChanges writes the changes to the files of their zones and runs the reload command afterwards.
+func (p *zonefileProvider) ApplyChanges(ctx context.Context, changes *plan.Changes) error {
+	zoneChanges := map[string]*plan.Changes{}
+	changesOf := func(ep *endpoint.Endpoint) *plan.Changes {
+		if !p.domainFilter.Match(ep.DNSName) {
+			log.Debugf("Skipping record %s because it was filtered out by the specified --domain-filter", ep.DNSName)
+			return nil
+		}
+		zone, _ := p.zoneNames.FindZone(strings.TrimSuffix(ep.DNSName, "."))
+		if zone == "" {
+			log.Debugf("Skipping record %s because no zone was found", ep.DNSName)
+			return nil
+		}
+		if _, ok := zoneChanges[zone]; !ok {
+			zoneChanges[zone] = &plan.Changes{}
+		}
+		return zoneChanges[zone]
+	}
+
+	for _, ep := range changes.Create {
+		if c := changesOf(ep); c != nil {
+			c.Create = append(c.Create, ep)
+		}
+	}
+	for i, ep := range changes.UpdateNew {
+		if c := changesOf(ep); c != nil {
+			c.UpdateOld = append(c.UpdateOld, changes.UpdateOld[i])
+			c.UpdateNew = append(c.UpdateNew, ep)
+		}
+	}
+	for _, ep := range changes.Delete {
+		if c := changesOf(ep); c != nil {
+			c.Delete = append(c.Delete, ep)
+		}
+	}
+
+	// the zones are independent of each other, so a failing zone doesn't keep the others from being written
+	var applyErr error
+	for zone, c := range zoneChanges {
+		if err := p.applyZoneChanges(zone, c); err != nil {
+			log.Errorf("Failed to apply changes to zone %s: %v", zone, err)
+			if applyErr == nil {
+				applyErr = err
+			}
+			continue
+		}
+		p.reloadPending = true
+	}
+
+	// the written files are reloaded even if other zones failed, a failed reload is retried with the next changes
+	if err := p.reload(ctx); err != nil {
+		if applyErr != nil {
+			log.Error(err)
+			return applyErr
+		}
+		return err
+	}
+	return applyErr
+}
+
+// applyZoneChanges rewrites the file of a zone with the changes applied and its SOA serial increased
+func (p *zonefileProvider) applyZoneChanges(zone string, changes *plan.Changes) error {
+	rrs, err := p.readZone(zone)
+	if err != nil {
+		return err
+	}
+
+	remove := append(append([]*endpoint.Endpoint{}, changes.UpdateOld...), changes.Delete...)
+	insert := append(append([]*endpoint.Endpoint{}, changes.Create...), changes.UpdateNew...)
+
+	for _, ep := range remove {
+		epRRs, err := endpointRRs(ep)
+		if err != nil {
+			return err
+		}
+		rrs = removeRRs(rrs, epRRs)
+	}
+	for _, ep := range insert {
+		epRRs, err := endpointRRs(ep)
+		if err != nil {
+			return err
+		}
+		// replace existing records, e.g. to change their TTL
+		rrs = append(removeRRs(rrs, epRRs), epRRs...)
+	}
+
+	soa := false
+	for _, rr := range rrs {
+		if rr, ok := rr.(*dns.SOA); ok && strings.EqualFold(rr.Hdr.Name, zone) {
+			rr.Serial++
+			soa = true
+			log.Infof("Updating zone %s to serial %d", zone, rr.Serial)
+		}
+	}
+	if !soa {
+		return errors.Errorf("zone file of zone %s has no SOA record", zone)
+	}
+
+	for _, ep := range remove {
+		log.Infof("Removing record %s %s %s from zone %s", ep.DNSName, ep.RecordType, ep.Targets, zone)
+	}
+	for _, ep := range insert {
+		log.Infof("Adding record %s %s %s to zone %s", ep.DNSName, ep.RecordType, ep.Targets, zone)
+	}
+	if p.dryRun {
+		return nil
+	}
+
+	return p.writeZone(zone, rrs)
+}
+
+// endpointRRs returns the records of an endpoint
+func endpointRRs(ep *endpoint.Endpoint) ([]dns.RR, error) {
+	ttl := int64(defaultTTL)
+	if ep.RecordTTL.IsConfigured() {
+		ttl = int64(ep.RecordTTL)
+	}
+
+	rrs := make([]dns.RR, 0, len(ep.Targets))
+	for _, target := range ep.Targets {
+		rr, err := dns.NewRR(fmt.Sprintf("%s %d IN %s %s", dns.Fqdn(ep.DNSName), ttl, ep.RecordType, target))
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to build record %s %s %s", ep.DNSName, ep.RecordType, target)
+		}
+		if rr == nil {
+			return nil, errors.Errorf("failed to build record %s %s %s", ep.DNSName, ep.RecordType, target)
+		}
+		rrs = append(rrs, rr)
+	}
+	return rrs, nil
+}
+
+// removeRRs returns the records without the given ones, regardless of their TTL
+func removeRRs(rrs []dns.RR, remove []dns.RR) []dns.RR {
+	kept := make([]dns.RR, 0, len(rrs))
+	for _, rr := range rrs {
+		removed := false
+		for _, r := range remove {
+			if dns.IsDuplicate(rr, r) {
+				removed = true
+				break
+			}
+		}
+		if !removed {
+			kept = append(kept, rr)
+		}
+	}
+	return kept
+}
+
+// readZone parses the file of a zone
+func (p *zonefileProvider) readZone(zone string) ([]dns.RR, error) {
+	file := p.zoneFile(zone)
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var rrs []dns.RR
+	zp := dns.NewZoneParser(bufio.NewReader(f), zone, file)
+	for rr, ok := zp.Next(); ok; rr, ok = zp.Next() {
+		rrs = append(rrs, rr)
+	}
+	if err := zp.Err(); err != nil {
+		return nil, errors.Wrapf(err, "failed to parse zone file of zone %s", zone)
+	}
+	return rrs, nil
+}
+
+// writeZone replaces the file of a zone atomically by renaming a temporary file
+func (p *zonefileProvider) writeZone(zone string, rrs []dns.RR) error {
+	file := p.zoneFile(zone)
+	info, err := os.Stat(file)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(p.directory, "."+filepath.Base(file))
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	w := bufio.NewWriter(tmp)
+	fmt.Fprintf(w, "; zone %s maintained by ExternalDNS\n$ORIGIN %s\n", zone, zone)
+	for _, rr := range rrs {
+		fmt.Fprintln(w, rr.String())
+	}
+	if err := w.Flush(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), info.Mode()); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), file)
+}
+
+// reload runs the reload command, if any, when zone files were written since its last successful run
+func (p *zonefileProvider) reload(ctx context.Context) error {
+	if !p.reloadPending || p.reloadCommand == "" || p.dryRun {
+		p.reloadPending = false
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, reloadTimeout)
+	defer cancel()
+
+	log.Infof("Running reload command '%s'", p.reloadCommand)
+	output, err := exec.CommandContext(ctx, "sh", "-c", p.reloadCommand).CombinedOutput()
+	if err != nil {
+		return errors.Wrapf(err, "reload command failed: %s", strings.TrimSpace(string(output)))
+	}
+	log.Debugf("Reload command output: %s", output)
+	p.reloadPending = false
+	return nil
+}
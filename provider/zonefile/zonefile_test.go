@@ -0,0 +1,249 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package zonefile
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"sigs.k8s.io/external-dns/endpoint"
+	"sigs.k8s.io/external-dns/internal/testutils"
+	"sigs.k8s.io/external-dns/plan"
+	"sigs.k8s.io/external-dns/provider"
+)
+
+var _ provider.Provider = &zonefileProvider{}
+
+const testZoneFile = `; managed by hand
+$ORIGIN example.org.
+$TTL 3600
+@       IN SOA ns1.example.org. hostmaster.example.org. 2020010101 7200 3600 1209600 3600
+@       IN NS  ns1.example.org.
+ns1     IN A   10.0.0.53
+www 300 IN A   10.0.0.1
+www 300 IN A   10.0.0.2
+mail    IN MX  10 mx.example.org.
+sub     IN NS  ns.sub.example.org.
+`
+
+func newTestZonefileProvider(t *testing.T, reloadCommand string, dryRun bool) (*zonefileProvider, string) {
+	dir, err := ioutil.TempDir("", "zonefile")
+	require.NoError(t, err)
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "example.org.zone"), []byte(testZoneFile), 0640))
+
+	p, err := NewZonefileProvider(dir, []string{"example.org"}, endpoint.NewDomainFilter([]string{}), reloadCommand, dryRun)
+	require.NoError(t, err)
+	return p.(*zonefileProvider), dir
+}
+
+func readTestZone(t *testing.T, p *zonefileProvider) []dns.RR {
+	rrs, err := p.readZone("example.org.")
+	require.NoError(t, err)
+	return rrs
+}
+
+func testSOASerial(t *testing.T, p *zonefileProvider) uint32 {
+	for _, rr := range readTestZone(t, p) {
+		if soa, ok := rr.(*dns.SOA); ok {
+			return soa.Serial
+		}
+	}
+	t.Fatal("no SOA record found")
+	return 0
+}
+
+func TestNewZonefileProvider(t *testing.T) {
+	_, dir := newTestZonefileProvider(t, "", false)
+	defer os.RemoveAll(dir)
+
+	_, err := NewZonefileProvider(dir, nil, endpoint.NewDomainFilter([]string{}), "", false)
+	assert.Error(t, err, "no zone")
+
+	_, err = NewZonefileProvider(dir, []string{"example.com"}, endpoint.NewDomainFilter([]string{}), "", false)
+	assert.Error(t, err, "missing zone file")
+}
+
+func TestZonefileRecords(t *testing.T) {
+	p, dir := newTestZonefileProvider(t, "", false)
+	defer os.RemoveAll(dir)
+
+	records, err := p.Records(context.Background())
+	require.NoError(t, err)
+
+	assert.True(t, testutils.SameEndpoints(records, []*endpoint.Endpoint{
+		endpoint.NewEndpointWithTTL("ns1.example.org", endpoint.RecordTypeA, 3600, "10.0.0.53"),
+		endpoint.NewEndpointWithTTL("www.example.org", endpoint.RecordTypeA, 300, "10.0.0.1", "10.0.0.2"),
+		endpoint.NewEndpointWithTTL("mail.example.org", endpoint.RecordTypeMX, 3600, "10 mx.example.org"),
+		endpoint.NewEndpointWithTTL("sub.example.org", endpoint.RecordTypeNS, 3600, "ns.sub.example.org"),
+	}), "unexpected records %s", records)
+}
+
+func TestZonefileApplyChanges(t *testing.T) {
+	p, dir := newTestZonefileProvider(t, "", false)
+	defer os.RemoveAll(dir)
+
+	err := p.ApplyChanges(context.Background(), &plan.Changes{
+		Create: []*endpoint.Endpoint{
+			endpoint.NewEndpoint("api.example.org", endpoint.RecordTypeCNAME, "www.example.org"),
+			endpoint.NewEndpoint("api.example.org", endpoint.RecordTypeTXT, "heritage=external-dns,external-dns/owner=default"),
+			endpoint.NewEndpoint("foo.example.com", endpoint.RecordTypeA, "10.1.0.1"),
+		},
+		UpdateOld: []*endpoint.Endpoint{
+			endpoint.NewEndpointWithTTL("www.example.org", endpoint.RecordTypeA, 300, "10.0.0.1", "10.0.0.2"),
+		},
+		UpdateNew: []*endpoint.Endpoint{
+			endpoint.NewEndpointWithTTL("www.example.org", endpoint.RecordTypeA, 600, "10.0.0.2", "10.0.0.3"),
+		},
+		Delete: []*endpoint.Endpoint{
+			endpoint.NewEndpointWithTTL("mail.example.org", endpoint.RecordTypeMX, 3600, "10 mx.example.org"),
+		},
+	})
+	require.NoError(t, err)
+
+	records, err := p.Records(context.Background())
+	require.NoError(t, err)
+	assert.True(t, testutils.SameEndpoints(records, []*endpoint.Endpoint{
+		endpoint.NewEndpointWithTTL("ns1.example.org", endpoint.RecordTypeA, 3600, "10.0.0.53"),
+		endpoint.NewEndpointWithTTL("www.example.org", endpoint.RecordTypeA, 600, "10.0.0.2", "10.0.0.3"),
+		endpoint.NewEndpointWithTTL("sub.example.org", endpoint.RecordTypeNS, 3600, "ns.sub.example.org"),
+		endpoint.NewEndpointWithTTL("api.example.org", endpoint.RecordTypeCNAME, defaultTTL, "www.example.org"),
+		endpoint.NewEndpointWithTTL("api.example.org", endpoint.RecordTypeTXT, defaultTTL, "heritage=external-dns,external-dns/owner=default"),
+	}), "unexpected records %s", records)
+
+	// the SOA stays the first record with an increased serial and the apex NS is kept
+	rrs := readTestZone(t, p)
+	require.IsType(t, &dns.SOA{}, rrs[0])
+	assert.Equal(t, uint32(2020010102), rrs[0].(*dns.SOA).Serial)
+	assert.Contains(t, rrs, dns.RR(&dns.NS{
+		Hdr: dns.RR_Header{Name: "example.org.", Rrtype: dns.TypeNS, Class: dns.ClassINET, Ttl: 3600, Rdlength: 0},
+		Ns:  "ns1.example.org.",
+	}))
+
+	info, err := os.Stat(p.zoneFile("example.org."))
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0640), info.Mode())
+
+	files, err := ioutil.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, files, 1, "temporary files are removed")
+}
+
+func TestZonefileApplyChangesWithoutChanges(t *testing.T) {
+	p, dir := newTestZonefileProvider(t, "false", false)
+	defer os.RemoveAll(dir)
+
+	// neither the serial is increased nor the reload command is run
+	require.NoError(t, p.ApplyChanges(context.Background(), &plan.Changes{
+		Create: []*endpoint.Endpoint{endpoint.NewEndpoint("foo.example.com", endpoint.RecordTypeA, "10.1.0.1")},
+	}))
+	assert.Equal(t, uint32(2020010101), testSOASerial(t, p))
+}
+
+func TestZonefileApplyChangesDryRun(t *testing.T) {
+	p, dir := newTestZonefileProvider(t, "false", true)
+	defer os.RemoveAll(dir)
+
+	require.NoError(t, p.ApplyChanges(context.Background(), &plan.Changes{
+		Create: []*endpoint.Endpoint{endpoint.NewEndpoint("foo.example.org", endpoint.RecordTypeA, "10.1.0.1")},
+	}))
+
+	data, err := ioutil.ReadFile(p.zoneFile("example.org."))
+	require.NoError(t, err)
+	assert.Equal(t, testZoneFile, string(data))
+}
+
+func TestZonefileReloadCommand(t *testing.T) {
+	p, dir := newTestZonefileProvider(t, "", false)
+	defer os.RemoveAll(dir)
+
+	changes := &plan.Changes{
+		Create: []*endpoint.Endpoint{endpoint.NewEndpoint("foo.example.org", endpoint.RecordTypeA, "10.1.0.1")},
+	}
+
+	marker := filepath.Join(dir, "reloaded")
+	p.reloadCommand = "touch " + marker
+	require.NoError(t, p.ApplyChanges(context.Background(), changes))
+	_, err := os.Stat(marker)
+	assert.NoError(t, err, "reload command was run")
+	require.NoError(t, os.Remove(marker))
+
+	// without changes there is nothing to reload
+	require.NoError(t, p.ApplyChanges(context.Background(), &plan.Changes{}))
+	_, err = os.Stat(marker)
+	assert.True(t, os.IsNotExist(err), "reload command was not run")
+
+	// a failing reload command is reported, the zone file is written nonetheless
+	p.reloadCommand = "echo reload failed; exit 1"
+	changes.Delete, changes.Create = changes.Create, nil
+	err = p.ApplyChanges(context.Background(), changes)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "reload failed")
+	assert.Equal(t, uint32(2020010103), testSOASerial(t, p))
+
+	// the reload is retried with the next changes, even if there are none
+	p.reloadCommand = "touch " + marker
+	require.NoError(t, p.ApplyChanges(context.Background(), &plan.Changes{}))
+	_, err = os.Stat(marker)
+	assert.NoError(t, err, "pending reload was run")
+	assert.Equal(t, uint32(2020010103), testSOASerial(t, p))
+}
+
+func TestZonefileReloadAfterFailedZone(t *testing.T) {
+	p, dir := newTestZonefileProvider(t, "", false)
+	defer os.RemoveAll(dir)
+
+	marker := filepath.Join(dir, "reloaded")
+	p.reloadCommand = "touch " + marker
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "broken.org.zone"), []byte("www IN A 10.0.0.1\n"), 0640))
+	p.zoneNames.Add("broken.org.", "broken.org")
+
+	// the zone written before or after the failing zone is reloaded
+	changes := &plan.Changes{
+		Create: []*endpoint.Endpoint{
+			endpoint.NewEndpoint("foo.example.org", endpoint.RecordTypeA, "10.1.0.1"),
+			endpoint.NewEndpoint("foo.broken.org", endpoint.RecordTypeA, "10.1.0.2"),
+		},
+	}
+	assert.Error(t, p.ApplyChanges(context.Background(), changes))
+	assert.Equal(t, uint32(2020010102), testSOASerial(t, p))
+	_, err := os.Stat(marker)
+	assert.NoError(t, err, "reload command was run")
+}
+
+func TestZonefileApplyChangesWithInvalidZone(t *testing.T) {
+	p, dir := newTestZonefileProvider(t, "", false)
+	defer os.RemoveAll(dir)
+
+	changes := &plan.Changes{
+		Create: []*endpoint.Endpoint{endpoint.NewEndpoint("foo.example.org", endpoint.RecordTypeA, "10.1.0.1")},
+	}
+
+	require.NoError(t, ioutil.WriteFile(p.zoneFile("example.org."), []byte("www IN A 10.0.0.1\n"), 0640))
+	assert.Error(t, p.ApplyChanges(context.Background(), changes), "zone without SOA")
+
+	require.NoError(t, ioutil.WriteFile(p.zoneFile("example.org."), []byte("www IN A not-an-address\n"), 0640))
+	assert.Error(t, p.ApplyChanges(context.Background(), changes), "broken zone")
+	_, err := p.Records(context.Background())
+	assert.Error(t, err)
+}
@@ -0,0 +1,140 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package registry
+
+import (
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"sigs.k8s.io/external-dns/endpoint"
+)
+
+var (
+	cacheHitsTotal = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: "external_dns",
+			Subsystem: "registry",
+			Name:      "cache_hits_total",
+			Help:      "Number of registry reads served from the records cache.",
+		},
+	)
+	cacheMissesTotal = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: "external_dns",
+			Subsystem: "registry",
+			Name:      "cache_misses_total",
+			Help:      "Number of registry reads which had to query the DNS provider.",
+		},
+	)
+	cacheAgeSeconds = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: "external_dns",
+			Subsystem: "registry",
+			Name:      "cache_age_seconds",
+			Help:      "Time since the records cache was last refreshed from the DNS provider.",
+		},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(cacheHitsTotal)
+	prometheus.MustRegister(cacheMissesTotal)
+	prometheus.MustRegister(cacheAgeSeconds)
+}
+
+// recordsCache holds the records last read from the provider. It is safe for concurrent use.
+// Every modification bumps the generation, which lets a refresh that raced with a change
+// detect that its result is already outdated.
+type recordsCache struct {
+	mu          sync.RWMutex
+	records     []*endpoint.Endpoint
+	refreshTime time.Time
+	generation  uint64
+}
+
+// get returns a copy of the cached records if they are younger than maxAge
+func (c *recordsCache) get(maxAge time.Duration) ([]*endpoint.Endpoint, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if c.records == nil {
+		return nil, false
+	}
+	age := time.Since(c.refreshTime)
+	cacheAgeSeconds.Set(age.Seconds())
+	if age >= maxAge {
+		return nil, false
+	}
+
+	records := make([]*endpoint.Endpoint, len(c.records))
+	copy(records, c.records)
+	return records, true
+}
+
+// currentGeneration returns the generation to be passed to set after reading from the provider
+func (c *recordsCache) currentGeneration() uint64 {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.generation
+}
+
+// set replaces the cached records unless the cache was modified since generation was obtained
+func (c *recordsCache) set(records []*endpoint.Endpoint, generation uint64) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if generation != c.generation {
+		return false
+	}
+	c.records = make([]*endpoint.Endpoint, len(records))
+	copy(c.records, records)
+	c.refreshTime = time.Now()
+	c.generation++
+	cacheAgeSeconds.Set(0)
+	return true
+}
+
+// update removes and adds records after a change was successfully applied
+func (c *recordsCache) update(add, remove []*endpoint.Endpoint) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.generation++
+	if c.records == nil {
+		return
+	}
+	for _, ep := range remove {
+		for i, e := range c.records {
+			if e.DNSName == ep.DNSName && e.RecordType == ep.RecordType && e.SetIdentifier == ep.SetIdentifier && e.Targets.Same(ep.Targets) {
+				c.records = append(c.records[:i], c.records[i+1:]...)
+				break
+			}
+		}
+	}
+	c.records = append(c.records, add...)
+}
+
+// invalidate drops the cached records, forcing the next read to query the provider
+func (c *recordsCache) invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.records = nil
+	c.generation++
+}
@@ -0,0 +1,146 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package registry
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"sigs.k8s.io/external-dns/endpoint"
+)
+
+func TestCacheMethods(t *testing.T) {
+	cache := &recordsCache{}
+
+	_, ok := cache.get(time.Hour)
+	assert.False(t, ok, "empty cache must not be used")
+
+	assert.True(t, cache.set([]*endpoint.Endpoint{
+		newEndpointWithOwner("thing.com", "1.2.3.4", "A", "owner"),
+		newEndpointWithOwner("thing1.com", "1.2.3.6", "A", "owner"),
+		newEndpointWithOwner("thing2.com", "1.2.3.4", "CNAME", "owner"),
+		newEndpointWithOwner("thing3.com", "1.2.3.4", "A", "owner"),
+		newEndpointWithOwner("thing4.com", "1.2.3.4", "A", "owner"),
+	}, cache.currentGeneration()))
+
+	expectedCacheAfterAdd := []*endpoint.Endpoint{
+		newEndpointWithOwner("thing.com", "1.2.3.4", "A", "owner"),
+		newEndpointWithOwner("thing1.com", "1.2.3.6", "A", "owner"),
+		newEndpointWithOwner("thing2.com", "1.2.3.4", "CNAME", "owner"),
+		newEndpointWithOwner("thing3.com", "1.2.3.4", "A", "owner"),
+		newEndpointWithOwner("thing4.com", "1.2.3.4", "A", "owner"),
+		newEndpointWithOwner("thing5.com", "1.2.3.5", "A", "owner"),
+	}
+
+	expectedCacheAfterUpdate := []*endpoint.Endpoint{
+		newEndpointWithOwner("thing1.com", "1.2.3.6", "A", "owner"),
+		newEndpointWithOwner("thing2.com", "1.2.3.4", "CNAME", "owner"),
+		newEndpointWithOwner("thing3.com", "1.2.3.4", "A", "owner"),
+		newEndpointWithOwner("thing4.com", "1.2.3.4", "A", "owner"),
+		newEndpointWithOwner("thing5.com", "1.2.3.5", "A", "owner"),
+		newEndpointWithOwner("thing.com", "1.2.3.6", "A", "owner2"),
+	}
+
+	expectedCacheAfterDelete := []*endpoint.Endpoint{
+		newEndpointWithOwner("thing1.com", "1.2.3.6", "A", "owner"),
+		newEndpointWithOwner("thing2.com", "1.2.3.4", "CNAME", "owner"),
+		newEndpointWithOwner("thing3.com", "1.2.3.4", "A", "owner"),
+		newEndpointWithOwner("thing4.com", "1.2.3.4", "A", "owner"),
+		newEndpointWithOwner("thing5.com", "1.2.3.5", "A", "owner"),
+	}
+
+	// test add cache
+	cache.update([]*endpoint.Endpoint{newEndpointWithOwner("thing5.com", "1.2.3.5", "A", "owner")}, nil)
+	records, ok := cache.get(time.Hour)
+	assert.True(t, ok)
+	if !reflect.DeepEqual(expectedCacheAfterAdd, records) {
+		t.Fatalf("expected endpoints should match endpoints from cache: expected %v, but got %v", expectedCacheAfterAdd, records)
+	}
+
+	// test update cache
+	cache.update(
+		[]*endpoint.Endpoint{newEndpointWithOwner("thing.com", "1.2.3.6", "A", "owner2")},
+		[]*endpoint.Endpoint{newEndpointWithOwner("thing.com", "1.2.3.4", "A", "owner")},
+	)
+	records, _ = cache.get(time.Hour)
+	if !reflect.DeepEqual(expectedCacheAfterUpdate, records) {
+		t.Fatalf("expected endpoints should match endpoints from cache: expected %v, but got %v", expectedCacheAfterUpdate, records)
+	}
+
+	// test deleting a record
+	cache.update(nil, []*endpoint.Endpoint{newEndpointWithOwner("thing.com", "1.2.3.6", "A", "owner2")})
+	records, _ = cache.get(time.Hour)
+	if !reflect.DeepEqual(expectedCacheAfterDelete, records) {
+		t.Fatalf("expected endpoints should match endpoints from cache: expected %v, but got %v", expectedCacheAfterDelete, records)
+	}
+
+	// modifying the returned records does not modify the cache
+	records[0] = nil
+	records, _ = cache.get(time.Hour)
+	assert.NotNil(t, records[0])
+
+	// expired records are not returned
+	_, ok = cache.get(0)
+	assert.False(t, ok)
+
+	cache.invalidate()
+	_, ok = cache.get(time.Hour)
+	assert.False(t, ok)
+}
+
+func TestCacheSetOutdatedGeneration(t *testing.T) {
+	cache := &recordsCache{}
+
+	generation := cache.currentGeneration()
+	// a change is applied while the records are read from the provider
+	cache.update([]*endpoint.Endpoint{newEndpointWithOwner("thing.com", "1.2.3.4", "A", "owner")}, nil)
+
+	assert.False(t, cache.set([]*endpoint.Endpoint{}, generation))
+	_, ok := cache.get(time.Hour)
+	assert.False(t, ok)
+
+	assert.True(t, cache.set([]*endpoint.Endpoint{}, cache.currentGeneration()))
+	_, ok = cache.get(time.Hour)
+	assert.True(t, ok)
+}
+
+func TestCacheConcurrentAccess(t *testing.T) {
+	cache := &recordsCache{}
+	cache.set([]*endpoint.Endpoint{}, cache.currentGeneration())
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(3)
+		go func() {
+			defer wg.Done()
+			cache.update([]*endpoint.Endpoint{newEndpointWithOwner("thing.com", "1.2.3.4", "A", "owner")}, nil)
+		}()
+		go func() {
+			defer wg.Done()
+			cache.get(time.Hour)
+		}()
+		go func() {
+			defer wg.Done()
+			cache.update(nil, []*endpoint.Endpoint{newEndpointWithOwner("thing.com", "1.2.3.4", "A", "owner")})
+		}()
+	}
+	wg.Wait()
+}
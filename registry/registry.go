@@ -35,6 +35,32 @@ type Registry interface {
 	PropertyValuesEqual(attribute string, previous string, current string) bool
 }
 
+// GarbageCollector is implemented by registries which are able to find and remove
+// ownership records that were left behind when the record they describe was deleted out-of-band.
+// GarbageCollect returns the ownership records it removed.
+type GarbageCollector interface {
+	GarbageCollect(ctx context.Context) ([]*endpoint.Endpoint, error)
+}
+
+// DriftDetector is implemented by registries which remember the state records were written with.
+// Drifted returns those of the given records owned by the registry which were changed since.
+type DriftDetector interface {
+	Drifted(records []*endpoint.Endpoint) []*endpoint.Endpoint
+}
+
+// ChangeExpander is implemented by registries which add records of their own, e.g. ownership records,
+// to the changes passed to the provider. RecordsPerChange returns the number of records the provider
+// receives for each planned change.
+type ChangeExpander interface {
+	RecordsPerChange() int
+}
+
+// SharedOwnerLister is implemented by registries which let several owners share a record.
+// OtherOwners returns the owners besides the registry which contribute targets to the given record.
+type SharedOwnerLister interface {
+	OtherOwners(ep *endpoint.Endpoint) []string
+}
+
 //TODO(ideahitme): consider moving this to Plan
 func filterOwnedRecords(ownerID string, eps []*endpoint.Endpoint) []*endpoint.Endpoint {
 	filtered := []*endpoint.Endpoint{}
@@ -37,13 +37,37 @@ type TXTRegistry struct {
 	mapper   nameMapper
 
 	// cache the records in memory and update on an interval instead.
-	recordsCache            []*endpoint.Endpoint
-	recordsCacheRefreshTime time.Time
-	cacheInterval           time.Duration
+	recordsCache  recordsCache
+	cacheInterval time.Duration
+
+	// allow several owners to publish targets for the same record
+	sharedOwnership bool
+	// store the state hash of written records to detect changes made by others
+	driftDetection bool
+}
+
+// TXTRegistryOption configures optional behaviour of the TXTRegistry
+type TXTRegistryOption func(*TXTRegistry)
+
+// TXTWithSharedOwnership lets several owners contribute targets to the same record. The ownership
+// record then lists all owners and the targets contributed by each of them, every owner only adds
+// and removes its own targets and the record is deleted when its last owner leaves.
+func TXTWithSharedOwnership() TXTRegistryOption {
+	return func(im *TXTRegistry) {
+		im.sharedOwnership = true
+	}
+}
+
+// TXTWithDriftDetection stores the state hash of every record written in its ownership record,
+// which allows to detect records changed outside of ExternalDNS
+func TXTWithDriftDetection() TXTRegistryOption {
+	return func(im *TXTRegistry) {
+		im.driftDetection = true
+	}
 }
 
 // NewTXTRegistry returns new TXTRegistry object
-func NewTXTRegistry(provider provider.Provider, txtPrefix, txtSuffix, ownerID string, cacheInterval time.Duration) (*TXTRegistry, error) {
+func NewTXTRegistry(provider provider.Provider, txtPrefix, txtSuffix, ownerID string, cacheInterval time.Duration, opts ...TXTRegistryOption) (*TXTRegistry, error) {
 	if ownerID == "" {
 		return nil, errors.New("owner id cannot be empty")
 	}
@@ -54,25 +78,76 @@ func NewTXTRegistry(provider provider.Provider, txtPrefix, txtSuffix, ownerID st
 
 	mapper := newaffixNameMapper(txtPrefix, txtSuffix)
 
-	return &TXTRegistry{
+	im := &TXTRegistry{
 		provider:      provider,
 		ownerID:       ownerID,
 		mapper:        mapper,
 		cacheInterval: cacheInterval,
-	}, nil
+	}
+	for _, opt := range opts {
+		opt(im)
+	}
+	return im, nil
 }
 
 // Records returns the current records from the registry excluding TXT Records
 // If TXT records was created previously to indicate ownership its corresponding value
 // will be added to the endpoints Labels map
 func (im *TXTRegistry) Records(ctx context.Context) ([]*endpoint.Endpoint, error) {
+	if im.cacheInterval <= 0 {
+		return im.readRecords(ctx)
+	}
+
 	// If we have the zones cached AND we have refreshed the cache since the
 	// last given interval, then just use the cached results.
-	if im.recordsCache != nil && time.Since(im.recordsCacheRefreshTime) < im.cacheInterval {
+	if records, ok := im.recordsCache.get(im.cacheInterval); ok {
 		log.Debug("Using cached records.")
-		return im.recordsCache, nil
+		cacheHitsTotal.Inc()
+		return records, nil
 	}
+	cacheMissesTotal.Inc()
 
+	return im.refreshCache(ctx)
+}
+
+// RunCacheRefresh refreshes the records cache in the background twice per cache interval,
+// so that reads in the reconciliation loop are served from the cache, until the context is canceled
+func (im *TXTRegistry) RunCacheRefresh(ctx context.Context) {
+	if im.cacheInterval <= 0 {
+		return
+	}
+
+	// refresh ahead of the expiry of the cache, which would otherwise expire right before each refresh
+	ticker := time.NewTicker(im.cacheInterval / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if _, err := im.refreshCache(ctx); err != nil {
+				log.Errorf("Failed to refresh the records cache: %v", err)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// refreshCache reads the records from the provider and stores them in the cache
+// unless the cache was modified while the records were read
+func (im *TXTRegistry) refreshCache(ctx context.Context) ([]*endpoint.Endpoint, error) {
+	generation := im.recordsCache.currentGeneration()
+	records, err := im.readRecords(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !im.recordsCache.set(records, generation) {
+		log.Debug("Records cache changed while refreshing, discarding the refreshed records.")
+	}
+	return records, nil
+}
+
+// readRecords reads the records from the provider and merges the labels stored in the TXT records
+func (im *TXTRegistry) readRecords(ctx context.Context) ([]*endpoint.Endpoint, error) {
 	records, err := im.provider.Records(ctx)
 	if err != nil {
 		return nil, err
@@ -115,12 +190,6 @@ func (im *TXTRegistry) Records(ctx context.Context) ([]*endpoint.Endpoint, error
 		}
 	}
 
-	// Update the cache.
-	if im.cacheInterval > 0 {
-		im.recordsCache = endpoints
-		im.recordsCacheRefreshTime = time.Now()
-	}
-
 	return endpoints, nil
 }
 
@@ -133,18 +202,24 @@ func (im *TXTRegistry) ApplyChanges(ctx context.Context, changes *plan.Changes)
 		UpdateOld: filterOwnedRecords(im.ownerID, changes.UpdateOld),
 		Delete:    filterOwnedRecords(im.ownerID, changes.Delete),
 	}
+	if im.sharedOwnership {
+		filteredChanges = sharedOwnershipChanges(im.ownerID, changes)
+	}
+	// remember the managed records before TXT records are added, they are applied to the cache on success
+	added := append(append([]*endpoint.Endpoint{}, filteredChanges.Create...), filteredChanges.UpdateNew...)
+	removed := append(append([]*endpoint.Endpoint{}, filteredChanges.Delete...), filteredChanges.UpdateOld...)
+
 	for _, r := range filteredChanges.Create {
 		if r.Labels == nil {
 			r.Labels = make(map[string]string)
 		}
-		r.Labels[endpoint.OwnerLabelKey] = im.ownerID
+		if !im.sharedOwnership {
+			r.Labels[endpoint.OwnerLabelKey] = im.ownerID
+		}
+		im.setHash(r)
 		txt := endpoint.NewEndpoint(im.mapper.toTXTName(r.DNSName), endpoint.RecordTypeTXT, r.Labels.Serialize(true)).WithSetIdentifier(r.SetIdentifier)
 		txt.ProviderSpecific = r.ProviderSpecific
 		filteredChanges.Create = append(filteredChanges.Create, txt)
-
-		if im.cacheInterval > 0 {
-			im.addToCache(r)
-		}
 	}
 
 	for _, r := range filteredChanges.Delete {
@@ -154,10 +229,6 @@ func (im *TXTRegistry) ApplyChanges(ctx context.Context, changes *plan.Changes)
 		// when we delete TXT records for which value has changed (due to new label) this would still work because
 		// !!! TXT record value is uniquely generated from the Labels of the endpoint. Hence old TXT record can be uniquely reconstructed
 		filteredChanges.Delete = append(filteredChanges.Delete, txt)
-
-		if im.cacheInterval > 0 {
-			im.removeFromCache(r)
-		}
 	}
 
 	// make sure TXT records are consistently updated as well
@@ -167,28 +238,131 @@ func (im *TXTRegistry) ApplyChanges(ctx context.Context, changes *plan.Changes)
 		// when we updateOld TXT records for which value has changed (due to new label) this would still work because
 		// !!! TXT record value is uniquely generated from the Labels of the endpoint. Hence old TXT record can be uniquely reconstructed
 		filteredChanges.UpdateOld = append(filteredChanges.UpdateOld, txt)
-		// remove old version of record from cache
-		if im.cacheInterval > 0 {
-			im.removeFromCache(r)
-		}
 	}
 
 	// make sure TXT records are consistently updated as well
 	for _, r := range filteredChanges.UpdateNew {
+		im.setHash(r)
 		txt := endpoint.NewEndpoint(im.mapper.toTXTName(r.DNSName), endpoint.RecordTypeTXT, r.Labels.Serialize(true)).WithSetIdentifier(r.SetIdentifier)
 		txt.ProviderSpecific = r.ProviderSpecific
 		filteredChanges.UpdateNew = append(filteredChanges.UpdateNew, txt)
-		// add new version of record to cache
-		if im.cacheInterval > 0 {
-			im.addToCache(r)
-		}
+	}
+
+	if im.cacheInterval <= 0 {
+		return im.provider.ApplyChanges(ctx, filteredChanges)
 	}
 
 	// when caching is enabled, disable the provider from using the cache
-	if im.cacheInterval > 0 {
-		ctx = context.WithValue(ctx, provider.RecordsContextKey, nil)
+	ctx = context.WithValue(ctx, provider.RecordsContextKey, nil)
+	if err := im.provider.ApplyChanges(ctx, filteredChanges); err != nil {
+		// the provider may have applied part of the changes, so the cache can no longer be trusted
+		im.recordsCache.invalidate()
+		return err
+	}
+	im.recordsCache.update(added, removed)
+	return nil
+}
+
+// RecordsPerChange returns the number of records changed for each planned change, which is
+// the managed record and its TXT record.
+func (im *TXTRegistry) RecordsPerChange() int {
+	return 2
+}
+
+// GarbageCollect deletes the TXT records owned by this instance which no longer have
+// a corresponding managed record, e.g. because the record was removed by hand.
+// It always reads the records from the provider, bypassing the cache.
+func (im *TXTRegistry) GarbageCollect(ctx context.Context) ([]*endpoint.Endpoint, error) {
+	records, err := im.provider.Records(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	orphans := im.orphanedRecords(records)
+	if len(orphans) == 0 {
+		return orphans, nil
+	}
+
+	for _, r := range orphans {
+		log.Infof("Deleting orphaned ownership record %s (%s)", r.DNSName, r.RecordType)
+	}
+	if err := im.provider.ApplyChanges(ctx, &plan.Changes{Delete: orphans}); err != nil {
+		return nil, err
+	}
+	return orphans, nil
+}
+
+// orphanedRecords returns the TXT records owned by this instance for which no other record
+// with the same name and set identifier exists
+func (im *TXTRegistry) orphanedRecords(records []*endpoint.Endpoint) []*endpoint.Endpoint {
+	existing := map[string]struct{}{}
+	owned := map[string][]*endpoint.Endpoint{}
+
+	for _, record := range records {
+		if record.RecordType == endpoint.RecordTypeTXT && len(record.Targets) > 0 {
+			labels, err := endpoint.NewLabelsFromString(record.Targets[0])
+			if err == nil {
+				// ownership records not matching the configured affix are left alone
+				name := im.mapper.toEndpointName(record.DNSName)
+				if name != "" && im.isOwner(labels) {
+					key := fmt.Sprintf("%s::%s", name, record.SetIdentifier)
+					owned[key] = append(owned[key], record)
+				}
+				continue
+			}
+		}
+		existing[fmt.Sprintf("%s::%s", record.DNSName, record.SetIdentifier)] = struct{}{}
+	}
+
+	orphans := []*endpoint.Endpoint{}
+	for key, txts := range owned {
+		if _, ok := existing[key]; !ok {
+			orphans = append(orphans, txts...)
+		}
 	}
-	return im.provider.ApplyChanges(ctx, filteredChanges)
+	return orphans
+}
+
+// Drifted returns the records owned by this instance whose targets differ from the ones
+// they were last written or adopted with
+func (im *TXTRegistry) Drifted(records []*endpoint.Endpoint) []*endpoint.Endpoint {
+	drifted := []*endpoint.Endpoint{}
+	for _, r := range records {
+		hash, ok := r.Labels[endpoint.HashLabelKey]
+		if !ok || !im.isOwner(r.Labels) {
+			continue
+		}
+		if current := r.StateHash(); current != hash && current != r.Labels[endpoint.AdoptedHashLabelKey] {
+			drifted = append(drifted, r)
+		}
+	}
+	return drifted
+}
+
+// setHash stores the state hash of a record about to be written, unless the caller already provided one
+func (im *TXTRegistry) setHash(r *endpoint.Endpoint) {
+	if !im.driftDetection {
+		return
+	}
+	if r.Labels == nil {
+		r.Labels = endpoint.NewLabels()
+	}
+	if _, ok := r.Labels[endpoint.HashLabelKey]; !ok {
+		r.Labels[endpoint.HashLabelKey] = r.StateHash()
+	}
+}
+
+// isOwner reports whether the ownership labels name this instance as the owner or one of the owners
+func (im *TXTRegistry) isOwner(labels endpoint.Labels) bool {
+	if labels[endpoint.OwnerLabelKey] == im.ownerID {
+		return true
+	}
+	for _, owner := range strings.Split(labels[endpoint.OwnersLabelKey], endpoint.LabelValueSeparator) {
+		if owner == im.ownerID {
+			return true
+		}
+	}
+	return false
 }
 
 // PropertyValuesEqual compares two attribute values for equality
@@ -243,24 +417,3 @@ func (pr affixNameMapper) toTXTName(endpointDNSName string) string {
 	}
 	return pr.prefix + DNSName[0] + pr.suffix + "." + DNSName[1]
 }
-
-func (im *TXTRegistry) addToCache(ep *endpoint.Endpoint) {
-	if im.recordsCache != nil {
-		im.recordsCache = append(im.recordsCache, ep)
-	}
-}
-
-func (im *TXTRegistry) removeFromCache(ep *endpoint.Endpoint) {
-	if im.recordsCache == nil || ep == nil {
-		// return early.
-		return
-	}
-
-	for i, e := range im.recordsCache {
-		if e.DNSName == ep.DNSName && e.RecordType == ep.RecordType && e.SetIdentifier == ep.SetIdentifier && e.Targets.Same(ep.Targets) {
-			// We found a match delete the endpoint from the cache.
-			im.recordsCache = append(im.recordsCache[:i], im.recordsCache[i+1:]...)
-			return
-		}
-	}
-}
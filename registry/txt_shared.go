@@ -0,0 +1,181 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package registry
+
+import (
+	"sort"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+
+	"sigs.k8s.io/external-dns/endpoint"
+	"sigs.k8s.io/external-dns/plan"
+)
+
+// sharedOwnership holds the owners of a shared record together with the targets each of them contributed
+type sharedOwnership map[string]endpoint.Targets
+
+// parseSharedOwnership reads the owners of a record from its labels. A record which is not shared yet
+// but owned by ownerID is converted, attributing all of its targets to ownerID.
+// It returns false if the record is owned exclusively by someone else or not owned at all.
+func parseSharedOwnership(ep *endpoint.Endpoint, ownerID string) (sharedOwnership, bool) {
+	owners, ok := ep.Labels[endpoint.OwnersLabelKey]
+	if !ok || owners == "" {
+		if ep.Labels[endpoint.OwnerLabelKey] != ownerID {
+			return nil, false
+		}
+		return sharedOwnership{ownerID: ep.Targets}, true
+	}
+
+	ownership := sharedOwnership{}
+	for _, owner := range strings.Split(owners, endpoint.LabelValueSeparator) {
+		targets := endpoint.Targets{}
+		if value := ep.Labels[endpoint.OwnerTargetsLabelPrefix+owner]; value != "" {
+			targets = strings.Split(value, endpoint.LabelValueSeparator)
+		}
+		ownership[owner] = targets
+	}
+	return ownership, true
+}
+
+// targets returns the union of the targets contributed by all owners
+func (s sharedOwnership) targets() endpoint.Targets {
+	seen := map[string]struct{}{}
+	targets := endpoint.Targets{}
+	for _, contributed := range s {
+		for _, target := range contributed {
+			if _, ok := seen[target]; !ok {
+				seen[target] = struct{}{}
+				targets = append(targets, target)
+			}
+		}
+	}
+	sort.Sort(targets)
+	return targets
+}
+
+// applyTo replaces the ownership labels and the targets of ep
+func (s sharedOwnership) applyTo(ep *endpoint.Endpoint) {
+	if ep.Labels == nil {
+		ep.Labels = endpoint.NewLabels()
+	}
+	for key := range ep.Labels {
+		if strings.HasPrefix(key, endpoint.OwnerTargetsLabelPrefix) {
+			delete(ep.Labels, key)
+		}
+	}
+	delete(ep.Labels, endpoint.OwnerLabelKey)
+
+	owners := make([]string, 0, len(s))
+	for owner, targets := range s {
+		owners = append(owners, owner)
+		sorted := append(endpoint.Targets{}, targets...)
+		sort.Sort(sorted)
+		ep.Labels[endpoint.OwnerTargetsLabelPrefix+owner] = strings.Join(sorted, endpoint.LabelValueSeparator)
+	}
+	sort.Strings(owners)
+	ep.Labels[endpoint.OwnersLabelKey] = strings.Join(owners, endpoint.LabelValueSeparator)
+	ep.Targets = s.targets()
+}
+
+// OtherOwners returns the owners besides this instance which contribute targets to a shared record
+func (im *TXTRegistry) OtherOwners(ep *endpoint.Endpoint) []string {
+	if !im.sharedOwnership {
+		return nil
+	}
+	ownership, ok := parseSharedOwnership(ep, im.ownerID)
+	if !ok {
+		return nil
+	}
+	var owners []string
+	for owner := range ownership {
+		if owner != im.ownerID {
+			owners = append(owners, owner)
+		}
+	}
+	sort.Strings(owners)
+	return owners
+}
+
+// sameOwnership reports whether two endpoints have the same targets and the same ownership labels
+func sameOwnership(a, b *endpoint.Endpoint) bool {
+	if !a.Targets.Same(b.Targets) || a.Labels[endpoint.OwnersLabelKey] != b.Labels[endpoint.OwnersLabelKey] {
+		return false
+	}
+	for key, value := range a.Labels {
+		if strings.HasPrefix(key, endpoint.OwnerTargetsLabelPrefix) && b.Labels[key] != value {
+			return false
+		}
+	}
+	return true
+}
+
+// sharedOwnershipChanges turns the planned changes into changes which only add or remove the targets
+// contributed by ownerID, leaving the targets of the other owners in place.
+// A record is deleted only once its last owner has left.
+func sharedOwnershipChanges(ownerID string, changes *plan.Changes) *plan.Changes {
+	result := &plan.Changes{
+		Create:    []*endpoint.Endpoint{},
+		UpdateOld: []*endpoint.Endpoint{},
+		UpdateNew: []*endpoint.Endpoint{},
+		Delete:    []*endpoint.Endpoint{},
+	}
+
+	for _, r := range changes.Create {
+		sharedOwnership{ownerID: r.Targets}.applyTo(r)
+		result.Create = append(result.Create, r)
+	}
+
+	for i, current := range changes.UpdateOld {
+		if i >= len(changes.UpdateNew) {
+			break
+		}
+		ownership, ok := parseSharedOwnership(current, ownerID)
+		if !ok {
+			log.Debugf(`Skipping endpoint %v because it is not shared and not owned by "%s"`, current, ownerID)
+			continue
+		}
+		desired := changes.UpdateNew[i]
+		ownership[ownerID] = desired.Targets
+		ownership.applyTo(desired)
+		if sameOwnership(current, desired) {
+			continue
+		}
+		result.UpdateOld = append(result.UpdateOld, current)
+		result.UpdateNew = append(result.UpdateNew, desired)
+	}
+
+	for _, current := range changes.Delete {
+		ownership, ok := parseSharedOwnership(current, ownerID)
+		if _, owner := ownership[ownerID]; !ok || !owner {
+			log.Debugf(`Skipping endpoint %v because "%s" is not one of its owners`, current, ownerID)
+			continue
+		}
+		delete(ownership, ownerID)
+		if len(ownership) == 0 {
+			result.Delete = append(result.Delete, current)
+			continue
+		}
+		// other owners remain, only withdraw the targets of this owner
+		remaining := current.DeepCopy()
+		ownership.applyTo(remaining)
+		result.UpdateOld = append(result.UpdateOld, current)
+		result.UpdateNew = append(result.UpdateNew, remaining)
+	}
+
+	return result
+}
@@ -0,0 +1,235 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package registry
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"sigs.k8s.io/external-dns/endpoint"
+	"sigs.k8s.io/external-dns/internal/testutils"
+	"sigs.k8s.io/external-dns/plan"
+	"sigs.k8s.io/external-dns/provider/inmemory"
+)
+
+func newSharedEndpoint(dnsName string, owners map[string][]string, targets ...string) *endpoint.Endpoint {
+	e := endpoint.NewEndpoint(dnsName, endpoint.RecordTypeA, targets...)
+	ownership := sharedOwnership{}
+	for owner, contributed := range owners {
+		ownership[owner] = contributed
+	}
+	ownership.applyTo(e)
+	return e
+}
+
+func TestParseSharedOwnership(t *testing.T) {
+	shared := newSharedEndpoint("foo.test-zone.example.org", map[string][]string{
+		"cluster-a": {"1.1.1.1"},
+		"cluster-b": {"2.2.2.2", "3.3.3.3"},
+	})
+	assert.Equal(t, endpoint.Targets{"1.1.1.1", "2.2.2.2", "3.3.3.3"}, shared.Targets)
+	assert.Equal(t, "cluster-a|cluster-b", shared.Labels[endpoint.OwnersLabelKey])
+	assert.Equal(t, "2.2.2.2|3.3.3.3", shared.Labels[endpoint.OwnerTargetsLabelPrefix+"cluster-b"])
+
+	ownership, ok := parseSharedOwnership(shared, "cluster-c")
+	require.True(t, ok)
+	assert.Equal(t, sharedOwnership{
+		"cluster-a": {"1.1.1.1"},
+		"cluster-b": {"2.2.2.2", "3.3.3.3"},
+	}, ownership)
+
+	// labels survive the round trip through the TXT record
+	labels, err := endpoint.NewLabelsFromString(shared.Labels.Serialize(true))
+	require.NoError(t, err)
+	assert.Equal(t, shared.Labels, labels)
+
+	// exclusively owned records are only converted by their owner
+	exclusive := newEndpointWithOwner("bar.test-zone.example.org", "4.4.4.4", endpoint.RecordTypeA, "cluster-a")
+	ownership, ok = parseSharedOwnership(exclusive, "cluster-a")
+	require.True(t, ok)
+	assert.Equal(t, sharedOwnership{"cluster-a": {"4.4.4.4"}}, ownership)
+
+	_, ok = parseSharedOwnership(exclusive, "cluster-b")
+	assert.False(t, ok)
+
+	_, ok = parseSharedOwnership(newEndpointWithOwner("baz.test-zone.example.org", "5.5.5.5", endpoint.RecordTypeA, ""), "cluster-a")
+	assert.False(t, ok)
+}
+
+func TestTXTRegistryOtherOwners(t *testing.T) {
+	shared := newSharedEndpoint("foo.test-zone.example.org", map[string][]string{
+		"cluster-a": {"1.1.1.1"},
+		"cluster-c": {"3.3.3.3"},
+		"cluster-b": {"2.2.2.2"},
+	})
+	exclusive := newEndpointWithOwner("bar.test-zone.example.org", "4.4.4.4", endpoint.RecordTypeA, "cluster-a")
+
+	r, err := NewTXTRegistry(inmemory.NewInMemoryProvider(), "", "", "cluster-a", 0, TXTWithSharedOwnership())
+	require.NoError(t, err)
+	assert.Equal(t, []string{"cluster-b", "cluster-c"}, r.OtherOwners(shared))
+	assert.Empty(t, r.OtherOwners(exclusive))
+
+	// without shared ownership records are never shared
+	r, err = NewTXTRegistry(inmemory.NewInMemoryProvider(), "", "", "cluster-a", 0)
+	require.NoError(t, err)
+	assert.Empty(t, r.OtherOwners(shared))
+}
+
+func TestSharedOwnershipChanges(t *testing.T) {
+	for _, tc := range []struct {
+		title    string
+		changes  *plan.Changes
+		expected *plan.Changes
+	}{
+		{
+			title: "create claims the record",
+			changes: &plan.Changes{
+				Create: []*endpoint.Endpoint{endpoint.NewEndpoint("foo.test-zone.example.org", endpoint.RecordTypeA, "1.1.1.1")},
+			},
+			expected: &plan.Changes{
+				Create: []*endpoint.Endpoint{
+					newSharedEndpoint("foo.test-zone.example.org", map[string][]string{"cluster-a": {"1.1.1.1"}}),
+				},
+			},
+		},
+		{
+			title: "update joins a record shared by another owner",
+			changes: &plan.Changes{
+				UpdateOld: []*endpoint.Endpoint{newSharedEndpoint("foo.test-zone.example.org", map[string][]string{"cluster-b": {"2.2.2.2"}})},
+				UpdateNew: []*endpoint.Endpoint{endpoint.NewEndpoint("foo.test-zone.example.org", endpoint.RecordTypeA, "1.1.1.1")},
+			},
+			expected: &plan.Changes{
+				UpdateOld: []*endpoint.Endpoint{newSharedEndpoint("foo.test-zone.example.org", map[string][]string{"cluster-b": {"2.2.2.2"}})},
+				UpdateNew: []*endpoint.Endpoint{
+					newSharedEndpoint("foo.test-zone.example.org", map[string][]string{"cluster-a": {"1.1.1.1"}, "cluster-b": {"2.2.2.2"}}),
+				},
+			},
+		},
+		{
+			title: "update replaces only the own targets",
+			changes: &plan.Changes{
+				UpdateOld: []*endpoint.Endpoint{
+					newSharedEndpoint("foo.test-zone.example.org", map[string][]string{"cluster-a": {"1.1.1.1"}, "cluster-b": {"2.2.2.2"}}),
+				},
+				UpdateNew: []*endpoint.Endpoint{endpoint.NewEndpoint("foo.test-zone.example.org", endpoint.RecordTypeA, "3.3.3.3")},
+			},
+			expected: &plan.Changes{
+				UpdateOld: []*endpoint.Endpoint{
+					newSharedEndpoint("foo.test-zone.example.org", map[string][]string{"cluster-a": {"1.1.1.1"}, "cluster-b": {"2.2.2.2"}}),
+				},
+				UpdateNew: []*endpoint.Endpoint{
+					newSharedEndpoint("foo.test-zone.example.org", map[string][]string{"cluster-a": {"3.3.3.3"}, "cluster-b": {"2.2.2.2"}}),
+				},
+			},
+		},
+		{
+			title: "update without effect on the shared record is dropped",
+			changes: &plan.Changes{
+				UpdateOld: []*endpoint.Endpoint{
+					newSharedEndpoint("foo.test-zone.example.org", map[string][]string{"cluster-a": {"1.1.1.1"}, "cluster-b": {"2.2.2.2"}}),
+				},
+				UpdateNew: []*endpoint.Endpoint{endpoint.NewEndpoint("foo.test-zone.example.org", endpoint.RecordTypeA, "1.1.1.1")},
+			},
+			expected: &plan.Changes{},
+		},
+		{
+			title: "update of a record owned exclusively by another owner is skipped",
+			changes: &plan.Changes{
+				UpdateOld: []*endpoint.Endpoint{newEndpointWithOwner("foo.test-zone.example.org", "2.2.2.2", endpoint.RecordTypeA, "cluster-b")},
+				UpdateNew: []*endpoint.Endpoint{endpoint.NewEndpoint("foo.test-zone.example.org", endpoint.RecordTypeA, "1.1.1.1")},
+			},
+			expected: &plan.Changes{},
+		},
+		{
+			title: "delete withdraws the own targets while other owners remain",
+			changes: &plan.Changes{
+				Delete: []*endpoint.Endpoint{
+					newSharedEndpoint("foo.test-zone.example.org", map[string][]string{"cluster-a": {"1.1.1.1"}, "cluster-b": {"2.2.2.2"}}),
+				},
+			},
+			expected: &plan.Changes{
+				UpdateOld: []*endpoint.Endpoint{
+					newSharedEndpoint("foo.test-zone.example.org", map[string][]string{"cluster-a": {"1.1.1.1"}, "cluster-b": {"2.2.2.2"}}),
+				},
+				UpdateNew: []*endpoint.Endpoint{
+					newSharedEndpoint("foo.test-zone.example.org", map[string][]string{"cluster-b": {"2.2.2.2"}}),
+				},
+			},
+		},
+		{
+			title: "delete by the last owner removes the record",
+			changes: &plan.Changes{
+				Delete: []*endpoint.Endpoint{newSharedEndpoint("foo.test-zone.example.org", map[string][]string{"cluster-a": {"1.1.1.1"}})},
+			},
+			expected: &plan.Changes{
+				Delete: []*endpoint.Endpoint{newSharedEndpoint("foo.test-zone.example.org", map[string][]string{"cluster-a": {"1.1.1.1"}})},
+			},
+		},
+		{
+			title: "delete of a record not owned is skipped",
+			changes: &plan.Changes{
+				Delete: []*endpoint.Endpoint{newSharedEndpoint("foo.test-zone.example.org", map[string][]string{"cluster-b": {"2.2.2.2"}})},
+			},
+			expected: &plan.Changes{},
+		},
+	} {
+		t.Run(tc.title, func(t *testing.T) {
+			got := sharedOwnershipChanges("cluster-a", tc.changes)
+			assertSameSharedEndpoints(t, tc.expected.Create, got.Create)
+			assertSameSharedEndpoints(t, tc.expected.UpdateOld, got.UpdateOld)
+			assertSameSharedEndpoints(t, tc.expected.UpdateNew, got.UpdateNew)
+			assertSameSharedEndpoints(t, tc.expected.Delete, got.Delete)
+		})
+	}
+}
+
+func TestTXTRegistrySharedOwnershipApplyChanges(t *testing.T) {
+	p := inmemory.NewInMemoryProvider()
+	p.CreateZone(testZone)
+	r, err := NewTXTRegistry(p, "txt.", "", "cluster-a", 0, TXTWithSharedOwnership())
+	require.NoError(t, err)
+
+	current := newSharedEndpoint("foo.test-zone.example.org", map[string][]string{"cluster-a": {"1.1.1.1"}, "cluster-b": {"2.2.2.2"}})
+	remaining := newSharedEndpoint("foo.test-zone.example.org", map[string][]string{"cluster-b": {"2.2.2.2"}})
+
+	p.OnApplyChanges = func(ctx context.Context, got *plan.Changes) {
+		assert.Empty(t, got.Create)
+		assert.Empty(t, got.Delete)
+		assert.True(t, testutils.SameEndpoints(got.UpdateOld, []*endpoint.Endpoint{
+			current,
+			endpoint.NewEndpoint("txt.foo.test-zone.example.org", endpoint.RecordTypeTXT, current.Labels.Serialize(true)),
+		}))
+		assert.True(t, testutils.SameEndpoints(got.UpdateNew, []*endpoint.Endpoint{
+			remaining,
+			endpoint.NewEndpoint("txt.foo.test-zone.example.org", endpoint.RecordTypeTXT, "\"heritage=external-dns,external-dns/owners=cluster-b,external-dns/targets/cluster-b=2.2.2.2\""),
+		}))
+	}
+	// the planned deletion is applied as an update since cluster-b still owns the record
+	r.ApplyChanges(context.Background(), &plan.Changes{Delete: []*endpoint.Endpoint{current}})
+}
+
+func assertSameSharedEndpoints(t *testing.T, expected, got []*endpoint.Endpoint) {
+	require.Len(t, got, len(expected))
+	for i := range expected {
+		assert.Equal(t, expected[i].DNSName, got[i].DNSName)
+		assert.Equal(t, expected[i].Targets, got[i].Targets)
+		assert.Equal(t, expected[i].Labels, got[i].Labels)
+	}
+}
@@ -18,10 +18,11 @@ package registry
 
 import (
 	"context"
-	"reflect"
+	"fmt"
 	"testing"
 	"time"
 
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
@@ -629,65 +630,150 @@ func testTXTRegistryApplyChangesNoPrefix(t *testing.T) {
 	require.NoError(t, err)
 }
 
-func TestCacheMethods(t *testing.T) {
-	cache := []*endpoint.Endpoint{
-		newEndpointWithOwner("thing.com", "1.2.3.4", "A", "owner"),
-		newEndpointWithOwner("thing1.com", "1.2.3.6", "A", "owner"),
-		newEndpointWithOwner("thing2.com", "1.2.3.4", "CNAME", "owner"),
-		newEndpointWithOwner("thing3.com", "1.2.3.4", "A", "owner"),
-		newEndpointWithOwner("thing4.com", "1.2.3.4", "A", "owner"),
+func TestTXTRegistryGarbageCollect(t *testing.T) {
+	ctx := context.Background()
+	p := inmemory.NewInMemoryProvider()
+	p.CreateZone(testZone)
+	p.ApplyChanges(ctx, &plan.Changes{
+		Create: []*endpoint.Endpoint{
+			newEndpointWithOwner("foo.test-zone.example.org", "foo.loadbalancer.com", endpoint.RecordTypeCNAME, ""),
+			newEndpointWithOwner("txt.foo.test-zone.example.org", "\"heritage=external-dns,external-dns/owner=owner\"", endpoint.RecordTypeTXT, ""),
+			newEndpointWithOwner("txt.bar.test-zone.example.org", "\"heritage=external-dns,external-dns/owner=owner\"", endpoint.RecordTypeTXT, ""),
+			newEndpointWithOwner("txt.baz.test-zone.example.org", "\"heritage=external-dns,external-dns/owner=owner-2\"", endpoint.RecordTypeTXT, ""),
+			newEndpointWithOwner("qux.test-zone.example.org", "\"heritage=external-dns,external-dns/owner=owner\"", endpoint.RecordTypeTXT, ""),
+			newEndpointWithOwner("txt.multiple.test-zone.example.org", "\"heritage=external-dns,external-dns/owner=owner\"", endpoint.RecordTypeTXT, "").WithSetIdentifier("test-set-1"),
+			newEndpointWithOwner("multiple.test-zone.example.org", "lb1.loadbalancer.com", endpoint.RecordTypeCNAME, "").WithSetIdentifier("test-set-1"),
+			newEndpointWithOwner("txt.multiple.test-zone.example.org", "\"heritage=external-dns,external-dns/owner=owner\"", endpoint.RecordTypeTXT, "").WithSetIdentifier("test-set-2"),
+		},
+	})
+
+	expected := []*endpoint.Endpoint{
+		newEndpointWithOwner("txt.bar.test-zone.example.org", "\"heritage=external-dns,external-dns/owner=owner\"", endpoint.RecordTypeTXT, ""),
+		newEndpointWithOwner("txt.multiple.test-zone.example.org", "\"heritage=external-dns,external-dns/owner=owner\"", endpoint.RecordTypeTXT, "").WithSetIdentifier("test-set-2"),
 	}
-	registry := &TXTRegistry{
-		recordsCache:  cache,
-		cacheInterval: time.Hour,
+	p.OnApplyChanges = func(ctx context.Context, got *plan.Changes) {
+		assert.Empty(t, got.Create)
+		assert.Empty(t, got.UpdateNew)
+		assert.Empty(t, got.UpdateOld)
+		assert.True(t, testutils.SameEndpoints(got.Delete, expected))
 	}
 
-	expectedCacheAfterAdd := []*endpoint.Endpoint{
-		newEndpointWithOwner("thing.com", "1.2.3.4", "A", "owner"),
-		newEndpointWithOwner("thing1.com", "1.2.3.6", "A", "owner"),
-		newEndpointWithOwner("thing2.com", "1.2.3.4", "CNAME", "owner"),
-		newEndpointWithOwner("thing3.com", "1.2.3.4", "A", "owner"),
-		newEndpointWithOwner("thing4.com", "1.2.3.4", "A", "owner"),
-		newEndpointWithOwner("thing5.com", "1.2.3.5", "A", "owner"),
-	}
+	r, _ := NewTXTRegistry(p, "txt.", "", "owner", 0)
+	orphans, err := r.GarbageCollect(ctx)
+	require.NoError(t, err)
+	assert.True(t, testutils.SameEndpoints(orphans, expected))
 
-	expectedCacheAfterUpdate := []*endpoint.Endpoint{
-		newEndpointWithOwner("thing1.com", "1.2.3.6", "A", "owner"),
-		newEndpointWithOwner("thing2.com", "1.2.3.4", "CNAME", "owner"),
-		newEndpointWithOwner("thing3.com", "1.2.3.4", "A", "owner"),
-		newEndpointWithOwner("thing4.com", "1.2.3.4", "A", "owner"),
-		newEndpointWithOwner("thing5.com", "1.2.3.5", "A", "owner"),
-		newEndpointWithOwner("thing.com", "1.2.3.6", "A", "owner2"),
+	// a second run has nothing left to collect
+	p.OnApplyChanges = func(ctx context.Context, got *plan.Changes) {
+		t.Error("no changes expected")
 	}
+	orphans, err = r.GarbageCollect(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, orphans)
+}
 
-	expectedCacheAfterDelete := []*endpoint.Endpoint{
-		newEndpointWithOwner("thing1.com", "1.2.3.6", "A", "owner"),
-		newEndpointWithOwner("thing2.com", "1.2.3.4", "CNAME", "owner"),
-		newEndpointWithOwner("thing3.com", "1.2.3.4", "A", "owner"),
-		newEndpointWithOwner("thing4.com", "1.2.3.4", "A", "owner"),
-		newEndpointWithOwner("thing5.com", "1.2.3.5", "A", "owner"),
-	}
-	// test add cache
-	registry.addToCache(newEndpointWithOwner("thing5.com", "1.2.3.5", "A", "owner"))
+func TestTXTRegistryCacheInvalidation(t *testing.T) {
+	ctx := context.Background()
+	p := inmemory.NewInMemoryProvider()
+	p.CreateZone(testZone)
+	p.ApplyChanges(ctx, &plan.Changes{
+		Create: []*endpoint.Endpoint{
+			newEndpointWithOwner("foo.test-zone.example.org", "foo.loadbalancer.com", endpoint.RecordTypeCNAME, ""),
+			newEndpointWithOwner("foo.test-zone.example.org", "\"heritage=external-dns,external-dns/owner=owner\"", endpoint.RecordTypeTXT, ""),
+		},
+	})
 
-	if !reflect.DeepEqual(expectedCacheAfterAdd, registry.recordsCache) {
-		t.Fatalf("expected endpoints should match endpoints from cache: expected %v, but got %v", expectedCacheAfterAdd, registry.recordsCache)
-	}
+	r, _ := NewTXTRegistry(p, "", "", "owner", time.Hour)
+	records, err := r.Records(ctx)
+	require.NoError(t, err)
+	assert.Len(t, records, 1)
 
-	// test update cache
-	registry.removeFromCache(newEndpointWithOwner("thing.com", "1.2.3.4", "A", "owner"))
-	registry.addToCache(newEndpointWithOwner("thing.com", "1.2.3.6", "A", "owner2"))
-	// ensure it was updated
-	if !reflect.DeepEqual(expectedCacheAfterUpdate, registry.recordsCache) {
-		t.Fatalf("expected endpoints should match endpoints from cache: expected %v, but got %v", expectedCacheAfterUpdate, registry.recordsCache)
-	}
+	// successful changes are reflected in the cache
+	err = r.ApplyChanges(ctx, &plan.Changes{
+		Create: []*endpoint.Endpoint{
+			newEndpointWithOwner("bar.test-zone.example.org", "bar.loadbalancer.com", endpoint.RecordTypeCNAME, ""),
+		},
+	})
+	require.NoError(t, err)
+	cached, ok := r.recordsCache.get(time.Hour)
+	require.True(t, ok)
+	assert.Len(t, cached, 2)
+
+	// failed changes invalidate the cache
+	err = r.ApplyChanges(ctx, &plan.Changes{
+		Create: []*endpoint.Endpoint{
+			newEndpointWithOwner("bar.test-zone.example.org", "bar.loadbalancer.com", endpoint.RecordTypeCNAME, ""),
+		},
+	})
+	require.Error(t, err)
+	_, ok = r.recordsCache.get(time.Hour)
+	assert.False(t, ok)
 
-	// test deleting a record
-	registry.removeFromCache(newEndpointWithOwner("thing.com", "1.2.3.6", "A", "owner2"))
-	// ensure it was deleted
-	if !reflect.DeepEqual(expectedCacheAfterDelete, registry.recordsCache) {
-		t.Fatalf("expected endpoints should match endpoints from cache: expected %v, but got %v", expectedCacheAfterDelete, registry.recordsCache)
+	records, err = r.Records(ctx)
+	require.NoError(t, err)
+	assert.Len(t, records, 2)
+	_, ok = r.recordsCache.get(time.Hour)
+	assert.True(t, ok)
+}
+
+func TestTXTRegistryRunCacheRefresh(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	p := inmemory.NewInMemoryProvider()
+	p.CreateZone(testZone)
+
+	r, _ := NewTXTRegistry(p, "", "", "owner", 10*time.Millisecond)
+	done := make(chan struct{})
+	go func() {
+		r.RunCacheRefresh(ctx)
+		close(done)
+	}()
+
+	assert.Eventually(t, func() bool {
+		_, ok := r.recordsCache.get(time.Hour)
+		return ok
+	}, time.Second, 10*time.Millisecond)
+
+	cancel()
+	<-done
+}
+
+// slowProvider takes a while to read the records, like providers reading them over the network
+type slowProvider struct {
+	provider.Provider
+	delay time.Duration
+}
+
+func (p *slowProvider) Records(ctx context.Context) ([]*endpoint.Endpoint, error) {
+	time.Sleep(p.delay)
+	return p.Provider.Records(ctx)
+}
+
+func TestTXTRegistryRunCacheRefreshAheadOfExpiry(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	p := inmemory.NewInMemoryProvider()
+	p.CreateZone(testZone)
+
+	interval := 100 * time.Millisecond
+	r, _ := NewTXTRegistry(&slowProvider{Provider: p, delay: interval / 4}, "", "", "owner", interval)
+	_, err := r.Records(ctx)
+	require.NoError(t, err)
+	misses := testutil.ToFloat64(cacheMissesTotal)
+
+	done := make(chan struct{})
+	go func() {
+		r.RunCacheRefresh(ctx)
+		close(done)
+	}()
+
+	// the reads keep hitting the cache across several refreshes
+	for deadline := time.Now().Add(4 * interval); time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) {
+		_, err := r.Records(ctx)
+		require.NoError(t, err)
 	}
+	assert.Equal(t, misses, testutil.ToFloat64(cacheMissesTotal))
+
+	cancel()
+	<-done
 }
 
 /**
@@ -715,3 +801,75 @@ func newEndpointWithOwnerResource(dnsName, target, recordType, ownerID, resource
 	e.Labels[endpoint.ResourceLabelKey] = resource
 	return e
 }
+
+func TestTXTRegistryDriftDetection(t *testing.T) {
+	ctx := context.Background()
+	p := inmemory.NewInMemoryProvider()
+	p.CreateZone(testZone)
+	r, _ := NewTXTRegistry(p, "txt.", "", "owner", 0, TXTWithDriftDetection())
+
+	err := r.ApplyChanges(ctx, &plan.Changes{
+		Create: []*endpoint.Endpoint{
+			newEndpointWithOwner("foo.test-zone.example.org", "1.2.3.4", endpoint.RecordTypeA, ""),
+			newEndpointWithOwner("bar.test-zone.example.org", "5.6.7.8", endpoint.RecordTypeA, ""),
+		},
+	})
+	require.NoError(t, err)
+
+	records, err := r.Records(ctx)
+	require.NoError(t, err)
+	require.Len(t, records, 2)
+	for _, record := range records {
+		assert.Equal(t, record.StateHash(), record.Labels[endpoint.HashLabelKey])
+	}
+	assert.Empty(t, r.Drifted(records))
+
+	// change the target of foo behind the back of the registry
+	err = p.ApplyChanges(ctx, &plan.Changes{
+		UpdateOld: []*endpoint.Endpoint{newEndpointWithOwner("foo.test-zone.example.org", "1.2.3.4", endpoint.RecordTypeA, "")},
+		UpdateNew: []*endpoint.Endpoint{newEndpointWithOwner("foo.test-zone.example.org", "4.3.2.1", endpoint.RecordTypeA, "")},
+	})
+	require.NoError(t, err)
+
+	records, err = r.Records(ctx)
+	require.NoError(t, err)
+	drifted := r.Drifted(records)
+	require.Len(t, drifted, 1)
+	assert.Equal(t, "foo.test-zone.example.org", drifted[0].DNSName)
+
+	// adopting the change resolves the drift
+	drifted[0].Labels[endpoint.AdoptedHashLabelKey] = drifted[0].StateHash()
+	assert.Empty(t, r.Drifted(drifted))
+
+	// records owned by others are never reported
+	other, _ := NewTXTRegistry(p, "txt.", "", "other-owner", 0, TXTWithDriftDetection())
+	records, err = other.Records(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, other.Drifted(records))
+}
+
+func TestTXTRegistryLongLabels(t *testing.T) {
+	ctx := context.Background()
+	p := inmemory.NewInMemoryProvider()
+	p.CreateZone(testZone)
+	r, _ := NewTXTRegistry(p, "txt.", "", "owner", 0)
+
+	// the targets recorded per resource exceed the maximum length of a TXT character string
+	merged := newEndpointWithOwnerResource("foo.test-zone.example.org", "10.0.0.1", endpoint.RecordTypeA, "", "service/default/foo")
+	merged.Labels[endpoint.MergeLabelKey] = "true"
+	for i := 0; i < 10; i++ {
+		resource := fmt.Sprintf("service/namespace-%d/service-%d", i, i)
+		merged.Labels[endpoint.ResourceTargetsLabelPrefix+resource] = fmt.Sprintf("10.0.%d.1|10.0.%d.2", i, i)
+	}
+	require.NoError(t, r.ApplyChanges(ctx, &plan.Changes{Create: []*endpoint.Endpoint{merged}}))
+
+	records, err := r.Records(ctx)
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+	assert.Equal(t, merged.Labels, records[0].Labels)
+
+	require.NoError(t, r.ApplyChanges(ctx, &plan.Changes{Delete: records}))
+	records, err = p.Records(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, records)
+}
@@ -19,7 +19,10 @@ package source
 import (
 	"context"
 	"encoding/gob"
+	"io"
 	"net"
+	"reflect"
+	"sync"
 	"time"
 
 	log "github.com/sirupsen/logrus"
@@ -29,23 +32,39 @@ import (
 
 const (
 	dialTimeout = 30 * time.Second
+	// reconnectInterval is the delay before the event stream reconnects after the connection ended
+	reconnectInterval = 10 * time.Second
 )
 
 // connectorSource is an implementation of Source that provides endpoints by connecting
 // to a remote tcp server. The encoding/decoding is done using encoder/gob package.
+// When an event handler is added, the source keeps a connection open and the remote
+// server may push a new endpoint set over it at any time.
 type connectorSource struct {
-	remoteServer string
+	remoteServer      string
+	reconnectInterval time.Duration
+	// streamed holds the endpoint set pushed over the open connection, nil until one arrived
+	streamed []*endpoint.Endpoint
+	// received holds the endpoint set last pushed by the remote server, which is kept across
+	// connections to tell whether a set pushed after reconnecting is a change
+	received    []*endpoint.Endpoint
+	streamedMux sync.RWMutex
 }
 
 // NewConnectorSource creates a new connectorSource with the given config.
 func NewConnectorSource(remoteServer string) (Source, error) {
 	return &connectorSource{
-		remoteServer: remoteServer,
+		remoteServer:      remoteServer,
+		reconnectInterval: reconnectInterval,
 	}, nil
 }
 
 // Endpoints returns endpoint objects.
 func (cs *connectorSource) Endpoints(ctx context.Context) ([]*endpoint.Endpoint, error) {
+	if streamed, ok := cs.streamedEndpoints(); ok {
+		return streamed, nil
+	}
+
 	endpoints := []*endpoint.Endpoint{}
 
 	conn, err := net.DialTimeout("tcp", cs.remoteServer, dialTimeout)
@@ -67,4 +86,103 @@ func (cs *connectorSource) Endpoints(ctx context.Context) ([]*endpoint.Endpoint,
 }
 
 func (cs *connectorSource) AddEventHandler(ctx context.Context, handler func()) {
+	log.Debug("Adding event handler for connector")
+
+	go func() {
+		for {
+			received, err := cs.receive(ctx, handler)
+			if err != nil && ctx.Err() == nil {
+				log.Warnf("Connection to %s interrupted: %v", cs.remoteServer, err)
+			}
+			if err == nil && received <= 1 {
+				// the remote server sends a single set per connection, its changes are picked up by Endpoints
+				log.Debugf("Remote server %s closed the connection after the first endpoint set, not streaming its changes", cs.remoteServer)
+				return
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(cs.reconnectInterval):
+			}
+		}
+	}()
+}
+
+// receive decodes the endpoint sets pushed by the remote server until the connection ends,
+// triggering the handler whenever the set changed, and returns the number of sets received.
+// The pushed set is no longer served once the connection ends, so that Endpoints connects to the
+// remote server again and reports its errors instead of serving a set which may be outdated.
+func (cs *connectorSource) receive(ctx context.Context, handler func()) (int, error) {
+	dialer := net.Dialer{Timeout: dialTimeout}
+	conn, err := dialer.DialContext(ctx, "tcp", cs.remoteServer)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+	defer cs.clearStreamedEndpoints()
+
+	// unblock the decoder once the context is canceled
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.Close()
+		case <-done:
+		}
+	}()
+
+	decoder := gob.NewDecoder(conn)
+	for received := 0; ; received++ {
+		endpoints := []*endpoint.Endpoint{}
+		if err := decoder.Decode(&endpoints); err != nil {
+			if err == io.EOF {
+				return received, nil
+			}
+			return received, err
+		}
+
+		log.Debugf("Received endpoints: %#v", endpoints)
+
+		if cs.setStreamedEndpoints(endpoints) {
+			handler()
+		}
+	}
+}
+
+// setStreamedEndpoints stores the pushed endpoint set and reports whether it differs from the one
+// received before, even over a previous connection
+func (cs *connectorSource) setStreamedEndpoints(endpoints []*endpoint.Endpoint) bool {
+	cs.streamedMux.Lock()
+	defer cs.streamedMux.Unlock()
+
+	cs.streamed = endpoints
+	if cs.received != nil && reflect.DeepEqual(cs.received, endpoints) {
+		return false
+	}
+	cs.received = endpoints
+	return true
+}
+
+// clearStreamedEndpoints stops serving the pushed endpoint set once the connection ended
+func (cs *connectorSource) clearStreamedEndpoints() {
+	cs.streamedMux.Lock()
+	defer cs.streamedMux.Unlock()
+
+	cs.streamed = nil
+}
+
+// streamedEndpoints returns a copy of the endpoint set last pushed by the remote server
+func (cs *connectorSource) streamedEndpoints() ([]*endpoint.Endpoint, bool) {
+	cs.streamedMux.RLock()
+	defer cs.streamedMux.RUnlock()
+
+	if cs.streamed == nil {
+		return nil, false
+	}
+	endpoints := make([]*endpoint.Endpoint, 0, len(cs.streamed))
+	for _, ep := range cs.streamed {
+		endpoints = append(endpoints, ep.DeepCopy())
+	}
+	return endpoints, true
 }
@@ -20,9 +20,12 @@ import (
 	"context"
 	"encoding/gob"
 	"net"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
 
 	"sigs.k8s.io/external-dns/endpoint"
@@ -59,6 +62,9 @@ func TestConnectorSource(t *testing.T) {
 	suite.Run(t, new(ConnectorSuite))
 	t.Run("Interface", testConnectorSourceImplementsSource)
 	t.Run("Endpoints", testConnectorSourceEndpoints)
+	t.Run("EventHandler", testConnectorSourceEventHandler)
+	t.Run("EventHandlerReconnect", testConnectorSourceEventHandlerReconnect)
+	t.Run("EventHandlerOneShot", testConnectorSourceEventHandlerOneShot)
 }
 
 // testConnectorSourceImplementsSource tests that connectorSource is a valid Source.
@@ -137,3 +143,145 @@ func testConnectorSourceEndpoints(t *testing.T) {
 		})
 	}
 }
+
+// testConnectorSourceEventHandler tests that endpoint sets pushed over a long-lived connection trigger the event handler.
+func testConnectorSourceEventHandler(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer ln.Close()
+
+	push := make(chan []*endpoint.Endpoint)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		enc := gob.NewEncoder(conn)
+		for endpoints := range push {
+			if err := enc.Encode(endpoints); err != nil {
+				return
+			}
+		}
+	}()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	cs, err := NewConnectorSource(ln.Addr().String())
+	require.NoError(t, err)
+	events := make(chan struct{}, 10)
+	cs.AddEventHandler(ctx, func() { events <- struct{}{} })
+
+	first := []*endpoint.Endpoint{endpoint.NewEndpoint("abc.example.org", endpoint.RecordTypeA, "1.2.3.4")}
+	push <- first
+	expectEvent(t, events, true)
+	endpoints, err := cs.Endpoints(ctx)
+	require.NoError(t, err)
+	validateEndpoints(t, endpoints, first)
+
+	// pushing the same set again is not a change
+	push <- first
+	expectEvent(t, events, false)
+
+	second := []*endpoint.Endpoint{
+		endpoint.NewEndpoint("abc.example.org", endpoint.RecordTypeA, "1.2.3.4"),
+		endpoint.NewEndpoint("xyz.example.org", endpoint.RecordTypeCNAME, "abc.example.org"),
+	}
+	push <- second
+	expectEvent(t, events, true)
+	endpoints, err = cs.Endpoints(ctx)
+	require.NoError(t, err)
+	validateEndpoints(t, endpoints, second)
+
+	// the pushed set is not served anymore once the remote server is gone
+	close(push)
+	ln.Close()
+	assert.Eventually(t, func() bool {
+		_, err := cs.Endpoints(ctx)
+		return err != nil
+	}, time.Second, 10*time.Millisecond)
+}
+
+// serveConnections serves each connection of the listener with the endpoint sets returned by sets,
+// closing the connection after the last one, and counts the connections
+func serveConnections(ln net.Listener, sets func() [][]*endpoint.Endpoint, connections *int32) {
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			atomic.AddInt32(connections, 1)
+			enc := gob.NewEncoder(conn)
+			for _, endpoints := range sets() {
+				if err := enc.Encode(endpoints); err != nil {
+					break
+				}
+			}
+			conn.Close()
+		}
+	}()
+}
+
+// testConnectorSourceEventHandlerReconnect tests that reconnecting to a remote server pushing the same set is not a change.
+func testConnectorSourceEventHandlerReconnect(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer ln.Close()
+
+	first := []*endpoint.Endpoint{endpoint.NewEndpoint("abc.example.org", endpoint.RecordTypeA, "1.2.3.4")}
+	second := []*endpoint.Endpoint{endpoint.NewEndpoint("abc.example.org", endpoint.RecordTypeA, "4.3.2.1")}
+	var connections, switched int32
+	serveConnections(ln, func() [][]*endpoint.Endpoint {
+		if atomic.LoadInt32(&switched) == 0 {
+			return [][]*endpoint.Endpoint{first, first}
+		}
+		return [][]*endpoint.Endpoint{second, second}
+	}, &connections)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	cs, err := NewConnectorSource(ln.Addr().String())
+	require.NoError(t, err)
+	cs.(*connectorSource).reconnectInterval = 10 * time.Millisecond
+	events := make(chan struct{}, 10)
+	cs.AddEventHandler(ctx, func() { events <- struct{}{} })
+
+	expectEvent(t, events, true)
+	require.Eventually(t, func() bool { return atomic.LoadInt32(&connections) >= 3 }, time.Second, 10*time.Millisecond)
+	expectEvent(t, events, false)
+
+	atomic.StoreInt32(&switched, 1)
+	expectEvent(t, events, true)
+	expectEvent(t, events, false)
+}
+
+// testConnectorSourceEventHandlerOneShot tests that a remote server sending a single set per connection is not streamed.
+func testConnectorSourceEventHandlerOneShot(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer ln.Close()
+
+	set := []*endpoint.Endpoint{endpoint.NewEndpoint("abc.example.org", endpoint.RecordTypeA, "1.2.3.4")}
+	var connections int32
+	serveConnections(ln, func() [][]*endpoint.Endpoint { return [][]*endpoint.Endpoint{set} }, &connections)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	cs, err := NewConnectorSource(ln.Addr().String())
+	require.NoError(t, err)
+	cs.(*connectorSource).reconnectInterval = 10 * time.Millisecond
+	events := make(chan struct{}, 10)
+	cs.AddEventHandler(ctx, func() { events <- struct{}{} })
+
+	expectEvent(t, events, true)
+	expectEvent(t, events, false)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&connections))
+
+	endpoints, err := cs.Endpoints(ctx)
+	require.NoError(t, err)
+	validateEndpoints(t, endpoints, set)
+}
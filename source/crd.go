@@ -20,6 +20,7 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"reflect"
 	"strings"
 
 	log "github.com/sirupsen/logrus"
@@ -28,8 +29,10 @@ import (
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/runtime/serializer"
+	"k8s.io/apimachinery/pkg/watch"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/cache"
 	"k8s.io/client-go/tools/clientcmd"
 
 	"sigs.k8s.io/external-dns/endpoint"
@@ -115,6 +118,41 @@ func NewCRDSource(crdClient rest.Interface, namespace, kind string, annotationFi
 }
 
 func (cs *crdSource) AddEventHandler(ctx context.Context, handler func()) {
+	log.Debug("Adding event handler for CRD")
+
+	lw := &cache.ListWatch{
+		ListFunc: func(opts metav1.ListOptions) (runtime.Object, error) {
+			opts.LabelSelector = cs.labelFilter
+			return cs.List(ctx, &opts)
+		},
+		WatchFunc: func(opts metav1.ListOptions) (watch.Interface, error) {
+			opts.LabelSelector = cs.labelFilter
+			return cs.watch(ctx, &opts)
+		},
+	}
+
+	_, informer := cache.NewInformer(lw, &endpoint.DNSEndpoint{}, 0,
+		cache.ResourceEventHandlerFuncs{
+			AddFunc: func(obj interface{}) {
+				handler()
+			},
+			UpdateFunc: func(old interface{}, new interface{}) {
+				// Endpoints updates the observed generation in the status, which must not trigger another run
+				oldEndpoint, okOld := old.(*endpoint.DNSEndpoint)
+				newEndpoint, okNew := new.(*endpoint.DNSEndpoint)
+				if okOld && okNew && oldEndpoint.Generation == newEndpoint.Generation &&
+					reflect.DeepEqual(oldEndpoint.Annotations, newEndpoint.Annotations) &&
+					reflect.DeepEqual(oldEndpoint.Labels, newEndpoint.Labels) {
+					return
+				}
+				handler()
+			},
+			DeleteFunc: func(obj interface{}) {
+				handler()
+			},
+		},
+	)
+	go informer.Run(ctx.Done())
 }
 
 // Endpoints returns endpoint objects.
@@ -170,6 +208,7 @@ func (cs *crdSource) Endpoints(ctx context.Context) ([]*endpoint.Endpoint, error
 		}
 
 		cs.setResourceLabel(&dnsEndpoint, crdEndpoints)
+		setMergeLabel(dnsEndpoint.Annotations, crdEndpoints)
 		endpoints = append(endpoints, crdEndpoints...)
 
 		if dnsEndpoint.Status.ObservedGeneration == dnsEndpoint.Generation {
@@ -204,6 +243,15 @@ func (cs *crdSource) List(ctx context.Context, opts *metav1.ListOptions) (result
 	return
 }
 
+func (cs *crdSource) watch(ctx context.Context, opts *metav1.ListOptions) (watch.Interface, error) {
+	opts.Watch = true
+	return cs.crdClient.Get().
+		Namespace(cs.namespace).
+		Resource(cs.crdResource).
+		VersionedParams(opts, cs.codec).
+		Watch(ctx)
+}
+
 func (cs *crdSource) UpdateStatus(ctx context.Context, dnsEndpoint *endpoint.DNSEndpoint) (result *endpoint.DNSEndpoint, err error) {
 	result = &endpoint.DNSEndpoint{}
 	err = cs.crdClient.Put().
@@ -26,7 +26,9 @@ import (
 	"net/http"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -418,3 +420,71 @@ func validateCRDResource(t *testing.T, src Source, expectError bool) {
 		}
 	}
 }
+
+func TestCRDSourceEventHandler(t *testing.T) {
+	apiVersion := "test.k8s.io/v1alpha1"
+	groupVersion, _ := schema.ParseGroupVersion(apiVersion)
+	scheme := runtime.NewScheme()
+	addKnownTypes(scheme, groupVersion)
+	codecFactory := serializer.WithoutConversionCodecFactory{
+		CodecFactory: serializer.NewCodecFactory(scheme),
+	}
+	codec := codecFactory.LegacyCodec(groupVersion)
+
+	watchReader, watchWriter := io.Pipe()
+	defer watchWriter.Close()
+	watched := make(chan struct{}, 10)
+
+	client := &fake.RESTClient{
+		GroupVersion:         groupVersion,
+		VersionedAPIPath:     "/apis/" + apiVersion,
+		NegotiatedSerializer: codecFactory,
+		Client: fake.CreateHTTPClient(func(req *http.Request) (*http.Response, error) {
+			if req.URL.Query().Get("watch") == "true" {
+				assert.Equal(t, "app=dns", req.URL.Query().Get("labelSelector"))
+				watched <- struct{}{}
+				return &http.Response{StatusCode: http.StatusOK, Header: defaultHeader(), Body: watchReader}, nil
+			}
+			return &http.Response{StatusCode: http.StatusOK, Header: defaultHeader(), Body: objBody(codec, &endpoint.DNSEndpointList{})}, nil
+		}),
+	}
+
+	cs, err := NewCRDSource(client, "default", "DNSEndpoint", "", "app=dns", scheme)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events := make(chan struct{}, 10)
+	cs.AddEventHandler(ctx, func() { events <- struct{}{} })
+
+	select {
+	case <-watched:
+	case <-time.After(5 * time.Second):
+		t.Fatal("source did not start watching")
+	}
+
+	dnsEndpoint := &endpoint.DNSEndpoint{
+		TypeMeta:   metav1.TypeMeta{APIVersion: apiVersion, Kind: "DNSEndpoint"},
+		ObjectMeta: metav1.ObjectMeta{Name: "foo", Namespace: "default", Generation: 1, ResourceVersion: "1"},
+	}
+	event, err := json.Marshal(map[string]interface{}{
+		"type":   "ADDED",
+		"object": json.RawMessage(runtime.EncodeOrDie(codec, dnsEndpoint)),
+	})
+	require.NoError(t, err)
+	_, err = watchWriter.Write(event)
+	require.NoError(t, err)
+	expectEvent(t, events, true)
+
+	// updates of the status only are ignored
+	dnsEndpoint.ResourceVersion = "2"
+	dnsEndpoint.Status.ObservedGeneration = 1
+	event, err = json.Marshal(map[string]interface{}{
+		"type":   "MODIFIED",
+		"object": json.RawMessage(runtime.EncodeOrDie(codec, dnsEndpoint)),
+	})
+	require.NoError(t, err)
+	_, err = watchWriter.Write(event)
+	require.NoError(t, err)
+	expectEvent(t, events, false)
+}
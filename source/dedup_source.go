@@ -62,3 +62,11 @@ func (ms *dedupSource) Endpoints(ctx context.Context) ([]*endpoint.Endpoint, err
 func (ms *dedupSource) AddEventHandler(ctx context.Context, handler func()) {
 	ms.source.AddEventHandler(ctx, handler)
 }
+
+// Failures returns the failures reported by the wrapped source, if it reports any
+func (ms *dedupSource) Failures() []SourceFailure {
+	if reporter, ok := ms.source.(FailureReporter); ok {
+		return reporter.Failures()
+	}
+	return nil
+}
@@ -0,0 +1,236 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package source
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"sigs.k8s.io/yaml"
+
+	"sigs.k8s.io/external-dns/endpoint"
+)
+
+// fileSourcePollInterval is the interval in which watched files are checked for changes
+const fileSourcePollInterval = 5 * time.Second
+
+// fileSource is an implementation of Source that reads endpoints from YAML or JSON files
+// using the schema of DNSEndpointSpec. Directories are expanded to the .yaml, .yml and .json
+// files they contain. A file which fails to parse is reported and keeps its last valid
+// endpoints, so that a broken file neither deletes its own records nor affects other files.
+// The same applies to a configured path which is temporarily missing.
+type fileSource struct {
+	paths        []string
+	pollInterval time.Duration
+
+	// parsed holds the endpoints of the last valid version of each file
+	parsed    map[string][]*endpoint.Endpoint
+	parsedMux sync.Mutex
+}
+
+// NewFileSource creates a new fileSource reading the given files or directories.
+func NewFileSource(paths []string) (Source, error) {
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("no file source path given")
+	}
+	for _, path := range paths {
+		if _, err := os.Stat(path); err != nil {
+			return nil, err
+		}
+	}
+
+	return &fileSource{
+		paths:        paths,
+		pollInterval: fileSourcePollInterval,
+		parsed:       map[string][]*endpoint.Endpoint{},
+	}, nil
+}
+
+// Endpoints returns the endpoints of all files.
+func (fs *fileSource) Endpoints(ctx context.Context) ([]*endpoint.Endpoint, error) {
+	files, unavailable := fs.files()
+
+	fs.parsedMux.Lock()
+	defer fs.parsedMux.Unlock()
+
+	endpoints := []*endpoint.Endpoint{}
+	parsed := map[string][]*endpoint.Endpoint{}
+
+	// keep the last valid endpoints of the files below paths which can't be read
+	for path, err := range unavailable {
+		kept := 0
+		for file, fileEndpoints := range fs.parsed {
+			if file != path && !strings.HasPrefix(file, filepath.Clean(path)+string(filepath.Separator)) {
+				continue
+			}
+			parsed[file] = fileEndpoints
+			kept += len(fileEndpoints)
+			for _, ep := range fileEndpoints {
+				endpoints = append(endpoints, ep.DeepCopy())
+			}
+		}
+		log.Errorf("Failed to read file source path %s, using %d previously read endpoints: %v", path, kept, err)
+	}
+
+	for _, file := range files {
+		fileEndpoints, err := parseEndpointsFile(file)
+		if err != nil {
+			// keep the last valid endpoints of the file, if any
+			fileEndpoints = fs.parsed[file]
+			log.Errorf("Failed to read endpoints from file %s, using %d previously read endpoints: %v", file, len(fileEndpoints), err)
+		}
+		if fileEndpoints == nil {
+			continue
+		}
+		parsed[file] = fileEndpoints
+
+		for _, ep := range fileEndpoints {
+			endpoints = append(endpoints, ep.DeepCopy())
+		}
+	}
+
+	// forget files which were removed
+	fs.parsed = parsed
+
+	return endpoints, nil
+}
+
+// AddEventHandler checks the files for changes of their modification time or size in the
+// poll interval and calls the handler whenever a file was changed, added or removed.
+func (fs *fileSource) AddEventHandler(ctx context.Context, handler func()) {
+	log.Debug("Adding event handler for file")
+
+	last := fs.fingerprint()
+	go func() {
+		ticker := time.NewTicker(fs.pollInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			current := fs.fingerprint()
+			if !reflect.DeepEqual(last, current) {
+				log.Debug("Files of the file source changed")
+				last = current
+				handler()
+			}
+		}
+	}()
+}
+
+// files returns the files to read, expanding directories to the YAML and JSON files they contain,
+// and the errors of the paths which can't be read
+func (fs *fileSource) files() ([]string, map[string]error) {
+	files := []string{}
+	unavailable := map[string]error{}
+	for _, path := range fs.paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			unavailable[path] = err
+			continue
+		}
+		if !info.IsDir() {
+			files = append(files, path)
+			continue
+		}
+
+		entries, err := ioutil.ReadDir(path)
+		if err != nil {
+			unavailable[path] = err
+			continue
+		}
+		for _, entry := range entries {
+			if entry.IsDir() {
+				continue
+			}
+			switch strings.ToLower(filepath.Ext(entry.Name())) {
+			case ".yaml", ".yml", ".json":
+				files = append(files, filepath.Join(path, entry.Name()))
+			}
+		}
+	}
+	sort.Strings(files)
+	return files, unavailable
+}
+
+// fingerprint returns the modification time and size of every file, errors are part of the
+// fingerprint so that a path which disappears or reappears is noticed as well
+func (fs *fileSource) fingerprint() map[string]string {
+	fingerprint := map[string]string{}
+
+	files, unavailable := fs.files()
+	for path, err := range unavailable {
+		fingerprint[path] = err.Error()
+	}
+	for _, file := range files {
+		info, err := os.Stat(file)
+		if err != nil {
+			fingerprint[file] = err.Error()
+			continue
+		}
+		fingerprint[file] = fmt.Sprintf("%s/%d", info.ModTime(), info.Size())
+	}
+	return fingerprint
+}
+
+// parseEndpointsFile reads the endpoints of a file in the format of DNSEndpointSpec
+// and labels them with the file as their resource
+func parseEndpointsFile(file string) ([]*endpoint.Endpoint, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	spec := endpoint.DNSEndpointSpec{}
+	// YAML is a superset of JSON, so this reads both formats
+	if err := yaml.UnmarshalStrict(data, &spec); err != nil {
+		return nil, err
+	}
+
+	endpoints := make([]*endpoint.Endpoint, 0, len(spec.Endpoints))
+	for i, ep := range spec.Endpoints {
+		if ep == nil || ep.DNSName == "" {
+			return nil, fmt.Errorf("endpoint %d has no dnsName", i)
+		}
+		if ep.RecordType == "" {
+			return nil, fmt.Errorf("endpoint %s has no recordType", ep.DNSName)
+		}
+		if len(ep.Targets) == 0 {
+			return nil, fmt.Errorf("endpoint %s has an empty list of targets", ep.DNSName)
+		}
+
+		if ep.Labels == nil {
+			ep.Labels = endpoint.NewLabels()
+		}
+		ep.Labels[endpoint.ResourceLabelKey] = "file/" + file
+		endpoints = append(endpoints, ep)
+	}
+	return endpoints, nil
+}
@@ -0,0 +1,212 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package source
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"sigs.k8s.io/external-dns/endpoint"
+)
+
+// Validates that fileSource is a Source
+var _ Source = &fileSource{}
+
+const (
+	testFileLegacyHosts = `
+endpoints:
+- dnsName: legacy.example.org
+  recordType: A
+  recordTTL: 300
+  targets:
+  - 10.0.0.1
+  - 10.0.0.2
+- dnsName: www.legacy.example.org
+  recordType: CNAME
+  targets:
+  - legacy.example.org
+`
+	testFileMail = `{"endpoints": [{"dnsName": "mail.example.org", "recordType": "A", "targets": ["10.0.1.1"]}]}`
+)
+
+func writeTestFile(t *testing.T, path, content string) {
+	require.NoError(t, ioutil.WriteFile(path, []byte(content), 0600))
+}
+
+func TestNewFileSource(t *testing.T) {
+	_, err := NewFileSource(nil)
+	assert.Error(t, err, "no path")
+
+	_, err = NewFileSource([]string{"/does/not/exist.yaml"})
+	assert.Error(t, err, "missing path")
+}
+
+func TestFileSourceEndpoints(t *testing.T) {
+	dir, err := ioutil.TempDir("", "file-source")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	legacy := filepath.Join(dir, "legacy.yaml")
+	mail := filepath.Join(dir, "mail.json")
+	writeTestFile(t, legacy, testFileLegacyHosts)
+	writeTestFile(t, mail, testFileMail)
+	writeTestFile(t, filepath.Join(dir, "README.md"), "not an endpoints file")
+
+	source, err := NewFileSource([]string{dir})
+	require.NoError(t, err)
+
+	endpoints, err := source.Endpoints(context.Background())
+	require.NoError(t, err)
+	validateEndpoints(t, endpoints, []*endpoint.Endpoint{
+		{DNSName: "legacy.example.org", RecordType: endpoint.RecordTypeA, Targets: endpoint.Targets{"10.0.0.1", "10.0.0.2"}, RecordTTL: 300},
+		{DNSName: "www.legacy.example.org", RecordType: endpoint.RecordTypeCNAME, Targets: endpoint.Targets{"legacy.example.org"}},
+		{DNSName: "mail.example.org", RecordType: endpoint.RecordTypeA, Targets: endpoint.Targets{"10.0.1.1"}},
+	})
+	for _, ep := range endpoints {
+		assert.Contains(t, []string{"file/" + legacy, "file/" + mail}, ep.Labels[endpoint.ResourceLabelKey])
+	}
+
+	// a broken file keeps its last valid endpoints without affecting other files
+	writeTestFile(t, legacy, "endpoints: [")
+	writeTestFile(t, mail, `{"endpoints": [{"dnsName": "smtp.example.org", "recordType": "A", "targets": ["10.0.1.2"]}]}`)
+	endpoints, err = source.Endpoints(context.Background())
+	require.NoError(t, err)
+	validateEndpoints(t, endpoints, []*endpoint.Endpoint{
+		{DNSName: "legacy.example.org", RecordType: endpoint.RecordTypeA, Targets: endpoint.Targets{"10.0.0.1", "10.0.0.2"}, RecordTTL: 300},
+		{DNSName: "www.legacy.example.org", RecordType: endpoint.RecordTypeCNAME, Targets: endpoint.Targets{"legacy.example.org"}},
+		{DNSName: "smtp.example.org", RecordType: endpoint.RecordTypeA, Targets: endpoint.Targets{"10.0.1.2"}},
+	})
+
+	// removed files drop their endpoints
+	require.NoError(t, os.Remove(legacy))
+	endpoints, err = source.Endpoints(context.Background())
+	require.NoError(t, err)
+	validateEndpoints(t, endpoints, []*endpoint.Endpoint{
+		{DNSName: "smtp.example.org", RecordType: endpoint.RecordTypeA, Targets: endpoint.Targets{"10.0.1.2"}},
+	})
+}
+
+func TestFileSourceMissingPath(t *testing.T) {
+	dir, err := ioutil.TempDir("", "file-source")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	legacyDir := filepath.Join(dir, "legacy")
+	require.NoError(t, os.Mkdir(legacyDir, 0700))
+	writeTestFile(t, filepath.Join(legacyDir, "legacy.yaml"), testFileLegacyHosts)
+	mail := filepath.Join(dir, "mail.json")
+	writeTestFile(t, mail, testFileMail)
+
+	source, err := NewFileSource([]string{legacyDir, mail})
+	require.NoError(t, err)
+	_, err = source.Endpoints(context.Background())
+	require.NoError(t, err)
+
+	// a missing path keeps its last endpoints without affecting other paths
+	require.NoError(t, os.Remove(mail))
+	writeTestFile(t, filepath.Join(legacyDir, "legacy.yaml"), `{"endpoints": [{"dnsName": "legacy.example.org", "recordType": "A", "targets": ["10.0.0.3"]}]}`)
+	endpoints, err := source.Endpoints(context.Background())
+	require.NoError(t, err)
+	validateEndpoints(t, endpoints, []*endpoint.Endpoint{
+		{DNSName: "legacy.example.org", RecordType: endpoint.RecordTypeA, Targets: endpoint.Targets{"10.0.0.3"}},
+		{DNSName: "mail.example.org", RecordType: endpoint.RecordTypeA, Targets: endpoint.Targets{"10.0.1.1"}},
+	})
+
+	require.NoError(t, os.RemoveAll(legacyDir))
+	endpoints, err = source.Endpoints(context.Background())
+	require.NoError(t, err)
+	validateEndpoints(t, endpoints, []*endpoint.Endpoint{
+		{DNSName: "legacy.example.org", RecordType: endpoint.RecordTypeA, Targets: endpoint.Targets{"10.0.0.3"}},
+		{DNSName: "mail.example.org", RecordType: endpoint.RecordTypeA, Targets: endpoint.Targets{"10.0.1.1"}},
+	})
+
+	// a path which reappears is read again
+	writeTestFile(t, mail, `{"endpoints": [{"dnsName": "smtp.example.org", "recordType": "A", "targets": ["10.0.1.2"]}]}`)
+	endpoints, err = source.Endpoints(context.Background())
+	require.NoError(t, err)
+	validateEndpoints(t, endpoints, []*endpoint.Endpoint{
+		{DNSName: "legacy.example.org", RecordType: endpoint.RecordTypeA, Targets: endpoint.Targets{"10.0.0.3"}},
+		{DNSName: "smtp.example.org", RecordType: endpoint.RecordTypeA, Targets: endpoint.Targets{"10.0.1.2"}},
+	})
+}
+
+func TestFileSourceInvalidFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "file-source")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	for _, ti := range []struct {
+		title   string
+		content string
+	}{
+		{"syntax error", "endpoints: ["},
+		{"unknown field", "endpoints:\n- dnsName: foo.example.org\n  recordType: A\n  target: 10.0.0.1\n"},
+		{"missing dnsName", "endpoints:\n- recordType: A\n  targets: [10.0.0.1]\n"},
+		{"missing recordType", "endpoints:\n- dnsName: foo.example.org\n  targets: [10.0.0.1]\n"},
+		{"missing targets", "endpoints:\n- dnsName: foo.example.org\n  recordType: A\n"},
+	} {
+		t.Run(ti.title, func(t *testing.T) {
+			file := filepath.Join(dir, "invalid.yaml")
+			writeTestFile(t, file, ti.content)
+
+			_, err := parseEndpointsFile(file)
+			assert.Error(t, err)
+
+			source, err := NewFileSource([]string{file})
+			require.NoError(t, err)
+			endpoints, err := source.Endpoints(context.Background())
+			require.NoError(t, err)
+			assert.Empty(t, endpoints)
+		})
+	}
+}
+
+func TestFileSourceEventHandler(t *testing.T) {
+	dir, err := ioutil.TempDir("", "file-source")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	legacy := filepath.Join(dir, "legacy.yaml")
+	writeTestFile(t, legacy, testFileLegacyHosts)
+
+	source, err := NewFileSource([]string{dir})
+	require.NoError(t, err)
+	source.(*fileSource).pollInterval = 10 * time.Millisecond
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events := make(chan struct{}, 10)
+	source.AddEventHandler(ctx, func() { events <- struct{}{} })
+
+	expectEvent(t, events, false)
+
+	writeTestFile(t, legacy, testFileLegacyHosts+"- dnsName: new.example.org\n  recordType: A\n  targets: [10.0.0.3]\n")
+	expectEvent(t, events, true)
+
+	writeTestFile(t, filepath.Join(dir, "mail.json"), testFileMail)
+	expectEvent(t, events, true)
+
+	require.NoError(t, os.Remove(legacy))
+	expectEvent(t, events, true)
+}
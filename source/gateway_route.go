@@ -0,0 +1,443 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package source
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/tools/cache"
+
+	"sigs.k8s.io/external-dns/endpoint"
+)
+
+const gatewayAPIGroup = "gateway.networking.k8s.io"
+
+var (
+	// GatewayGVR is the resource of Gateway API Gateways
+	GatewayGVR = schema.GroupVersionResource{Group: gatewayAPIGroup, Version: "v1beta1", Resource: "gateways"}
+	// HTTPRouteGVR is the resource of Gateway API HTTPRoutes
+	HTTPRouteGVR = schema.GroupVersionResource{Group: gatewayAPIGroup, Version: "v1beta1", Resource: "httproutes"}
+	// TLSRouteGVR is the resource of Gateway API TLSRoutes
+	TLSRouteGVR = schema.GroupVersionResource{Group: gatewayAPIGroup, Version: "v1alpha2", Resource: "tlsroutes"}
+)
+
+// The following types mirror the subset of the Gateway API used by the route sources.
+// They are decoded from unstructured objects so that no Gateway API client is required.
+
+type gatewayAPIGateway struct {
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+	Spec              struct {
+		Listeners []gatewayAPIListener `json:"listeners,omitempty"`
+	} `json:"spec"`
+	Status struct {
+		Addresses []struct {
+			Value string `json:"value"`
+		} `json:"addresses,omitempty"`
+	} `json:"status,omitempty"`
+}
+
+type gatewayAPIListener struct {
+	Name     string `json:"name"`
+	Hostname string `json:"hostname,omitempty"`
+	Protocol string `json:"protocol"`
+}
+
+type gatewayAPIRoute struct {
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+	Spec              struct {
+		ParentRefs []gatewayAPIParentRef `json:"parentRefs,omitempty"`
+		Hostnames  []string              `json:"hostnames,omitempty"`
+	} `json:"spec"`
+	Status struct {
+		Parents []struct {
+			ParentRef  gatewayAPIParentRef `json:"parentRef"`
+			Conditions []struct {
+				Type   string `json:"type"`
+				Status string `json:"status"`
+			} `json:"conditions,omitempty"`
+		} `json:"parents,omitempty"`
+	} `json:"status,omitempty"`
+}
+
+type gatewayAPIParentRef struct {
+	Group       *string `json:"group,omitempty"`
+	Kind        *string `json:"kind,omitempty"`
+	Namespace   *string `json:"namespace,omitempty"`
+	Name        string  `json:"name"`
+	SectionName *string `json:"sectionName,omitempty"`
+}
+
+// gatewayRouteSource is an implementation of Source for Gateway API routes.
+// Hostnames are taken from the route and restricted to the listeners of the parent Gateways
+// the route is attached to, targets are the addresses of these Gateways.
+// Use targetAnnotationKey to explicitly set Endpoint.
+type gatewayRouteSource struct {
+	kind                     string
+	resource                 string
+	protocols                []string
+	namespace                string
+	annotationFilter         string
+	ignoreHostnameAnnotation bool
+	routeInformer            informers.GenericInformer
+	gatewayInformer          informers.GenericInformer
+}
+
+// NewGatewayHTTPRouteSource creates a new gatewayRouteSource for HTTPRoutes with the given config.
+func NewGatewayHTTPRouteSource(dynamicKubeClient dynamic.Interface, namespace string, annotationFilter string, ignoreHostnameAnnotation bool) (Source, error) {
+	return newGatewayRouteSource(dynamicKubeClient, HTTPRouteGVR, "HTTPRoute", []string{"HTTP", "HTTPS"}, namespace, annotationFilter, ignoreHostnameAnnotation)
+}
+
+// NewGatewayTLSRouteSource creates a new gatewayRouteSource for TLSRoutes with the given config.
+func NewGatewayTLSRouteSource(dynamicKubeClient dynamic.Interface, namespace string, annotationFilter string, ignoreHostnameAnnotation bool) (Source, error) {
+	return newGatewayRouteSource(dynamicKubeClient, TLSRouteGVR, "TLSRoute", []string{"TLS"}, namespace, annotationFilter, ignoreHostnameAnnotation)
+}
+
+func newGatewayRouteSource(
+	dynamicKubeClient dynamic.Interface,
+	gvr schema.GroupVersionResource,
+	kind string,
+	protocols []string,
+	namespace string,
+	annotationFilter string,
+	ignoreHostnameAnnotation bool,
+) (Source, error) {
+	// Use shared informers to listen for add/update/delete of routes in the specified namespace.
+	// Routes may attach to Gateways of other namespaces, so Gateways are watched in all namespaces.
+	// Set resync period to 0, to prevent processing when nothing has changed.
+	routeInformerFactory := dynamicinformer.NewFilteredDynamicSharedInformerFactory(dynamicKubeClient, 0, namespace, nil)
+	routeInformer := routeInformerFactory.ForResource(gvr)
+	gatewayInformerFactory := dynamicinformer.NewDynamicSharedInformerFactory(dynamicKubeClient, 0)
+	gatewayInformer := gatewayInformerFactory.ForResource(GatewayGVR)
+
+	// Add default resource event handlers to properly initialize informer.
+	for _, informer := range []informers.GenericInformer{routeInformer, gatewayInformer} {
+		informer.Informer().AddEventHandler(
+			cache.ResourceEventHandlerFuncs{
+				AddFunc: func(obj interface{}) {
+				},
+			},
+		)
+	}
+
+	// TODO informer is not explicitly stopped since controller is not passing in its channel.
+	routeInformerFactory.Start(wait.NeverStop)
+	gatewayInformerFactory.Start(wait.NeverStop)
+
+	// wait for the local cache to be populated.
+	err := poll(time.Second, 60*time.Second, func() (bool, error) {
+		return routeInformer.Informer().HasSynced() && gatewayInformer.Informer().HasSynced(), nil
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to sync cache")
+	}
+
+	return &gatewayRouteSource{
+		kind:                     kind,
+		resource:                 gvr.Resource,
+		protocols:                protocols,
+		namespace:                namespace,
+		annotationFilter:         annotationFilter,
+		ignoreHostnameAnnotation: ignoreHostnameAnnotation,
+		routeInformer:            routeInformer,
+		gatewayInformer:          gatewayInformer,
+	}, nil
+}
+
+// Endpoints returns endpoint objects for each host-target combination that should be processed.
+// Retrieves all routes of the source's kind in the source's namespace(s).
+func (sc *gatewayRouteSource) Endpoints(ctx context.Context) ([]*endpoint.Endpoint, error) {
+	selector, err := getLabelSelector(sc.annotationFilter)
+	if err != nil {
+		return nil, err
+	}
+
+	gateways, err := sc.gateways()
+	if err != nil {
+		return nil, err
+	}
+
+	objects, err := sc.routeInformer.Lister().ByNamespace(sc.namespace).List(labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+
+	endpoints := []*endpoint.Endpoint{}
+
+	for _, object := range objects {
+		route := &gatewayAPIRoute{}
+		if err := convertUnstructured(object, route); err != nil {
+			return nil, errors.Wrapf(err, "failed to convert to %s", sc.kind)
+		}
+
+		// include route if its annotations match the selector
+		if !selector.Empty() && !matchLabelSelector(selector, route.Annotations) {
+			continue
+		}
+		// Check controller annotation to see if we are responsible.
+		controller, ok := route.Annotations[controllerAnnotationKey]
+		if ok && controller != controllerAnnotationValue {
+			log.Debugf("Skipping %s %s/%s because controller value does not match, found: %s, required: %s",
+				sc.kind, route.Namespace, route.Name, controller, controllerAnnotationValue)
+			continue
+		}
+
+		routeEndpoints := sc.endpointsFromRoute(route, gateways)
+		if len(routeEndpoints) == 0 {
+			log.Debugf("No endpoints could be generated from %s %s/%s", sc.kind, route.Namespace, route.Name)
+			continue
+		}
+
+		log.Debugf("Endpoints generated from %s %s/%s: %v", sc.kind, route.Namespace, route.Name, routeEndpoints)
+		for _, ep := range routeEndpoints {
+			ep.Labels[endpoint.ResourceLabelKey] = fmt.Sprintf("%s/%s/%s", strings.ToLower(sc.kind), route.Namespace, route.Name)
+		}
+		setMergeLabel(route.Annotations, routeEndpoints)
+		endpoints = append(endpoints, routeEndpoints...)
+	}
+
+	for _, ep := range endpoints {
+		sort.Sort(ep.Targets)
+	}
+
+	return endpoints, nil
+}
+
+// gateways returns all Gateways keyed by namespace/name
+func (sc *gatewayRouteSource) gateways() (map[string]*gatewayAPIGateway, error) {
+	objects, err := sc.gatewayInformer.Lister().List(labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+	gateways := make(map[string]*gatewayAPIGateway, len(objects))
+	for _, object := range objects {
+		gw := &gatewayAPIGateway{}
+		if err := convertUnstructured(object, gw); err != nil {
+			return nil, errors.Wrap(err, "failed to convert to Gateway")
+		}
+		gateways[gw.Namespace+"/"+gw.Name] = gw
+	}
+	return gateways, nil
+}
+
+// endpointsFromRoute extracts the endpoints of a route from the Gateways it is attached to
+func (sc *gatewayRouteSource) endpointsFromRoute(route *gatewayAPIRoute, gateways map[string]*gatewayAPIGateway) []*endpoint.Endpoint {
+	routeTargets := getTargetsFromTargetAnnotation(route.Annotations)
+
+	hostTargets := map[string]endpoint.Targets{}
+	var allTargets endpoint.Targets
+	for _, ref := range route.Spec.ParentRefs {
+		if !isGatewayParentRef(ref) {
+			continue
+		}
+		namespace := route.Namespace
+		if ref.Namespace != nil && *ref.Namespace != "" {
+			namespace = *ref.Namespace
+		}
+		gw, ok := gateways[namespace+"/"+ref.Name]
+		if !ok {
+			log.Debugf("Gateway %s/%s referenced by %s %s/%s not found", namespace, ref.Name, sc.kind, route.Namespace, route.Name)
+			continue
+		}
+		if !routeAccepted(route, ref, namespace) {
+			log.Debugf("%s %s/%s is not accepted by Gateway %s/%s", sc.kind, route.Namespace, route.Name, namespace, ref.Name)
+			continue
+		}
+
+		targets := routeTargets
+		if len(targets) == 0 {
+			targets = getTargetsFromTargetAnnotation(gw.Annotations)
+		}
+		if len(targets) == 0 {
+			for _, address := range gw.Status.Addresses {
+				if address.Value != "" {
+					targets = append(targets, address.Value)
+				}
+			}
+		}
+		if len(targets) == 0 {
+			continue
+		}
+		allTargets = appendUniqueTargets(allTargets, targets...)
+
+		for _, listener := range gw.Spec.Listeners {
+			if ref.SectionName != nil && *ref.SectionName != "" && *ref.SectionName != listener.Name {
+				continue
+			}
+			if !sc.supportsProtocol(listener.Protocol) {
+				continue
+			}
+			hostnames := route.Spec.Hostnames
+			if len(hostnames) == 0 {
+				hostnames = []string{""}
+			}
+			for _, hostname := range hostnames {
+				if match := gatewayHostnameMatch(hostname, listener.Hostname); match != "" {
+					hostTargets[match] = appendUniqueTargets(hostTargets[match], targets...)
+				}
+			}
+		}
+	}
+
+	if !sc.ignoreHostnameAnnotation && len(allTargets) > 0 {
+		for _, hostname := range getHostnamesFromAnnotations(route.Annotations) {
+			hostTargets[hostname] = appendUniqueTargets(hostTargets[hostname], allTargets...)
+		}
+	}
+
+	ttl, err := getTTLFromAnnotations(route.Annotations)
+	if err != nil {
+		log.Warn(err)
+	}
+	providerSpecific, setIdentifier := getProviderSpecificAnnotations(route.Annotations)
+
+	hostnames := make([]string, 0, len(hostTargets))
+	for hostname := range hostTargets {
+		hostnames = append(hostnames, hostname)
+	}
+	sort.Strings(hostnames)
+
+	var endpoints []*endpoint.Endpoint
+	for _, hostname := range hostnames {
+		endpoints = append(endpoints, endpointsForHostname(strings.TrimSuffix(hostname, "."), hostTargets[hostname], ttl, providerSpecific, setIdentifier)...)
+	}
+	return endpoints
+}
+
+func (sc *gatewayRouteSource) supportsProtocol(protocol string) bool {
+	for _, p := range sc.protocols {
+		if strings.EqualFold(p, protocol) {
+			return true
+		}
+	}
+	return false
+}
+
+func (sc *gatewayRouteSource) AddEventHandler(ctx context.Context, handler func()) {
+	log.Debugf("Adding event handler for %s", sc.resource)
+
+	// Right now there is no way to remove event handler from informer, see:
+	// https://github.com/kubernetes/kubernetes/issues/79610
+	for _, informer := range []informers.GenericInformer{sc.routeInformer, sc.gatewayInformer} {
+		informer.Informer().AddEventHandler(
+			cache.ResourceEventHandlerFuncs{
+				AddFunc: func(obj interface{}) {
+					handler()
+				},
+				UpdateFunc: func(old interface{}, new interface{}) {
+					handler()
+				},
+				DeleteFunc: func(obj interface{}) {
+					handler()
+				},
+			},
+		)
+	}
+}
+
+// isGatewayParentRef reports whether ref references a Gateway, which is the default kind of parent references
+func isGatewayParentRef(ref gatewayAPIParentRef) bool {
+	if ref.Group != nil && *ref.Group != "" && *ref.Group != gatewayAPIGroup {
+		return false
+	}
+	return ref.Kind == nil || *ref.Kind == "" || *ref.Kind == "Gateway"
+}
+
+// routeAccepted reports whether the Gateway referenced by ref reported the route as accepted
+func routeAccepted(route *gatewayAPIRoute, ref gatewayAPIParentRef, namespace string) bool {
+	for _, parent := range route.Status.Parents {
+		if !isGatewayParentRef(parent.ParentRef) || parent.ParentRef.Name != ref.Name {
+			continue
+		}
+		parentNamespace := route.Namespace
+		if parent.ParentRef.Namespace != nil && *parent.ParentRef.Namespace != "" {
+			parentNamespace = *parent.ParentRef.Namespace
+		}
+		if parentNamespace != namespace || stringValue(parent.ParentRef.SectionName) != stringValue(ref.SectionName) {
+			continue
+		}
+		for _, condition := range parent.Conditions {
+			if condition.Type == "Accepted" && condition.Status == "True" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// gatewayHostnameMatch returns the hostname to publish for a route hostname on a listener hostname,
+// which is the more specific of both, or "" if they don't intersect.
+// An empty hostname matches everything, a wildcard matches one or more labels.
+func gatewayHostnameMatch(routeHostname, listenerHostname string) string {
+	switch {
+	case listenerHostname == "":
+		return routeHostname
+	case routeHostname == "", routeHostname == listenerHostname:
+		return listenerHostname
+	case strings.HasPrefix(listenerHostname, "*.") && strings.HasSuffix(routeHostname, listenerHostname[1:]):
+		return routeHostname
+	case strings.HasPrefix(routeHostname, "*.") && strings.HasSuffix(listenerHostname, routeHostname[1:]):
+		return listenerHostname
+	}
+	return ""
+}
+
+func appendUniqueTargets(targets endpoint.Targets, add ...string) endpoint.Targets {
+	for _, target := range add {
+		found := false
+		for _, existing := range targets {
+			if existing == target {
+				found = true
+				break
+			}
+		}
+		if !found {
+			targets = append(targets, target)
+		}
+	}
+	return targets
+}
+
+func stringValue(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+// convertUnstructured decodes an object returned by a dynamic informer into out
+func convertUnstructured(object runtime.Object, out interface{}) error {
+	u, ok := object.(*unstructured.Unstructured)
+	if !ok {
+		return errors.New("could not convert")
+	}
+	return runtime.DefaultUnstructuredConverter.FromUnstructured(u.Object, out)
+}
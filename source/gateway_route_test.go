@@ -0,0 +1,325 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package source
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	fakeDynamic "k8s.io/client-go/dynamic/fake"
+
+	"sigs.k8s.io/external-dns/endpoint"
+)
+
+// Validates that gatewayRouteSource is a Source
+var _ Source = &gatewayRouteSource{}
+
+func newGatewayAPIClient(objects ...runtime.Object) *fakeDynamic.FakeDynamicClient {
+	s := runtime.NewScheme()
+	for _, gvk := range []schema.GroupVersionKind{
+		{Group: gatewayAPIGroup, Version: GatewayGVR.Version, Kind: "Gateway"},
+		{Group: gatewayAPIGroup, Version: HTTPRouteGVR.Version, Kind: "HTTPRoute"},
+		{Group: gatewayAPIGroup, Version: TLSRouteGVR.Version, Kind: "TLSRoute"},
+	} {
+		s.AddKnownTypeWithName(gvk, &unstructured.Unstructured{})
+		gvk.Kind += "List"
+		s.AddKnownTypeWithName(gvk, &unstructured.UnstructuredList{})
+	}
+	client := fakeDynamic.NewSimpleDynamicClient(s)
+
+	// the fake client would guess the resource "gatewaies" from the kind, so objects are created explicitly
+	resources := map[string]schema.GroupVersionResource{"Gateway": GatewayGVR, "HTTPRoute": HTTPRouteGVR, "TLSRoute": TLSRouteGVR}
+	for _, object := range objects {
+		u := object.(*unstructured.Unstructured)
+		if _, err := client.Resource(resources[u.GetKind()]).Namespace(u.GetNamespace()).Create(context.Background(), u, metav1.CreateOptions{}); err != nil {
+			panic(err)
+		}
+	}
+	return client
+}
+
+type fakeGatewayListener struct {
+	name, hostname, protocol string
+}
+
+func newFakeGateway(namespace, name string, annotations map[string]string, addresses []string, listeners ...fakeGatewayListener) *unstructured.Unstructured {
+	specListeners := []interface{}{}
+	for _, l := range listeners {
+		listener := map[string]interface{}{"name": l.name, "protocol": l.protocol, "port": int64(443)}
+		if l.hostname != "" {
+			listener["hostname"] = l.hostname
+		}
+		specListeners = append(specListeners, listener)
+	}
+	statusAddresses := []interface{}{}
+	for _, address := range addresses {
+		statusAddresses = append(statusAddresses, map[string]interface{}{"type": "IPAddress", "value": address})
+	}
+	gw := &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"apiVersion": gatewayAPIGroup + "/" + GatewayGVR.Version,
+			"kind":       "Gateway",
+			"spec":       map[string]interface{}{"gatewayClassName": "example", "listeners": specListeners},
+			"status":     map[string]interface{}{"addresses": statusAddresses},
+		},
+	}
+	gw.SetNamespace(namespace)
+	gw.SetName(name)
+	gw.SetAnnotations(annotations)
+	return gw
+}
+
+type fakeParentRef struct {
+	namespace, name, sectionName string
+	// accepted is the status of the Accepted condition reported by the parent, no status if empty
+	accepted string
+}
+
+func newFakeRoute(gvr schema.GroupVersionResource, kind, namespace, name string, annotations map[string]string, hostnames []string, parents ...fakeParentRef) *unstructured.Unstructured {
+	parentRefs := []interface{}{}
+	statusParents := []interface{}{}
+	for _, p := range parents {
+		ref := map[string]interface{}{"name": p.name}
+		if p.namespace != "" {
+			ref["namespace"] = p.namespace
+		}
+		if p.sectionName != "" {
+			ref["sectionName"] = p.sectionName
+		}
+		parentRefs = append(parentRefs, ref)
+		if p.accepted != "" {
+			statusParents = append(statusParents, map[string]interface{}{
+				"parentRef":      ref,
+				"controllerName": "example.com/gateway-controller",
+				"conditions":     []interface{}{map[string]interface{}{"type": "Accepted", "status": p.accepted}},
+			})
+		}
+	}
+	specHostnames := []interface{}{}
+	for _, hostname := range hostnames {
+		specHostnames = append(specHostnames, hostname)
+	}
+	route := &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"apiVersion": gatewayAPIGroup + "/" + gvr.Version,
+			"kind":       kind,
+			"spec":       map[string]interface{}{"parentRefs": parentRefs, "hostnames": specHostnames},
+			"status":     map[string]interface{}{"parents": statusParents},
+		},
+	}
+	route.SetNamespace(namespace)
+	route.SetName(name)
+	route.SetAnnotations(annotations)
+	return route
+}
+
+func newFakeHTTPRoute(namespace, name string, annotations map[string]string, hostnames []string, parents ...fakeParentRef) *unstructured.Unstructured {
+	return newFakeRoute(HTTPRouteGVR, "HTTPRoute", namespace, name, annotations, hostnames, parents...)
+}
+
+func TestGatewayHostnameMatch(t *testing.T) {
+	for _, ti := range []struct {
+		route, listener, expected string
+	}{
+		{"foo.example.org", "", "foo.example.org"},
+		{"", "foo.example.org", "foo.example.org"},
+		{"", "", ""},
+		{"foo.example.org", "foo.example.org", "foo.example.org"},
+		{"foo.example.org", "bar.example.org", ""},
+		{"foo.example.org", "*.example.org", "foo.example.org"},
+		{"a.b.example.org", "*.example.org", "a.b.example.org"},
+		{"example.org", "*.example.org", ""},
+		{"*.example.org", "foo.example.org", "foo.example.org"},
+		{"*.example.org", "*.example.org", "*.example.org"},
+		{"*.example.org", "*.example.com", ""},
+	} {
+		assert.Equal(t, ti.expected, gatewayHostnameMatch(ti.route, ti.listener), "route %q, listener %q", ti.route, ti.listener)
+	}
+}
+
+func TestGatewayHTTPRouteSourceEndpoints(t *testing.T) {
+	gateways := []runtime.Object{
+		newFakeGateway("gateways", "internet", nil, []string{"1.2.3.4"},
+			fakeGatewayListener{name: "https", hostname: "*.example.org", protocol: "HTTPS"},
+			fakeGatewayListener{name: "tls", hostname: "tls.example.org", protocol: "TLS"},
+		),
+		newFakeGateway("default", "internal", nil, []string{"10.0.0.1", "lb.internal.example.org"},
+			fakeGatewayListener{name: "http", protocol: "HTTP"},
+		),
+		newFakeGateway("default", "annotated", map[string]string{targetAnnotationKey: "gateway.example.org"}, []string{"10.0.0.2"},
+			fakeGatewayListener{name: "http", hostname: "annotated.example.org", protocol: "HTTP"},
+		),
+	}
+
+	for _, ti := range []struct {
+		title                    string
+		routes                   []runtime.Object
+		annotationFilter         string
+		ignoreHostnameAnnotation bool
+		expected                 []*endpoint.Endpoint
+	}{
+		{
+			title: "hostnames restricted to the listeners of the parent gateway",
+			routes: []runtime.Object{
+				newFakeHTTPRoute("default", "foo", nil, []string{"foo.example.org", "foo.example.com"},
+					fakeParentRef{namespace: "gateways", name: "internet", accepted: "True"}),
+			},
+			expected: []*endpoint.Endpoint{
+				{DNSName: "foo.example.org", RecordType: endpoint.RecordTypeA, Targets: endpoint.Targets{"1.2.3.4"}},
+			},
+		},
+		{
+			title: "listener hostnames are used if the route has none",
+			routes: []runtime.Object{
+				newFakeHTTPRoute("default", "foo", nil, nil,
+					fakeParentRef{name: "annotated", accepted: "True"}),
+			},
+			expected: []*endpoint.Endpoint{
+				{DNSName: "annotated.example.org", RecordType: endpoint.RecordTypeCNAME, Targets: endpoint.Targets{"gateway.example.org"}},
+			},
+		},
+		{
+			title: "targets of multiple parents are merged",
+			routes: []runtime.Object{
+				newFakeHTTPRoute("default", "foo", nil, []string{"foo.example.org"},
+					fakeParentRef{namespace: "gateways", name: "internet", accepted: "True"},
+					fakeParentRef{name: "internal", accepted: "True"}),
+			},
+			expected: []*endpoint.Endpoint{
+				{DNSName: "foo.example.org", RecordType: endpoint.RecordTypeA, Targets: endpoint.Targets{"1.2.3.4", "10.0.0.1"}},
+				{DNSName: "foo.example.org", RecordType: endpoint.RecordTypeCNAME, Targets: endpoint.Targets{"lb.internal.example.org"}},
+			},
+		},
+		{
+			title: "parents which did not accept the route are ignored",
+			routes: []runtime.Object{
+				newFakeHTTPRoute("default", "foo", nil, []string{"foo.example.org"},
+					fakeParentRef{namespace: "gateways", name: "internet", accepted: "False"},
+					fakeParentRef{name: "internal"}),
+			},
+			expected: []*endpoint.Endpoint{},
+		},
+		{
+			// the wildcard of the https listener would match, but the TLS listener does not serve HTTPRoutes
+			title: "section name restricts the listeners",
+			routes: []runtime.Object{
+				newFakeHTTPRoute("default", "foo", nil, []string{"tls.example.org"},
+					fakeParentRef{namespace: "gateways", name: "internet", sectionName: "tls", accepted: "True"}),
+			},
+			expected: []*endpoint.Endpoint{},
+		},
+		{
+			title: "annotations of the route",
+			routes: []runtime.Object{
+				newFakeHTTPRoute("default", "foo", map[string]string{
+					hostnameAnnotationKey: "extra.example.com",
+					targetAnnotationKey:   "route.example.org",
+					ttlAnnotationKey:      "60",
+				}, []string{"foo.example.org"},
+					fakeParentRef{namespace: "gateways", name: "internet", accepted: "True"}),
+			},
+			expected: []*endpoint.Endpoint{
+				{DNSName: "foo.example.org", RecordType: endpoint.RecordTypeCNAME, Targets: endpoint.Targets{"route.example.org"}, RecordTTL: 60},
+				{DNSName: "extra.example.com", RecordType: endpoint.RecordTypeCNAME, Targets: endpoint.Targets{"route.example.org"}, RecordTTL: 60},
+			},
+		},
+		{
+			title:                    "hostname annotation is ignored",
+			ignoreHostnameAnnotation: true,
+			routes: []runtime.Object{
+				newFakeHTTPRoute("default", "foo", map[string]string{hostnameAnnotationKey: "extra.example.com"}, []string{"foo.example.org"},
+					fakeParentRef{namespace: "gateways", name: "internet", accepted: "True"}),
+			},
+			expected: []*endpoint.Endpoint{
+				{DNSName: "foo.example.org", RecordType: endpoint.RecordTypeA, Targets: endpoint.Targets{"1.2.3.4"}},
+			},
+		},
+		{
+			title:            "annotation and controller filters",
+			annotationFilter: "team=dns",
+			routes: []runtime.Object{
+				newFakeHTTPRoute("default", "match", map[string]string{"team": "dns"}, []string{"match.example.org"},
+					fakeParentRef{namespace: "gateways", name: "internet", accepted: "True"}),
+				newFakeHTTPRoute("default", "other-team", map[string]string{"team": "web"}, []string{"web.example.org"},
+					fakeParentRef{namespace: "gateways", name: "internet", accepted: "True"}),
+				newFakeHTTPRoute("default", "other-controller", map[string]string{"team": "dns", controllerAnnotationKey: "other"}, []string{"other.example.org"},
+					fakeParentRef{namespace: "gateways", name: "internet", accepted: "True"}),
+			},
+			expected: []*endpoint.Endpoint{
+				{DNSName: "match.example.org", RecordType: endpoint.RecordTypeA, Targets: endpoint.Targets{"1.2.3.4"}},
+			},
+		},
+	} {
+		t.Run(ti.title, func(t *testing.T) {
+			source, err := NewGatewayHTTPRouteSource(newGatewayAPIClient(append(ti.routes, gateways...)...), "", ti.annotationFilter, ti.ignoreHostnameAnnotation)
+			require.NoError(t, err)
+
+			endpoints, err := source.Endpoints(context.Background())
+			require.NoError(t, err)
+			validateEndpoints(t, endpoints, ti.expected)
+			for _, ep := range endpoints {
+				assert.Equal(t, "httproute/default/"+ti.routes[0].(*unstructured.Unstructured).GetName(), ep.Labels[endpoint.ResourceLabelKey])
+			}
+		})
+	}
+}
+
+func TestGatewayTLSRouteSourceEndpoints(t *testing.T) {
+	client := newGatewayAPIClient(
+		newFakeGateway("default", "gateway", nil, []string{"1.2.3.4"},
+			fakeGatewayListener{name: "https", hostname: "https.example.org", protocol: "HTTPS"},
+			fakeGatewayListener{name: "tls", hostname: "*.example.org", protocol: "TLS"},
+		),
+		newFakeRoute(TLSRouteGVR, "TLSRoute", "default", "foo", nil, []string{"foo.example.org", "https.example.org"},
+			fakeParentRef{name: "gateway", accepted: "True"}),
+	)
+
+	source, err := NewGatewayTLSRouteSource(client, "default", "", false)
+	require.NoError(t, err)
+
+	endpoints, err := source.Endpoints(context.Background())
+	require.NoError(t, err)
+	validateEndpoints(t, endpoints, []*endpoint.Endpoint{
+		{DNSName: "foo.example.org", RecordType: endpoint.RecordTypeA, Targets: endpoint.Targets{"1.2.3.4"}},
+		{DNSName: "https.example.org", RecordType: endpoint.RecordTypeA, Targets: endpoint.Targets{"1.2.3.4"}},
+	})
+}
+
+func TestGatewayRouteSourceEventHandler(t *testing.T) {
+	client := newGatewayAPIClient()
+	source, err := NewGatewayHTTPRouteSource(client, "", "", false)
+	require.NoError(t, err)
+
+	events := make(chan struct{}, 10)
+	source.AddEventHandler(context.Background(), func() { events <- struct{}{} })
+
+	_, err = client.Resource(GatewayGVR).Namespace("default").Create(context.Background(),
+		newFakeGateway("default", "gateway", nil, []string{"1.2.3.4"}), metav1.CreateOptions{})
+	require.NoError(t, err)
+	expectEvent(t, events, true)
+
+	_, err = client.Resource(HTTPRouteGVR).Namespace("default").Create(context.Background(),
+		newFakeHTTPRoute("default", "foo", nil, []string{"foo.example.org"}, fakeParentRef{name: "gateway", accepted: "True"}), metav1.CreateOptions{})
+	require.NoError(t, err)
+	expectEvent(t, events, true)
+}
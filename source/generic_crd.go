@@ -0,0 +1,431 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package source
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v2"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	"k8s.io/client-go/informers"
+	coreinformers "k8s.io/client-go/informers/core/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/jsonpath"
+
+	"sigs.k8s.io/external-dns/endpoint"
+)
+
+// GenericCRDRule describes how endpoints are derived from the objects of a custom resource.
+// Paths are JSONPath expressions evaluated against the whole object, e.g. `.spec.hosts[*]`.
+type GenericCRDRule struct {
+	Group    string `yaml:"group"`
+	Version  string `yaml:"version"`
+	Resource string `yaml:"resource"`
+	// HostnamesPath selects the hostnames to publish
+	HostnamesPath string `yaml:"hostnamesPath"`
+	// TargetsPath selects the targets of the hostnames, mutually exclusive with ServiceNamePath
+	TargetsPath string `yaml:"targetsPath,omitempty"`
+	// ServiceNamePath selects the name of a Service whose load balancer addresses are used as targets
+	ServiceNamePath string `yaml:"serviceNamePath,omitempty"`
+	// ServiceNamespacePath selects the namespace of that Service, defaulting to the namespace of the object
+	ServiceNamespacePath string `yaml:"serviceNamespacePath,omitempty"`
+	// TTLPath selects the TTL in seconds, defaulting to the TTL annotation of the object
+	TTLPath string `yaml:"ttlPath,omitempty"`
+	// RecordTypePath selects the record type, which is derived from the targets if not set
+	RecordTypePath string `yaml:"recordTypePath,omitempty"`
+}
+
+// LoadGenericCRDRules reads the rules of the generic-crd source from a YAML file holding a list of rules
+func LoadGenericCRDRules(path string) ([]GenericCRDRule, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read generic-crd rules")
+	}
+	rules := []GenericCRDRule{}
+	if err := yaml.UnmarshalStrict(data, &rules); err != nil {
+		return nil, errors.Wrapf(err, "failed to parse generic-crd rules from %s", path)
+	}
+	return rules, nil
+}
+
+// genericCRDRule is a GenericCRDRule with compiled paths and the informer of its resource
+type genericCRDRule struct {
+	gvr                  schema.GroupVersionResource
+	hostnamesPath        *jsonpath.JSONPath
+	targetsPath          *jsonpath.JSONPath
+	serviceNamePath      *jsonpath.JSONPath
+	serviceNamespacePath *jsonpath.JSONPath
+	ttlPath              *jsonpath.JSONPath
+	recordTypePath       *jsonpath.JSONPath
+	informer             informers.GenericInformer
+}
+
+// genericCRDSource is an implementation of Source for arbitrary custom resources.
+// Hostnames and targets are read from the objects using the JSONPath expressions of the configured rules.
+// Use targetAnnotationKey to explicitly set Endpoint.
+type genericCRDSource struct {
+	namespace        string
+	annotationFilter string
+	rules            []*genericCRDRule
+	// serviceInformer is only set if a rule references Services
+	serviceInformer coreinformers.ServiceInformer
+}
+
+// NewGenericCRDSource creates a new genericCRDSource with the given rules.
+func NewGenericCRDSource(
+	dynamicKubeClient dynamic.Interface,
+	kubeClient kubernetes.Interface,
+	namespace string,
+	annotationFilter string,
+	rules []GenericCRDRule,
+) (Source, error) {
+	if len(rules) == 0 {
+		return nil, errors.New("generic-crd source requires at least one rule")
+	}
+
+	// Use shared informer to listen for add/update/delete of the objects in the specified namespace.
+	// Set resync period to 0, to prevent processing when nothing has changed.
+	informerFactory := dynamicinformer.NewFilteredDynamicSharedInformerFactory(dynamicKubeClient, 0, namespace, nil)
+
+	sc := &genericCRDSource{
+		namespace:        namespace,
+		annotationFilter: annotationFilter,
+	}
+	for _, rule := range rules {
+		compiled, err := compileGenericCRDRule(rule)
+		if err != nil {
+			return nil, err
+		}
+		compiled.informer = informerFactory.ForResource(compiled.gvr)
+
+		// Add default resource event handlers to properly initialize informer.
+		compiled.informer.Informer().AddEventHandler(
+			cache.ResourceEventHandlerFuncs{
+				AddFunc: func(obj interface{}) {
+				},
+			},
+		)
+		sc.rules = append(sc.rules, compiled)
+	}
+
+	// the Services referenced by the objects are read from a shared informer as well
+	var kubeInformerFactory informers.SharedInformerFactory
+	for _, rule := range sc.rules {
+		if rule.serviceNamePath != nil {
+			kubeInformerFactory = informers.NewSharedInformerFactoryWithOptions(kubeClient, 0, informers.WithNamespace(namespace))
+			sc.serviceInformer = kubeInformerFactory.Core().V1().Services()
+
+			// Add default resource event handlers to properly initialize informer.
+			sc.serviceInformer.Informer().AddEventHandler(
+				cache.ResourceEventHandlerFuncs{
+					AddFunc: func(obj interface{}) {
+						log.Debug("service added")
+					},
+				},
+			)
+			break
+		}
+	}
+
+	// TODO informer is not explicitly stopped since controller is not passing in its channel.
+	informerFactory.Start(wait.NeverStop)
+	if kubeInformerFactory != nil {
+		kubeInformerFactory.Start(wait.NeverStop)
+	}
+
+	// wait for the local cache to be populated.
+	err := poll(time.Second, 60*time.Second, func() (bool, error) {
+		for _, rule := range sc.rules {
+			if !rule.informer.Informer().HasSynced() {
+				return false, nil
+			}
+		}
+		return sc.serviceInformer == nil || sc.serviceInformer.Informer().HasSynced(), nil
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to sync cache")
+	}
+
+	return sc, nil
+}
+
+func compileGenericCRDRule(rule GenericCRDRule) (*genericCRDRule, error) {
+	if rule.Version == "" || rule.Resource == "" {
+		return nil, errors.Errorf("generic-crd rule %q requires a version and a resource", rule.Resource)
+	}
+	if rule.HostnamesPath == "" {
+		return nil, errors.Errorf("generic-crd rule for %s requires a hostnamesPath", rule.Resource)
+	}
+	if rule.TargetsPath != "" && rule.ServiceNamePath != "" {
+		return nil, errors.Errorf("generic-crd rule for %s can either use targetsPath or serviceNamePath", rule.Resource)
+	}
+
+	compiled := &genericCRDRule{
+		gvr: schema.GroupVersionResource{Group: rule.Group, Version: rule.Version, Resource: rule.Resource},
+	}
+	for _, path := range []struct {
+		expression string
+		jsonPath   **jsonpath.JSONPath
+	}{
+		{rule.HostnamesPath, &compiled.hostnamesPath},
+		{rule.TargetsPath, &compiled.targetsPath},
+		{rule.ServiceNamePath, &compiled.serviceNamePath},
+		{rule.ServiceNamespacePath, &compiled.serviceNamespacePath},
+		{rule.TTLPath, &compiled.ttlPath},
+		{rule.RecordTypePath, &compiled.recordTypePath},
+	} {
+		if path.expression == "" {
+			continue
+		}
+		expression := path.expression
+		if !strings.HasPrefix(expression, "{") {
+			expression = "{" + expression + "}"
+		}
+		jsonPath := jsonpath.New(rule.Resource).AllowMissingKeys(true)
+		if err := jsonPath.Parse(expression); err != nil {
+			return nil, errors.Wrapf(err, "invalid JSONPath %q in generic-crd rule for %s", path.expression, rule.Resource)
+		}
+		*path.jsonPath = jsonPath
+	}
+	return compiled, nil
+}
+
+// Endpoints returns endpoint objects for each host-target combination that should be processed.
+// Retrieves the objects of all configured resources in the source's namespace(s).
+func (sc *genericCRDSource) Endpoints(ctx context.Context) ([]*endpoint.Endpoint, error) {
+	selector, err := getLabelSelector(sc.annotationFilter)
+	if err != nil {
+		return nil, err
+	}
+
+	endpoints := []*endpoint.Endpoint{}
+
+	for _, rule := range sc.rules {
+		objects, err := rule.informer.Lister().ByNamespace(sc.namespace).List(labels.Everything())
+		if err != nil {
+			return nil, err
+		}
+
+		for _, object := range objects {
+			obj, ok := object.(*unstructured.Unstructured)
+			if !ok {
+				return nil, errors.New("could not convert")
+			}
+			annotations := obj.GetAnnotations()
+
+			// include object if its annotations match the selector
+			if !selector.Empty() && !matchLabelSelector(selector, annotations) {
+				continue
+			}
+			// Check controller annotation to see if we are responsible.
+			controller, ok := annotations[controllerAnnotationKey]
+			if ok && controller != controllerAnnotationValue {
+				log.Debugf("Skipping %s %s/%s because controller value does not match, found: %s, required: %s",
+					rule.gvr.Resource, obj.GetNamespace(), obj.GetName(), controller, controllerAnnotationValue)
+				continue
+			}
+
+			objEndpoints, err := sc.endpointsFromObject(rule, obj)
+			if err != nil {
+				return nil, err
+			}
+			if len(objEndpoints) == 0 {
+				log.Debugf("No endpoints could be generated from %s %s/%s", rule.gvr.Resource, obj.GetNamespace(), obj.GetName())
+				continue
+			}
+
+			log.Debugf("Endpoints generated from %s %s/%s: %v", rule.gvr.Resource, obj.GetNamespace(), obj.GetName(), objEndpoints)
+			for _, ep := range objEndpoints {
+				ep.Labels[endpoint.ResourceLabelKey] = fmt.Sprintf("%s/%s/%s", rule.gvr.GroupResource(), obj.GetNamespace(), obj.GetName())
+			}
+			setMergeLabel(obj.GetAnnotations(), objEndpoints)
+			endpoints = append(endpoints, objEndpoints...)
+		}
+	}
+
+	for _, ep := range endpoints {
+		sort.Sort(ep.Targets)
+	}
+
+	return endpoints, nil
+}
+
+// endpointsFromObject extracts the endpoints of a single object according to its rule
+func (sc *genericCRDSource) endpointsFromObject(rule *genericCRDRule, obj *unstructured.Unstructured) ([]*endpoint.Endpoint, error) {
+	hostnames, err := evalJSONPath(rule.hostnamesPath, obj)
+	if err != nil {
+		return nil, err
+	}
+	if len(hostnames) == 0 {
+		return nil, nil
+	}
+
+	targets := getTargetsFromTargetAnnotation(obj.GetAnnotations())
+	if len(targets) == 0 {
+		targets, err = sc.targetsFromObject(rule, obj)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	ttl, err := getTTLFromAnnotations(obj.GetAnnotations())
+	if err != nil {
+		log.Warn(err)
+	}
+	if values, err := evalJSONPath(rule.ttlPath, obj); err != nil {
+		return nil, err
+	} else if len(values) > 0 {
+		seconds, err := strconv.ParseInt(values[0], 10, 64)
+		if err != nil || seconds < 0 {
+			log.Warnf("Ignoring invalid TTL %q of %s %s/%s", values[0], rule.gvr.Resource, obj.GetNamespace(), obj.GetName())
+		} else {
+			ttl = endpoint.TTL(seconds)
+		}
+	}
+
+	recordType := ""
+	if values, err := evalJSONPath(rule.recordTypePath, obj); err != nil {
+		return nil, err
+	} else if len(values) > 0 {
+		recordType = strings.ToUpper(values[0])
+	}
+
+	providerSpecific, setIdentifier := getProviderSpecificAnnotations(obj.GetAnnotations())
+
+	var endpoints []*endpoint.Endpoint
+	for _, hostname := range hostnames {
+		hostname = strings.TrimSuffix(hostname, ".")
+		if recordType == "" {
+			endpoints = append(endpoints, endpointsForHostname(hostname, targets, ttl, providerSpecific, setIdentifier)...)
+			continue
+		}
+		if len(targets) == 0 {
+			continue
+		}
+		ep := endpoint.NewEndpointWithTTL(hostname, recordType, ttl, targets...)
+		ep.ProviderSpecific = providerSpecific
+		ep.SetIdentifier = setIdentifier
+		endpoints = append(endpoints, ep)
+	}
+	return endpoints, nil
+}
+
+// targetsFromObject reads the targets of an object, either directly or from the Service it references
+func (sc *genericCRDSource) targetsFromObject(rule *genericCRDRule, obj *unstructured.Unstructured) (endpoint.Targets, error) {
+	if rule.serviceNamePath == nil {
+		return evalJSONPath(rule.targetsPath, obj)
+	}
+
+	names, err := evalJSONPath(rule.serviceNamePath, obj)
+	if err != nil || len(names) == 0 {
+		return nil, err
+	}
+	namespace := obj.GetNamespace()
+	if namespaces, err := evalJSONPath(rule.serviceNamespacePath, obj); err != nil {
+		return nil, err
+	} else if len(namespaces) > 0 {
+		namespace = namespaces[0]
+	}
+
+	svc, err := sc.serviceInformer.Lister().Services(namespace).Get(names[0])
+	if err != nil {
+		log.Warnf("Failed to get Service %s/%s referenced by %s %s/%s: %v", namespace, names[0], rule.gvr.Resource, obj.GetNamespace(), obj.GetName(), err)
+		return nil, nil
+	}
+	return extractLoadBalancerTargets(svc), nil
+}
+
+// evalJSONPath returns the non-empty values selected by jsonPath, flattening lists
+func evalJSONPath(jsonPath *jsonpath.JSONPath, obj *unstructured.Unstructured) ([]string, error) {
+	if jsonPath == nil {
+		return nil, nil
+	}
+	results, err := jsonPath.FindResults(obj.Object)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to evaluate JSONPath on %s/%s", obj.GetNamespace(), obj.GetName())
+	}
+
+	var values []string
+	var appendValue func(value interface{})
+	appendValue = func(value interface{}) {
+		switch v := value.(type) {
+		case nil:
+		case []interface{}:
+			for _, item := range v {
+				appendValue(item)
+			}
+		case string:
+			if v != "" {
+				values = append(values, v)
+			}
+		default:
+			values = append(values, fmt.Sprint(v))
+		}
+	}
+	for _, result := range results {
+		for _, value := range result {
+			if value.IsValid() && value.CanInterface() {
+				appendValue(value.Interface())
+			}
+		}
+	}
+	return values, nil
+}
+
+func (sc *genericCRDSource) AddEventHandler(ctx context.Context, handler func()) {
+	log.Debug("Adding event handler for generic-crd")
+
+	// Right now there is no way to remove event handler from informer, see:
+	// https://github.com/kubernetes/kubernetes/issues/79610
+	sharedInformers := []cache.SharedIndexInformer{}
+	for _, rule := range sc.rules {
+		sharedInformers = append(sharedInformers, rule.informer.Informer())
+	}
+	// the targets of the objects referencing a Service change with its load balancer addresses
+	if sc.serviceInformer != nil {
+		sharedInformers = append(sharedInformers, sc.serviceInformer.Informer())
+	}
+	for _, informer := range sharedInformers {
+		informer.AddEventHandler(
+			cache.ResourceEventHandlerFuncs{
+				AddFunc: func(obj interface{}) {
+					handler()
+				},
+				UpdateFunc: func(old interface{}, new interface{}) {
+					handler()
+				},
+				DeleteFunc: func(obj interface{}) {
+					handler()
+				},
+			},
+		)
+	}
+}
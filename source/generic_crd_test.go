@@ -0,0 +1,258 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package source
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	fakeDynamic "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"sigs.k8s.io/external-dns/endpoint"
+)
+
+// Validates that genericCRDSource is a Source
+var _ Source = &genericCRDSource{}
+
+var widgetGVR = schema.GroupVersionResource{Group: "example.com", Version: "v1", Resource: "widgets"}
+
+func newWidget(namespace, name string, annotations map[string]string, spec map[string]interface{}) *unstructured.Unstructured {
+	widget := &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"apiVersion": "example.com/v1",
+			"kind":       "Widget",
+			"spec":       spec,
+		},
+	}
+	widget.SetNamespace(namespace)
+	widget.SetName(name)
+	widget.SetAnnotations(annotations)
+	return widget
+}
+
+func newWidgetClient(objects ...runtime.Object) *fakeDynamic.FakeDynamicClient {
+	s := runtime.NewScheme()
+	s.AddKnownTypeWithName(schema.GroupVersionKind{Group: "example.com", Version: "v1", Kind: "Widget"}, &unstructured.Unstructured{})
+	s.AddKnownTypeWithName(schema.GroupVersionKind{Group: "example.com", Version: "v1", Kind: "WidgetList"}, &unstructured.UnstructuredList{})
+	return fakeDynamic.NewSimpleDynamicClient(s, objects...)
+}
+
+func TestLoadGenericCRDRules(t *testing.T) {
+	dir, err := ioutil.TempDir("", "generic-crd")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "rules.yaml")
+	require.NoError(t, ioutil.WriteFile(path, []byte(`
+- group: example.com
+  version: v1
+  resource: widgets
+  hostnamesPath: .spec.hosts[*]
+  serviceNamePath: .spec.service.name
+  ttlPath: .spec.ttl
+`), 0600))
+
+	rules, err := LoadGenericCRDRules(path)
+	require.NoError(t, err)
+	assert.Equal(t, []GenericCRDRule{{
+		Group:           "example.com",
+		Version:         "v1",
+		Resource:        "widgets",
+		HostnamesPath:   ".spec.hosts[*]",
+		ServiceNamePath: ".spec.service.name",
+		TTLPath:         ".spec.ttl",
+	}}, rules)
+
+	require.NoError(t, ioutil.WriteFile(path, []byte("- resource: widgets\n  hostnamePath: .spec.host\n"), 0600))
+	_, err = LoadGenericCRDRules(path)
+	assert.Error(t, err, "unknown fields are rejected")
+
+	_, err = LoadGenericCRDRules(filepath.Join(dir, "missing.yaml"))
+	assert.Error(t, err)
+}
+
+func TestNewGenericCRDSourceInvalidRules(t *testing.T) {
+	for _, ti := range []struct {
+		title string
+		rules []GenericCRDRule
+	}{
+		{title: "no rules"},
+		{title: "missing resource", rules: []GenericCRDRule{{Version: "v1", HostnamesPath: ".spec.host"}}},
+		{title: "missing hostnames path", rules: []GenericCRDRule{{Version: "v1", Resource: "widgets"}}},
+		{title: "targets and service", rules: []GenericCRDRule{{Version: "v1", Resource: "widgets", HostnamesPath: ".spec.host", TargetsPath: ".spec.ip", ServiceNamePath: ".spec.service"}}},
+		{title: "invalid JSONPath", rules: []GenericCRDRule{{Version: "v1", Resource: "widgets", HostnamesPath: ".spec.hosts[*"}}},
+	} {
+		t.Run(ti.title, func(t *testing.T) {
+			_, err := NewGenericCRDSource(newWidgetClient(), fake.NewSimpleClientset(), "", "", ti.rules)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestGenericCRDSourceEndpoints(t *testing.T) {
+	kubeClient := fake.NewSimpleClientset(&v1.Service{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "ingress", Name: "gateway"},
+		Status: v1.ServiceStatus{
+			LoadBalancer: v1.LoadBalancerStatus{
+				Ingress: []v1.LoadBalancerIngress{{Hostname: "lb.example.com"}},
+			},
+		},
+	})
+
+	for _, ti := range []struct {
+		title            string
+		rule             GenericCRDRule
+		annotationFilter string
+		widgets          []runtime.Object
+		expected         []*endpoint.Endpoint
+	}{
+		{
+			title: "hostnames and targets from paths",
+			rule:  GenericCRDRule{HostnamesPath: ".spec.hosts[*]", TargetsPath: ".status.addresses"},
+			widgets: []runtime.Object{
+				func() runtime.Object {
+					w := newWidget("default", "foo", nil, map[string]interface{}{
+						"hosts": []interface{}{"foo.example.org", "bar.example.org."},
+					})
+					w.Object["status"] = map[string]interface{}{"addresses": []interface{}{"1.2.3.4", "5.6.7.8"}}
+					return w
+				}(),
+			},
+			expected: []*endpoint.Endpoint{
+				{DNSName: "foo.example.org", RecordType: endpoint.RecordTypeA, Targets: endpoint.Targets{"1.2.3.4", "5.6.7.8"}},
+				{DNSName: "bar.example.org", RecordType: endpoint.RecordTypeA, Targets: endpoint.Targets{"1.2.3.4", "5.6.7.8"}},
+			},
+		},
+		{
+			title: "targets from referenced service with TTL and record type",
+			rule: GenericCRDRule{
+				HostnamesPath:        ".spec.host",
+				ServiceNamePath:      ".spec.service.name",
+				ServiceNamespacePath: ".spec.service.namespace",
+				TTLPath:              ".spec.ttl",
+				RecordTypePath:       ".spec.type",
+			},
+			widgets: []runtime.Object{
+				newWidget("default", "foo", nil, map[string]interface{}{
+					"host":    "foo.example.org",
+					"service": map[string]interface{}{"name": "gateway", "namespace": "ingress"},
+					"ttl":     int64(60),
+					"type":    "cname",
+				}),
+			},
+			expected: []*endpoint.Endpoint{
+				{DNSName: "foo.example.org", RecordType: endpoint.RecordTypeCNAME, Targets: endpoint.Targets{"lb.example.com"}, RecordTTL: 60},
+			},
+		},
+		{
+			title: "target annotation overrides targets",
+			rule:  GenericCRDRule{HostnamesPath: ".spec.host", TargetsPath: ".spec.ip"},
+			widgets: []runtime.Object{
+				newWidget("default", "foo", map[string]string{targetAnnotationKey: "override.example.com"}, map[string]interface{}{
+					"host": "foo.example.org",
+					"ip":   "1.2.3.4",
+				}),
+			},
+			expected: []*endpoint.Endpoint{
+				{DNSName: "foo.example.org", RecordType: endpoint.RecordTypeCNAME, Targets: endpoint.Targets{"override.example.com"}},
+			},
+		},
+		{
+			title:            "annotation and controller filters",
+			rule:             GenericCRDRule{HostnamesPath: ".spec.host", TargetsPath: ".spec.ip"},
+			annotationFilter: "team=dns",
+			widgets: []runtime.Object{
+				newWidget("default", "match", map[string]string{"team": "dns"}, map[string]interface{}{"host": "match.example.org", "ip": "1.2.3.4"}),
+				newWidget("default", "other-team", map[string]string{"team": "web"}, map[string]interface{}{"host": "web.example.org", "ip": "1.2.3.4"}),
+				newWidget("default", "other-controller", map[string]string{"team": "dns", controllerAnnotationKey: "other"}, map[string]interface{}{"host": "other.example.org", "ip": "1.2.3.4"}),
+			},
+			expected: []*endpoint.Endpoint{
+				{DNSName: "match.example.org", RecordType: endpoint.RecordTypeA, Targets: endpoint.Targets{"1.2.3.4"}},
+			},
+		},
+		{
+			title: "objects without hostnames are skipped",
+			rule:  GenericCRDRule{HostnamesPath: ".spec.host", TargetsPath: ".spec.ip"},
+			widgets: []runtime.Object{
+				newWidget("default", "foo", nil, map[string]interface{}{"ip": "1.2.3.4"}),
+			},
+			expected: []*endpoint.Endpoint{},
+		},
+	} {
+		t.Run(ti.title, func(t *testing.T) {
+			rule := ti.rule
+			rule.Group, rule.Version, rule.Resource = widgetGVR.Group, widgetGVR.Version, widgetGVR.Resource
+
+			source, err := NewGenericCRDSource(newWidgetClient(ti.widgets...), kubeClient, "", ti.annotationFilter, []GenericCRDRule{rule})
+			require.NoError(t, err)
+
+			endpoints, err := source.Endpoints(context.Background())
+			require.NoError(t, err)
+			validateEndpoints(t, endpoints, ti.expected)
+			for _, ep := range endpoints {
+				assert.True(t, strings.HasPrefix(ep.Labels[endpoint.ResourceLabelKey], "widgets.example.com/default/"), "should set resource label")
+			}
+			for _, action := range kubeClient.Actions() {
+				assert.False(t, action.Matches("get", "services"), "should read services from the informer")
+			}
+		})
+	}
+}
+
+// TestGenericCRDSourceServiceChanges tests that a change of a referenced Service triggers the event handler.
+func TestGenericCRDSourceServiceChanges(t *testing.T) {
+	service := &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "gateway"},
+		Status: v1.ServiceStatus{
+			LoadBalancer: v1.LoadBalancerStatus{Ingress: []v1.LoadBalancerIngress{{IP: "1.2.3.4"}}},
+		},
+	}
+	kubeClient := fake.NewSimpleClientset(service)
+	widget := newWidget("default", "foo", nil, map[string]interface{}{"host": "foo.example.org", "service": "gateway"})
+	rule := GenericCRDRule{Group: widgetGVR.Group, Version: widgetGVR.Version, Resource: widgetGVR.Resource, HostnamesPath: ".spec.host", ServiceNamePath: ".spec.service"}
+
+	source, err := NewGenericCRDSource(newWidgetClient(widget), kubeClient, "", "", []GenericCRDRule{rule})
+	require.NoError(t, err)
+	events := make(chan struct{}, 10)
+	source.AddEventHandler(context.Background(), func() { events <- struct{}{} })
+	// the handler is called for the existing objects
+	expectEvent(t, events, true)
+
+	service = service.DeepCopy()
+	service.Status.LoadBalancer.Ingress = []v1.LoadBalancerIngress{{IP: "5.6.7.8"}}
+	_, err = kubeClient.CoreV1().Services("default").UpdateStatus(context.Background(), service, metav1.UpdateOptions{})
+	require.NoError(t, err)
+	expectEvent(t, events, true)
+
+	endpoints, err := source.Endpoints(context.Background())
+	require.NoError(t, err)
+	validateEndpoints(t, endpoints, []*endpoint.Endpoint{
+		{DNSName: "foo.example.org", RecordType: endpoint.RecordTypeA, Targets: endpoint.Targets{"5.6.7.8"}},
+	})
+}
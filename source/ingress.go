@@ -19,6 +19,7 @@ package source
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
 	"sort"
 	"strings"
@@ -26,11 +27,13 @@ import (
 	"time"
 
 	log "github.com/sirupsen/logrus"
-	"k8s.io/api/extensions/v1beta1"
+	extv1beta1 "k8s.io/api/extensions/v1beta1"
+	"k8s.io/api/networking/v1beta1"
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/util/wait"
 	kubeinformers "k8s.io/client-go/informers"
 	extinformers "k8s.io/client-go/informers/extensions/v1beta1"
+	netinformers "k8s.io/client-go/informers/networking/v1beta1"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/tools/cache"
 
@@ -42,25 +45,31 @@ const (
 	ALBDualstackAnnotationKey = "alb.ingress.kubernetes.io/ip-address-type"
 	// ALBDualstackAnnotationValue is the value of the ALB dualstack annotation that indicates it is dualstack
 	ALBDualstackAnnotationValue = "dualstack"
+	// IngressClassAnnotationKey is the legacy annotation used to select the ingress controller
+	IngressClassAnnotationKey = "kubernetes.io/ingress.class"
 )
 
 // ingressSource is an implementation of Source for Kubernetes ingress objects.
 // Ingress implementation will use the spec.rules.host value for the hostname
 // Use targetAnnotationKey to explicitly set Endpoint. (useful if the ingress
 // controller does not update, or to override with alternative endpoint)
+// Ingresses are read from networking.k8s.io/v1beta1, falling back to
+// extensions/v1beta1 on clusters which do not serve the networking API group yet.
 type ingressSource struct {
 	client                   kubernetes.Interface
 	namespace                string
 	annotationFilter         string
+	ingressClassNames        []string
 	fqdnTemplate             *template.Template
 	combineFQDNAnnotation    bool
 	ignoreHostnameAnnotation bool
-	ingressInformer          extinformers.IngressInformer
+	ingressInformer          netinformers.IngressInformer
+	legacyIngressInformer    extinformers.IngressInformer
 	ignoreIngressTLSSpec     bool
 }
 
 // NewIngressSource creates a new ingressSource with the given config.
-func NewIngressSource(kubeClient kubernetes.Interface, namespace, annotationFilter string, fqdnTemplate string, combineFqdnAnnotation bool, ignoreHostnameAnnotation bool, ignoreIngressTLSSpec bool) (Source, error) {
+func NewIngressSource(kubeClient kubernetes.Interface, namespace, annotationFilter string, fqdnTemplate string, combineFqdnAnnotation bool, ignoreHostnameAnnotation bool, ignoreIngressTLSSpec bool, ingressClassNames []string) (Source, error) {
 	var (
 		tmpl *template.Template
 		err  error
@@ -77,10 +86,26 @@ func NewIngressSource(kubeClient kubernetes.Interface, namespace, annotationFilt
 	// Use shared informer to listen for add/update/delete of ingresses in the specified namespace.
 	// Set resync period to 0, to prevent processing when nothing has changed.
 	informerFactory := kubeinformers.NewSharedInformerFactoryWithOptions(kubeClient, 0, kubeinformers.WithNamespace(namespace))
-	ingressInformer := informerFactory.Extensions().V1beta1().Ingresses()
+
+	sc := &ingressSource{
+		client:                   kubeClient,
+		namespace:                namespace,
+		annotationFilter:         annotationFilter,
+		ingressClassNames:        ingressClassNames,
+		fqdnTemplate:             tmpl,
+		combineFQDNAnnotation:    combineFqdnAnnotation,
+		ignoreHostnameAnnotation: ignoreHostnameAnnotation,
+		ignoreIngressTLSSpec:     ignoreIngressTLSSpec,
+	}
+	if networkingIngressAvailable(kubeClient) {
+		sc.ingressInformer = informerFactory.Networking().V1beta1().Ingresses()
+	} else {
+		log.Info("networking.k8s.io/v1beta1 is not served by the cluster, falling back to extensions/v1beta1 ingresses")
+		sc.legacyIngressInformer = informerFactory.Extensions().V1beta1().Ingresses()
+	}
 
 	// Add default resource event handlers to properly initialize informer.
-	ingressInformer.Informer().AddEventHandler(
+	sc.informer().AddEventHandler(
 		cache.ResourceEventHandlerFuncs{
 			AddFunc: func(obj interface{}) {
 			},
@@ -92,29 +117,77 @@ func NewIngressSource(kubeClient kubernetes.Interface, namespace, annotationFilt
 
 	// wait for the local cache to be populated.
 	err = poll(time.Second, 60*time.Second, func() (bool, error) {
-		return ingressInformer.Informer().HasSynced(), nil
+		return sc.informer().HasSynced(), nil
 	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to sync cache: %v", err)
 	}
 
-	sc := &ingressSource{
-		client:                   kubeClient,
-		namespace:                namespace,
-		annotationFilter:         annotationFilter,
-		fqdnTemplate:             tmpl,
-		combineFQDNAnnotation:    combineFqdnAnnotation,
-		ignoreHostnameAnnotation: ignoreHostnameAnnotation,
-		ingressInformer:          ingressInformer,
-		ignoreIngressTLSSpec:     ignoreIngressTLSSpec,
-	}
 	return sc, nil
 }
 
+// networkingIngressAvailable reports whether the cluster serves ingresses in networking.k8s.io/v1beta1
+func networkingIngressAvailable(kubeClient kubernetes.Interface) bool {
+	resources, err := kubeClient.Discovery().ServerResourcesForGroupVersion(v1beta1.SchemeGroupVersion.String())
+	if err != nil {
+		log.Debugf("Failed to discover %s: %v", v1beta1.SchemeGroupVersion, err)
+		return false
+	}
+	for _, resource := range resources.APIResources {
+		if resource.Name == "ingresses" {
+			return true
+		}
+	}
+	return false
+}
+
+// informer returns the informer of the ingress API in use
+func (sc *ingressSource) informer() cache.SharedIndexInformer {
+	if sc.legacyIngressInformer != nil {
+		return sc.legacyIngressInformer.Informer()
+	}
+	return sc.ingressInformer.Informer()
+}
+
+// listIngresses returns all ingresses in the namespace of the source, converting legacy ingresses if needed
+func (sc *ingressSource) listIngresses() ([]*v1beta1.Ingress, error) {
+	if sc.legacyIngressInformer == nil {
+		return sc.ingressInformer.Lister().Ingresses(sc.namespace).List(labels.Everything())
+	}
+
+	legacyIngresses, err := sc.legacyIngressInformer.Lister().Ingresses(sc.namespace).List(labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+	ingresses := make([]*v1beta1.Ingress, 0, len(legacyIngresses))
+	for _, legacy := range legacyIngresses {
+		ingress, err := convertLegacyIngress(legacy)
+		if err != nil {
+			return nil, err
+		}
+		ingresses = append(ingresses, ingress)
+	}
+	return ingresses, nil
+}
+
+// convertLegacyIngress converts an extensions/v1beta1 ingress to its networking.k8s.io/v1beta1 equivalent.
+// Both versions share the same schema, so the conversion is a round trip through JSON.
+func convertLegacyIngress(legacy *extv1beta1.Ingress) (*v1beta1.Ingress, error) {
+	data, err := json.Marshal(legacy)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert ingress %s/%s: %v", legacy.Namespace, legacy.Name, err)
+	}
+	ingress := &v1beta1.Ingress{}
+	if err := json.Unmarshal(data, ingress); err != nil {
+		return nil, fmt.Errorf("failed to convert ingress %s/%s: %v", legacy.Namespace, legacy.Name, err)
+	}
+	return ingress, nil
+}
+
 // Endpoints returns endpoint objects for each host-target combination that should be processed.
 // Retrieves all ingress resources on all namespaces
 func (sc *ingressSource) Endpoints(ctx context.Context) ([]*endpoint.Endpoint, error) {
-	ingresses, err := sc.ingressInformer.Lister().Ingresses(sc.namespace).List(labels.Everything())
+	ingresses, err := sc.listIngresses()
 	if err != nil {
 		return nil, err
 	}
@@ -122,6 +195,7 @@ func (sc *ingressSource) Endpoints(ctx context.Context) ([]*endpoint.Endpoint, e
 	if err != nil {
 		return nil, err
 	}
+	ingresses = sc.filterByIngressClass(ingresses)
 
 	endpoints := []*endpoint.Endpoint{}
 
@@ -158,6 +232,7 @@ func (sc *ingressSource) Endpoints(ctx context.Context) ([]*endpoint.Endpoint, e
 		log.Debugf("Endpoints generated from ingress: %s/%s: %v", ing.Namespace, ing.Name, ingEndpoints)
 		sc.setResourceLabel(ing, ingEndpoints)
 		sc.setDualstackLabel(ing, ingEndpoints)
+		setMergeLabel(ing.Annotations, ingEndpoints)
 		endpoints = append(endpoints, ingEndpoints...)
 	}
 
@@ -225,6 +300,40 @@ func (sc *ingressSource) filterByAnnotations(ingresses []*v1beta1.Ingress) ([]*v
 	return filteredList, nil
 }
 
+// filterByIngressClass keeps the ingresses of the configured ingress classes, taken from
+// spec.ingressClassName or, if not set, from the legacy ingress class annotation.
+func (sc *ingressSource) filterByIngressClass(ingresses []*v1beta1.Ingress) []*v1beta1.Ingress {
+	// no ingress class filter returns original list
+	if len(sc.ingressClassNames) == 0 {
+		return ingresses
+	}
+
+	filteredList := []*v1beta1.Ingress{}
+
+	for _, ingress := range ingresses {
+		class := ingress.Annotations[IngressClassAnnotationKey]
+		if ingress.Spec.IngressClassName != nil {
+			class = *ingress.Spec.IngressClassName
+		}
+		if !sc.matchIngressClass(class) {
+			log.Debugf("Skipping ingress %s/%s because its ingress class %q is not one of %v", ingress.Namespace, ingress.Name, class, sc.ingressClassNames)
+			continue
+		}
+		filteredList = append(filteredList, ingress)
+	}
+
+	return filteredList
+}
+
+func (sc *ingressSource) matchIngressClass(class string) bool {
+	for _, name := range sc.ingressClassNames {
+		if class == name {
+			return true
+		}
+	}
+	return false
+}
+
 func (sc *ingressSource) setResourceLabel(ingress *v1beta1.Ingress, endpoints []*endpoint.Endpoint) {
 	for _, ep := range endpoints {
 		ep.Labels[endpoint.ResourceLabelKey] = fmt.Sprintf("ingress/%s/%s", ingress.Namespace, ingress.Name)
@@ -307,7 +416,7 @@ func (sc *ingressSource) AddEventHandler(ctx context.Context, handler func()) {
 
 	// Right now there is no way to remove event handler from informer, see:
 	// https://github.com/kubernetes/kubernetes/issues/79610
-	sc.ingressInformer.Informer().AddEventHandler(
+	sc.informer().AddEventHandler(
 		cache.ResourceEventHandlerFuncs{
 			AddFunc: func(obj interface{}) {
 				handler()
@@ -25,8 +25,10 @@ import (
 	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
 	v1 "k8s.io/api/core/v1"
-	"k8s.io/api/extensions/v1beta1"
+	extv1beta1 "k8s.io/api/extensions/v1beta1"
+	"k8s.io/api/networking/v1beta1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	fakediscovery "k8s.io/client-go/discovery/fake"
 	"k8s.io/client-go/kubernetes/fake"
 
 	"sigs.k8s.io/external-dns/endpoint"
@@ -42,7 +44,7 @@ type IngressSuite struct {
 }
 
 func (suite *IngressSuite) SetupTest() {
-	fakeClient := fake.NewSimpleClientset()
+	fakeClient := newIngressClientset()
 	var err error
 
 	suite.sc, err = NewIngressSource(
@@ -53,6 +55,7 @@ func (suite *IngressSuite) SetupTest() {
 		false,
 		false,
 		false,
+		nil,
 	)
 	suite.NoError(err, "should initialize ingress source")
 
@@ -64,7 +67,7 @@ func (suite *IngressSuite) SetupTest() {
 		hostnames:   []string{"v1"},
 		annotations: map[string]string{ALBDualstackAnnotationKey: ALBDualstackAnnotationValue},
 	}).Ingress()
-	_, err = fakeClient.ExtensionsV1beta1().Ingresses(suite.fooWithTargets.Namespace).Create(context.Background(), suite.fooWithTargets, metav1.CreateOptions{})
+	_, err = fakeClient.NetworkingV1beta1().Ingresses(suite.fooWithTargets.Namespace).Create(context.Background(), suite.fooWithTargets, metav1.CreateOptions{})
 	suite.NoError(err, "should succeed")
 }
 
@@ -129,13 +132,14 @@ func TestNewIngressSource(t *testing.T) {
 	} {
 		t.Run(ti.title, func(t *testing.T) {
 			_, err := NewIngressSource(
-				fake.NewSimpleClientset(),
+				newIngressClientset(),
 				"",
 				ti.annotationFilter,
 				ti.fqdnTemplate,
 				ti.combineFQDNAndAnnotation,
 				false,
 				false,
+				nil,
 			)
 			if ti.expectError {
 				assert.Error(t, err)
@@ -241,6 +245,7 @@ func testIngressEndpoints(t *testing.T) {
 		combineFQDNAndAnnotation bool
 		ignoreHostnameAnnotation bool
 		ignoreIngressTLSSpec     bool
+		ingressClassNames        []string
 	}{
 		{
 			title:           "no ingress",
@@ -1029,6 +1034,64 @@ func testIngressEndpoints(t *testing.T) {
 				},
 			},
 		},
+		{
+			title:             "ingress class filter matches spec and legacy annotation",
+			ingressClassNames: []string{"internal"},
+			ingressItems: []fakeIngress{
+				{
+					name:      "spec-class",
+					namespace: namespace,
+					dnsnames:  []string{"spec.example.org"},
+					ips:       []string{"1.2.3.4"},
+					className: "internal",
+				},
+				{
+					name:        "annotation-class",
+					namespace:   namespace,
+					dnsnames:    []string{"annotation.example.org"},
+					ips:         []string{"1.2.3.4"},
+					annotations: map[string]string{IngressClassAnnotationKey: "internal"},
+				},
+				{
+					name:      "other-class",
+					namespace: namespace,
+					dnsnames:  []string{"other.example.org"},
+					ips:       []string{"1.2.3.4"},
+					className: "external",
+				},
+				{
+					name:      "no-class",
+					namespace: namespace,
+					dnsnames:  []string{"none.example.org"},
+					ips:       []string{"1.2.3.4"},
+				},
+			},
+			expected: []*endpoint.Endpoint{
+				{
+					DNSName: "spec.example.org",
+					Targets: endpoint.Targets{"1.2.3.4"},
+				},
+				{
+					DNSName: "annotation.example.org",
+					Targets: endpoint.Targets{"1.2.3.4"},
+				},
+			},
+		},
+		{
+			title:             "spec.ingressClassName takes precedence over the legacy annotation",
+			ingressClassNames: []string{"internal", "private"},
+			ingressItems: []fakeIngress{
+				{
+					name:        "fake1",
+					namespace:   namespace,
+					dnsnames:    []string{"example.org"},
+					ips:         []string{"1.2.3.4"},
+					annotations: map[string]string{IngressClassAnnotationKey: "internal"},
+					className:   "external",
+				},
+			},
+			expected: []*endpoint.Endpoint{},
+		},
 	} {
 		t.Run(ti.title, func(t *testing.T) {
 			ingresses := make([]*v1beta1.Ingress, 0)
@@ -1036,7 +1099,7 @@ func testIngressEndpoints(t *testing.T) {
 				ingresses = append(ingresses, item.Ingress())
 			}
 
-			fakeClient := fake.NewSimpleClientset()
+			fakeClient := newIngressClientset()
 			source, _ := NewIngressSource(
 				fakeClient,
 				ti.targetNamespace,
@@ -1045,9 +1108,10 @@ func testIngressEndpoints(t *testing.T) {
 				ti.combineFQDNAndAnnotation,
 				ti.ignoreHostnameAnnotation,
 				ti.ignoreIngressTLSSpec,
+				ti.ingressClassNames,
 			)
 			for _, ingress := range ingresses {
-				_, err := fakeClient.ExtensionsV1beta1().Ingresses(ingress.Namespace).Create(context.Background(), ingress, metav1.CreateOptions{})
+				_, err := fakeClient.NetworkingV1beta1().Ingresses(ingress.Namespace).Create(context.Background(), ingress, metav1.CreateOptions{})
 				require.NoError(t, err)
 			}
 
@@ -1096,6 +1160,7 @@ type fakeIngress struct {
 	namespace   string
 	name        string
 	annotations map[string]string
+	className   string
 }
 
 func (ing fakeIngress) Ingress() *v1beta1.Ingress {
@@ -1114,6 +1179,9 @@ func (ing fakeIngress) Ingress() *v1beta1.Ingress {
 			},
 		},
 	}
+	if ing.className != "" {
+		ingress.Spec.IngressClassName = &ing.className
+	}
 	for _, dnsname := range ing.dnsnames {
 		ingress.Spec.Rules = append(ingress.Spec.Rules, v1beta1.IngressRule{
 			Host: dnsname,
@@ -1136,3 +1204,54 @@ func (ing fakeIngress) Ingress() *v1beta1.Ingress {
 	}
 	return ingress
 }
+
+// newIngressClientset returns a fake clientset serving ingresses in networking.k8s.io/v1beta1
+func newIngressClientset() *fake.Clientset {
+	fakeClient := fake.NewSimpleClientset()
+	fakeClient.Discovery().(*fakediscovery.FakeDiscovery).Resources = []*metav1.APIResourceList{
+		{
+			GroupVersion: v1beta1.SchemeGroupVersion.String(),
+			APIResources: []metav1.APIResource{{Name: "ingresses", Namespaced: true, Kind: "Ingress"}},
+		},
+	}
+	return fakeClient
+}
+
+func TestIngressLegacyFallback(t *testing.T) {
+	// the plain fake clientset does not serve networking.k8s.io/v1beta1
+	fakeClient := fake.NewSimpleClientset()
+	legacy := &extv1beta1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:   "default",
+			Name:        "legacy",
+			Annotations: map[string]string{IngressClassAnnotationKey: "internal"},
+		},
+		Spec: extv1beta1.IngressSpec{
+			Rules: []extv1beta1.IngressRule{{Host: "legacy.example.org"}},
+		},
+		Status: extv1beta1.IngressStatus{
+			LoadBalancer: v1.LoadBalancerStatus{
+				Ingress: []v1.LoadBalancerIngress{{IP: "1.2.3.4"}},
+			},
+		},
+	}
+	_, err := fakeClient.ExtensionsV1beta1().Ingresses(legacy.Namespace).Create(context.Background(), legacy, metav1.CreateOptions{})
+	require.NoError(t, err)
+
+	source, err := NewIngressSource(fakeClient, "", "", "", false, false, false, []string{"internal"})
+	require.NoError(t, err)
+	concreteIngressSource := source.(*ingressSource)
+	require.NotNil(t, concreteIngressSource.legacyIngressInformer)
+
+	err = poll(250*time.Millisecond, 6*time.Second, func() (bool, error) {
+		_, err := concreteIngressSource.legacyIngressInformer.Lister().Ingresses(legacy.Namespace).Get(legacy.Name)
+		return err == nil, nil
+	})
+	require.NoError(t, err)
+
+	endpoints, err := source.Endpoints(context.Background())
+	require.NoError(t, err)
+	validateEndpoints(t, endpoints, []*endpoint.Endpoint{
+		{DNSName: "legacy.example.org", Targets: endpoint.Targets{"1.2.3.4"}},
+	})
+}
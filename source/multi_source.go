@@ -18,31 +18,109 @@ package source
 
 import (
 	"context"
+	"time"
+
+	log "github.com/sirupsen/logrus"
 
 	"sigs.k8s.io/external-dns/endpoint"
 )
 
+// SourceFailure describes a nested source whose last call of Endpoints failed.
+type SourceFailure struct {
+	// Source is the name of the failed source
+	Source string
+	Err    error
+	// Stale is set if the last known endpoints of the source were returned in place of its current ones
+	Stale bool
+}
+
+// FailureReporter is implemented by Sources which return the endpoints of their nested sources
+// even if some of them failed.
+type FailureReporter interface {
+	// Failures returns the nested sources which failed during the last call of Endpoints
+	Failures() []SourceFailure
+}
+
 // multiSource is a Source that merges the endpoints of its nested Sources.
+// In tolerant mode a failing source doesn't fail the others: its last known endpoints are
+// used as long as they are not older than maxStaleness, otherwise they are left out and
+// the failure is reported, so that their absence is not taken for a deletion.
 type multiSource struct {
-	children []Source
+	children     []Source
+	names        []string
+	tolerant     bool
+	maxStaleness time.Duration
+	// lastKnown holds the endpoints of the last successful call of each child
+	lastKnown []lastKnownEndpoints
+	failures  []SourceFailure
+}
+
+type lastKnownEndpoints struct {
+	endpoints []*endpoint.Endpoint
+	timestamp time.Time
 }
 
 // Endpoints collects endpoints of all nested Sources and returns them in a single slice.
 func (ms *multiSource) Endpoints(ctx context.Context) ([]*endpoint.Endpoint, error) {
 	result := []*endpoint.Endpoint{}
+	ms.failures = nil
 
-	for _, s := range ms.children {
+	for i, s := range ms.children {
 		endpoints, err := s.Endpoints(ctx)
 		if err != nil {
-			return nil, err
+			if !ms.tolerant {
+				return nil, err
+			}
+			var stale bool
+			endpoints, stale = ms.lastKnownEndpoints(i, err)
+			ms.failures = append(ms.failures, SourceFailure{Source: ms.names[i], Err: err, Stale: stale})
+		} else if ms.tolerant {
+			ms.lastKnown[i] = lastKnownEndpoints{endpoints: endpoints, timestamp: time.Now()}
 		}
 
 		result = append(result, endpoints...)
 	}
 
+	// there is nothing to isolate if all sources failed without any endpoints to fall back to
+	if len(ms.children) > 0 && len(ms.failures) == len(ms.children) {
+		for _, failure := range ms.failures {
+			if failure.Stale {
+				return result, nil
+			}
+		}
+		return nil, ms.failures[0].Err
+	}
+
 	return result, nil
 }
 
+// lastKnownEndpoints returns the last known endpoints of a failed child unless they are too old
+func (ms *multiSource) lastKnownEndpoints(i int, err error) ([]*endpoint.Endpoint, bool) {
+	name := ms.names[i]
+	lastKnown := ms.lastKnown[i]
+	if lastKnown.timestamp.IsZero() {
+		log.Errorf("Source %s failed and has no previous endpoints, deletions are suspended: %v", name, err)
+		return nil, false
+	}
+	age := time.Since(lastKnown.timestamp)
+	if ms.maxStaleness > 0 && age > ms.maxStaleness {
+		log.Errorf("Source %s failed and its previous endpoints are older than %s, deletions are suspended: %v", name, ms.maxStaleness, err)
+		return nil, false
+	}
+
+	log.Warnf("Source %s failed, using its endpoints from %s ago: %v", name, age.Round(time.Second), err)
+	endpoints := make([]*endpoint.Endpoint, 0, len(lastKnown.endpoints))
+	for _, ep := range lastKnown.endpoints {
+		endpoints = append(endpoints, ep.DeepCopy())
+	}
+	return endpoints, true
+}
+
+// Failures returns the nested sources which failed during the last call of Endpoints
+func (ms *multiSource) Failures() []SourceFailure {
+	return ms.failures
+}
+
 func (ms *multiSource) AddEventHandler(ctx context.Context, handler func()) {
 	for _, s := range ms.children {
 		s.AddEventHandler(ctx, handler)
@@ -53,3 +131,16 @@ func (ms *multiSource) AddEventHandler(ctx context.Context, handler func()) {
 func NewMultiSource(children []Source) Source {
 	return &multiSource{children: children}
 }
+
+// NewTolerantMultiSource creates a new multiSource which isolates the failures of its children.
+// The names identify the children in logs and failures, maxStaleness limits the age of the last
+// known endpoints used for a failing child, 0 means no limit.
+func NewTolerantMultiSource(children []Source, names []string, maxStaleness time.Duration) Source {
+	return &multiSource{
+		children:     children,
+		names:        names,
+		tolerant:     true,
+		maxStaleness: maxStaleness,
+		lastKnown:    make([]lastKnownEndpoints, len(children)),
+	}
+}
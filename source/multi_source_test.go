@@ -20,6 +20,7 @@ import (
 	"context"
 	"errors"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -32,6 +33,8 @@ func TestMultiSource(t *testing.T) {
 	t.Run("Interface", testMultiSourceImplementsSource)
 	t.Run("Endpoints", testMultiSourceEndpoints)
 	t.Run("EndpointsWithError", testMultiSourceEndpointsWithError)
+	t.Run("TolerantEndpointsWithError", testTolerantMultiSourceEndpointsWithError)
+	t.Run("TolerantMaxStaleness", testTolerantMultiSourceMaxStaleness)
 }
 
 // testMultiSourceImplementsSource tests that multiSource is a valid Source.
@@ -119,3 +122,70 @@ func testMultiSourceEndpointsWithError(t *testing.T) {
 	// Validate that the nested source was called.
 	src.AssertExpectations(t)
 }
+
+// testTolerantMultiSourceEndpointsWithError tests that a failing nested source doesn't affect the others.
+func testTolerantMultiSourceEndpointsWithError(t *testing.T) {
+	foo := &endpoint.Endpoint{DNSName: "foo", Targets: endpoint.Targets{"8.8.8.8"}}
+	bar := &endpoint.Endpoint{DNSName: "bar", Targets: endpoint.Targets{"8.8.4.4"}}
+	errSomeError := errors.New("some error")
+
+	healthy := new(testutils.MockSource)
+	healthy.On("Endpoints").Return([]*endpoint.Endpoint{foo}, nil)
+	flaky := new(testutils.MockSource)
+	flaky.On("Endpoints").Return(nil, errSomeError).Once()
+	flaky.On("Endpoints").Return([]*endpoint.Endpoint{bar}, nil).Once()
+	flaky.On("Endpoints").Return(nil, errSomeError).Once()
+
+	source := NewTolerantMultiSource([]Source{healthy, flaky}, []string{"service", "istio-gateway"}, 0)
+	reporter := NewDedupSource(source).(FailureReporter)
+
+	// without previous endpoints the failed source is left out
+	endpoints, err := source.Endpoints(context.Background())
+	require.NoError(t, err)
+	validateEndpoints(t, endpoints, []*endpoint.Endpoint{foo})
+	assert.Equal(t, []SourceFailure{{Source: "istio-gateway", Err: errSomeError}}, reporter.Failures())
+
+	endpoints, err = source.Endpoints(context.Background())
+	require.NoError(t, err)
+	validateEndpoints(t, endpoints, []*endpoint.Endpoint{foo, bar})
+	assert.Empty(t, reporter.Failures())
+
+	// afterwards its last known endpoints are used
+	endpoints, err = source.Endpoints(context.Background())
+	require.NoError(t, err)
+	validateEndpoints(t, endpoints, []*endpoint.Endpoint{foo, bar})
+	assert.Equal(t, []SourceFailure{{Source: "istio-gateway", Err: errSomeError, Stale: true}}, reporter.Failures())
+
+	healthy.AssertExpectations(t)
+	flaky.AssertExpectations(t)
+
+	// the error is returned if there are no endpoints at all
+	failing := new(testutils.MockSource)
+	failing.On("Endpoints").Return(nil, errSomeError)
+	_, err = NewTolerantMultiSource([]Source{failing}, []string{"service"}, 0).Endpoints(context.Background())
+	assert.EqualError(t, err, "some error")
+}
+
+// testTolerantMultiSourceMaxStaleness tests that last known endpoints older than the limit are not used.
+func testTolerantMultiSourceMaxStaleness(t *testing.T) {
+	foo := &endpoint.Endpoint{DNSName: "foo", Targets: endpoint.Targets{"8.8.8.8"}}
+	bar := &endpoint.Endpoint{DNSName: "bar", Targets: endpoint.Targets{"8.8.4.4"}}
+	errSomeError := errors.New("some error")
+
+	healthy := new(testutils.MockSource)
+	healthy.On("Endpoints").Return([]*endpoint.Endpoint{foo}, nil)
+	flaky := new(testutils.MockSource)
+	flaky.On("Endpoints").Return([]*endpoint.Endpoint{bar}, nil).Once()
+	flaky.On("Endpoints").Return(nil, errSomeError)
+
+	source := NewTolerantMultiSource([]Source{healthy, flaky}, []string{"service", "cloudfoundry"}, time.Minute)
+
+	_, err := source.Endpoints(context.Background())
+	require.NoError(t, err)
+
+	source.(*multiSource).lastKnown[1].timestamp = time.Now().Add(-2 * time.Minute)
+	endpoints, err := source.Endpoints(context.Background())
+	require.NoError(t, err)
+	validateEndpoints(t, endpoints, []*endpoint.Endpoint{foo})
+	assert.Equal(t, []SourceFailure{{Source: "cloudfoundry", Err: errSomeError}}, source.(FailureReporter).Failures())
+}
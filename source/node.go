@@ -20,6 +20,8 @@ import (
 	"bytes"
 	"context"
 	"fmt"
+	"net"
+	"reflect"
 	"strings"
 	"text/template"
 	"time"
@@ -37,20 +39,59 @@ import (
 	"sigs.k8s.io/external-dns/endpoint"
 )
 
+// nodeAddressType selects the node addresses of a type and IP family
+type nodeAddressType struct {
+	addressType v1.NodeAddressType
+	ipv6        bool
+}
+
+// nodeAddressTypes are the address types which can be published for nodes
+var nodeAddressTypes = map[string]nodeAddressType{
+	"ExternalIP":   {addressType: v1.NodeExternalIP},
+	"InternalIP":   {addressType: v1.NodeInternalIP},
+	"ExternalIPv6": {addressType: v1.NodeExternalIP, ipv6: true},
+	"InternalIPv6": {addressType: v1.NodeInternalIP, ipv6: true},
+}
+
+// DefaultNodeAddressTypes are the address types published for nodes unless configured otherwise
+var DefaultNodeAddressTypes = []string{"ExternalIP", "InternalIP"}
+
 type nodeSource struct {
-	client           kubernetes.Interface
-	annotationFilter string
-	fqdnTemplate     *template.Template
-	nodeInformer     coreinformers.NodeInformer
+	client             kubernetes.Interface
+	annotationFilter   string
+	labelSelector      labels.Selector
+	fqdnTemplate       *template.Template
+	addressTypes       []nodeAddressType
+	excludeUnavailable bool
+	nodeInformer       coreinformers.NodeInformer
 }
 
 // NewNodeSource creates a new nodeSource with the given config.
-func NewNodeSource(kubeClient kubernetes.Interface, annotationFilter, fqdnTemplate string) (Source, error) {
+// The addresses of the first of addressTypes a node has are published, nodes which are
+// cordoned or not Ready are skipped if excludeUnavailable is set.
+func NewNodeSource(kubeClient kubernetes.Interface, annotationFilter, fqdnTemplate, labelFilter string, addressTypes []string, excludeUnavailable bool) (Source, error) {
 	var (
 		tmpl *template.Template
 		err  error
 	)
 
+	labelSelector, err := labels.Parse(labelFilter)
+	if err != nil {
+		return nil, fmt.Errorf("invalid node label selector %q: %v", labelFilter, err)
+	}
+
+	if len(addressTypes) == 0 {
+		addressTypes = DefaultNodeAddressTypes
+	}
+	types := make([]nodeAddressType, 0, len(addressTypes))
+	for _, name := range addressTypes {
+		addressType, ok := nodeAddressTypes[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown node address type %q", name)
+		}
+		types = append(types, addressType)
+	}
+
 	if fqdnTemplate != "" {
 		tmpl, err = template.New("endpoint").Funcs(template.FuncMap{
 			"trimPrefix": strings.TrimPrefix,
@@ -86,16 +127,19 @@ func NewNodeSource(kubeClient kubernetes.Interface, annotationFilter, fqdnTempla
 	}
 
 	return &nodeSource{
-		client:           kubeClient,
-		annotationFilter: annotationFilter,
-		fqdnTemplate:     tmpl,
-		nodeInformer:     nodeInformer,
+		client:             kubeClient,
+		annotationFilter:   annotationFilter,
+		labelSelector:      labelSelector,
+		fqdnTemplate:       tmpl,
+		addressTypes:       types,
+		excludeUnavailable: excludeUnavailable,
+		nodeInformer:       nodeInformer,
 	}, nil
 }
 
 // Endpoints returns endpoint objects for each service that should be processed.
 func (ns *nodeSource) Endpoints(ctx context.Context) ([]*endpoint.Endpoint, error) {
-	nodes, err := ns.nodeInformer.Lister().List(labels.Everything())
+	nodes, err := ns.nodeInformer.Lister().List(ns.labelSelector)
 	if err != nil {
 		return nil, err
 	}
@@ -117,6 +161,11 @@ func (ns *nodeSource) Endpoints(ctx context.Context) ([]*endpoint.Endpoint, erro
 			continue
 		}
 
+		if ns.excludeUnavailable && !nodeAvailable(node) {
+			log.Debugf("Skipping node %s because it is cordoned or not Ready", node.Name)
+			continue
+		}
+
 		log.Debugf("creating endpoint for node %s", node.Name)
 
 		ttl, err := getTTLFromAnnotations(node.Annotations)
@@ -124,12 +173,7 @@ func (ns *nodeSource) Endpoints(ctx context.Context) ([]*endpoint.Endpoint, erro
 			log.Warn(err)
 		}
 
-		// create new endpoint with the information we already have
-		ep := &endpoint.Endpoint{
-			RecordType: "A", // hardcoded DNS record type
-			RecordTTL:  ttl,
-		}
-
+		var dnsName string
 		if ns.fqdnTemplate != nil {
 			// Process the whole template string
 			var buf bytes.Buffer
@@ -138,25 +182,44 @@ func (ns *nodeSource) Endpoints(ctx context.Context) ([]*endpoint.Endpoint, erro
 				return nil, fmt.Errorf("failed to apply template on node %s: %v", node.Name, err)
 			}
 
-			ep.DNSName = buf.String()
-			log.Debugf("applied template for %s, converting to %s", node.Name, ep.DNSName)
+			dnsName = buf.String()
+			log.Debugf("applied template for %s, converting to %s", node.Name, dnsName)
 		} else {
-			ep.DNSName = node.Name
+			dnsName = node.Name
 			log.Debugf("not applying template for %s", node.Name)
 		}
 
-		addrs, err := ns.nodeAddresses(node)
-		if err != nil {
-			return nil, fmt.Errorf("failed to get node address from %s: %s", node.Name, err.Error())
+		// the target annotation overrides the addresses of the node, its targets are split by record type
+		var nodeEndpoints []*endpoint.Endpoint
+		if targets := getTargetsFromTargetAnnotation(node.Annotations); len(targets) > 0 {
+			for _, recordType := range []string{endpoint.RecordTypeA, endpoint.RecordTypeAAAA, endpoint.RecordTypeCNAME} {
+				typed := endpoint.Targets{}
+				for _, target := range targets {
+					if nodeTargetType(target) == recordType {
+						typed = append(typed, target)
+					}
+				}
+				if len(typed) > 0 {
+					nodeEndpoints = append(nodeEndpoints, &endpoint.Endpoint{DNSName: dnsName, Targets: typed, RecordType: recordType, RecordTTL: ttl})
+				}
+			}
+		} else {
+			addrs, recordType, err := ns.nodeAddresses(node)
+			if err != nil {
+				return nil, fmt.Errorf("failed to get node address from %s: %s", node.Name, err.Error())
+			}
+			nodeEndpoints = append(nodeEndpoints, &endpoint.Endpoint{DNSName: dnsName, Targets: endpoint.Targets(addrs), RecordType: recordType, RecordTTL: ttl})
 		}
 
-		ep.Targets = endpoint.Targets(addrs)
-
-		log.Debugf("adding endpoint %s", ep)
-		if _, ok := endpoints[ep.DNSName]; ok {
-			endpoints[ep.DNSName].Targets = append(endpoints[ep.DNSName].Targets, ep.Targets...)
-		} else {
-			endpoints[ep.DNSName] = ep
+		for _, ep := range nodeEndpoints {
+			log.Debugf("adding endpoint %s", ep)
+			key := ep.DNSName + "/" + ep.RecordType
+			if _, ok := endpoints[key]; ok {
+				endpoints[key].Targets = append(endpoints[key].Targets, ep.Targets...)
+			} else {
+				endpoints[key] = ep
+				setMergeLabel(node.Annotations, []*endpoint.Endpoint{ep})
+			}
 		}
 	}
 
@@ -169,29 +232,90 @@ func (ns *nodeSource) Endpoints(ctx context.Context) ([]*endpoint.Endpoint, erro
 }
 
 func (ns *nodeSource) AddEventHandler(ctx context.Context, handler func()) {
+	log.Debug("Adding event handler for node")
+
+	// Right now there is no way to remove event handler from informer, see:
+	// https://github.com/kubernetes/kubernetes/issues/79610
+	ns.nodeInformer.Informer().AddEventHandler(
+		cache.ResourceEventHandlerFuncs{
+			AddFunc: func(obj interface{}) {
+				handler()
+			},
+			UpdateFunc: func(old interface{}, new interface{}) {
+				// nodes are updated frequently by their status heartbeats, ignore updates which cannot change any endpoint
+				oldNode, okOld := old.(*v1.Node)
+				newNode, okNew := new.(*v1.Node)
+				if okOld && okNew && !nodeChanged(oldNode, newNode) {
+					return
+				}
+				handler()
+			},
+			DeleteFunc: func(obj interface{}) {
+				handler()
+			},
+		},
+	)
+}
+
+// nodeChanged reports whether a node changed in a way which may affect its endpoints
+func nodeChanged(old, new *v1.Node) bool {
+	return !reflect.DeepEqual(old.Status.Addresses, new.Status.Addresses) ||
+		!reflect.DeepEqual(old.Annotations, new.Annotations) ||
+		!reflect.DeepEqual(old.Labels, new.Labels) ||
+		old.Name != new.Name ||
+		nodeAvailable(old) != nodeAvailable(new)
 }
 
-// nodeAddress returns node's externalIP and if that's not found, node's internalIP
+// nodeAddresses returns the node's addresses of the first configured address type it has,
+// together with the matching record type.
+// By default this is node's externalIP and if that's not found, node's internalIP,
 // basically what k8s.io/kubernetes/pkg/util/node.GetPreferredNodeAddress does
-func (ns *nodeSource) nodeAddresses(node *v1.Node) ([]string, error) {
-	addresses := map[v1.NodeAddressType][]string{
-		v1.NodeExternalIP: {},
-		v1.NodeInternalIP: {},
+func (ns *nodeSource) nodeAddresses(node *v1.Node) ([]string, string, error) {
+	for _, addressType := range ns.addressTypes {
+		addresses := []string{}
+		for _, addr := range node.Status.Addresses {
+			ip := net.ParseIP(addr.Address)
+			if addr.Type != addressType.addressType || ip == nil || (ip.To4() == nil) != addressType.ipv6 {
+				continue
+			}
+			addresses = append(addresses, addr.Address)
+		}
+		if len(addresses) == 0 {
+			continue
+		}
+		if addressType.ipv6 {
+			return addresses, endpoint.RecordTypeAAAA, nil
+		}
+		return addresses, endpoint.RecordTypeA, nil
 	}
 
-	for _, addr := range node.Status.Addresses {
-		addresses[addr.Type] = append(addresses[addr.Type], addr.Address)
-	}
+	return nil, "", fmt.Errorf("could not find node address for %s", node.Name)
+}
 
-	if len(addresses[v1.NodeExternalIP]) > 0 {
-		return addresses[v1.NodeExternalIP], nil
+// nodeTargetType returns the record type for a target set by annotation
+func nodeTargetType(target string) string {
+	ip := net.ParseIP(target)
+	switch {
+	case ip == nil:
+		return endpoint.RecordTypeCNAME
+	case ip.To4() == nil:
+		return endpoint.RecordTypeAAAA
+	default:
+		return endpoint.RecordTypeA
 	}
+}
 
-	if len(addresses[v1.NodeInternalIP]) > 0 {
-		return addresses[v1.NodeInternalIP], nil
+// nodeAvailable reports whether a node is schedulable and Ready
+func nodeAvailable(node *v1.Node) bool {
+	if node.Spec.Unschedulable {
+		return false
 	}
-
-	return nil, fmt.Errorf("could not find node address for %s", node.Name)
+	for _, condition := range node.Status.Conditions {
+		if condition.Type == v1.NodeReady {
+			return condition.Status == v1.ConditionTrue
+		}
+	}
+	return false
 }
 
 // filterByAnnotations filters a list of nodes by a given annotation selector.
@@ -18,6 +18,7 @@ package source
 
 import (
 	"context"
+	"sort"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -32,6 +33,8 @@ import (
 func TestNodeSource(t *testing.T) {
 	t.Run("NewNodeSource", testNodeSourceNewNodeSource)
 	t.Run("Endpoints", testNodeSourceEndpoints)
+	t.Run("AddressTypesAndFilters", testNodeSourceAddressTypesAndFilters)
+	t.Run("EventHandler", testNodeSourceEventHandler)
 }
 
 // testNodeSourceNewNodeSource tests that NewNodeService doesn't return an error.
@@ -67,6 +70,9 @@ func testNodeSourceNewNodeSource(t *testing.T) {
 				fake.NewSimpleClientset(),
 				ti.annotationFilter,
 				ti.fqdnTemplate,
+				"",
+				nil,
+				false,
 			)
 
 			if ti.expectError {
@@ -331,6 +337,9 @@ func testNodeSourceEndpoints(t *testing.T) {
 				kubernetes,
 				tc.annotationFilter,
 				tc.fqdnTemplate,
+				"",
+				nil,
+				false,
 			)
 			require.NoError(t, err)
 
@@ -346,3 +355,167 @@ func testNodeSourceEndpoints(t *testing.T) {
 		})
 	}
 }
+
+// testNodeSourceEventHandler tests that relevant node changes trigger the event handler while status heartbeats don't.
+func testNodeSourceEventHandler(t *testing.T) {
+	kubernetes := fake.NewSimpleClientset()
+	source, err := NewNodeSource(kubernetes, "", "", "", nil, false)
+	require.NoError(t, err)
+
+	events := make(chan struct{}, 10)
+	source.AddEventHandler(context.Background(), func() { events <- struct{}{} })
+
+	node := &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "node1"},
+		Status: v1.NodeStatus{
+			Addresses:  []v1.NodeAddress{{Type: v1.NodeExternalIP, Address: "1.2.3.4"}},
+			Conditions: []v1.NodeCondition{{Type: v1.NodeReady, Status: v1.ConditionTrue}},
+		},
+	}
+	node, err = kubernetes.CoreV1().Nodes().Create(context.Background(), node, metav1.CreateOptions{})
+	require.NoError(t, err)
+	expectEvent(t, events, true)
+
+	// a heartbeat only touches the conditions
+	node.Status.Conditions[0].LastHeartbeatTime = metav1.Now()
+	node, err = kubernetes.CoreV1().Nodes().UpdateStatus(context.Background(), node, metav1.UpdateOptions{})
+	require.NoError(t, err)
+	expectEvent(t, events, false)
+
+	node.Status.Addresses = []v1.NodeAddress{{Type: v1.NodeExternalIP, Address: "5.6.7.8"}}
+	node, err = kubernetes.CoreV1().Nodes().UpdateStatus(context.Background(), node, metav1.UpdateOptions{})
+	require.NoError(t, err)
+	expectEvent(t, events, true)
+
+	node.Status.Conditions[0].Status = v1.ConditionFalse
+	_, err = kubernetes.CoreV1().Nodes().UpdateStatus(context.Background(), node, metav1.UpdateOptions{})
+	require.NoError(t, err)
+	expectEvent(t, events, true)
+
+	require.NoError(t, kubernetes.CoreV1().Nodes().Delete(context.Background(), node.Name, metav1.DeleteOptions{}))
+	expectEvent(t, events, true)
+}
+
+// testNodeSourceAddressTypesAndFilters tests the address type preference, the label selector and the exclusion of unavailable nodes.
+func testNodeSourceAddressTypesAndFilters(t *testing.T) {
+	ready := []v1.NodeCondition{{Type: v1.NodeReady, Status: v1.ConditionTrue}}
+	dualStack := []v1.NodeAddress{
+		{Type: v1.NodeExternalIP, Address: "1.2.3.4"},
+		{Type: v1.NodeInternalIP, Address: "10.0.0.1"},
+		{Type: v1.NodeInternalIP, Address: "fd00::1"},
+	}
+
+	for _, tc := range []struct {
+		title              string
+		fqdnTemplate       string
+		labelFilter        string
+		addressTypes       []string
+		excludeUnavailable bool
+		nodes              []*v1.Node
+		expected           []*endpoint.Endpoint
+		expectError        bool
+	}{
+		{
+			title:        "first address type a node has wins",
+			addressTypes: []string{"InternalIPv6", "InternalIP"},
+			nodes: []*v1.Node{
+				{ObjectMeta: metav1.ObjectMeta{Name: "node1"}, Status: v1.NodeStatus{Addresses: dualStack}},
+				{ObjectMeta: metav1.ObjectMeta{Name: "node2"}, Status: v1.NodeStatus{Addresses: []v1.NodeAddress{{Type: v1.NodeInternalIP, Address: "10.0.0.2"}}}},
+			},
+			expected: []*endpoint.Endpoint{
+				{RecordType: endpoint.RecordTypeAAAA, DNSName: "node1", Targets: endpoint.Targets{"fd00::1"}},
+				{RecordType: endpoint.RecordTypeA, DNSName: "node2", Targets: endpoint.Targets{"10.0.0.2"}},
+			},
+		},
+		{
+			title: "IPv6 addresses are not published as A records",
+			nodes: []*v1.Node{
+				{ObjectMeta: metav1.ObjectMeta{Name: "node1"}, Status: v1.NodeStatus{Addresses: []v1.NodeAddress{
+					{Type: v1.NodeExternalIP, Address: "2001:db8::1"},
+					{Type: v1.NodeInternalIP, Address: "10.0.0.1"},
+				}}},
+			},
+			expected: []*endpoint.Endpoint{
+				{RecordType: endpoint.RecordTypeA, DNSName: "node1", Targets: endpoint.Targets{"10.0.0.1"}},
+			},
+		},
+		{
+			title:        "unknown address type",
+			addressTypes: []string{"Hostname"},
+			expectError:  true,
+		},
+		{
+			title:       "label selector",
+			labelFilter: "role=ingress",
+			nodes: []*v1.Node{
+				{ObjectMeta: metav1.ObjectMeta{Name: "node1", Labels: map[string]string{"role": "ingress"}}, Status: v1.NodeStatus{Addresses: dualStack}},
+				{ObjectMeta: metav1.ObjectMeta{Name: "node2", Labels: map[string]string{"role": "worker"}}, Status: v1.NodeStatus{Addresses: dualStack}},
+			},
+			expected: []*endpoint.Endpoint{
+				{RecordType: endpoint.RecordTypeA, DNSName: "node1", Targets: endpoint.Targets{"1.2.3.4"}},
+			},
+		},
+		{
+			title:       "invalid label selector",
+			labelFilter: "role in (",
+			expectError: true,
+		},
+		{
+			title:              "cordoned and not ready nodes are excluded",
+			fqdnTemplate:       "ingress.example.org",
+			excludeUnavailable: true,
+			nodes: []*v1.Node{
+				{ObjectMeta: metav1.ObjectMeta{Name: "node1"}, Status: v1.NodeStatus{Addresses: []v1.NodeAddress{{Type: v1.NodeExternalIP, Address: "1.1.1.1"}}, Conditions: ready}},
+				{ObjectMeta: metav1.ObjectMeta{Name: "node2"}, Spec: v1.NodeSpec{Unschedulable: true}, Status: v1.NodeStatus{Addresses: []v1.NodeAddress{{Type: v1.NodeExternalIP, Address: "2.2.2.2"}}, Conditions: ready}},
+				{ObjectMeta: metav1.ObjectMeta{Name: "node3"}, Status: v1.NodeStatus{Addresses: []v1.NodeAddress{{Type: v1.NodeExternalIP, Address: "3.3.3.3"}}, Conditions: []v1.NodeCondition{{Type: v1.NodeReady, Status: v1.ConditionFalse}}}},
+			},
+			expected: []*endpoint.Endpoint{
+				{RecordType: endpoint.RecordTypeA, DNSName: "ingress.example.org", Targets: endpoint.Targets{"1.1.1.1"}},
+			},
+		},
+		{
+			title: "target annotation overrides the node addresses",
+			nodes: []*v1.Node{
+				{ObjectMeta: metav1.ObjectMeta{Name: "node1", Annotations: map[string]string{targetAnnotationKey: "203.0.113.1"}}, Status: v1.NodeStatus{Addresses: dualStack}},
+				{ObjectMeta: metav1.ObjectMeta{Name: "node2", Annotations: map[string]string{targetAnnotationKey: "2001:db8::2"}}, Status: v1.NodeStatus{Addresses: dualStack}},
+			},
+			expected: []*endpoint.Endpoint{
+				{RecordType: endpoint.RecordTypeA, DNSName: "node1", Targets: endpoint.Targets{"203.0.113.1"}},
+				{RecordType: endpoint.RecordTypeAAAA, DNSName: "node2", Targets: endpoint.Targets{"2001:db8::2"}},
+			},
+		},
+		{
+			title:        "target annotation with both address families",
+			fqdnTemplate: "ingress.example.org",
+			nodes: []*v1.Node{
+				{ObjectMeta: metav1.ObjectMeta{Name: "node1", Annotations: map[string]string{targetAnnotationKey: "2001:db8::1,203.0.113.1"}}, Status: v1.NodeStatus{Addresses: dualStack}},
+				{ObjectMeta: metav1.ObjectMeta{Name: "node2", Annotations: map[string]string{targetAnnotationKey: "203.0.113.2"}}, Status: v1.NodeStatus{Addresses: dualStack}},
+			},
+			expected: []*endpoint.Endpoint{
+				{RecordType: endpoint.RecordTypeA, DNSName: "ingress.example.org", Targets: endpoint.Targets{"203.0.113.1", "203.0.113.2"}},
+				{RecordType: endpoint.RecordTypeAAAA, DNSName: "ingress.example.org", Targets: endpoint.Targets{"2001:db8::1"}},
+			},
+		},
+	} {
+		t.Run(tc.title, func(t *testing.T) {
+			kubernetes := fake.NewSimpleClientset()
+			for _, node := range tc.nodes {
+				_, err := kubernetes.CoreV1().Nodes().Create(context.Background(), node, metav1.CreateOptions{})
+				require.NoError(t, err)
+			}
+
+			client, err := NewNodeSource(kubernetes, "", tc.fqdnTemplate, tc.labelFilter, tc.addressTypes, tc.excludeUnavailable)
+			if tc.expectError {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+
+			endpoints, err := client.Endpoints(context.Background())
+			require.NoError(t, err)
+			// endpoints of the same name are validated in the order of their record types
+			sort.SliceStable(endpoints, func(i, j int) bool { return endpoints[i].RecordType < endpoints[j].RecordType })
+			validateEndpoints(t, endpoints, tc.expected)
+		})
+	}
+}
@@ -0,0 +1,240 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package source
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/wait"
+	kubeinformers "k8s.io/client-go/informers"
+	coreinformers "k8s.io/client-go/informers/core/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+
+	"sigs.k8s.io/external-dns/endpoint"
+)
+
+// podSource is an implementation of Source for Kubernetes pods.
+// Pods carrying the hostname annotation are published with their pod IPs, or the IP
+// of their node if they use the host network. Targets of pods sharing a hostname are aggregated.
+// Use targetAnnotationKey to explicitly set Endpoint.
+type podSource struct {
+	client           kubernetes.Interface
+	namespace        string
+	annotationFilter string
+	podInformer      coreinformers.PodInformer
+}
+
+// NewPodSource creates a new podSource with the given config.
+func NewPodSource(kubeClient kubernetes.Interface, namespace string, annotationFilter string) (Source, error) {
+	if _, err := getLabelSelector(annotationFilter); err != nil {
+		return nil, err
+	}
+
+	// Use shared informers to listen for add/update/delete of pods in the specified namespace.
+	// Set resync period to 0, to prevent processing when nothing has changed
+	informerFactory := kubeinformers.NewSharedInformerFactoryWithOptions(kubeClient, 0, kubeinformers.WithNamespace(namespace))
+	podInformer := informerFactory.Core().V1().Pods()
+
+	// Add default resource event handler to properly initialize informer.
+	podInformer.Informer().AddEventHandler(
+		cache.ResourceEventHandlerFuncs{
+			AddFunc: func(obj interface{}) {
+				log.Debug("pod added")
+			},
+		},
+	)
+
+	// TODO informer is not explicitly stopped since controller is not passing in its channel.
+	informerFactory.Start(wait.NeverStop)
+
+	// wait for the local cache to be populated.
+	err := poll(time.Second, 60*time.Second, func() (bool, error) {
+		return podInformer.Informer().HasSynced(), nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to sync cache: %v", err)
+	}
+
+	return &podSource{
+		client:           kubeClient,
+		namespace:        namespace,
+		annotationFilter: annotationFilter,
+		podInformer:      podInformer,
+	}, nil
+}
+
+// Endpoints returns endpoint objects for each hostname annotated on Ready pods.
+func (ps *podSource) Endpoints(ctx context.Context) ([]*endpoint.Endpoint, error) {
+	selector, err := getLabelSelector(ps.annotationFilter)
+	if err != nil {
+		return nil, err
+	}
+
+	pods, err := ps.podInformer.Lister().Pods(ps.namespace).List(labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+
+	// process pods in a stable order, the settings of the first pod publishing a hostname apply to the whole record
+	sort.Slice(pods, func(i, j int) bool {
+		if pods[i].Namespace != pods[j].Namespace {
+			return pods[i].Namespace < pods[j].Namespace
+		}
+		return pods[i].Name < pods[j].Name
+	})
+
+	endpoints := map[string]*endpoint.Endpoint{}
+
+	for _, pod := range pods {
+		hostnames := getHostnamesFromAnnotations(pod.Annotations)
+		if len(hostnames) == 0 {
+			continue
+		}
+
+		// include pod if its annotations match the selector
+		if !selector.Empty() && !matchLabelSelector(selector, pod.Annotations) {
+			continue
+		}
+		// Check controller annotation to see if we are responsible.
+		controller, ok := pod.Annotations[controllerAnnotationKey]
+		if ok && controller != controllerAnnotationValue {
+			log.Debugf("Skipping pod %s/%s because controller value does not match, found: %s, required: %s",
+				pod.Namespace, pod.Name, controller, controllerAnnotationValue)
+			continue
+		}
+
+		if !podReady(pod) {
+			log.Debugf("Skipping pod %s/%s because it is not Ready", pod.Namespace, pod.Name)
+			continue
+		}
+
+		targets := getTargetsFromTargetAnnotation(pod.Annotations)
+		if len(targets) == 0 {
+			targets = podTargets(pod)
+		}
+		if len(targets) == 0 {
+			log.Debugf("Skipping pod %s/%s because it has no IP", pod.Namespace, pod.Name)
+			continue
+		}
+
+		ttl, err := getTTLFromAnnotations(pod.Annotations)
+		if err != nil {
+			log.Warn(err)
+		}
+
+		for _, hostname := range hostnames {
+			for _, target := range targets {
+				recordType := nodeTargetType(target)
+				key := hostname + "/" + recordType
+				if ep, ok := endpoints[key]; ok {
+					ep.Targets = appendUniqueTargets(ep.Targets, target)
+					continue
+				}
+				log.Debugf("creating endpoint %s for pod %s/%s", hostname, pod.Namespace, pod.Name)
+				endpoints[key] = endpoint.NewEndpointWithTTL(hostname, recordType, ttl, target)
+				setMergeLabel(pod.Annotations, []*endpoint.Endpoint{endpoints[key]})
+			}
+		}
+	}
+
+	endpointsSlice := []*endpoint.Endpoint{}
+	for _, ep := range endpoints {
+		sort.Sort(ep.Targets)
+		endpointsSlice = append(endpointsSlice, ep)
+	}
+	sort.Slice(endpointsSlice, func(i, j int) bool {
+		if endpointsSlice[i].DNSName != endpointsSlice[j].DNSName {
+			return endpointsSlice[i].DNSName < endpointsSlice[j].DNSName
+		}
+		return endpointsSlice[i].RecordType < endpointsSlice[j].RecordType
+	})
+
+	return endpointsSlice, nil
+}
+
+func (ps *podSource) AddEventHandler(ctx context.Context, handler func()) {
+	log.Debug("Adding event handler for pod")
+
+	// Right now there is no way to remove event handler from informer, see:
+	// https://github.com/kubernetes/kubernetes/issues/79610
+	ps.podInformer.Informer().AddEventHandler(
+		cache.ResourceEventHandlerFuncs{
+			AddFunc: func(obj interface{}) {
+				handler()
+			},
+			UpdateFunc: func(old interface{}, new interface{}) {
+				// pods are updated frequently by their status, ignore updates which cannot change any endpoint
+				oldPod, okOld := old.(*v1.Pod)
+				newPod, okNew := new.(*v1.Pod)
+				if okOld && okNew && !podChanged(oldPod, newPod) {
+					return
+				}
+				handler()
+			},
+			DeleteFunc: func(obj interface{}) {
+				handler()
+			},
+		},
+	)
+}
+
+// podChanged reports whether a pod changed in a way which may affect its endpoints
+func podChanged(old, new *v1.Pod) bool {
+	return !reflect.DeepEqual(old.Annotations, new.Annotations) ||
+		!reflect.DeepEqual(podTargets(old), podTargets(new)) ||
+		podReady(old) != podReady(new)
+}
+
+// podTargets returns the IPs of a pod, which is the IP of its node for pods using the host network
+func podTargets(pod *v1.Pod) endpoint.Targets {
+	if pod.Spec.HostNetwork {
+		if pod.Status.HostIP == "" {
+			return nil
+		}
+		return endpoint.Targets{pod.Status.HostIP}
+	}
+
+	var targets endpoint.Targets
+	for _, ip := range pod.Status.PodIPs {
+		targets = append(targets, ip.IP)
+	}
+	if len(targets) == 0 && pod.Status.PodIP != "" {
+		targets = append(targets, pod.Status.PodIP)
+	}
+	return targets
+}
+
+// podReady reports whether a pod is running, not being deleted and Ready
+func podReady(pod *v1.Pod) bool {
+	if pod.DeletionTimestamp != nil || pod.Status.Phase != v1.PodRunning {
+		return false
+	}
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == v1.PodReady {
+			return condition.Status == v1.ConditionTrue
+		}
+	}
+	return false
+}
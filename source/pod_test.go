@@ -0,0 +1,187 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package source
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"sigs.k8s.io/external-dns/endpoint"
+)
+
+// Validates that podSource is a Source
+var _ Source = &podSource{}
+
+func newTestPod(namespace, name string, annotations map[string]string, hostNetwork bool, hostIP string, podIPs ...string) *v1.Pod {
+	pod := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name, Annotations: annotations},
+		Spec:       v1.PodSpec{HostNetwork: hostNetwork},
+		Status: v1.PodStatus{
+			Phase:      v1.PodRunning,
+			HostIP:     hostIP,
+			Conditions: []v1.PodCondition{{Type: v1.PodReady, Status: v1.ConditionTrue}},
+		},
+	}
+	for _, ip := range podIPs {
+		pod.Status.PodIPs = append(pod.Status.PodIPs, v1.PodIP{IP: ip})
+	}
+	if len(podIPs) > 0 {
+		pod.Status.PodIP = podIPs[0]
+	}
+	return pod
+}
+
+func TestPodSourceEndpoints(t *testing.T) {
+	notReady := newTestPod("default", "not-ready", map[string]string{hostnameAnnotationKey: "db.example.org"}, false, "10.0.0.3", "192.168.0.3")
+	notReady.Status.Conditions[0].Status = v1.ConditionFalse
+	pending := newTestPod("default", "pending", map[string]string{hostnameAnnotationKey: "db.example.org"}, false, "10.0.0.4", "192.168.0.4")
+	pending.Status.Phase = v1.PodPending
+
+	for _, ti := range []struct {
+		title            string
+		namespace        string
+		annotationFilter string
+		pods             []*v1.Pod
+		expected         []*endpoint.Endpoint
+	}{
+		{
+			title: "pod IPs of annotated pods",
+			pods: []*v1.Pod{
+				newTestPod("default", "db-0", map[string]string{hostnameAnnotationKey: "db-0.example.org,db.example.org"}, false, "10.0.0.1", "192.168.0.1", "fd00::1"),
+				newTestPod("default", "db-1", map[string]string{hostnameAnnotationKey: "db-1.example.org,db.example.org", ttlAnnotationKey: "60"}, false, "10.0.0.2", "192.168.0.2"),
+				newTestPod("default", "unannotated", nil, false, "10.0.0.5", "192.168.0.5"),
+			},
+			expected: []*endpoint.Endpoint{
+				{DNSName: "db-0.example.org", RecordType: endpoint.RecordTypeA, Targets: endpoint.Targets{"192.168.0.1"}},
+				{DNSName: "db-0.example.org", RecordType: endpoint.RecordTypeAAAA, Targets: endpoint.Targets{"fd00::1"}},
+				{DNSName: "db-1.example.org", RecordType: endpoint.RecordTypeA, Targets: endpoint.Targets{"192.168.0.2"}, RecordTTL: 60},
+				{DNSName: "db.example.org", RecordType: endpoint.RecordTypeA, Targets: endpoint.Targets{"192.168.0.1", "192.168.0.2"}},
+				{DNSName: "db.example.org", RecordType: endpoint.RecordTypeAAAA, Targets: endpoint.Targets{"fd00::1"}},
+			},
+		},
+		{
+			title: "host IP of pods using the host network",
+			pods: []*v1.Pod{
+				newTestPod("default", "agent-a", map[string]string{hostnameAnnotationKey: "agent.example.org"}, true, "10.0.0.1", "10.0.0.1"),
+				newTestPod("default", "agent-b", map[string]string{hostnameAnnotationKey: "agent.example.org"}, true, "10.0.0.2", "10.0.0.2"),
+			},
+			expected: []*endpoint.Endpoint{
+				{DNSName: "agent.example.org", RecordType: endpoint.RecordTypeA, Targets: endpoint.Targets{"10.0.0.1", "10.0.0.2"}},
+			},
+		},
+		{
+			title: "target annotation",
+			pods: []*v1.Pod{
+				newTestPod("default", "db-0", map[string]string{hostnameAnnotationKey: "db-0.example.org", targetAnnotationKey: "db-0.internal.example.org"}, false, "10.0.0.1", "192.168.0.1"),
+			},
+			expected: []*endpoint.Endpoint{
+				{DNSName: "db-0.example.org", RecordType: endpoint.RecordTypeCNAME, Targets: endpoint.Targets{"db-0.internal.example.org"}},
+			},
+		},
+		{
+			title: "pods which are not Ready or without IP are skipped",
+			pods: []*v1.Pod{
+				notReady,
+				pending,
+				newTestPod("default", "no-ip", map[string]string{hostnameAnnotationKey: "db.example.org"}, false, ""),
+			},
+			expected: []*endpoint.Endpoint{},
+		},
+		{
+			title:            "namespace, annotation and controller filters",
+			namespace:        "default",
+			annotationFilter: "team=dns",
+			pods: []*v1.Pod{
+				newTestPod("default", "match", map[string]string{hostnameAnnotationKey: "match.example.org", "team": "dns"}, false, "10.0.0.1", "192.168.0.1"),
+				newTestPod("other", "other-namespace", map[string]string{hostnameAnnotationKey: "ns.example.org", "team": "dns"}, false, "10.0.0.2", "192.168.0.2"),
+				newTestPod("default", "other-team", map[string]string{hostnameAnnotationKey: "web.example.org", "team": "web"}, false, "10.0.0.3", "192.168.0.3"),
+				newTestPod("default", "other-controller", map[string]string{hostnameAnnotationKey: "other.example.org", "team": "dns", controllerAnnotationKey: "other"}, false, "10.0.0.4", "192.168.0.4"),
+			},
+			expected: []*endpoint.Endpoint{
+				{DNSName: "match.example.org", RecordType: endpoint.RecordTypeA, Targets: endpoint.Targets{"192.168.0.1"}},
+			},
+		},
+	} {
+		t.Run(ti.title, func(t *testing.T) {
+			kubeClient := fake.NewSimpleClientset()
+			for _, pod := range ti.pods {
+				_, err := kubeClient.CoreV1().Pods(pod.Namespace).Create(context.Background(), pod, metav1.CreateOptions{})
+				require.NoError(t, err)
+			}
+
+			source, err := NewPodSource(kubeClient, ti.namespace, ti.annotationFilter)
+			require.NoError(t, err)
+
+			endpoints, err := source.Endpoints(context.Background())
+			require.NoError(t, err)
+			validateEndpoints(t, endpoints, ti.expected)
+		})
+	}
+}
+
+func TestPodSourceMergeLabel(t *testing.T) {
+	kubeClient := fake.NewSimpleClientset()
+	for _, pod := range []*v1.Pod{
+		newTestPod("default", "db-0", map[string]string{hostnameAnnotationKey: "db.example.org", mergeAnnotationKey: "true"}, false, "10.0.0.1", "192.168.0.1"),
+		newTestPod("default", "web-0", map[string]string{hostnameAnnotationKey: "web.example.org"}, false, "10.0.0.2", "192.168.0.2"),
+	} {
+		_, err := kubeClient.CoreV1().Pods(pod.Namespace).Create(context.Background(), pod, metav1.CreateOptions{})
+		require.NoError(t, err)
+	}
+
+	source, err := NewPodSource(kubeClient, "", "")
+	require.NoError(t, err)
+	endpoints, err := source.Endpoints(context.Background())
+	require.NoError(t, err)
+	require.Len(t, endpoints, 2)
+	assert.Equal(t, "true", endpoints[0].Labels[endpoint.MergeLabelKey], endpoints[0].DNSName)
+	assert.Empty(t, endpoints[1].Labels[endpoint.MergeLabelKey], endpoints[1].DNSName)
+}
+
+func TestPodSourceEventHandler(t *testing.T) {
+	kubeClient := fake.NewSimpleClientset()
+	source, err := NewPodSource(kubeClient, "", "")
+	require.NoError(t, err)
+
+	events := make(chan struct{}, 10)
+	source.AddEventHandler(context.Background(), func() { events <- struct{}{} })
+
+	pod := newTestPod("default", "db-0", map[string]string{hostnameAnnotationKey: "db-0.example.org"}, false, "10.0.0.1", "192.168.0.1")
+	pod, err = kubeClient.CoreV1().Pods("default").Create(context.Background(), pod, metav1.CreateOptions{})
+	require.NoError(t, err)
+	expectEvent(t, events, true)
+
+	// status updates which don't affect the endpoints are ignored
+	pod.Status.ContainerStatuses = []v1.ContainerStatus{{Name: "db", RestartCount: 1}}
+	pod, err = kubeClient.CoreV1().Pods("default").UpdateStatus(context.Background(), pod, metav1.UpdateOptions{})
+	require.NoError(t, err)
+	expectEvent(t, events, false)
+
+	pod.Status.Conditions[0].Status = v1.ConditionFalse
+	_, err = kubeClient.CoreV1().Pods("default").UpdateStatus(context.Background(), pod, metav1.UpdateOptions{})
+	require.NoError(t, err)
+	expectEvent(t, events, true)
+
+	assert.NoError(t, kubeClient.CoreV1().Pods("default").Delete(context.Background(), "db-0", metav1.DeleteOptions{}))
+	expectEvent(t, events, true)
+}
@@ -21,17 +21,21 @@ import (
 	"context"
 	"fmt"
 	"sort"
+	"strconv"
 	"strings"
 	"text/template"
 	"time"
 
 	log "github.com/sirupsen/logrus"
 	v1 "k8s.io/api/core/v1"
+	discoveryv1beta1 "k8s.io/api/discovery/v1beta1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/intstr"
 	"k8s.io/apimachinery/pkg/util/wait"
 	kubeinformers "k8s.io/client-go/informers"
 	coreinformers "k8s.io/client-go/informers/core/v1"
+	discoveryinformers "k8s.io/client-go/informers/discovery/v1beta1"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/tools/cache"
 
@@ -61,14 +65,19 @@ type serviceSource struct {
 	publishHostIP                  bool
 	alwaysPublishNotReadyAddresses bool
 	serviceInformer                coreinformers.ServiceInformer
-	endpointsInformer              coreinformers.EndpointsInformer
-	podInformer                    coreinformers.PodInformer
 	nodeInformer                   coreinformers.NodeInformer
-	serviceTypeFilter              map[string]struct{}
+	// endpointsInformer and podInformer are only set if headless services are published from Endpoints
+	endpointsInformer coreinformers.EndpointsInformer
+	podInformer       coreinformers.PodInformer
+	// endpointSliceInformer is only set if headless services are published from EndpointSlices
+	endpointSliceInformer discoveryinformers.EndpointSliceInformer
+	serviceTypeFilter     map[string]struct{}
 }
 
 // NewServiceSource creates a new serviceSource with the given config.
-func NewServiceSource(kubeClient kubernetes.Interface, namespace, annotationFilter string, fqdnTemplate string, combineFqdnAnnotation bool, compatibility string, publishInternal bool, publishHostIP bool, alwaysPublishNotReadyAddresses bool, serviceTypeFilter []string, ignoreHostnameAnnotation bool) (Source, error) {
+// Headless services are published from EndpointSlices instead of Endpoints if useEndpointSlices is set,
+// in which case neither Endpoints nor Pods are watched.
+func NewServiceSource(kubeClient kubernetes.Interface, namespace, annotationFilter string, fqdnTemplate string, combineFqdnAnnotation bool, compatibility string, publishInternal bool, publishHostIP bool, alwaysPublishNotReadyAddresses bool, serviceTypeFilter []string, ignoreHostnameAnnotation bool, useEndpointSlices bool) (Source, error) {
 	var (
 		tmpl *template.Template
 		err  error
@@ -86,8 +95,6 @@ func NewServiceSource(kubeClient kubernetes.Interface, namespace, annotationFilt
 	// Set resync period to 0, to prevent processing when nothing has changed
 	informerFactory := kubeinformers.NewSharedInformerFactoryWithOptions(kubeClient, 0, kubeinformers.WithNamespace(namespace))
 	serviceInformer := informerFactory.Core().V1().Services()
-	endpointsInformer := informerFactory.Core().V1().Endpoints()
-	podInformer := informerFactory.Core().V1().Pods()
 	nodeInformer := informerFactory.Core().V1().Nodes()
 
 	// Add default resource event handlers to properly initialize informer.
@@ -97,24 +104,41 @@ func NewServiceSource(kubeClient kubernetes.Interface, namespace, annotationFilt
 			},
 		},
 	)
-	endpointsInformer.Informer().AddEventHandler(
+	nodeInformer.Informer().AddEventHandler(
 		cache.ResourceEventHandlerFuncs{
 			AddFunc: func(obj interface{}) {
 			},
 		},
 	)
-	podInformer.Informer().AddEventHandler(
-		cache.ResourceEventHandlerFuncs{
-			AddFunc: func(obj interface{}) {
-			},
-		},
+	var (
+		endpointsInformer     coreinformers.EndpointsInformer
+		podInformer           coreinformers.PodInformer
+		endpointSliceInformer discoveryinformers.EndpointSliceInformer
 	)
-	nodeInformer.Informer().AddEventHandler(
-		cache.ResourceEventHandlerFuncs{
-			AddFunc: func(obj interface{}) {
+	if useEndpointSlices {
+		endpointSliceInformer = informerFactory.Discovery().V1beta1().EndpointSlices()
+		endpointSliceInformer.Informer().AddEventHandler(
+			cache.ResourceEventHandlerFuncs{
+				AddFunc: func(obj interface{}) {
+				},
 			},
-		},
-	)
+		)
+	} else {
+		endpointsInformer = informerFactory.Core().V1().Endpoints()
+		endpointsInformer.Informer().AddEventHandler(
+			cache.ResourceEventHandlerFuncs{
+				AddFunc: func(obj interface{}) {
+				},
+			},
+		)
+		podInformer = informerFactory.Core().V1().Pods()
+		podInformer.Informer().AddEventHandler(
+			cache.ResourceEventHandlerFuncs{
+				AddFunc: func(obj interface{}) {
+				},
+			},
+		)
+	}
 
 	// TODO informer is not explicitly stopped since controller is not passing in its channel.
 	informerFactory.Start(wait.NeverStop)
@@ -122,9 +146,10 @@ func NewServiceSource(kubeClient kubernetes.Interface, namespace, annotationFilt
 	// wait for the local cache to be populated.
 	err = poll(time.Second, 60*time.Second, func() (bool, error) {
 		return serviceInformer.Informer().HasSynced() &&
-			endpointsInformer.Informer().HasSynced() &&
-			podInformer.Informer().HasSynced() &&
-			nodeInformer.Informer().HasSynced(), nil
+			nodeInformer.Informer().HasSynced() &&
+			(endpointsInformer == nil || endpointsInformer.Informer().HasSynced()) &&
+			(podInformer == nil || podInformer.Informer().HasSynced()) &&
+			(endpointSliceInformer == nil || endpointSliceInformer.Informer().HasSynced()), nil
 	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to sync cache: %v", err)
@@ -152,6 +177,7 @@ func NewServiceSource(kubeClient kubernetes.Interface, namespace, annotationFilt
 		endpointsInformer:              endpointsInformer,
 		podInformer:                    podInformer,
 		nodeInformer:                   nodeInformer,
+		endpointSliceInformer:          endpointSliceInformer,
 		serviceTypeFilter:              serviceTypes,
 	}, nil
 }
@@ -211,6 +237,7 @@ func (sc *serviceSource) Endpoints(ctx context.Context) ([]*endpoint.Endpoint, e
 
 		log.Debugf("Endpoints generated from service: %s/%s: %v", svc.Namespace, svc.Name, svcEndpoints)
 		sc.setResourceLabel(svc, svcEndpoints)
+		setMergeLabel(svc.Annotations, svcEndpoints)
 		endpoints = append(endpoints, svcEndpoints...)
 	}
 
@@ -349,6 +376,150 @@ func (sc *serviceSource) extractHeadlessEndpoints(svc *v1.Service, hostname stri
 	return endpoints
 }
 
+// extractHeadlessEndpointSliceEndpoints extracts endpoints from a headless service using its EndpointSlices.
+// Besides the names of the service and its pods, a name per topology zone is published if the service
+// is annotated with zoneHostnamesAnnotationKey.
+func (sc *serviceSource) extractHeadlessEndpointSliceEndpoints(svc *v1.Service, hostname string, ttl endpoint.TTL) []*endpoint.Endpoint {
+	var endpoints []*endpoint.Endpoint
+
+	slices, err := sc.endpointSliceInformer.Lister().EndpointSlices(svc.Namespace).List(
+		labels.SelectorFromSet(labels.Set{discoveryv1beta1.LabelServiceName: svc.Name}))
+	if err != nil {
+		log.Errorf("List EndpointSlices of service[%s] error:%v", svc.GetName(), err)
+		return endpoints
+	}
+
+	publishNotReady := svc.Spec.PublishNotReadyAddresses || sc.alwaysPublishNotReadyAddresses
+	zoneHostnames := svc.Annotations[zoneHostnamesAnnotationKey] == "true"
+
+	// targets by record type and headless domain
+	targetsByHeadlessDomain := map[string]map[string]endpoint.Targets{
+		endpoint.RecordTypeA:    {},
+		endpoint.RecordTypeAAAA: {},
+	}
+	for _, slice := range slices {
+		var recordType string
+		switch slice.AddressType {
+		case discoveryv1beta1.AddressTypeIPv4:
+			recordType = endpoint.RecordTypeA
+		case discoveryv1beta1.AddressTypeIPv6:
+			recordType = endpoint.RecordTypeAAAA
+		default:
+			log.Debugf("Skipping EndpointSlice %s/%s with address type %s", slice.Namespace, slice.Name, slice.AddressType)
+			continue
+		}
+
+		for _, ep := range slice.Endpoints {
+			// an unknown readiness is to be interpreted as ready
+			if !publishNotReady && ep.Conditions.Ready != nil && !*ep.Conditions.Ready {
+				continue
+			}
+
+			headlessDomains := []string{hostname}
+			if ep.Hostname != nil && *ep.Hostname != "" {
+				headlessDomains = append(headlessDomains, fmt.Sprintf("%s.%s", *ep.Hostname, hostname))
+			}
+			if zone := ep.Topology[v1.LabelZoneFailureDomainStable]; zoneHostnames && zone != "" {
+				headlessDomains = append(headlessDomains, fmt.Sprintf("%s.%s", zone, hostname))
+			}
+
+			addresses := ep.Addresses
+			addressType := recordType
+			if sc.publishHostIP {
+				if ep.TargetRef == nil || ep.TargetRef.Kind != "Pod" {
+					log.Debugf("Skipping endpoint because its target is not a pod: %v", ep)
+					continue
+				}
+				// the host IP of a pod is the internal IP of its node
+				hostIP := ""
+				if node, err := sc.nodeOfEndpoint(ep); err == nil {
+					hostIP = nodeInternalIP(node)
+				}
+				if hostIP == "" {
+					log.Errorf("Node of pod %s not found for endpoint %v", ep.TargetRef.Name, ep)
+					continue
+				}
+				addresses = []string{hostIP}
+				addressType = nodeTargetType(hostIP)
+			}
+
+			for _, headlessDomain := range headlessDomains {
+				log.Debugf("Generating matching endpoint %s with addresses %v", headlessDomain, addresses)
+				targetsByHeadlessDomain[addressType][headlessDomain] = appendUniqueTargets(targetsByHeadlessDomain[addressType][headlessDomain], addresses...)
+			}
+		}
+	}
+
+	for _, recordType := range []string{endpoint.RecordTypeA, endpoint.RecordTypeAAAA} {
+		headlessDomains := []string{}
+		for headlessDomain := range targetsByHeadlessDomain[recordType] {
+			headlessDomains = append(headlessDomains, headlessDomain)
+		}
+		sort.Strings(headlessDomains)
+		for _, headlessDomain := range headlessDomains {
+			endpoints = append(endpoints, endpoint.NewEndpointWithTTL(headlessDomain, recordType, ttl, targetsByHeadlessDomain[recordType][headlessDomain]...))
+		}
+	}
+
+	return endpoints
+}
+
+// nodeOfEndpoint returns the node an endpoint of an EndpointSlice runs on, which is identified by the
+// kubernetes.io/hostname label the EndpointSlice controller copies into the topology of the endpoint.
+func (sc *serviceSource) nodeOfEndpoint(ep discoveryv1beta1.Endpoint) (*v1.Node, error) {
+	hostname := ep.Topology[v1.LabelHostname]
+	if hostname == "" {
+		return nil, fmt.Errorf("no node given by the topology of endpoint %v", ep.Addresses)
+	}
+	nodes, err := sc.nodeInformer.Lister().List(labels.SelectorFromSet(labels.Set{v1.LabelHostname: hostname}))
+	if err != nil {
+		return nil, err
+	}
+	if len(nodes) != 1 {
+		return nil, fmt.Errorf("found %d nodes with hostname %s", len(nodes), hostname)
+	}
+	return nodes[0], nil
+}
+
+// nodeInternalIP returns the first internal IP of a node, or an empty string if it has none
+func nodeInternalIP(node *v1.Node) string {
+	for _, address := range node.Status.Addresses {
+		if address.Type == v1.NodeInternalIP {
+			return address.Address
+		}
+	}
+	return ""
+}
+
+// nodesOfEndpointSlices returns the nodes running the ready endpoints of a service
+func (sc *serviceSource) nodesOfEndpointSlices(svc *v1.Service) ([]*v1.Node, error) {
+	slices, err := sc.endpointSliceInformer.Lister().EndpointSlices(svc.Namespace).List(
+		labels.SelectorFromSet(labels.Set{discoveryv1beta1.LabelServiceName: svc.Name}))
+	if err != nil {
+		return nil, err
+	}
+
+	var nodes []*v1.Node
+	seen := map[string]bool{}
+	for _, slice := range slices {
+		for _, ep := range slice.Endpoints {
+			if ep.Conditions.Ready != nil && !*ep.Conditions.Ready {
+				continue
+			}
+			node, err := sc.nodeOfEndpoint(ep)
+			if err != nil {
+				log.Debugf("Unable to find node of endpoint %v of service %s/%s: %v", ep.Addresses, svc.Namespace, svc.Name, err)
+				continue
+			}
+			if !seen[node.Name] {
+				seen[node.Name] = true
+				nodes = append(nodes, node)
+			}
+		}
+	}
+	return nodes, nil
+}
+
 func (sc *serviceSource) endpointsFromTemplate(svc *v1.Service) ([]*endpoint.Endpoint, error) {
 	var endpoints []*endpoint.Endpoint
 
@@ -466,20 +637,36 @@ func (sc *serviceSource) generateEndpoints(svc *v1.Service, hostname string, pro
 			targets = append(targets, extractServiceIps(svc)...)
 		}
 		if svc.Spec.ClusterIP == v1.ClusterIPNone {
-			endpoints = append(endpoints, sc.extractHeadlessEndpoints(svc, hostname, ttl)...)
+			if sc.endpointSliceInformer != nil {
+				endpoints = append(endpoints, sc.extractHeadlessEndpointSliceEndpoints(svc, hostname, ttl)...)
+			} else {
+				endpoints = append(endpoints, sc.extractHeadlessEndpoints(svc, hostname, ttl)...)
+			}
 		}
 	case v1.ServiceTypeNodePort:
-		// add the nodeTargets and extract an SRV endpoint
+		// add the nodeTargets and extract an SRV endpoint if requested
 		targets, err = sc.extractNodePortTargets(svc)
 		if err != nil {
 			log.Errorf("Unable to extract targets from service %s/%s error: %v", svc.Namespace, svc.Name, err)
 			return endpoints
 		}
-		endpoints = append(endpoints, sc.extractNodePortEndpoints(svc, targets, hostname, ttl)...)
+		if svc.Annotations[srvRecordsAnnotationKey] == "true" {
+			endpoints = append(endpoints, sc.extractNodePortEndpoints(svc, targets, hostname, ttl)...)
+		}
 	case v1.ServiceTypeExternalName:
 		targets = append(targets, extractServiceExternalName(svc)...)
 	}
 
+	if svc.Annotations[srvRecordsAnnotationKey] == "true" {
+		switch {
+		case svc.Spec.Type == v1.ServiceTypeClusterIP && svc.Spec.ClusterIP != v1.ClusterIPNone && !sc.publishInternal:
+			// the records would point to the hostname of the service, which is not published
+			log.Debugf("Skipping SRV records of service %s/%s, its cluster IP is not published", svc.Namespace, svc.Name)
+		case svc.Spec.Type == v1.ServiceTypeClusterIP, svc.Spec.Type == v1.ServiceTypeLoadBalancer:
+			endpoints = append(endpoints, sc.extractNamedPortSRVEndpoints(svc, hostname, ttl, endpoints)...)
+		}
+	}
+
 	for _, t := range targets {
 		if suitableType(t) == endpoint.RecordTypeA {
 			epA.Targets = append(epA.Targets, t)
@@ -551,8 +738,13 @@ func (sc *serviceSource) extractNodePortTargets(svc *v1.Service) (endpoint.Targe
 		err         error
 	)
 
-	switch svc.Spec.ExternalTrafficPolicy {
-	case v1.ServiceExternalTrafficPolicyTypeLocal:
+	switch {
+	case svc.Spec.ExternalTrafficPolicy == v1.ServiceExternalTrafficPolicyTypeLocal && sc.endpointSliceInformer != nil:
+		nodes, err = sc.nodesOfEndpointSlices(svc)
+		if err != nil {
+			return nil, err
+		}
+	case svc.Spec.ExternalTrafficPolicy == v1.ServiceExternalTrafficPolicyTypeLocal:
 		nodesMap := map[*v1.Node]struct{}{}
 		labelSelector, err := metav1.ParseToLabelSelector(labels.Set(svc.Spec.Selector).AsSelectorPreValidated().String())
 		if err != nil {
@@ -647,6 +839,115 @@ func (sc *serviceSource) extractNodePortEndpoints(svc *v1.Service, nodeTargets e
 	return endpoints
 }
 
+// extractNamedPortSRVEndpoints builds a _port._proto.hostname SRV record for each named port of a service.
+// The records of headless services point to the names of the individual pods, given in headlessEndpoints,
+// if there are any.
+func (sc *serviceSource) extractNamedPortSRVEndpoints(svc *v1.Service, hostname string, ttl endpoint.TTL, headlessEndpoints []*endpoint.Endpoint) []*endpoint.Endpoint {
+	var endpoints []*endpoint.Endpoint
+
+	priority := getSRVValueFromAnnotations(svc.Annotations, srvPriorityAnnotationKey, 0)
+	weight := getSRVValueFromAnnotations(svc.Annotations, srvWeightAnnotationKey, 50)
+	headless := svc.Spec.ClusterIP == v1.ClusterIPNone
+
+	hosts := []string{}
+	var endpointPorts map[string]int32
+	if headless {
+		endpointPorts = sc.endpointPorts(svc)
+		for _, ep := range headlessEndpoints {
+			if ep.DNSName != hostname && strings.HasSuffix(ep.DNSName, "."+hostname) {
+				hosts = append(hosts, ep.DNSName)
+			}
+		}
+	}
+	if len(hosts) == 0 {
+		hosts = append(hosts, hostname)
+	}
+
+	for _, port := range svc.Spec.Ports {
+		if port.Name == "" {
+			continue
+		}
+
+		// clients of headless services connect to the pods directly, whose ports are given by
+		// the endpoints of the service, which resolve named target ports
+		portNumber := port.Port
+		if headless {
+			switch {
+			case endpointPorts[port.Name] > 0:
+				portNumber = endpointPorts[port.Name]
+			case port.TargetPort.Type == intstr.Int && port.TargetPort.IntVal > 0:
+				portNumber = port.TargetPort.IntVal
+			case port.TargetPort.Type == intstr.String && port.TargetPort.StrVal != "":
+				log.Debugf("Skipping SRV record of port %s of service %s/%s, its target port %s is not resolved by any endpoint", port.Name, svc.Namespace, svc.Name, port.TargetPort.StrVal)
+				continue
+			}
+		}
+
+		protocol := strings.ToLower(string(port.Protocol))
+		if protocol == "" {
+			protocol = "tcp"
+		}
+
+		var targets endpoint.Targets
+		for _, host := range hosts {
+			targets = appendUniqueTargets(targets, fmt.Sprintf("%d %d %d %s", priority, weight, portNumber, host))
+		}
+		sort.Sort(targets)
+
+		recordName := fmt.Sprintf("_%s._%s.%s", port.Name, protocol, hostname)
+		endpoints = append(endpoints, endpoint.NewEndpointWithTTL(recordName, endpoint.RecordTypeSRV, ttl, targets...))
+	}
+
+	return endpoints
+}
+
+// endpointPorts returns the ports of the endpoints of a service by the names of the service ports
+func (sc *serviceSource) endpointPorts(svc *v1.Service) map[string]int32 {
+	ports := map[string]int32{}
+	if sc.endpointSliceInformer != nil {
+		slices, err := sc.endpointSliceInformer.Lister().EndpointSlices(svc.Namespace).List(
+			labels.SelectorFromSet(labels.Set{discoveryv1beta1.LabelServiceName: svc.Name}))
+		if err != nil {
+			log.Errorf("List EndpointSlices of service[%s] error:%v", svc.GetName(), err)
+			return ports
+		}
+		for _, slice := range slices {
+			for _, port := range slice.Ports {
+				if port.Name != nil && port.Port != nil {
+					ports[*port.Name] = *port.Port
+				}
+			}
+		}
+		return ports
+	}
+
+	endpointsObject, err := sc.endpointsInformer.Lister().Endpoints(svc.Namespace).Get(svc.GetName())
+	if err != nil {
+		log.Debugf("Get endpoints of service[%s] error:%v", svc.GetName(), err)
+		return ports
+	}
+	for _, subset := range endpointsObject.Subsets {
+		for _, port := range subset.Ports {
+			ports[port.Name] = port.Port
+		}
+	}
+	return ports
+}
+
+// getSRVValueFromAnnotations reads the priority or weight of SRV records from an annotation
+func getSRVValueFromAnnotations(annotations map[string]string, key string, defaultValue uint16) uint16 {
+	value, exists := annotations[key]
+	if !exists {
+		return defaultValue
+	}
+	parsed, err := strconv.ParseUint(value, 10, 16)
+	if err != nil {
+		log.Warnf("Ignoring invalid value %q of annotation %s: %v", value, key, err)
+		return defaultValue
+	}
+	return uint16(parsed)
+}
+
 func (sc *serviceSource) AddEventHandler(ctx context.Context, handler func()) {
 	log.Debug("Adding event handler for service")
 
@@ -665,4 +966,19 @@ func (sc *serviceSource) AddEventHandler(ctx context.Context, handler func()) {
 			},
 		},
 	)
+	if sc.endpointSliceInformer != nil {
+		sc.endpointSliceInformer.Informer().AddEventHandler(
+			cache.ResourceEventHandlerFuncs{
+				AddFunc: func(obj interface{}) {
+					handler()
+				},
+				UpdateFunc: func(old interface{}, new interface{}) {
+					handler()
+				},
+				DeleteFunc: func(obj interface{}) {
+					handler()
+				},
+			},
+		)
+	}
 }
@@ -18,6 +18,7 @@ package source
 
 import (
 	"context"
+	"fmt"
 	"net"
 	"sort"
 	"strings"
@@ -28,7 +29,9 @@ import (
 	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
 	v1 "k8s.io/api/core/v1"
+	discoveryv1beta1 "k8s.io/api/discovery/v1beta1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
 	"k8s.io/client-go/kubernetes/fake"
 
 	"sigs.k8s.io/external-dns/endpoint"
@@ -56,6 +59,7 @@ func (suite *ServiceSuite) SetupTest() {
 		false,
 		[]string{},
 		false,
+		false,
 	)
 	suite.fooWithTargets = &v1.Service{
 		Spec: v1.ServiceSpec{
@@ -150,6 +154,7 @@ func testServiceSourceNewServiceSource(t *testing.T) {
 				false,
 				ti.serviceTypesFilter,
 				false,
+				false,
 			)
 
 			if ti.expectError {
@@ -1180,6 +1185,7 @@ func testServiceSourceEndpoints(t *testing.T) {
 				false,
 				tc.serviceTypesFilter,
 				tc.ignoreHostnameAnnotation,
+				false,
 			)
 			require.NoError(t, err)
 
@@ -1352,6 +1358,7 @@ func testMultipleServicesEndpoints(t *testing.T) {
 				false,
 				tc.serviceTypesFilter,
 				tc.ignoreHostnameAnnotation,
+				false,
 			)
 			require.NoError(t, err)
 
@@ -1536,6 +1543,7 @@ func TestClusterIpServices(t *testing.T) {
 				false,
 				[]string{},
 				tc.ignoreHostnameAnnotation,
+				false,
 			)
 			require.NoError(t, err)
 
@@ -1591,6 +1599,52 @@ func TestNodePortServices(t *testing.T) {
 				hostnameAnnotationKey: "foo.example.org.",
 			},
 			nil,
+			[]*endpoint.Endpoint{
+				{DNSName: "foo.example.org", Targets: endpoint.Targets{"54.10.11.1", "54.10.11.2"}, RecordType: endpoint.RecordTypeA},
+			},
+			false,
+			[]*v1.Node{{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "node1",
+				},
+				Status: v1.NodeStatus{
+					Addresses: []v1.NodeAddress{
+						{Type: v1.NodeExternalIP, Address: "54.10.11.1"},
+						{Type: v1.NodeInternalIP, Address: "10.0.1.1"},
+					},
+				},
+			}, {
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "node2",
+				},
+				Status: v1.NodeStatus{
+					Addresses: []v1.NodeAddress{
+						{Type: v1.NodeExternalIP, Address: "54.10.11.2"},
+						{Type: v1.NodeInternalIP, Address: "10.0.1.2"},
+					},
+				},
+			}},
+			[]string{},
+			[]int{},
+			[]v1.PodPhase{},
+		},
+		{
+			"NodePort services annotated for SRV records return an SRV endpoint for the node port",
+			"",
+			"",
+			"testing",
+			"foo",
+			v1.ServiceTypeNodePort,
+			v1.ServiceExternalTrafficPolicyTypeCluster,
+			"",
+			"",
+			false,
+			map[string]string{},
+			map[string]string{
+				hostnameAnnotationKey:   "foo.example.org.",
+				srvRecordsAnnotationKey: "true",
+			},
+			nil,
 			[]*endpoint.Endpoint{
 				{DNSName: "_30192._tcp.foo.example.org", Targets: endpoint.Targets{"0 50 30192 foo.example.org"}, RecordType: endpoint.RecordTypeSRV},
 				{DNSName: "foo.example.org", Targets: endpoint.Targets{"54.10.11.1", "54.10.11.2"}, RecordType: endpoint.RecordTypeA},
@@ -1679,7 +1733,6 @@ func TestNodePortServices(t *testing.T) {
 			map[string]string{},
 			nil,
 			[]*endpoint.Endpoint{
-				{DNSName: "_30192._tcp.foo.bar.example.com", Targets: endpoint.Targets{"0 50 30192 foo.bar.example.com"}, RecordType: endpoint.RecordTypeSRV},
 				{DNSName: "foo.bar.example.com", Targets: endpoint.Targets{"54.10.11.1", "54.10.11.2"}, RecordType: endpoint.RecordTypeA},
 			},
 			false,
@@ -1725,7 +1778,6 @@ func TestNodePortServices(t *testing.T) {
 			},
 			nil,
 			[]*endpoint.Endpoint{
-				{DNSName: "_30192._tcp.foo.example.org", Targets: endpoint.Targets{"0 50 30192 foo.example.org"}, RecordType: endpoint.RecordTypeSRV},
 				{DNSName: "foo.example.org", Targets: endpoint.Targets{"10.0.1.1", "10.0.1.2"}, RecordType: endpoint.RecordTypeA},
 			},
 			false,
@@ -1769,7 +1821,6 @@ func TestNodePortServices(t *testing.T) {
 			},
 			nil,
 			[]*endpoint.Endpoint{
-				{DNSName: "_30192._tcp.foo.example.org", Targets: endpoint.Targets{"0 50 30192 foo.example.org"}, RecordType: endpoint.RecordTypeSRV},
 				{DNSName: "foo.example.org", Targets: endpoint.Targets{"54.10.11.2"}, RecordType: endpoint.RecordTypeA},
 			},
 			false,
@@ -1815,7 +1866,6 @@ func TestNodePortServices(t *testing.T) {
 			},
 			nil,
 			[]*endpoint.Endpoint{
-				{DNSName: "_30192._tcp.foo.example.org", Targets: endpoint.Targets{"0 50 30192 foo.example.org"}, RecordType: endpoint.RecordTypeSRV},
 				{DNSName: "foo.example.org", Targets: endpoint.Targets{"54.10.11.2"}, RecordType: endpoint.RecordTypeA},
 			},
 			false,
@@ -1862,7 +1912,6 @@ func TestNodePortServices(t *testing.T) {
 			},
 			nil,
 			[]*endpoint.Endpoint{
-				{DNSName: "_30192._tcp.foo.example.org", Targets: endpoint.Targets{"0 50 30192 foo.example.org"}, RecordType: endpoint.RecordTypeSRV},
 				{DNSName: "foo.example.org", Targets: endpoint.Targets{"10.0.1.1", "10.0.1.2"}, RecordType: endpoint.RecordTypeA},
 			},
 			false,
@@ -1909,7 +1958,6 @@ func TestNodePortServices(t *testing.T) {
 			},
 			nil,
 			[]*endpoint.Endpoint{
-				{DNSName: "_30192._tcp.foo.example.org", Targets: endpoint.Targets{"0 50 30192 foo.example.org"}, RecordType: endpoint.RecordTypeSRV},
 				{DNSName: "foo.example.org", Targets: endpoint.Targets{"54.10.11.1", "54.10.11.2"}, RecordType: endpoint.RecordTypeA},
 			},
 			false,
@@ -2008,6 +2056,7 @@ func TestNodePortServices(t *testing.T) {
 				false,
 				[]string{},
 				tc.ignoreHostnameAnnotation,
+				false,
 			)
 			require.NoError(t, err)
 
@@ -2337,6 +2386,7 @@ func TestHeadlessServices(t *testing.T) {
 				false,
 				[]string{},
 				tc.ignoreHostnameAnnotation,
+				false,
 			)
 			require.NoError(t, err)
 
@@ -2639,6 +2689,7 @@ func TestHeadlessServicesHostIP(t *testing.T) {
 				false,
 				[]string{},
 				tc.ignoreHostnameAnnotation,
+				false,
 			)
 			require.NoError(t, err)
 
@@ -2655,6 +2706,343 @@ func TestHeadlessServicesHostIP(t *testing.T) {
 	}
 }
 
+// TestHeadlessServicesEndpointSlices tests that headless services are published from their EndpointSlices.
+func TestHeadlessServicesEndpointSlices(t *testing.T) {
+	ready, notReady := true, false
+	hostname := func(s string) *string { return &s }
+
+	slices := []*discoveryv1beta1.EndpointSlice{
+		{
+			ObjectMeta:  metav1.ObjectMeta{Namespace: "testing", Name: "foo-ipv4", Labels: map[string]string{discoveryv1beta1.LabelServiceName: "foo"}},
+			AddressType: discoveryv1beta1.AddressTypeIPv4,
+			Endpoints: []discoveryv1beta1.Endpoint{
+				{
+					Addresses:  []string{"1.1.1.1"},
+					Conditions: discoveryv1beta1.EndpointConditions{Ready: &ready},
+					Hostname:   hostname("foo-0"),
+					TargetRef:  &v1.ObjectReference{Kind: "Pod", Name: "foo-0"},
+					Topology:   map[string]string{v1.LabelZoneFailureDomainStable: "zone-a", v1.LabelHostname: "node-1"},
+				},
+				{
+					Addresses: []string{"1.1.1.2"},
+					Hostname:  hostname("foo-1"),
+					TargetRef: &v1.ObjectReference{Kind: "Pod", Name: "foo-1"},
+					Topology:  map[string]string{v1.LabelZoneFailureDomainStable: "zone-b", v1.LabelHostname: "node-2"},
+				},
+				{
+					Addresses:  []string{"1.1.1.3"},
+					Conditions: discoveryv1beta1.EndpointConditions{Ready: &notReady},
+					Hostname:   hostname("foo-2"),
+					TargetRef:  &v1.ObjectReference{Kind: "Pod", Name: "foo-2"},
+					Topology:   map[string]string{v1.LabelZoneFailureDomainStable: "zone-a", v1.LabelHostname: "node-3"},
+				},
+			},
+		},
+		{
+			ObjectMeta:  metav1.ObjectMeta{Namespace: "testing", Name: "foo-ipv6", Labels: map[string]string{discoveryv1beta1.LabelServiceName: "foo"}},
+			AddressType: discoveryv1beta1.AddressTypeIPv6,
+			Endpoints: []discoveryv1beta1.Endpoint{
+				{
+					Addresses:  []string{"fd00::1"},
+					Conditions: discoveryv1beta1.EndpointConditions{Ready: &ready},
+					Hostname:   hostname("foo-0"),
+					TargetRef:  &v1.ObjectReference{Kind: "Pod", Name: "foo-0"},
+				},
+			},
+		},
+		{
+			ObjectMeta:  metav1.ObjectMeta{Namespace: "testing", Name: "bar-ipv4", Labels: map[string]string{discoveryv1beta1.LabelServiceName: "bar"}},
+			AddressType: discoveryv1beta1.AddressTypeIPv4,
+			Endpoints:   []discoveryv1beta1.Endpoint{{Addresses: []string{"2.2.2.2"}}},
+		},
+	}
+
+	for _, tc := range []struct {
+		title                    string
+		annotations              map[string]string
+		publishNotReadyAddresses bool
+		publishHostIP            bool
+		nodePort                 bool
+		expected                 []*endpoint.Endpoint
+	}{
+		{
+			title:       "ready endpoints of all address families",
+			annotations: map[string]string{hostnameAnnotationKey: "service.example.org"},
+			expected: []*endpoint.Endpoint{
+				{DNSName: "service.example.org", RecordType: endpoint.RecordTypeA, Targets: endpoint.Targets{"1.1.1.1", "1.1.1.2"}},
+				{DNSName: "foo-0.service.example.org", RecordType: endpoint.RecordTypeA, Targets: endpoint.Targets{"1.1.1.1"}},
+				{DNSName: "foo-1.service.example.org", RecordType: endpoint.RecordTypeA, Targets: endpoint.Targets{"1.1.1.2"}},
+				{DNSName: "service.example.org", RecordType: endpoint.RecordTypeAAAA, Targets: endpoint.Targets{"fd00::1"}},
+				{DNSName: "foo-0.service.example.org", RecordType: endpoint.RecordTypeAAAA, Targets: endpoint.Targets{"fd00::1"}},
+			},
+		},
+		{
+			title:                    "not ready endpoints are published on request",
+			annotations:              map[string]string{hostnameAnnotationKey: "service.example.org", ttlAnnotationKey: "60"},
+			publishNotReadyAddresses: true,
+			expected: []*endpoint.Endpoint{
+				{DNSName: "service.example.org", RecordType: endpoint.RecordTypeA, Targets: endpoint.Targets{"1.1.1.1", "1.1.1.2", "1.1.1.3"}, RecordTTL: 60},
+				{DNSName: "foo-0.service.example.org", RecordType: endpoint.RecordTypeA, Targets: endpoint.Targets{"1.1.1.1"}, RecordTTL: 60},
+				{DNSName: "foo-1.service.example.org", RecordType: endpoint.RecordTypeA, Targets: endpoint.Targets{"1.1.1.2"}, RecordTTL: 60},
+				{DNSName: "foo-2.service.example.org", RecordType: endpoint.RecordTypeA, Targets: endpoint.Targets{"1.1.1.3"}, RecordTTL: 60},
+				{DNSName: "service.example.org", RecordType: endpoint.RecordTypeAAAA, Targets: endpoint.Targets{"fd00::1"}, RecordTTL: 60},
+				{DNSName: "foo-0.service.example.org", RecordType: endpoint.RecordTypeAAAA, Targets: endpoint.Targets{"fd00::1"}, RecordTTL: 60},
+			},
+		},
+		{
+			title:       "names per topology zone",
+			annotations: map[string]string{hostnameAnnotationKey: "service.example.org", zoneHostnamesAnnotationKey: "true"},
+			expected: []*endpoint.Endpoint{
+				{DNSName: "service.example.org", RecordType: endpoint.RecordTypeA, Targets: endpoint.Targets{"1.1.1.1", "1.1.1.2"}},
+				{DNSName: "foo-0.service.example.org", RecordType: endpoint.RecordTypeA, Targets: endpoint.Targets{"1.1.1.1"}},
+				{DNSName: "foo-1.service.example.org", RecordType: endpoint.RecordTypeA, Targets: endpoint.Targets{"1.1.1.2"}},
+				{DNSName: "zone-a.service.example.org", RecordType: endpoint.RecordTypeA, Targets: endpoint.Targets{"1.1.1.1"}},
+				{DNSName: "zone-b.service.example.org", RecordType: endpoint.RecordTypeA, Targets: endpoint.Targets{"1.1.1.2"}},
+				{DNSName: "service.example.org", RecordType: endpoint.RecordTypeAAAA, Targets: endpoint.Targets{"fd00::1"}},
+				{DNSName: "foo-0.service.example.org", RecordType: endpoint.RecordTypeAAAA, Targets: endpoint.Targets{"fd00::1"}},
+			},
+		},
+		{
+			title:         "host IPs of the pods",
+			annotations:   map[string]string{hostnameAnnotationKey: "service.example.org"},
+			publishHostIP: true,
+			expected: []*endpoint.Endpoint{
+				{DNSName: "service.example.org", RecordType: endpoint.RecordTypeA, Targets: endpoint.Targets{"10.0.0.1", "10.0.0.2"}},
+				{DNSName: "foo-0.service.example.org", RecordType: endpoint.RecordTypeA, Targets: endpoint.Targets{"10.0.0.1"}},
+				{DNSName: "foo-1.service.example.org", RecordType: endpoint.RecordTypeA, Targets: endpoint.Targets{"10.0.0.2"}},
+			},
+		},
+		{
+			title:       "nodes of the ready endpoints of a NodePort service with local traffic policy",
+			annotations: map[string]string{hostnameAnnotationKey: "service.example.org"},
+			nodePort:    true,
+			expected: []*endpoint.Endpoint{
+				{DNSName: "service.example.org", RecordType: endpoint.RecordTypeA, Targets: endpoint.Targets{"10.0.0.1", "10.0.0.2"}},
+			},
+		},
+	} {
+		t.Run(tc.title, func(t *testing.T) {
+			kubernetes := fake.NewSimpleClientset()
+
+			service := &v1.Service{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "testing", Name: "foo", Annotations: tc.annotations},
+				Spec: v1.ServiceSpec{
+					Type:                     v1.ServiceTypeClusterIP,
+					ClusterIP:                v1.ClusterIPNone,
+					PublishNotReadyAddresses: tc.publishNotReadyAddresses,
+				},
+			}
+			if tc.nodePort {
+				service.Spec = v1.ServiceSpec{
+					Type:                  v1.ServiceTypeNodePort,
+					ExternalTrafficPolicy: v1.ServiceExternalTrafficPolicyTypeLocal,
+					Ports:                 []v1.ServicePort{{NodePort: 30080, Protocol: v1.ProtocolTCP}},
+				}
+			}
+			_, err := kubernetes.CoreV1().Services("testing").Create(context.Background(), service, metav1.CreateOptions{})
+			require.NoError(t, err)
+
+			for i, name := range []string{"node-1", "node-2", "node-3"} {
+				node := &v1.Node{
+					ObjectMeta: metav1.ObjectMeta{Name: name, Labels: map[string]string{v1.LabelHostname: name}},
+					Status:     v1.NodeStatus{Addresses: []v1.NodeAddress{{Type: v1.NodeInternalIP, Address: fmt.Sprintf("10.0.0.%d", i+1)}}},
+				}
+				_, err = kubernetes.CoreV1().Nodes().Create(context.Background(), node, metav1.CreateOptions{})
+				require.NoError(t, err)
+			}
+			for _, slice := range slices {
+				_, err = kubernetes.DiscoveryV1beta1().EndpointSlices("testing").Create(context.Background(), slice, metav1.CreateOptions{})
+				require.NoError(t, err)
+			}
+
+			client, err := NewServiceSource(kubernetes, "", "", "", false, "", false, tc.publishHostIP, false, []string{}, false, true)
+			require.NoError(t, err)
+			assert.Nil(t, client.(*serviceSource).podInformer, "should not watch pods")
+			assert.Nil(t, client.(*serviceSource).endpointsInformer, "should not watch endpoints")
+
+			endpoints, err := client.Endpoints(context.Background())
+			require.NoError(t, err)
+			validateEndpoints(t, endpoints, tc.expected)
+		})
+	}
+}
+
+// TestNamedPortSRVServices tests that SRV records are published for the named ports of annotated services.
+func TestNamedPortSRVServices(t *testing.T) {
+	ready := true
+	hostname := func(s string) *string { return &s }
+	port := func(p int32) *int32 { return &p }
+	ports := []v1.ServicePort{
+		{Name: "sip", Protocol: v1.ProtocolUDP, Port: 5060, TargetPort: intstr.FromInt(15060)},
+		{Name: "ldap", Protocol: v1.ProtocolTCP, Port: 389, TargetPort: intstr.FromString("ldap")},
+		{Protocol: v1.ProtocolTCP, Port: 8080},
+	}
+
+	for _, tc := range []struct {
+		title           string
+		svcType         v1.ServiceType
+		clusterIP       string
+		publishInternal bool
+		annotations     map[string]string
+		lbs             []string
+		expected        []*endpoint.Endpoint
+	}{
+		{
+			title:           "ClusterIP service",
+			svcType:         v1.ServiceTypeClusterIP,
+			clusterIP:       "10.0.0.1",
+			publishInternal: true,
+			annotations:     map[string]string{hostnameAnnotationKey: "foo.example.org", srvRecordsAnnotationKey: "true"},
+			expected: []*endpoint.Endpoint{
+				{DNSName: "foo.example.org", RecordType: endpoint.RecordTypeA, Targets: endpoint.Targets{"10.0.0.1"}},
+				{DNSName: "_sip._udp.foo.example.org", RecordType: endpoint.RecordTypeSRV, Targets: endpoint.Targets{"0 50 5060 foo.example.org"}},
+				{DNSName: "_ldap._tcp.foo.example.org", RecordType: endpoint.RecordTypeSRV, Targets: endpoint.Targets{"0 50 389 foo.example.org"}},
+			},
+		},
+		{
+			title:       "ClusterIP service without published cluster IP",
+			svcType:     v1.ServiceTypeClusterIP,
+			clusterIP:   "10.0.0.1",
+			annotations: map[string]string{hostnameAnnotationKey: "foo.example.org", srvRecordsAnnotationKey: "true"},
+			expected:    []*endpoint.Endpoint{},
+		},
+		{
+			title:     "LoadBalancer service with priority, weight and TTL",
+			svcType:   v1.ServiceTypeLoadBalancer,
+			clusterIP: "10.0.0.1",
+			annotations: map[string]string{
+				hostnameAnnotationKey:    "foo.example.org",
+				srvRecordsAnnotationKey:  "true",
+				srvPriorityAnnotationKey: "10",
+				srvWeightAnnotationKey:   "invalid",
+				ttlAnnotationKey:         "60",
+			},
+			lbs: []string{"1.2.3.4"},
+			expected: []*endpoint.Endpoint{
+				{DNSName: "foo.example.org", RecordType: endpoint.RecordTypeA, Targets: endpoint.Targets{"1.2.3.4"}, RecordTTL: 60},
+				{DNSName: "_sip._udp.foo.example.org", RecordType: endpoint.RecordTypeSRV, Targets: endpoint.Targets{"10 50 5060 foo.example.org"}, RecordTTL: 60},
+				{DNSName: "_ldap._tcp.foo.example.org", RecordType: endpoint.RecordTypeSRV, Targets: endpoint.Targets{"10 50 389 foo.example.org"}, RecordTTL: 60},
+			},
+		},
+		{
+			title:       "headless service points to the pods",
+			svcType:     v1.ServiceTypeClusterIP,
+			clusterIP:   v1.ClusterIPNone,
+			annotations: map[string]string{hostnameAnnotationKey: "foo.example.org", srvRecordsAnnotationKey: "true"},
+			expected: []*endpoint.Endpoint{
+				{DNSName: "foo.example.org", RecordType: endpoint.RecordTypeA, Targets: endpoint.Targets{"1.1.1.1", "1.1.1.2"}},
+				{DNSName: "foo-0.foo.example.org", RecordType: endpoint.RecordTypeA, Targets: endpoint.Targets{"1.1.1.1"}},
+				{DNSName: "foo-1.foo.example.org", RecordType: endpoint.RecordTypeA, Targets: endpoint.Targets{"1.1.1.2"}},
+				{DNSName: "_sip._udp.foo.example.org", RecordType: endpoint.RecordTypeSRV, Targets: endpoint.Targets{"0 50 15060 foo-0.foo.example.org", "0 50 15060 foo-1.foo.example.org"}},
+				{DNSName: "_ldap._tcp.foo.example.org", RecordType: endpoint.RecordTypeSRV, Targets: endpoint.Targets{"0 50 1389 foo-0.foo.example.org", "0 50 1389 foo-1.foo.example.org"}},
+			},
+		},
+		{
+			title:       "not annotated service",
+			svcType:     v1.ServiceTypeLoadBalancer,
+			clusterIP:   "10.0.0.1",
+			annotations: map[string]string{hostnameAnnotationKey: "foo.example.org"},
+			lbs:         []string{"1.2.3.4"},
+			expected: []*endpoint.Endpoint{
+				{DNSName: "foo.example.org", RecordType: endpoint.RecordTypeA, Targets: endpoint.Targets{"1.2.3.4"}},
+			},
+		},
+	} {
+		t.Run(tc.title, func(t *testing.T) {
+			kubernetes := fake.NewSimpleClientset()
+
+			service := &v1.Service{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "testing", Name: "foo", Annotations: tc.annotations},
+				Spec: v1.ServiceSpec{
+					Type:      tc.svcType,
+					ClusterIP: tc.clusterIP,
+					Ports:     ports,
+				},
+			}
+			for _, lb := range tc.lbs {
+				service.Status.LoadBalancer.Ingress = append(service.Status.LoadBalancer.Ingress, v1.LoadBalancerIngress{IP: lb})
+			}
+			_, err := kubernetes.CoreV1().Services("testing").Create(context.Background(), service, metav1.CreateOptions{})
+			require.NoError(t, err)
+
+			slice := &discoveryv1beta1.EndpointSlice{
+				ObjectMeta:  metav1.ObjectMeta{Namespace: "testing", Name: "foo", Labels: map[string]string{discoveryv1beta1.LabelServiceName: "foo"}},
+				AddressType: discoveryv1beta1.AddressTypeIPv4,
+				Endpoints: []discoveryv1beta1.Endpoint{
+					{Addresses: []string{"1.1.1.1"}, Conditions: discoveryv1beta1.EndpointConditions{Ready: &ready}, Hostname: hostname("foo-0")},
+					{Addresses: []string{"1.1.1.2"}, Conditions: discoveryv1beta1.EndpointConditions{Ready: &ready}, Hostname: hostname("foo-1")},
+				},
+				// the named target port ldap of the pods is 1389
+				Ports: []discoveryv1beta1.EndpointPort{
+					{Name: hostname("sip"), Port: port(15060)},
+					{Name: hostname("ldap"), Port: port(1389)},
+				},
+			}
+			_, err = kubernetes.DiscoveryV1beta1().EndpointSlices("testing").Create(context.Background(), slice, metav1.CreateOptions{})
+			require.NoError(t, err)
+
+			client, err := NewServiceSource(kubernetes, "", "", "", false, "", tc.publishInternal, false, false, []string{}, false, true)
+			require.NoError(t, err)
+
+			endpoints, err := client.Endpoints(context.Background())
+			require.NoError(t, err)
+			validateEndpoints(t, endpoints, tc.expected)
+		})
+	}
+}
+
+// TestNamedPortSRVServicesFromEndpoints tests that the named target ports of headless services are resolved by their Endpoints.
+func TestNamedPortSRVServicesFromEndpoints(t *testing.T) {
+	kubernetes := fake.NewSimpleClientset()
+
+	service := &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:   "testing",
+			Name:        "foo",
+			Annotations: map[string]string{hostnameAnnotationKey: "foo.example.org", srvRecordsAnnotationKey: "true"},
+		},
+		Spec: v1.ServiceSpec{
+			Type:      v1.ServiceTypeClusterIP,
+			ClusterIP: v1.ClusterIPNone,
+			Selector:  map[string]string{"app": "foo"},
+			Ports:     []v1.ServicePort{{Name: "ldap", Protocol: v1.ProtocolTCP, Port: 389, TargetPort: intstr.FromString("ldap")}},
+		},
+	}
+	_, err := kubernetes.CoreV1().Services("testing").Create(context.Background(), service, metav1.CreateOptions{})
+	require.NoError(t, err)
+
+	endpointsObject := &v1.Endpoints{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "testing", Name: "foo"},
+		Subsets: []v1.EndpointSubset{{
+			Addresses: []v1.EndpointAddress{{IP: "1.1.1.1", Hostname: "foo-0", TargetRef: &v1.ObjectReference{Kind: "Pod", Name: "foo-0"}}},
+			Ports:     []v1.EndpointPort{{Name: "ldap", Port: 1389, Protocol: v1.ProtocolTCP}},
+		}},
+	}
+	_, err = kubernetes.CoreV1().Endpoints("testing").Create(context.Background(), endpointsObject, metav1.CreateOptions{})
+	require.NoError(t, err)
+	pod := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "testing", Name: "foo-0", Labels: map[string]string{"app": "foo"}},
+		Spec:       v1.PodSpec{Hostname: "foo-0"},
+	}
+	_, err = kubernetes.CoreV1().Pods("testing").Create(context.Background(), pod, metav1.CreateOptions{})
+	require.NoError(t, err)
+
+	client, err := NewServiceSource(kubernetes, "", "", "", false, "", false, false, false, []string{}, false, false)
+	require.NoError(t, err)
+
+	endpoints, err := client.Endpoints(context.Background())
+	require.NoError(t, err)
+	var srv []*endpoint.Endpoint
+	for _, ep := range endpoints {
+		if ep.RecordType == endpoint.RecordTypeSRV {
+			srv = append(srv, ep)
+		}
+	}
+	validateEndpoints(t, srv, []*endpoint.Endpoint{
+		{DNSName: "_ldap._tcp.foo.example.org", RecordType: endpoint.RecordTypeSRV, Targets: endpoint.Targets{"0 50 1389 foo-0.foo.example.org"}},
+	})
+}
+
 // TestExternalServices tests that external services generate the correct endpoints.
 func TestExternalServices(t *testing.T) {
 	for _, tc := range []struct {
@@ -2744,6 +3132,7 @@ func TestExternalServices(t *testing.T) {
 				false,
 				[]string{},
 				tc.ignoreHostnameAnnotation,
+				false,
 			)
 			require.NoError(t, err)
 
@@ -2784,7 +3173,7 @@ func BenchmarkServiceEndpoints(b *testing.B) {
 	_, err := kubernetes.CoreV1().Services(service.Namespace).Create(context.Background(), service, metav1.CreateOptions{})
 	require.NoError(b, err)
 
-	client, err := NewServiceSource(kubernetes, v1.NamespaceAll, "", "", false, "", false, false, false, []string{}, false)
+	client, err := NewServiceSource(kubernetes, v1.NamespaceAll, "", "", false, "", false, false, false, []string{}, false, false)
 	require.NoError(b, err)
 
 	for i := 0; i < b.N; i++ {
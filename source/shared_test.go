@@ -20,12 +20,40 @@ import (
 	"sort"
 	"strings"
 	"testing"
+	"time"
 
 	"sigs.k8s.io/external-dns/endpoint"
 )
 
 // test helper functions
 
+// expectEvent checks whether an event handler fired, draining all events received in the meantime
+func expectEvent(t *testing.T, events <-chan struct{}, expected bool) {
+	t.Helper()
+	timeout := time.After(time.Second)
+	if !expected {
+		timeout = time.After(200 * time.Millisecond)
+	}
+	select {
+	case <-events:
+		if !expected {
+			t.Error("unexpected event")
+		}
+	case <-timeout:
+		if expected {
+			t.Error("expected event, got none")
+		}
+		return
+	}
+	for {
+		select {
+		case <-events:
+		case <-time.After(50 * time.Millisecond):
+			return
+		}
+	}
+}
+
 func validateEndpoints(t *testing.T, endpoints, expected []*endpoint.Endpoint) {
 	if len(endpoints) != len(expected) {
 		t.Fatalf("expected %d endpoints, got %d", len(expected), len(endpoints))
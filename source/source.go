@@ -46,6 +46,15 @@ const (
 	ttlAnnotationKey = "external-dns.alpha.kubernetes.io/ttl"
 	// The annotation used for switching to the alias record types e. g. AWS Alias records instead of a normal CNAME
 	aliasAnnotationKey = "external-dns.alpha.kubernetes.io/alias"
+	// The annotation used for publishing a name per topology zone for the endpoints of headless services
+	zoneHostnamesAnnotationKey = "external-dns.alpha.kubernetes.io/zone-hostnames"
+	// The annotation used for publishing SRV records for the named ports of a service
+	srvRecordsAnnotationKey = "external-dns.alpha.kubernetes.io/srv-records"
+	// The annotations used for defining the priority and weight of these SRV records
+	srvPriorityAnnotationKey = "external-dns.alpha.kubernetes.io/srv-priority"
+	srvWeightAnnotationKey   = "external-dns.alpha.kubernetes.io/srv-weight"
+	// The annotation used for merging the targets of a hostname with the ones of other resources instead of picking one resource
+	mergeAnnotationKey = "external-dns.alpha.kubernetes.io/merge-targets"
 	// The value of the controller annotation so that we feel responsible
 	controllerAnnotationValue = "dns-controller"
 )
@@ -155,6 +164,19 @@ func getProviderSpecificAnnotations(annotations map[string]string) (endpoint.Pro
 	return providerSpecificAnnotations, setIdentifier
 }
 
+// setMergeLabel marks the endpoints of a resource which opted in to merging its targets with the ones of other resources
+func setMergeLabel(annotations map[string]string, endpoints []*endpoint.Endpoint) {
+	if annotations[mergeAnnotationKey] != "true" {
+		return
+	}
+	for _, ep := range endpoints {
+		if ep.Labels == nil {
+			ep.Labels = endpoint.NewLabels()
+		}
+		ep.Labels[endpoint.MergeLabelKey] = "true"
+	}
+}
+
 // getTargetsFromTargetAnnotation gets endpoints from optional "target" annotation.
 // Returns empty endpoints array if none are found.
 func getTargetsFromTargetAnnotation(annotations map[string]string) endpoint.Targets {
@@ -105,3 +105,21 @@ func TestSuitableType(t *testing.T) {
 		}
 	}
 }
+
+func TestSetMergeLabel(t *testing.T) {
+	for _, tc := range []struct {
+		title       string
+		annotations map[string]string
+		expected    string
+	}{
+		{title: "merge annotation is not present"},
+		{title: "merge annotation is enabled", annotations: map[string]string{mergeAnnotationKey: "true"}, expected: "true"},
+		{title: "merge annotation is disabled", annotations: map[string]string{mergeAnnotationKey: "false"}},
+	} {
+		t.Run(tc.title, func(t *testing.T) {
+			endpoints := []*endpoint.Endpoint{endpoint.NewEndpoint("foo.example.org", endpoint.RecordTypeA, "1.2.3.4")}
+			setMergeLabel(tc.annotations, endpoints)
+			assert.Equal(t, tc.expected, endpoints[0].Labels[endpoint.MergeLabelKey])
+		})
+	}
+}
@@ -47,13 +47,20 @@ type Config struct {
 	CombineFQDNAndAnnotation       bool
 	IgnoreHostnameAnnotation       bool
 	IgnoreIngressTLSSpec           bool
+	IngressClassNames              []string
+	NodeAddressTypes               []string
+	NodeLabelFilter                string
+	ExcludeUnavailableNodes        bool
 	Compatibility                  string
 	PublishInternal                bool
 	PublishHostIP                  bool
 	AlwaysPublishNotReadyAddresses bool
+	UseEndpointSlices              bool
 	ConnectorServer                string
+	FileSourcePaths                []string
 	CRDSourceAPIVersion            string
 	CRDSourceKind                  string
+	GenericCRDConfig               string
 	KubeConfig                     string
 	APIServerURL                   string
 	ServiceTypeFilter              []string
@@ -61,6 +68,7 @@ type Config struct {
 	CFUsername                     string
 	CFPassword                     string
 	ContourLoadBalancerService     string
+	TraefikLoadBalancerService     string
 	SkipperRouteGroupVersion       string
 	RequestTimeout                 time.Duration
 }
@@ -152,6 +160,31 @@ func (p *SingletonClientGenerator) OpenShiftClient() (openshift.Interface, error
 	return p.openshiftClient, err
 }
 
+// resourceLabelKinds maps the names of the sources to the kind they use in the resource label
+// of their endpoints, sources which don't label their endpoints with a fixed kind are missing
+var resourceLabelKinds = map[string]string{
+	"service":              "service",
+	"ingress":              "ingress",
+	"istio-gateway":        "gateway",
+	"istio-virtualservice": "virtualservice",
+	"contour-ingressroute": "ingressroute",
+	"contour-httpproxy":    "HTTPProxy",
+	"traefik-ingressroute": "traefik-ingressroute",
+	"gateway-httproute":    "httproute",
+	"gateway-tlsroute":     "tlsroute",
+	"openshift-route":      "route",
+	"file":                 "file",
+	"crd":                  "crd",
+	"skipper-routegroup":   "routegroup",
+}
+
+// ResourceLabelKind returns the kind the named source uses in the resource label of its endpoints,
+// which is the first part of the label <kind>/<namespace>/<name>
+func ResourceLabelKind(name string) (string, bool) {
+	kind, ok := resourceLabelKinds[name]
+	return kind, ok
+}
+
 // ByNames returns multiple Sources given multiple names.
 func ByNames(p ClientGenerator, names []string, cfg *Config) ([]Source, error) {
 	sources := []Source{}
@@ -174,19 +207,25 @@ func BuildWithConfig(source string, p ClientGenerator, cfg *Config) (Source, err
 		if err != nil {
 			return nil, err
 		}
-		return NewNodeSource(client, cfg.AnnotationFilter, cfg.FQDNTemplate)
+		return NewNodeSource(client, cfg.AnnotationFilter, cfg.FQDNTemplate, cfg.NodeLabelFilter, cfg.NodeAddressTypes, cfg.ExcludeUnavailableNodes)
 	case "service":
 		client, err := p.KubeClient()
 		if err != nil {
 			return nil, err
 		}
-		return NewServiceSource(client, cfg.Namespace, cfg.AnnotationFilter, cfg.FQDNTemplate, cfg.CombineFQDNAndAnnotation, cfg.Compatibility, cfg.PublishInternal, cfg.PublishHostIP, cfg.AlwaysPublishNotReadyAddresses, cfg.ServiceTypeFilter, cfg.IgnoreHostnameAnnotation)
+		return NewServiceSource(client, cfg.Namespace, cfg.AnnotationFilter, cfg.FQDNTemplate, cfg.CombineFQDNAndAnnotation, cfg.Compatibility, cfg.PublishInternal, cfg.PublishHostIP, cfg.AlwaysPublishNotReadyAddresses, cfg.ServiceTypeFilter, cfg.IgnoreHostnameAnnotation, cfg.UseEndpointSlices)
 	case "ingress":
 		client, err := p.KubeClient()
 		if err != nil {
 			return nil, err
 		}
-		return NewIngressSource(client, cfg.Namespace, cfg.AnnotationFilter, cfg.FQDNTemplate, cfg.CombineFQDNAndAnnotation, cfg.IgnoreHostnameAnnotation, cfg.IgnoreIngressTLSSpec)
+		return NewIngressSource(client, cfg.Namespace, cfg.AnnotationFilter, cfg.FQDNTemplate, cfg.CombineFQDNAndAnnotation, cfg.IgnoreHostnameAnnotation, cfg.IgnoreIngressTLSSpec, cfg.IngressClassNames)
+	case "pod":
+		client, err := p.KubeClient()
+		if err != nil {
+			return nil, err
+		}
+		return NewPodSource(client, cfg.Namespace, cfg.AnnotationFilter)
 	case "istio-gateway":
 		kubernetesClient, err := p.KubeClient()
 		if err != nil {
@@ -229,6 +268,28 @@ func BuildWithConfig(source string, p ClientGenerator, cfg *Config) (Source, err
 			return nil, err
 		}
 		return NewContourHTTPProxySource(dynamicClient, cfg.Namespace, cfg.AnnotationFilter, cfg.FQDNTemplate, cfg.CombineFQDNAndAnnotation, cfg.IgnoreHostnameAnnotation)
+	case "traefik-ingressroute":
+		kubernetesClient, err := p.KubeClient()
+		if err != nil {
+			return nil, err
+		}
+		dynamicClient, err := p.DynamicKubernetesClient()
+		if err != nil {
+			return nil, err
+		}
+		return NewTraefikIngressRouteSource(dynamicClient, kubernetesClient, cfg.TraefikLoadBalancerService, cfg.Namespace, cfg.AnnotationFilter, cfg.FQDNTemplate, cfg.CombineFQDNAndAnnotation, cfg.IgnoreHostnameAnnotation)
+	case "gateway-httproute":
+		dynamicClient, err := p.DynamicKubernetesClient()
+		if err != nil {
+			return nil, err
+		}
+		return NewGatewayHTTPRouteSource(dynamicClient, cfg.Namespace, cfg.AnnotationFilter, cfg.IgnoreHostnameAnnotation)
+	case "gateway-tlsroute":
+		dynamicClient, err := p.DynamicKubernetesClient()
+		if err != nil {
+			return nil, err
+		}
+		return NewGatewayTLSRouteSource(dynamicClient, cfg.Namespace, cfg.AnnotationFilter, cfg.IgnoreHostnameAnnotation)
 	case "openshift-route":
 		ocpClient, err := p.OpenShiftClient()
 		if err != nil {
@@ -239,6 +300,8 @@ func BuildWithConfig(source string, p ClientGenerator, cfg *Config) (Source, err
 		return NewFakeSource(cfg.FQDNTemplate)
 	case "connector":
 		return NewConnectorSource(cfg.ConnectorServer)
+	case "file":
+		return NewFileSource(cfg.FileSourcePaths)
 	case "crd":
 		client, err := p.KubeClient()
 		if err != nil {
@@ -249,6 +312,20 @@ func BuildWithConfig(source string, p ClientGenerator, cfg *Config) (Source, err
 			return nil, err
 		}
 		return NewCRDSource(crdClient, cfg.Namespace, cfg.CRDSourceKind, cfg.AnnotationFilter, cfg.LabelFilter, scheme)
+	case "generic-crd":
+		kubernetesClient, err := p.KubeClient()
+		if err != nil {
+			return nil, err
+		}
+		dynamicClient, err := p.DynamicKubernetesClient()
+		if err != nil {
+			return nil, err
+		}
+		rules, err := LoadGenericCRDRules(cfg.GenericCRDConfig)
+		if err != nil {
+			return nil, err
+		}
+		return NewGenericCRDSource(dynamicClient, kubernetesClient, cfg.Namespace, cfg.AnnotationFilter, rules)
 	case "skipper-routegroup":
 		apiServerURL := cfg.APIServerURL
 		tokenPath := ""
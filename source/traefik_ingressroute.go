@@ -0,0 +1,343 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package source
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+
+	"sigs.k8s.io/external-dns/endpoint"
+)
+
+// TraefikIngressRouteGVR is the resource of Traefik v2 IngressRoutes
+var TraefikIngressRouteGVR = schema.GroupVersionResource{Group: "traefik.containo.us", Version: "v1alpha1", Resource: "ingressroutes"}
+
+// traefikHostMatcher matches the Host and HostHeader rules of a Traefik match expression, capturing their arguments
+var traefikHostMatcher = regexp.MustCompile(`\bHost(?:Header)?\(([^)]*)\)`)
+
+// traefikIngressRoute mirrors the subset of a Traefik IngressRoute used by the source.
+type traefikIngressRoute struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+	Spec              struct {
+		Routes []struct {
+			Match string `json:"match"`
+			Kind  string `json:"kind,omitempty"`
+		} `json:"routes"`
+	} `json:"spec"`
+}
+
+// traefikIngressRouteSource is an implementation of Source for Traefik IngressRoute objects.
+// The IngressRoute implementation uses the hosts of the Host() rules in spec.routes[].match for the hostnames.
+// Use targetAnnotationKey to explicitly set Endpoint.
+type traefikIngressRouteSource struct {
+	kubeClient                 kubernetes.Interface
+	traefikLoadBalancerService string
+	namespace                  string
+	annotationFilter           string
+	fqdnTemplate               *template.Template
+	combineFQDNAnnotation      bool
+	ignoreHostnameAnnotation   bool
+	ingressRouteInformer       informers.GenericInformer
+}
+
+// NewTraefikIngressRouteSource creates a new traefikIngressRouteSource with the given config.
+func NewTraefikIngressRouteSource(
+	dynamicKubeClient dynamic.Interface,
+	kubeClient kubernetes.Interface,
+	traefikLoadBalancerService string,
+	namespace string,
+	annotationFilter string,
+	fqdnTemplate string,
+	combineFqdnAnnotation bool,
+	ignoreHostnameAnnotation bool,
+) (Source, error) {
+	var (
+		tmpl *template.Template
+		err  error
+	)
+	if fqdnTemplate != "" {
+		tmpl, err = template.New("endpoint").Funcs(template.FuncMap{
+			"trimPrefix": strings.TrimPrefix,
+		}).Parse(fqdnTemplate)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if _, _, err = parseTraefikLoadBalancerService(traefikLoadBalancerService); err != nil {
+		return nil, err
+	}
+
+	// Use shared informer to listen for add/update/delete of IngressRoutes in the specified namespace.
+	// Set resync period to 0, to prevent processing when nothing has changed.
+	informerFactory := dynamicinformer.NewFilteredDynamicSharedInformerFactory(dynamicKubeClient, 0, namespace, nil)
+	ingressRouteInformer := informerFactory.ForResource(TraefikIngressRouteGVR)
+
+	// Add default resource event handlers to properly initialize informer.
+	ingressRouteInformer.Informer().AddEventHandler(
+		cache.ResourceEventHandlerFuncs{
+			AddFunc: func(obj interface{}) {
+			},
+		},
+	)
+
+	// TODO informer is not explicitly stopped since controller is not passing in its channel.
+	informerFactory.Start(wait.NeverStop)
+
+	// wait for the local cache to be populated.
+	err = poll(time.Second, 60*time.Second, func() (bool, error) {
+		return ingressRouteInformer.Informer().HasSynced(), nil
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to sync cache")
+	}
+
+	return &traefikIngressRouteSource{
+		kubeClient:                 kubeClient,
+		traefikLoadBalancerService: traefikLoadBalancerService,
+		namespace:                  namespace,
+		annotationFilter:           annotationFilter,
+		fqdnTemplate:               tmpl,
+		combineFQDNAnnotation:      combineFqdnAnnotation,
+		ignoreHostnameAnnotation:   ignoreHostnameAnnotation,
+		ingressRouteInformer:       ingressRouteInformer,
+	}, nil
+}
+
+// Endpoints returns endpoint objects for each host-target combination that should be processed.
+// Retrieves all Traefik IngressRoute resources in the source's namespace(s).
+func (sc *traefikIngressRouteSource) Endpoints(ctx context.Context) ([]*endpoint.Endpoint, error) {
+	selector, err := getLabelSelector(sc.annotationFilter)
+	if err != nil {
+		return nil, err
+	}
+
+	irs, err := sc.ingressRouteInformer.Lister().ByNamespace(sc.namespace).List(labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+
+	endpoints := []*endpoint.Endpoint{}
+
+	for _, obj := range irs {
+		ir := &traefikIngressRoute{}
+		if err := convertUnstructured(obj, ir); err != nil {
+			return nil, errors.Wrap(err, "failed to convert to Traefik IngressRoute")
+		}
+
+		// include IngressRoute if its annotations match the selector
+		if !selector.Empty() && !matchLabelSelector(selector, ir.Annotations) {
+			continue
+		}
+		// Check controller annotation to see if we are responsible.
+		controller, ok := ir.Annotations[controllerAnnotationKey]
+		if ok && controller != controllerAnnotationValue {
+			log.Debugf("Skipping Traefik IngressRoute %s/%s because controller value does not match, found: %s, required: %s",
+				ir.Namespace, ir.Name, controller, controllerAnnotationValue)
+			continue
+		}
+
+		targets := getTargetsFromTargetAnnotation(ir.Annotations)
+		if len(targets) == 0 {
+			targets, err = sc.targetsFromTraefikLoadBalancer(ctx)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		irEndpoints := sc.endpointsFromIngressRoute(ir, targets)
+
+		// apply template if no host is matched by the IngressRoute
+		if (sc.combineFQDNAnnotation || len(irEndpoints) == 0) && sc.fqdnTemplate != nil {
+			tmplEndpoints, err := sc.endpointsFromTemplate(ir, targets)
+			if err != nil {
+				return nil, err
+			}
+
+			if sc.combineFQDNAnnotation {
+				irEndpoints = append(irEndpoints, tmplEndpoints...)
+			} else {
+				irEndpoints = tmplEndpoints
+			}
+		}
+
+		if len(irEndpoints) == 0 {
+			log.Debugf("No endpoints could be generated from Traefik IngressRoute %s/%s", ir.Namespace, ir.Name)
+			continue
+		}
+
+		log.Debugf("Endpoints generated from Traefik IngressRoute: %s/%s: %v", ir.Namespace, ir.Name, irEndpoints)
+		for _, ep := range irEndpoints {
+			ep.Labels[endpoint.ResourceLabelKey] = fmt.Sprintf("traefik-ingressroute/%s/%s", ir.Namespace, ir.Name)
+		}
+		setMergeLabel(ir.Annotations, irEndpoints)
+		endpoints = append(endpoints, irEndpoints...)
+	}
+
+	for _, ep := range endpoints {
+		sort.Sort(ep.Targets)
+	}
+
+	return endpoints, nil
+}
+
+func (sc *traefikIngressRouteSource) endpointsFromTemplate(ir *traefikIngressRoute, targets endpoint.Targets) ([]*endpoint.Endpoint, error) {
+	// Process the whole template string
+	var buf bytes.Buffer
+	err := sc.fqdnTemplate.Execute(&buf, ir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to apply template on Traefik IngressRoute %s/%s: %v", ir.Namespace, ir.Name, err)
+	}
+
+	hostnames := buf.String()
+
+	ttl, err := getTTLFromAnnotations(ir.Annotations)
+	if err != nil {
+		log.Warn(err)
+	}
+
+	providerSpecific, setIdentifier := getProviderSpecificAnnotations(ir.Annotations)
+
+	var endpoints []*endpoint.Endpoint
+	// splits the FQDN template and removes the trailing periods
+	hostnameList := strings.Split(strings.Replace(hostnames, " ", "", -1), ",")
+	for _, hostname := range hostnameList {
+		hostname = strings.TrimSuffix(hostname, ".")
+		endpoints = append(endpoints, endpointsForHostname(hostname, targets, ttl, providerSpecific, setIdentifier)...)
+	}
+	return endpoints, nil
+}
+
+// endpointsFromIngressRoute extracts the endpoints from the match rules and annotations of a Traefik IngressRoute
+func (sc *traefikIngressRouteSource) endpointsFromIngressRoute(ir *traefikIngressRoute, targets endpoint.Targets) []*endpoint.Endpoint {
+	var endpoints []*endpoint.Endpoint
+
+	ttl, err := getTTLFromAnnotations(ir.Annotations)
+	if err != nil {
+		log.Warn(err)
+	}
+
+	providerSpecific, setIdentifier := getProviderSpecificAnnotations(ir.Annotations)
+
+	seen := map[string]bool{}
+	for _, route := range ir.Spec.Routes {
+		for _, hostname := range parseTraefikHosts(route.Match) {
+			if seen[hostname] {
+				continue
+			}
+			seen[hostname] = true
+			endpoints = append(endpoints, endpointsForHostname(hostname, targets, ttl, providerSpecific, setIdentifier)...)
+		}
+	}
+
+	// Skip endpoints if we do not want entries from annotations
+	if !sc.ignoreHostnameAnnotation {
+		hostnameList := getHostnamesFromAnnotations(ir.Annotations)
+		for _, hostname := range hostnameList {
+			endpoints = append(endpoints, endpointsForHostname(hostname, targets, ttl, providerSpecific, setIdentifier)...)
+		}
+	}
+
+	return endpoints
+}
+
+func (sc *traefikIngressRouteSource) targetsFromTraefikLoadBalancer(ctx context.Context) (targets endpoint.Targets, err error) {
+	lbNamespace, lbName, err := parseTraefikLoadBalancerService(sc.traefikLoadBalancerService)
+	if err != nil {
+		return nil, err
+	}
+	if svc, err := sc.kubeClient.CoreV1().Services(lbNamespace).Get(ctx, lbName, metav1.GetOptions{}); err != nil {
+		log.Warn(err)
+	} else {
+		for _, lb := range svc.Status.LoadBalancer.Ingress {
+			if lb.IP != "" {
+				targets = append(targets, lb.IP)
+			}
+			if lb.Hostname != "" {
+				targets = append(targets, lb.Hostname)
+			}
+		}
+	}
+
+	return
+}
+
+// parseTraefikHosts returns the hosts of the Host() and HostHeader() rules of a match expression,
+// e.g. "Host(`a.example.org`, `b.example.org`) && PathPrefix(`/`)" yields a.example.org and b.example.org
+func parseTraefikHosts(match string) []string {
+	var hosts []string
+	for _, rule := range traefikHostMatcher.FindAllStringSubmatch(match, -1) {
+		for _, host := range strings.Split(rule[1], ",") {
+			host = strings.Trim(strings.TrimSpace(host), "`\"'")
+			host = strings.TrimSuffix(host, ".")
+			if host != "" {
+				hosts = append(hosts, host)
+			}
+		}
+	}
+	return hosts
+}
+
+func parseTraefikLoadBalancerService(service string) (namespace, name string, err error) {
+	parts := strings.Split(service, "/")
+	if len(parts) != 2 {
+		err = fmt.Errorf("invalid traefik load balancer service (namespace/name) found '%v'", service)
+	} else {
+		namespace, name = parts[0], parts[1]
+	}
+
+	return
+}
+
+func (sc *traefikIngressRouteSource) AddEventHandler(ctx context.Context, handler func()) {
+	log.Debug("Adding event handler for Traefik IngressRoute")
+
+	// Right now there is no way to remove event handler from informer, see:
+	// https://github.com/kubernetes/kubernetes/issues/79610
+	sc.ingressRouteInformer.Informer().AddEventHandler(
+		cache.ResourceEventHandlerFuncs{
+			AddFunc: func(obj interface{}) {
+				handler()
+			},
+			UpdateFunc: func(old interface{}, new interface{}) {
+				handler()
+			},
+			DeleteFunc: func(obj interface{}) {
+				handler()
+			},
+		},
+	)
+}
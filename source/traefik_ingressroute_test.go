@@ -0,0 +1,206 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package source
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	fakeDynamic "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"sigs.k8s.io/external-dns/endpoint"
+)
+
+// Validates that traefikIngressRouteSource is a Source
+var _ Source = &traefikIngressRouteSource{}
+
+func newTraefikIngressRoute(namespace, name string, annotations map[string]string, matches ...string) *unstructured.Unstructured {
+	routes := []interface{}{}
+	for _, match := range matches {
+		routes = append(routes, map[string]interface{}{"match": match, "kind": "Rule"})
+	}
+	ir := &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"apiVersion": "traefik.containo.us/v1alpha1",
+			"kind":       "IngressRoute",
+			"spec":       map[string]interface{}{"entryPoints": []interface{}{"websecure"}, "routes": routes},
+		},
+	}
+	ir.SetNamespace(namespace)
+	ir.SetName(name)
+	ir.SetAnnotations(annotations)
+	return ir
+}
+
+func newTraefikClient(objects ...runtime.Object) *fakeDynamic.FakeDynamicClient {
+	s := runtime.NewScheme()
+	s.AddKnownTypeWithName(schema.GroupVersionKind{Group: "traefik.containo.us", Version: "v1alpha1", Kind: "IngressRoute"}, &unstructured.Unstructured{})
+	s.AddKnownTypeWithName(schema.GroupVersionKind{Group: "traefik.containo.us", Version: "v1alpha1", Kind: "IngressRouteList"}, &unstructured.UnstructuredList{})
+	return fakeDynamic.NewSimpleDynamicClient(s, objects...)
+}
+
+func TestParseTraefikHosts(t *testing.T) {
+	for _, ti := range []struct {
+		match    string
+		expected []string
+	}{
+		{"Host(`foo.example.org`)", []string{"foo.example.org"}},
+		{"Host(`foo.example.org`, `bar.example.org.`) && PathPrefix(`/api`)", []string{"foo.example.org", "bar.example.org"}},
+		{"(Host(`foo.example.org`) || HostHeader(`bar.example.org`)) && Method(`GET`)", []string{"foo.example.org", "bar.example.org"}},
+		{"HostRegexp(`{subdomain:[a-z]+}.example.org`)", nil},
+		{"PathPrefix(`/`)", nil},
+	} {
+		assert.Equal(t, ti.expected, parseTraefikHosts(ti.match), ti.match)
+	}
+}
+
+func TestNewTraefikIngressRouteSource(t *testing.T) {
+	_, err := NewTraefikIngressRouteSource(newTraefikClient(), fake.NewSimpleClientset(), "traefik", "", "", "", false, false)
+	assert.Error(t, err, "invalid load balancer service")
+
+	_, err = NewTraefikIngressRouteSource(newTraefikClient(), fake.NewSimpleClientset(), "traefik/traefik", "", "", "{{.Name", false, false)
+	assert.Error(t, err, "invalid template")
+}
+
+func TestTraefikIngressRouteSourceEndpoints(t *testing.T) {
+	kubeClient := fake.NewSimpleClientset(&v1.Service{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "traefik", Name: "traefik"},
+		Status: v1.ServiceStatus{
+			LoadBalancer: v1.LoadBalancerStatus{
+				Ingress: []v1.LoadBalancerIngress{{IP: "1.2.3.4"}},
+			},
+		},
+	})
+
+	for _, ti := range []struct {
+		title                    string
+		ingressRoutes            []runtime.Object
+		annotationFilter         string
+		fqdnTemplate             string
+		combineFQDNAnnotation    bool
+		ignoreHostnameAnnotation bool
+		expected                 []*endpoint.Endpoint
+	}{
+		{
+			title: "hosts of all routes with the load balancer as target",
+			ingressRoutes: []runtime.Object{
+				newTraefikIngressRoute("default", "foo", nil,
+					"Host(`foo.example.org`) && PathPrefix(`/`)",
+					"Host(`foo.example.org`, `bar.example.org`) && PathPrefix(`/api`)"),
+			},
+			expected: []*endpoint.Endpoint{
+				{DNSName: "foo.example.org", RecordType: endpoint.RecordTypeA, Targets: endpoint.Targets{"1.2.3.4"}},
+				{DNSName: "bar.example.org", RecordType: endpoint.RecordTypeA, Targets: endpoint.Targets{"1.2.3.4"}},
+			},
+		},
+		{
+			title: "annotations",
+			ingressRoutes: []runtime.Object{
+				newTraefikIngressRoute("default", "foo", map[string]string{
+					targetAnnotationKey:   "lb.example.com",
+					hostnameAnnotationKey: "extra.example.org",
+					ttlAnnotationKey:      "60",
+				}, "Host(`foo.example.org`)"),
+			},
+			expected: []*endpoint.Endpoint{
+				{DNSName: "foo.example.org", RecordType: endpoint.RecordTypeCNAME, Targets: endpoint.Targets{"lb.example.com"}, RecordTTL: 60},
+				{DNSName: "extra.example.org", RecordType: endpoint.RecordTypeCNAME, Targets: endpoint.Targets{"lb.example.com"}, RecordTTL: 60},
+			},
+		},
+		{
+			title:                    "hostname annotation is ignored",
+			ignoreHostnameAnnotation: true,
+			ingressRoutes: []runtime.Object{
+				newTraefikIngressRoute("default", "foo", map[string]string{hostnameAnnotationKey: "extra.example.org"}, "Host(`foo.example.org`)"),
+			},
+			expected: []*endpoint.Endpoint{
+				{DNSName: "foo.example.org", RecordType: endpoint.RecordTypeA, Targets: endpoint.Targets{"1.2.3.4"}},
+			},
+		},
+		{
+			title:            "annotation and controller filters",
+			annotationFilter: "team=dns",
+			ingressRoutes: []runtime.Object{
+				newTraefikIngressRoute("default", "match", map[string]string{"team": "dns"}, "Host(`match.example.org`)"),
+				newTraefikIngressRoute("default", "other-team", map[string]string{"team": "web"}, "Host(`web.example.org`)"),
+				newTraefikIngressRoute("default", "other-controller", map[string]string{"team": "dns", controllerAnnotationKey: "other"}, "Host(`other.example.org`)"),
+			},
+			expected: []*endpoint.Endpoint{
+				{DNSName: "match.example.org", RecordType: endpoint.RecordTypeA, Targets: endpoint.Targets{"1.2.3.4"}},
+			},
+		},
+		{
+			title:        "template is used if no host is matched",
+			fqdnTemplate: "{{.Name}}.{{.Namespace}}.example.org",
+			ingressRoutes: []runtime.Object{
+				newTraefikIngressRoute("default", "foo", nil, "PathPrefix(`/`)"),
+				newTraefikIngressRoute("default", "bar", nil, "Host(`bar.example.org`)"),
+			},
+			expected: []*endpoint.Endpoint{
+				{DNSName: "foo.default.example.org", RecordType: endpoint.RecordTypeA, Targets: endpoint.Targets{"1.2.3.4"}},
+				{DNSName: "bar.example.org", RecordType: endpoint.RecordTypeA, Targets: endpoint.Targets{"1.2.3.4"}},
+			},
+		},
+		{
+			title:                 "template combined with hosts",
+			fqdnTemplate:          "{{.Name}}.{{.Namespace}}.example.org",
+			combineFQDNAnnotation: true,
+			ingressRoutes: []runtime.Object{
+				newTraefikIngressRoute("default", "bar", nil, "Host(`bar.example.org`)"),
+			},
+			expected: []*endpoint.Endpoint{
+				{DNSName: "bar.example.org", RecordType: endpoint.RecordTypeA, Targets: endpoint.Targets{"1.2.3.4"}},
+				{DNSName: "bar.default.example.org", RecordType: endpoint.RecordTypeA, Targets: endpoint.Targets{"1.2.3.4"}},
+			},
+		},
+	} {
+		t.Run(ti.title, func(t *testing.T) {
+			source, err := NewTraefikIngressRouteSource(newTraefikClient(ti.ingressRoutes...), kubeClient, "traefik/traefik", "",
+				ti.annotationFilter, ti.fqdnTemplate, ti.combineFQDNAnnotation, ti.ignoreHostnameAnnotation)
+			require.NoError(t, err)
+
+			endpoints, err := source.Endpoints(context.Background())
+			require.NoError(t, err)
+			validateEndpoints(t, endpoints, ti.expected)
+			for _, ep := range endpoints {
+				assert.Contains(t, ep.Labels[endpoint.ResourceLabelKey], "traefik-ingressroute/default/")
+			}
+		})
+	}
+}
+
+func TestTraefikIngressRouteSourceEventHandler(t *testing.T) {
+	client := newTraefikClient()
+	source, err := NewTraefikIngressRouteSource(client, fake.NewSimpleClientset(), "traefik/traefik", "", "", "", false, false)
+	require.NoError(t, err)
+
+	events := make(chan struct{}, 10)
+	source.AddEventHandler(context.Background(), func() { events <- struct{}{} })
+
+	_, err = client.Resource(TraefikIngressRouteGVR).Namespace("default").Create(context.Background(),
+		newTraefikIngressRoute("default", "foo", nil, "Host(`foo.example.org`)"), metav1.CreateOptions{})
+	require.NoError(t, err)
+	expectEvent(t, events, true)
+}
@@ -0,0 +1,165 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package source
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+
+	"sigs.k8s.io/external-dns/endpoint"
+)
+
+// TTLPolicy is the TTL policy for the endpoints of a domain and its subdomains. Unset values are
+// inherited from the policies of the parent domains, the empty domain matches all endpoints.
+type TTLPolicy struct {
+	// Domain is the suffix of the DNS names of the endpoints the policy applies to
+	Domain string
+	// Default is the TTL of the endpoints without a configured TTL, if it is set
+	Default endpoint.TTL
+	// Min is the lowest TTL of the endpoints, if it is set
+	Min endpoint.TTL
+	// Max is the highest TTL of the endpoints, if it is set
+	Max endpoint.TTL
+}
+
+// ParseTTLPolicy parses a TTL policy of the form <domain>:default=<ttl>,min=<ttl>,max=<ttl>, where each
+// value is optional and TTLs are given in seconds or as durations like TTL annotations, e.g. "10m".
+func ParseTTLPolicy(s string) (TTLPolicy, error) {
+	parts := strings.SplitN(s, ":", 2)
+	if len(parts) != 2 {
+		return TTLPolicy{}, fmt.Errorf("invalid TTL policy %q, expected <domain>:default=<ttl>,min=<ttl>,max=<ttl>", s)
+	}
+
+	policy := TTLPolicy{Domain: strings.TrimSuffix(strings.ToLower(strings.TrimSpace(parts[0])), ".")}
+	for _, setting := range strings.Split(parts[1], ",") {
+		kv := strings.SplitN(strings.TrimSpace(setting), "=", 2)
+		if len(kv) != 2 {
+			return TTLPolicy{}, fmt.Errorf("invalid setting %q of TTL policy %q", setting, s)
+		}
+		ttl, err := parseTTL(kv[1])
+		if err != nil || ttl < ttlMinimum || ttl > ttlMaximum {
+			return TTLPolicy{}, fmt.Errorf("invalid TTL %q of TTL policy %q, it must be between [%d, %d]", kv[1], s, ttlMinimum, ttlMaximum)
+		}
+		switch kv[0] {
+		case "default":
+			policy.Default = endpoint.TTL(ttl)
+		case "min":
+			policy.Min = endpoint.TTL(ttl)
+		case "max":
+			policy.Max = endpoint.TTL(ttl)
+		default:
+			return TTLPolicy{}, fmt.Errorf("unknown setting %q of TTL policy %q, expected default, min or max", kv[0], s)
+		}
+	}
+	if policy.Min > 0 && policy.Max > 0 && policy.Min > policy.Max {
+		return TTLPolicy{}, fmt.Errorf("minimum TTL of TTL policy %q is higher than its maximum", s)
+	}
+	return policy, nil
+}
+
+// matches tells whether the policy applies to the DNS name
+func (p TTLPolicy) matches(dnsName string) bool {
+	name := strings.TrimSuffix(strings.ToLower(dnsName), ".")
+	return p.Domain == "" || name == p.Domain || strings.HasSuffix(name, "."+p.Domain)
+}
+
+// ttlPolicySource is a Source that applies TTL policies to the endpoints of its wrapped source.
+type ttlPolicySource struct {
+	source Source
+	// policies are sorted by the length of their domain, so that the ones of subdomains are applied last
+	policies []TTLPolicy
+}
+
+// NewTTLPolicySource creates a new ttlPolicySource wrapping the provided Source.
+func NewTTLPolicySource(source Source, policies []TTLPolicy) Source {
+	sorted := append([]TTLPolicy{}, policies...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return len(sorted[i].Domain) < len(sorted[j].Domain)
+	})
+	return &ttlPolicySource{source: source, policies: sorted}
+}
+
+// Endpoints collects endpoints from its wrapped source and sets their TTLs according to the policies.
+func (ps *ttlPolicySource) Endpoints(ctx context.Context) ([]*endpoint.Endpoint, error) {
+	endpoints, err := ps.source.Endpoints(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, ep := range endpoints {
+		if ttl := ps.policyFor(ep.DNSName).apply(ep.RecordTTL); ttl != ep.RecordTTL {
+			log.Debugf("Setting TTL of endpoint %s %s from %d to %d according to the TTL policy", ep.DNSName, ep.RecordType, ep.RecordTTL, ttl)
+			ep.RecordTTL = ttl
+		}
+	}
+	return endpoints, nil
+}
+
+// policyFor merges the policies matching the DNS name
+func (ps *ttlPolicySource) policyFor(dnsName string) TTLPolicy {
+	merged := TTLPolicy{}
+	for _, p := range ps.policies {
+		if !p.matches(dnsName) {
+			continue
+		}
+		merged.Domain = p.Domain
+		if p.Default > 0 {
+			merged.Default = p.Default
+		}
+		if p.Min > 0 {
+			merged.Min = p.Min
+		}
+		if p.Max > 0 {
+			merged.Max = p.Max
+		}
+	}
+	return merged
+}
+
+// apply returns the TTL set according to the policy
+func (p TTLPolicy) apply(ttl endpoint.TTL) endpoint.TTL {
+	if !ttl.IsConfigured() {
+		ttl = p.Default
+	}
+	// without a TTL the default of the provider is used, which enforces its own minimum
+	if !ttl.IsConfigured() {
+		return ttl
+	}
+	if p.Min > 0 && ttl < p.Min {
+		ttl = p.Min
+	}
+	if p.Max > 0 && ttl > p.Max {
+		ttl = p.Max
+	}
+	return ttl
+}
+
+func (ps *ttlPolicySource) AddEventHandler(ctx context.Context, handler func()) {
+	ps.source.AddEventHandler(ctx, handler)
+}
+
+// Failures returns the failures reported by the wrapped source, if it reports any
+func (ps *ttlPolicySource) Failures() []SourceFailure {
+	if reporter, ok := ps.source.(FailureReporter); ok {
+		return reporter.Failures()
+	}
+	return nil
+}
@@ -0,0 +1,120 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package source
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"sigs.k8s.io/external-dns/endpoint"
+	"sigs.k8s.io/external-dns/internal/testutils"
+)
+
+// Validates that ttlPolicySource is a Source
+var _ Source = &ttlPolicySource{}
+
+func TestTTLPolicy(t *testing.T) {
+	t.Run("Parse", testParseTTLPolicy)
+	t.Run("Endpoints", testTTLPolicyEndpoints)
+	t.Run("UnconfiguredWithoutDefault", testTTLPolicyUnconfiguredWithoutDefault)
+}
+
+// testParseTTLPolicy tests that valid TTL policies are parsed and invalid ones are rejected.
+func testParseTTLPolicy(t *testing.T) {
+	for _, tc := range []struct {
+		policy   string
+		expected TTLPolicy
+		err      bool
+	}{
+		{policy: "example.org:default=300", expected: TTLPolicy{Domain: "example.org", Default: 300}},
+		{policy: "Example.org.:default=5m,min=1m,max=1h", expected: TTLPolicy{Domain: "example.org", Default: 300, Min: 60, Max: 3600}},
+		{policy: ":max=1h", expected: TTLPolicy{Max: 3600}},
+		{policy: "example.org", err: true},
+		{policy: "example.org:default", err: true},
+		{policy: "example.org:default=forever", err: true},
+		{policy: "example.org:default=0", err: true},
+		{policy: "example.org:ttl=300", err: true},
+		{policy: "example.org:min=1h,max=1m", err: true},
+	} {
+		t.Run(tc.policy, func(t *testing.T) {
+			policy, err := ParseTTLPolicy(tc.policy)
+			if tc.err {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, policy)
+		})
+	}
+}
+
+// testTTLPolicyEndpoints tests that the TTLs of the endpoints are set according to the policy of their domain.
+func testTTLPolicyEndpoints(t *testing.T) {
+	policies := []TTLPolicy{
+		{Domain: "dev.example.org", Default: 60, Max: 300},
+		{Default: 3600, Min: 30, Max: 86400},
+		{Domain: "example.org", Min: 120},
+	}
+
+	for _, tc := range []struct {
+		title    string
+		dnsName  string
+		ttl      endpoint.TTL
+		expected endpoint.TTL
+	}{
+		{title: "default of all domains", dnsName: "foo.example.com", expected: 3600},
+		{title: "configured TTL within the bounds", dnsName: "foo.example.com", ttl: 600, expected: 600},
+		{title: "minimum of all domains", dnsName: "foo.example.com", ttl: 10, expected: 30},
+		{title: "maximum of all domains", dnsName: "foo.example.com", ttl: 100000, expected: 86400},
+		{title: "minimum of the domain", dnsName: "foo.example.org", ttl: 60, expected: 120},
+		{title: "apex of the domain", dnsName: "example.org.", ttl: 60, expected: 120},
+		{title: "other domain with the same suffix", dnsName: "fooexample.org", ttl: 60, expected: 60},
+		{title: "default of the subdomain", dnsName: "foo.dev.example.org", expected: 120},
+		{title: "maximum of the subdomain", dnsName: "foo.dev.example.org", ttl: 3600, expected: 300},
+	} {
+		t.Run(tc.title, func(t *testing.T) {
+			ep := endpoint.NewEndpointWithTTL(tc.dnsName, endpoint.RecordTypeA, tc.ttl, "1.2.3.4")
+			mockSource := new(testutils.MockSource)
+			mockSource.On("Endpoints").Return([]*endpoint.Endpoint{ep}, nil)
+
+			endpoints, err := NewTTLPolicySource(mockSource, policies).Endpoints(context.Background())
+			require.NoError(t, err)
+			require.Len(t, endpoints, 1)
+			assert.Equal(t, tc.expected, endpoints[0].RecordTTL)
+
+			mockSource.AssertExpectations(t)
+		})
+	}
+}
+
+// testTTLPolicyUnconfiguredWithoutDefault tests that endpoints without a TTL and default keep the default of the provider.
+func testTTLPolicyUnconfiguredWithoutDefault(t *testing.T) {
+	mockSource := new(testutils.MockSource)
+	mockSource.On("Endpoints").Return([]*endpoint.Endpoint{
+		endpoint.NewEndpoint("foo.example.org", endpoint.RecordTypeA, "1.2.3.4"),
+		endpoint.NewEndpoint("foo.example.com", endpoint.RecordTypeA, "1.2.3.4"),
+	}, nil)
+
+	endpoints, err := NewTTLPolicySource(mockSource, []TTLPolicy{{Max: 3600}, {Domain: "example.org", Min: 120}}).Endpoints(context.Background())
+	require.NoError(t, err)
+	require.Len(t, endpoints, 2)
+	assert.False(t, endpoints[0].RecordTTL.IsConfigured())
+	assert.False(t, endpoints[1].RecordTTL.IsConfigured())
+}